@@ -0,0 +1,487 @@
+package git
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/filemode"
+	"gopkg.in/src-d/go-git.v4/plumbing/format/gitattributes"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+	"gopkg.in/src-d/go-git.v4/utils/ioutil"
+)
+
+const (
+	exportIgnoreAttr = "export-ignore"
+	exportSubstAttr  = "export-subst"
+)
+
+// Archive writes to w an archive of the files of a tree, in the same way as
+// `git archive`. The files with the export-ignore attribute are not included
+// and the placeholders of the files with the export-subst attribute are
+// expanded when a commit is archived.
+func (r *Repository) Archive(w io.Writer, o *ArchiveOptions) (err error) {
+	if err := o.Validate(r); err != nil {
+		return err
+	}
+
+	commit, tree, err := r.archiveTree(o.hash)
+	if err != nil {
+		return err
+	}
+
+	when := time.Now()
+	if commit != nil {
+		when = commit.Committer.When
+	}
+
+	var aw archiveWriter
+	switch o.Format {
+	case ZipArchive:
+		aw = newZipArchiveWriter(w, when)
+	case TarGzipArchive:
+		gw := gzip.NewWriter(w)
+		defer ioutil.CheckClose(gw, &err)
+		aw = newTarArchiveWriter(gw, when)
+	default:
+		aw = newTarArchiveWriter(w, when)
+	}
+
+	a := &archiver{
+		r:       r,
+		w:       aw,
+		o:       o,
+		commit:  commit,
+		tree:    tree,
+		written: make(map[string]bool),
+	}
+
+	if err := a.archive(); err != nil {
+		return err
+	}
+
+	return aw.Close()
+}
+
+// archiveTree resolves h to a tree, returning also the commit if h is a commit
+// or a tag pointing to a commit.
+func (r *Repository) archiveTree(h plumbing.Hash) (*object.Commit, *object.Tree, error) {
+	obj, err := r.Object(plumbing.AnyObject, h)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for {
+		switch o := obj.(type) {
+		case *object.Tag:
+			if obj, err = o.Object(); err != nil {
+				return nil, nil, err
+			}
+		case *object.Commit:
+			t, err := o.Tree()
+			return o, t, err
+		case *object.Tree:
+			return nil, o, nil
+		default:
+			return nil, nil, object.ErrUnsupportedObject
+		}
+	}
+}
+
+type archiver struct {
+	r      *Repository
+	w      archiveWriter
+	o      *ArchiveOptions
+	commit *object.Commit
+	tree   *object.Tree
+	attrs  gitattributes.Matcher
+
+	// written contains the directories already written to the archive.
+	written map[string]bool
+}
+
+func (a *archiver) archive() error {
+	if a.commit != nil {
+		if err := a.w.SetCommit(a.commit.Hash); err != nil {
+			return err
+		}
+	}
+
+	attrs, err := a.r.treeAttributesMatcher(a.tree)
+	if err != nil {
+		return err
+	}
+
+	a.attrs = attrs
+
+	walker := object.NewTreeWalker(a.tree, true, nil)
+	defer walker.Close()
+
+	var ignored []string
+	for {
+		name, entry, err := walker.Next()
+		if err == io.EOF {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if isArchiveIgnored(ignored, name) {
+			continue
+		}
+
+		isDir := entry.Mode == filemode.Dir
+		if a.isSet(exportIgnoreAttr, name) {
+			if isDir {
+				ignored = append(ignored, name+"/")
+			}
+
+			continue
+		}
+
+		if isDir {
+			continue
+		}
+
+		if !a.matchPathSpecs(name) {
+			continue
+		}
+
+		if err := a.archiveEntry(name, entry); err != nil {
+			return err
+		}
+	}
+}
+
+func (a *archiver) isSet(attr, name string) bool {
+	attrs, _ := a.attrs.Match(strings.Split(name, "/"), []string{attr})
+	return attrs[attr] != nil && attrs[attr].IsSet()
+}
+
+func isArchiveIgnored(ignored []string, name string) bool {
+	for _, prefix := range ignored {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (a *archiver) matchPathSpecs(name string) bool {
+	if len(a.o.PathSpecs) == 0 {
+		return true
+	}
+
+	for _, spec := range a.o.PathSpecs {
+		spec = path.Clean(spec)
+		if spec == "." || name == spec || strings.HasPrefix(name, spec+"/") {
+			return true
+		}
+
+		// as in git, the wildcards of a pathspec also match the slashes
+		if match, err := path.Match(
+			strings.Replace(spec, "/", "\x00", -1),
+			strings.Replace(name, "/", "\x00", -1),
+		); err == nil && match {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (a *archiver) archiveEntry(name string, entry object.TreeEntry) error {
+	fullname := a.o.Prefix + name
+	if err := a.writeParents(fullname); err != nil {
+		return err
+	}
+
+	if entry.Mode == filemode.Submodule {
+		a.written[fullname] = true
+		return a.w.WriteDir(fullname)
+	}
+
+	blob, err := a.r.BlobObject(entry.Hash)
+	if err != nil {
+		return err
+	}
+
+	r, err := blob.Reader()
+	if err != nil {
+		return err
+	}
+
+	defer r.Close()
+
+	size := blob.Size
+	var content io.Reader = r
+	if a.commit != nil && entry.Mode != filemode.Symlink &&
+		a.isSet(exportSubstAttr, name) {
+		buf := bytes.NewBuffer(nil)
+		if _, err := io.Copy(buf, r); err != nil {
+			return err
+		}
+
+		expanded := expandFormatPlaceholders(buf.Bytes(), a.commit)
+		size, content = int64(len(expanded)), bytes.NewReader(expanded)
+	}
+
+	return a.w.WriteFile(fullname, entry.Mode, size, content)
+}
+
+// writeParents writes the directories containing name, that were not written
+// yet, a directory is only written if any of its files is archived.
+func (a *archiver) writeParents(name string) error {
+	dir := path.Dir(name)
+	if dir == "." || dir == "/" || a.written[dir] {
+		return nil
+	}
+
+	if err := a.writeParents(dir); err != nil {
+		return err
+	}
+
+	a.written[dir] = true
+	return a.w.WriteDir(dir)
+}
+
+var formatPlaceholder = regexp.MustCompile(`\$Format:([^$\n]*)\$`)
+
+// expandFormatPlaceholders replaces the `$Format:<format>$` placeholders of
+// content with the result of formatting the commit c.
+func expandFormatPlaceholders(content []byte, c *object.Commit) []byte {
+	return formatPlaceholder.ReplaceAllFunc(content, func(match []byte) []byte {
+		format := formatPlaceholder.FindSubmatch(match)[1]
+		return []byte(formatCommit(string(format), c))
+	})
+}
+
+// formatCommit formats the commit c, using a subset of the placeholders
+// supported by `git log --pretty=format:`.
+func formatCommit(format string, c *object.Commit) string {
+	var buf bytes.Buffer
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' || i+1 == len(format) {
+			buf.WriteByte(format[i])
+			continue
+		}
+
+		i++
+		switch format[i] {
+		case 'H':
+			buf.WriteString(c.Hash.String())
+		case 'h':
+			buf.WriteString(c.Hash.String()[:7])
+		case 'T':
+			buf.WriteString(c.TreeHash.String())
+		case 't':
+			buf.WriteString(c.TreeHash.String()[:7])
+		case 'P', 'p':
+			for j, p := range c.ParentHashes {
+				if j != 0 {
+					buf.WriteByte(' ')
+				}
+
+				if format[i] == 'p' {
+					buf.WriteString(p.String()[:7])
+				} else {
+					buf.WriteString(p.String())
+				}
+			}
+		case 's':
+			buf.WriteString(strings.SplitN(c.Message, "\n", 2)[0])
+		case 'n':
+			buf.WriteByte('\n')
+		case '%':
+			buf.WriteByte('%')
+		case 'a', 'c':
+			sig := &c.Author
+			if format[i] == 'c' {
+				sig = &c.Committer
+			}
+
+			if i+1 == len(format) || !formatSignature(&buf, format[i+1], sig) {
+				buf.WriteByte('%')
+				buf.WriteByte(format[i])
+				continue
+			}
+
+			i++
+		default:
+			buf.WriteByte('%')
+			buf.WriteByte(format[i])
+		}
+	}
+
+	return buf.String()
+}
+
+func formatSignature(buf *bytes.Buffer, field byte, sig *object.Signature) bool {
+	switch field {
+	case 'n':
+		buf.WriteString(sig.Name)
+	case 'e':
+		buf.WriteString(sig.Email)
+	case 'd':
+		buf.WriteString(sig.When.Format(object.DateFormat))
+	case 'D':
+		buf.WriteString(sig.When.Format(time.RFC1123Z))
+	case 'I':
+		buf.WriteString(sig.When.Format(time.RFC3339))
+	case 't':
+		buf.WriteString(strconv.FormatInt(sig.When.Unix(), 10))
+	default:
+		return false
+	}
+
+	return true
+}
+
+// archiveWriter writes the entries of an archive in a specific format.
+type archiveWriter interface {
+	// SetCommit records the hash of the archived commit in the archive.
+	SetCommit(h plumbing.Hash) error
+	// WriteDir writes a directory entry.
+	WriteDir(name string) error
+	// WriteFile writes a file entry, with the given mode, size and content.
+	WriteFile(name string, mode filemode.FileMode, size int64, r io.Reader) error
+	// Close writes the trailer of the archive.
+	Close() error
+}
+
+type tarArchiveWriter struct {
+	w    *tar.Writer
+	when time.Time
+}
+
+func newTarArchiveWriter(w io.Writer, when time.Time) *tarArchiveWriter {
+	return &tarArchiveWriter{w: tar.NewWriter(w), when: when}
+}
+
+func (w *tarArchiveWriter) SetCommit(h plumbing.Hash) error {
+	return w.w.WriteHeader(&tar.Header{
+		Typeflag:   tar.TypeXGlobalHeader,
+		Name:       "pax_global_header",
+		PAXRecords: map[string]string{"comment": h.String()},
+	})
+}
+
+func (w *tarArchiveWriter) WriteDir(name string) error {
+	return w.w.WriteHeader(w.header(tar.TypeDir, name+"/", 0775))
+}
+
+func (w *tarArchiveWriter) WriteFile(name string, mode filemode.FileMode, size int64, r io.Reader) error {
+	if mode == filemode.Symlink {
+		target, err := readAllString(r)
+		if err != nil {
+			return err
+		}
+
+		h := w.header(tar.TypeSymlink, name, 0777)
+		h.Linkname = target
+		return w.w.WriteHeader(h)
+	}
+
+	perm := int64(0664)
+	if mode == filemode.Executable {
+		perm = 0775
+	}
+
+	h := w.header(tar.TypeReg, name, perm)
+	h.Size = size
+	if err := w.w.WriteHeader(h); err != nil {
+		return err
+	}
+
+	_, err := io.Copy(w.w, r)
+	return err
+}
+
+func (w *tarArchiveWriter) header(typ byte, name string, perm int64) *tar.Header {
+	return &tar.Header{
+		Typeflag: typ,
+		Name:     name,
+		Mode:     perm,
+		ModTime:  w.when,
+		Uname:    "root",
+		Gname:    "root",
+	}
+}
+
+func (w *tarArchiveWriter) Close() error {
+	return w.w.Close()
+}
+
+type zipArchiveWriter struct {
+	w    *zip.Writer
+	when time.Time
+}
+
+func newZipArchiveWriter(w io.Writer, when time.Time) *zipArchiveWriter {
+	return &zipArchiveWriter{w: zip.NewWriter(w), when: when}
+}
+
+func (w *zipArchiveWriter) SetCommit(h plumbing.Hash) error {
+	return w.w.SetComment(h.String())
+}
+
+func (w *zipArchiveWriter) WriteDir(name string) error {
+	_, err := w.create(name+"/", os.ModeDir|0775, zip.Store)
+	return err
+}
+
+func (w *zipArchiveWriter) WriteFile(name string, mode filemode.FileMode, size int64, r io.Reader) error {
+	m, err := mode.ToOSFileMode()
+	if err != nil {
+		return err
+	}
+
+	method := zip.Deflate
+	if mode == filemode.Symlink {
+		method = zip.Store
+	}
+
+	fw, err := w.create(name, m, method)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(fw, r)
+	return err
+}
+
+func (w *zipArchiveWriter) create(name string, mode os.FileMode, method uint16) (io.Writer, error) {
+	h := &zip.FileHeader{
+		Name:     name,
+		Method:   method,
+		Modified: w.when,
+	}
+
+	h.SetMode(mode)
+	return w.w.CreateHeader(h)
+}
+
+func (w *zipArchiveWriter) Close() error {
+	return w.w.Close()
+}
+
+func readAllString(r io.Reader) (string, error) {
+	buf := bytes.NewBuffer(nil)
+	if _, err := io.Copy(buf, r); err != nil {
+		return "", err
+	}
+
+	return buf.String(), nil
+}
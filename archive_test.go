@@ -0,0 +1,194 @@
+package git
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/storage/memory"
+
+	. "gopkg.in/check.v1"
+	"gopkg.in/src-d/go-billy.v4/memfs"
+	"gopkg.in/src-d/go-billy.v4/util"
+)
+
+type ArchiveSuite struct {
+	BaseSuite
+}
+
+var _ = Suite(&ArchiveSuite{})
+
+type archivedFile struct {
+	mode    os.FileMode
+	content string
+}
+
+func readTar(c *C, r io.Reader) (comment string, files map[string]archivedFile) {
+	files = make(map[string]archivedFile)
+	tr := tar.NewReader(r)
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			return
+		}
+
+		c.Assert(err, IsNil)
+		if h.Typeflag == tar.TypeXGlobalHeader {
+			comment = h.PAXRecords["comment"]
+			continue
+		}
+
+		content, err := ioutil.ReadAll(tr)
+		c.Assert(err, IsNil)
+
+		if h.Typeflag == tar.TypeSymlink {
+			content = []byte(h.Linkname)
+		}
+
+		files[h.Name] = archivedFile{h.FileInfo().Mode(), string(content)}
+	}
+}
+
+func archivedNames(files map[string]archivedFile) []string {
+	var names []string
+	for name := range files {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+func (s *ArchiveSuite) TestArchiveTar(c *C) {
+	buf := bytes.NewBuffer(nil)
+	err := s.Repository.Archive(buf, &ArchiveOptions{Prefix: "basic/"})
+	c.Assert(err, IsNil)
+
+	comment, files := readTar(c, buf)
+	c.Assert(comment, Equals, "6ecf0ef2c2dffb796033e5a02219af86ec6584e5")
+	c.Assert(archivedNames(files), HasLen, 14)
+	c.Assert(files["basic/"].mode, Equals, os.ModeDir|0775)
+	c.Assert(files["basic/go/"].mode, Equals, os.ModeDir|0775)
+	c.Assert(files["basic/go/example.go"].mode, Equals, os.FileMode(0664))
+	c.Assert(files["basic/.gitignore"].content, Equals, "*.class\n\n# Mobile Tools for Java (J2ME)\n.mtj.tmp/\n\n# Package Files #\n*.jar\n*.war\n*.ear\n\n# virtual machine crash logs, see http://www.java.com/en/download/help/error_hotspot.xml\nhs_err_pid*\n")
+}
+
+func (s *ArchiveSuite) TestArchiveTarGzip(c *C) {
+	buf := bytes.NewBuffer(nil)
+	err := s.Repository.Archive(buf, &ArchiveOptions{
+		Format:    TarGzipArchive,
+		PathSpecs: []string{"go", "*.json"},
+	})
+	c.Assert(err, IsNil)
+
+	r, err := gzip.NewReader(buf)
+	c.Assert(err, IsNil)
+
+	_, files := readTar(c, r)
+	c.Assert(archivedNames(files), DeepEquals, []string{
+		"go/", "go/example.go", "json/", "json/long.json", "json/short.json",
+	})
+}
+
+func (s *ArchiveSuite) TestArchiveZip(c *C) {
+	buf := bytes.NewBuffer(nil)
+	err := s.Repository.Archive(buf, &ArchiveOptions{
+		Format:   ZipArchive,
+		Revision: "HEAD~1",
+	})
+	c.Assert(err, IsNil)
+
+	r, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	c.Assert(err, IsNil)
+	c.Assert(r.Comment, Equals, "918c48b83bd081e863dbe1b80f8998f058cd8294")
+
+	names := make(map[string]os.FileMode)
+	for _, f := range r.File {
+		names[f.Name] = f.Mode()
+	}
+
+	c.Assert(names["go/"], Equals, os.ModeDir|0775)
+	c.Assert(names["go/example.go"], Equals, os.FileMode(0644))
+	c.Assert(names, Not(HasLen), 0)
+}
+
+func (s *ArchiveSuite) TestArchiveTreeHash(c *C) {
+	commit, err := s.Repository.CommitObject(plumbing.NewHash("6ecf0ef2c2dffb796033e5a02219af86ec6584e5"))
+	c.Assert(err, IsNil)
+
+	buf := bytes.NewBuffer(nil)
+	err = s.Repository.Archive(buf, &ArchiveOptions{Tree: commit.TreeHash})
+	c.Assert(err, IsNil)
+
+	comment, files := readTar(c, buf)
+	c.Assert(comment, Equals, "")
+	c.Assert(archivedNames(files), HasLen, 13)
+}
+
+func (s *ArchiveSuite) TestArchiveAttributes(c *C) {
+	fs := memfs.New()
+	r, err := Init(memory.NewStorage(), fs)
+	c.Assert(err, IsNil)
+
+	w, err := r.Worktree()
+	c.Assert(err, IsNil)
+
+	c.Assert(util.WriteFile(fs, ".gitattributes", []byte("VERSION export-subst\nsecret export-ignore\n*.log export-ignore\n"), 0644), IsNil)
+	c.Assert(util.WriteFile(fs, "VERSION", []byte("$Format:%H %an$\n"), 0644), IsNil)
+	c.Assert(util.WriteFile(fs, "run.sh", []byte("#!/bin/sh\n"), 0755), IsNil)
+	c.Assert(util.WriteFile(fs, "secret/key", []byte("foo"), 0644), IsNil)
+	c.Assert(util.WriteFile(fs, "docs/.gitattributes", []byte("*.log -export-ignore\n"), 0644), IsNil)
+	c.Assert(util.WriteFile(fs, "docs/build.log", []byte("foo"), 0644), IsNil)
+	c.Assert(util.WriteFile(fs, "build.log", []byte("foo"), 0644), IsNil)
+	c.Assert(fs.Symlink("run.sh", "link"), IsNil)
+
+	_, err = w.Add(".")
+	c.Assert(err, IsNil)
+
+	hash, err := w.Commit("foo\n", &CommitOptions{Author: defaultSignature()})
+	c.Assert(err, IsNil)
+
+	buf := bytes.NewBuffer(nil)
+	err = r.Archive(buf, &ArchiveOptions{})
+	c.Assert(err, IsNil)
+
+	_, files := readTar(c, buf)
+	c.Assert(archivedNames(files), DeepEquals, []string{
+		".gitattributes", "VERSION", "docs/", "docs/.gitattributes",
+		"docs/build.log", "link", "run.sh",
+	})
+	c.Assert(files["VERSION"].content, Equals, hash.String()+" foo\n")
+	c.Assert(files["run.sh"].mode, Equals, os.FileMode(0775))
+	c.Assert(files["link"].mode, Equals, os.ModeSymlink|0777)
+	c.Assert(files["link"].content, Equals, "run.sh")
+	c.Assert(files["docs/build.log"].content, Equals, "foo")
+	_, ok := files["secret/key"]
+	c.Assert(ok, Equals, false)
+	_, ok = files["build.log"]
+	c.Assert(ok, Equals, false)
+}
+
+func (s *ArchiveSuite) TestArchiveOptionsValidate(c *C) {
+	err := s.Repository.Archive(ioutil.Discard, &ArchiveOptions{
+		Tree:     plumbing.NewHash("6ecf0ef2c2dffb796033e5a02219af86ec6584e5"),
+		Revision: "HEAD",
+	})
+	c.Assert(err, Equals, ErrTreeOrRevision)
+
+	err = s.Repository.Archive(ioutil.Discard, &ArchiveOptions{Format: "rar"})
+	c.Assert(err, Equals, ErrUnsupportedArchiveFormat)
+}
+
+func (s *ArchiveSuite) TestArchiveOptionsReused(c *C) {
+	o := &ArchiveOptions{Revision: "HEAD"}
+	c.Assert(s.Repository.Archive(ioutil.Discard, o), IsNil)
+	c.Assert(s.Repository.Archive(ioutil.Discard, o), IsNil)
+	c.Assert(o.Tree.IsZero(), Equals, true)
+	c.Assert(o.Revision, Equals, plumbing.Revision("HEAD"))
+}
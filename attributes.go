@@ -0,0 +1,222 @@
+package git
+
+import (
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"gopkg.in/src-d/go-git.v4/plumbing/filemode"
+	"gopkg.in/src-d/go-git.v4/plumbing/format/gitattributes"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+	"gopkg.in/src-d/go-git.v4/utils/ioutil"
+
+	"gopkg.in/src-d/go-billy.v4"
+)
+
+const (
+	gitattributesFile = ".gitattributes"
+	infoAttributes    = "info/attributes"
+)
+
+// Attributes returns the attributes assigned to the given path by the
+// .gitattributes files of the worktree, the $GIT_DIR/info/attributes file and
+// the Worktree.ExternalAttributes. If names are given, only the attributes
+// with those names are returned.
+func (w *Worktree) Attributes(path string, names ...string) (map[string]gitattributes.Attribute, error) {
+	m, err := w.attributesMatcher()
+	if err != nil {
+		return nil, err
+	}
+
+	return m.Match(splitPath(path), names)
+}
+
+func (w *Worktree) attributesMatcher() (*worktreeAttributes, error) {
+	info, err := w.r.infoAttributes()
+	if err != nil {
+		return nil, err
+	}
+
+	return &worktreeAttributes{
+		fs:       w.Filesystem,
+		external: w.ExternalAttributes,
+		info:     info,
+		files:    make(map[string][]gitattributes.MatchAttribute),
+		matchers: make(map[string]gitattributes.Matcher),
+	}, nil
+}
+
+// worktreeAttributes matches the attributes of the files of a worktree. The
+// .gitattributes file of a directory is read the first time a path under it
+// is matched, so only the directories of the paths matched are read.
+type worktreeAttributes struct {
+	fs       billy.Filesystem
+	external []gitattributes.MatchAttribute
+	info     []gitattributes.MatchAttribute
+
+	m        sync.Mutex
+	files    map[string][]gitattributes.MatchAttribute
+	matchers map[string]gitattributes.Matcher
+}
+
+// Match returns the attributes assigned to the given path, as
+// gitattributes.Matcher does, only the ones with the given names if any.
+func (a *worktreeAttributes) Match(path []string, names []string) (map[string]gitattributes.Attribute, error) {
+	a.m.Lock()
+	defer a.m.Unlock()
+
+	m, err := a.matcher(path[:len(path)-1])
+	if err != nil {
+		return nil, err
+	}
+
+	attrs, _ := m.Match(path, names)
+	return attrs, nil
+}
+
+// matcher returns the matcher of the paths in the directory dir, with the
+// lines of the .gitattributes files of dir and its parents.
+func (a *worktreeAttributes) matcher(dir []string) (gitattributes.Matcher, error) {
+	key := strings.Join(dir, "/")
+	if m, ok := a.matchers[key]; ok {
+		return m, nil
+	}
+
+	stack := append([]gitattributes.MatchAttribute{}, a.external...)
+	for i := 0; i <= len(dir); i++ {
+		attrs, err := a.file(dir[:i])
+		if err != nil {
+			return nil, err
+		}
+
+		stack = append(stack, attrs...)
+	}
+
+	m := gitattributes.NewMatcher(append(stack, a.info...))
+	a.matchers[key] = m
+	return m, nil
+}
+
+// file returns the lines of the .gitattributes file of the directory dir.
+func (a *worktreeAttributes) file(dir []string) ([]gitattributes.MatchAttribute, error) {
+	key := strings.Join(dir, "/")
+	if attrs, ok := a.files[key]; ok {
+		return attrs, nil
+	}
+
+	// the domain of the lines must not share the array of the matched path
+	domain := append([]string(nil), dir...)
+	attrs, err := gitattributes.ReadAttributesFile(a.fs, domain, gitattributesFile, len(domain) == 0)
+	if err != nil {
+		return nil, err
+	}
+
+	a.files[key] = attrs
+	return attrs, nil
+}
+
+// TreeAttributes returns the attributes assigned to the given path by the
+// .gitattributes files of the tree t and the $GIT_DIR/info/attributes file,
+// as used by `git archive`. If names are given, only the attributes with those
+// names are returned.
+func (r *Repository) TreeAttributes(t *object.Tree, path string, names ...string) (map[string]gitattributes.Attribute, error) {
+	m, err := r.treeAttributesMatcher(t)
+	if err != nil {
+		return nil, err
+	}
+
+	attrs, _ := m.Match(splitPath(path), names)
+	return attrs, nil
+}
+
+func (r *Repository) treeAttributesMatcher(t *object.Tree) (gitattributes.Matcher, error) {
+	attrs, err := readTreeAttributes(t)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := r.infoAttributes()
+	if err != nil {
+		return nil, err
+	}
+
+	return gitattributes.NewMatcher(append(attrs, info...)), nil
+}
+
+// readTreeAttributes reads all the .gitattributes files of the tree, in the
+// ascending order of priority.
+func readTreeAttributes(t *object.Tree) ([]gitattributes.MatchAttribute, error) {
+	walker := object.NewTreeWalker(t, true, nil)
+	defer walker.Close()
+
+	var attrs []gitattributes.MatchAttribute
+	for {
+		name, entry, err := walker.Next()
+		if err == io.EOF {
+			return attrs, nil
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		if entry.Name != gitattributesFile || entry.Mode == filemode.Dir {
+			continue
+		}
+
+		f, err := t.TreeEntryFile(&entry)
+		if err != nil {
+			return nil, err
+		}
+
+		var domain []string
+		if dir := path.Dir(name); dir != "." {
+			domain = strings.Split(dir, "/")
+		}
+
+		fileAttrs, err := readBlobAttributes(f, domain)
+		if err != nil {
+			return nil, err
+		}
+
+		attrs = append(attrs, fileAttrs...)
+	}
+}
+
+func readBlobAttributes(f *object.File, domain []string) (attrs []gitattributes.MatchAttribute, err error) {
+	r, err := f.Reader()
+	if err != nil {
+		return nil, err
+	}
+
+	defer ioutil.CheckClose(r, &err)
+	return gitattributes.ReadAttributes(r, domain, len(domain) == 0)
+}
+
+// infoAttributes reads the $GIT_DIR/info/attributes file, if the storage is
+// file based.
+func (r *Repository) infoAttributes() (attrs []gitattributes.MatchAttribute, err error) {
+	fs, ok := r.dotGitFilesystem()
+	if !ok {
+		return nil, nil
+	}
+
+	f, err := fs.Open(infoAttributes)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer ioutil.CheckClose(f, &err)
+	return gitattributes.ReadAttributes(f, nil, true)
+}
+
+func splitPath(p string) []string {
+	return strings.Split(strings.Trim(filepath.ToSlash(p), "/"), "/")
+}
@@ -0,0 +1,143 @@
+package git
+
+import (
+	"gopkg.in/src-d/go-git.v4/plumbing/cache"
+	"gopkg.in/src-d/go-git.v4/plumbing/format/gitattributes"
+	"gopkg.in/src-d/go-git.v4/storage/filesystem"
+
+	. "gopkg.in/check.v1"
+	"gopkg.in/src-d/go-billy.v4"
+	"gopkg.in/src-d/go-billy.v4/memfs"
+	"gopkg.in/src-d/go-billy.v4/util"
+)
+
+type AttributesSuite struct {
+	BaseSuite
+}
+
+var _ = Suite(&AttributesSuite{})
+
+func (s *AttributesSuite) newRepository(c *C) (*Repository, billy.Filesystem, billy.Filesystem) {
+	dotgit, fs := memfs.New(), memfs.New()
+	r, err := Init(filesystem.NewStorage(dotgit, cache.NewObjectLRUDefault()), fs)
+	c.Assert(err, IsNil)
+
+	c.Assert(util.WriteFile(fs, ".gitattributes", []byte("*.go text eol=lf\n*.png binary\n"), 0644), IsNil)
+	c.Assert(util.WriteFile(fs, "vendor/.gitattributes", []byte("*.go -text\n"), 0644), IsNil)
+	c.Assert(util.WriteFile(dotgit, "info/attributes", []byte("*.go eol=crlf\n"), 0644), IsNil)
+
+	return r, dotgit, fs
+}
+
+func (s *AttributesSuite) TestWorktreeAttributes(c *C) {
+	r, _, _ := s.newRepository(c)
+
+	w, err := r.Worktree()
+	c.Assert(err, IsNil)
+
+	attrs, err := w.Attributes("main.go")
+	c.Assert(err, IsNil)
+	c.Assert(attrs, HasLen, 2)
+	c.Assert(attrs["text"].IsSet(), Equals, true)
+	c.Assert(attrs["eol"].Value(), Equals, "crlf")
+
+	attrs, err = w.Attributes("vendor/lib.go", "text")
+	c.Assert(err, IsNil)
+	c.Assert(attrs, HasLen, 1)
+	c.Assert(attrs["text"].IsUnset(), Equals, true)
+
+	attrs, err = w.Attributes("img/logo.png", "diff")
+	c.Assert(err, IsNil)
+	c.Assert(attrs["diff"].IsUnset(), Equals, true)
+
+	attrs, err = w.Attributes("README")
+	c.Assert(err, IsNil)
+	c.Assert(attrs, HasLen, 0)
+}
+
+// openRecorder records the names of the files opened.
+type openRecorder struct {
+	billy.Filesystem
+	opened []string
+}
+
+func (fs *openRecorder) Open(name string) (billy.File, error) {
+	fs.opened = append(fs.opened, name)
+	return fs.Filesystem.Open(name)
+}
+
+func (s *AttributesSuite) TestWorktreeAttributesReadByDirectory(c *C) {
+	r, _, fs := s.newRepository(c)
+	c.Assert(util.WriteFile(fs, "other/.gitattributes", []byte("*.go binary\n"), 0644), IsNil)
+
+	w, err := r.Worktree()
+	c.Assert(err, IsNil)
+
+	recorder := &openRecorder{Filesystem: fs}
+	w.Filesystem = recorder
+
+	m, err := w.attributesMatcher()
+	c.Assert(err, IsNil)
+
+	attrs, err := m.Match([]string{"vendor", "lib.go"}, []string{"text"})
+	c.Assert(err, IsNil)
+	c.Assert(attrs["text"].IsUnset(), Equals, true)
+
+	attrs, err = m.Match([]string{"vendor", "foo.go"}, []string{"text"})
+	c.Assert(err, IsNil)
+	c.Assert(attrs["text"].IsUnset(), Equals, true)
+
+	c.Assert(recorder.opened, DeepEquals, []string{".gitattributes", "vendor/.gitattributes"})
+}
+
+func (s *AttributesSuite) TestWorktreeExternalAttributes(c *C) {
+	r, _, _ := s.newRepository(c)
+
+	w, err := r.Worktree()
+	c.Assert(err, IsNil)
+
+	m, err := gitattributes.ParseAttributesLine("* export-ignore text=auto", nil, true)
+	c.Assert(err, IsNil)
+	w.ExternalAttributes = []gitattributes.MatchAttribute{m}
+
+	attrs, err := w.Attributes("README")
+	c.Assert(err, IsNil)
+	c.Assert(attrs, HasLen, 2)
+	c.Assert(attrs["text"].Value(), Equals, "auto")
+
+	attrs, err = w.Attributes("main.go", "text")
+	c.Assert(err, IsNil)
+	c.Assert(attrs["text"].IsSet(), Equals, true)
+}
+
+func (s *AttributesSuite) TestTreeAttributes(c *C) {
+	r, _, fs := s.newRepository(c)
+	c.Assert(util.WriteFile(fs, "vendor/lib.go", []byte("package lib\n"), 0644), IsNil)
+
+	w, err := r.Worktree()
+	c.Assert(err, IsNil)
+
+	_, err = w.Add(".")
+	c.Assert(err, IsNil)
+
+	hash, err := w.Commit("foo\n", &CommitOptions{Author: defaultSignature()})
+	c.Assert(err, IsNil)
+
+	// changes in the worktree don't affect to the tree attributes
+	c.Assert(util.WriteFile(fs, ".gitattributes", []byte("*.go -text\n"), 0644), IsNil)
+
+	commit, err := r.CommitObject(hash)
+	c.Assert(err, IsNil)
+
+	tree, err := commit.Tree()
+	c.Assert(err, IsNil)
+
+	attrs, err := r.TreeAttributes(tree, "main.go")
+	c.Assert(err, IsNil)
+	c.Assert(attrs["text"].IsSet(), Equals, true)
+	c.Assert(attrs["eol"].Value(), Equals, "crlf")
+
+	attrs, err = r.TreeAttributes(tree, "vendor/lib.go", "text")
+	c.Assert(err, IsNil)
+	c.Assert(attrs["text"].IsUnset(), Equals, true)
+}
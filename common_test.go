@@ -1,7 +1,11 @@
 package git
 
 import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"gopkg.in/src-d/go-git.v4/plumbing"
 	"gopkg.in/src-d/go-git.v4/plumbing/cache"
@@ -17,7 +21,13 @@ import (
 	"gopkg.in/src-d/go-git-fixtures.v3"
 )
 
-func Test(t *testing.T) { TestingT(t) }
+func Test(t *testing.T) {
+	// the configs of the user and of the system are not read by the tests
+	os.Setenv("GIT_CONFIG_GLOBAL", os.DevNull)
+	os.Setenv("GIT_CONFIG_NOSYSTEM", "1")
+
+	TestingT(t)
+}
 
 type BaseSuite struct {
 	fixtures.Suite
@@ -123,6 +133,58 @@ func (s *BaseSuite) NewRepositoryFromPackfile(f *fixtures.Fixture) *Repository {
 	return r
 }
 
+// newInitWorktree returns the worktree of a new repository in memory, with
+// the given files committed, if any.
+func (s *BaseSuite) newInitWorktree(c *C, files map[string]string) (*Worktree, billy.Filesystem) {
+	fs := memfs.New()
+	r, err := Init(memory.NewStorage(), fs)
+	c.Assert(err, IsNil)
+
+	w, err := r.Worktree()
+	c.Assert(err, IsNil)
+
+	if len(files) == 0 {
+		return w, fs
+	}
+
+	for name, content := range files {
+		c.Assert(util.WriteFile(fs, name, []byte(content), 0644), IsNil)
+		_, err = w.Add(name)
+		c.Assert(err, IsNil)
+	}
+
+	_, err = w.Commit("foo\n", &CommitOptions{Author: defaultSignature()})
+	c.Assert(err, IsNil)
+
+	return w, fs
+}
+
+// newPlainInitWorktree returns the worktree of a new repository in a
+// temporary directory, removed by the caller, with the given files added and
+// modified at mtime.
+func (s *BaseSuite) newPlainInitWorktree(c *C, files map[string]string, mtime time.Time) (*Worktree, string) {
+	dir, err := ioutil.TempDir("", "plain-init")
+	c.Assert(err, IsNil)
+
+	r, err := PlainInit(dir, false)
+	c.Assert(err, IsNil)
+
+	w, err := r.Worktree()
+	c.Assert(err, IsNil)
+
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		c.Assert(os.MkdirAll(filepath.Dir(path), 0755), IsNil)
+		c.Assert(ioutil.WriteFile(path, []byte(content), 0644), IsNil)
+		c.Assert(os.Chtimes(path, mtime, mtime), IsNil)
+
+		_, err = w.Add(name)
+		c.Assert(err, IsNil)
+	}
+
+	return w, dir
+}
+
 func (s *BaseSuite) GetBasicLocalRepositoryURL() string {
 	fixture := fixtures.Basic().One()
 	return s.GetLocalRepositoryURL(fixture)
@@ -5,6 +5,7 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
+	"io"
 	"sort"
 	"strconv"
 
@@ -43,6 +44,50 @@ type Config struct {
 		// CommentChar is the character indicating the start of a
 		// comment for commands like commit and tag
 		CommentChar string
+		// AutoCRLF defines the conversion of the line endings of the text
+		// files, "true" converts them to CRLF on checkout and to LF when
+		// added to the index, "input" only converts them to LF when added.
+		AutoCRLF string
+		// EOL is the line ending used on checkout by the text files, "lf",
+		// "crlf" or "native" (the default).
+		EOL string
+		// SparseCheckout if true, only the paths matching the patterns of
+		// the $GIT_DIR/info/sparse-checkout file are checked out.
+		SparseCheckout bool
+		// SparseCheckoutCone if true, the sparse-checkout patterns are
+		// restricted to directories (cone mode).
+		SparseCheckoutCone bool
+		// UntrackedCache controls the untracked cache of the index, "true"
+		// adds it, "false" removes it and "keep" (the default) keeps it if
+		// present.
+		UntrackedCache string
+	}
+
+	User struct {
+		// Name is the personal name of the author and the committer of the
+		// commits and the tagger of the tags.
+		Name string
+		// Email is the email of the author and the committer of the commits
+		// and the tagger of the tags.
+		Email string
+	}
+
+	Author struct {
+		// Name is the personal name of the author of the commits, it takes
+		// precedence over User.Name.
+		Name string
+		// Email is the email of the author of the commits, it takes
+		// precedence over User.Email.
+		Email string
+	}
+
+	Committer struct {
+		// Name is the personal name of the committer of the commits and the
+		// tagger of the tags, it takes precedence over User.Name.
+		Name string
+		// Email is the email of the committer of the commits and the tagger
+		// of the tags, it takes precedence over User.Email.
+		Email string
 	}
 
 	Pack struct {
@@ -61,6 +106,9 @@ type Config struct {
 	// Branches list of branches, the key is the branch name and should
 	// equal Branch.Name
 	Branches map[string]*Branch
+	// URLs list of url rewriting rules, the key is the base URL and should
+	// equal URL.Name
+	URLs map[string]*URL
 	// Raw contains the raw information of a config file. The main goal is
 	// preserve the parsed information from the original format, to avoid
 	// dropping unsupported fields.
@@ -73,6 +121,7 @@ func NewConfig() *Config {
 		Remotes:    make(map[string]*RemoteConfig),
 		Submodules: make(map[string]*Submodule),
 		Branches:   make(map[string]*Branch),
+		URLs:       make(map[string]*URL),
 		Raw:        format.New(),
 	}
 
@@ -103,6 +152,16 @@ func (c *Config) Validate() error {
 		}
 	}
 
+	for name, u := range c.URLs {
+		if u.Name != name {
+			return ErrInvalid
+		}
+
+		if err := u.Validate(); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -112,13 +171,27 @@ const (
 	branchSection    = "branch"
 	coreSection      = "core"
 	packSection      = "pack"
+	userSection      = "user"
+	authorSection    = "author"
+	committerSection = "committer"
+	urlSection       = "url"
 	fetchKey         = "fetch"
 	urlKey           = "url"
 	bareKey          = "bare"
 	worktreeKey      = "worktree"
 	commentCharKey   = "commentChar"
+	autoCRLFKey      = "autocrlf"
+	eolKey           = "eol"
 	windowKey        = "window"
 	mergeKey         = "merge"
+	nameKey          = "name"
+	emailKey         = "email"
+	insteadOfKey     = "insteadOf"
+	pushInsteadOfKey = "pushInsteadOf"
+
+	sparseCheckoutKey     = "sparseCheckout"
+	sparseCheckoutConeKey = "sparseCheckoutCone"
+	untrackedCacheKey     = "untrackedCache"
 
 	// DefaultPackWindow holds the number of previous objects used to
 	// generate deltas. The value 10 is the same used by git command.
@@ -135,7 +208,27 @@ func (c *Config) Unmarshal(b []byte) error {
 		return err
 	}
 
+	return c.unmarshal()
+}
+
+// ReadConfig reads a git-config file, expanding its include directives as
+// described by the given options.
+func ReadConfig(r io.Reader, o *format.IncludeOptions) (*Config, error) {
+	c := NewConfig()
+	if err := format.NewDecoder(r).DecodeWithIncludes(c.Raw, o); err != nil {
+		return nil, err
+	}
+
+	if err := c.unmarshal(); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+func (c *Config) unmarshal() error {
 	c.unmarshalCore()
+	c.unmarshalUser()
 	if err := c.unmarshalPack(); err != nil {
 		return err
 	}
@@ -145,6 +238,10 @@ func (c *Config) Unmarshal(b []byte) error {
 		return err
 	}
 
+	if err := c.unmarshalURLs(); err != nil {
+		return err
+	}
+
 	return c.unmarshalRemotes()
 }
 
@@ -156,6 +253,25 @@ func (c *Config) unmarshalCore() {
 
 	c.Core.Worktree = s.Options.Get(worktreeKey)
 	c.Core.CommentChar = s.Options.Get(commentCharKey)
+	c.Core.AutoCRLF = s.Options.Get(autoCRLFKey)
+	c.Core.EOL = s.Options.Get(eolKey)
+	c.Core.SparseCheckout = s.Options.Get(sparseCheckoutKey) == "true"
+	c.Core.SparseCheckoutCone = s.Options.Get(sparseCheckoutConeKey) == "true"
+	c.Core.UntrackedCache = s.Options.Get(untrackedCacheKey)
+}
+
+func (c *Config) unmarshalUser() {
+	s := c.Raw.Section(userSection)
+	c.User.Name = s.Options.Get(nameKey)
+	c.User.Email = s.Options.Get(emailKey)
+
+	s = c.Raw.Section(authorSection)
+	c.Author.Name = s.Options.Get(nameKey)
+	c.Author.Email = s.Options.Get(emailKey)
+
+	s = c.Raw.Section(committerSection)
+	c.Committer.Name = s.Options.Get(nameKey)
+	c.Committer.Email = s.Options.Get(emailKey)
 }
 
 func (c *Config) unmarshalPack() error {
@@ -215,14 +331,34 @@ func (c *Config) unmarshalBranches() error {
 	return nil
 }
 
+func (c *Config) unmarshalURLs() error {
+	s := c.Raw.Section(urlSection)
+	for _, sub := range s.Subsections {
+		u := &URL{}
+		if err := u.unmarshal(sub); err != nil {
+			return err
+		}
+
+		c.URLs[u.Name] = u
+	}
+
+	return nil
+}
+
 // Marshal returns Config encoded as a git-config file.
 func (c *Config) Marshal() ([]byte, error) {
-	c.marshalCore()
-	c.marshalPack()
-	c.marshalRemotes()
-	c.marshalSubmodules()
-	c.marshalBranches()
+	c.marshal()
+	return c.encode()
+}
+
+// MarshalScope returns Config encoded as a git-config file of the given
+// scope, core.bare being only written in the config of the repository.
+func (c *Config) MarshalScope(scope Scope) ([]byte, error) {
+	c.marshalScope(scope)
+	return c.encode()
+}
 
+func (c *Config) encode() ([]byte, error) {
 	buf := bytes.NewBuffer(nil)
 	if err := format.NewEncoder(buf).Encode(c.Raw); err != nil {
 		return nil, err
@@ -231,6 +367,26 @@ func (c *Config) Marshal() ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
+// marshal sets the fields of the config in Raw.
+func (c *Config) marshal() {
+	c.marshalCore()
+	c.marshalUser()
+	c.marshalPack()
+	c.marshalRemotes()
+	c.marshalSubmodules()
+	c.marshalBranches()
+	c.marshalURLs()
+}
+
+// marshalScope sets the fields of the config in Raw, as marshal, for a config
+// of the given scope.
+func (c *Config) marshalScope(scope Scope) {
+	c.marshal()
+	if scope != LocalScope && !c.Core.IsBare {
+		c.Raw.Section(coreSection).RemoveOption(bareKey)
+	}
+}
+
 func (c *Config) marshalCore() {
 	s := c.Raw.Section(coreSection)
 	s.SetOption(bareKey, fmt.Sprintf("%t", c.Core.IsBare))
@@ -238,6 +394,50 @@ func (c *Config) marshalCore() {
 	if c.Core.Worktree != "" {
 		s.SetOption(worktreeKey, c.Core.Worktree)
 	}
+
+	if c.Core.AutoCRLF != "" {
+		s.SetOption(autoCRLFKey, c.Core.AutoCRLF)
+	}
+
+	if c.Core.EOL != "" {
+		s.SetOption(eolKey, c.Core.EOL)
+	}
+
+	if c.Core.SparseCheckout {
+		s.SetOption(sparseCheckoutKey, "true")
+	} else {
+		s.RemoveOption(sparseCheckoutKey)
+	}
+
+	if c.Core.SparseCheckoutCone {
+		s.SetOption(sparseCheckoutConeKey, "true")
+	} else {
+		s.RemoveOption(sparseCheckoutConeKey)
+	}
+
+	if c.Core.UntrackedCache != "" {
+		s.SetOption(untrackedCacheKey, c.Core.UntrackedCache)
+	}
+}
+
+func (c *Config) marshalUser() {
+	marshalIdentity(c.Raw.Section(userSection), c.User.Name, c.User.Email)
+	marshalIdentity(c.Raw.Section(authorSection), c.Author.Name, c.Author.Email)
+	marshalIdentity(c.Raw.Section(committerSection), c.Committer.Name, c.Committer.Email)
+}
+
+func marshalIdentity(s *format.Section, name, email string) {
+	if name != "" {
+		s.SetOption(nameKey, name)
+	} else {
+		s.RemoveOption(nameKey)
+	}
+
+	if email != "" {
+		s.SetOption(emailKey, email)
+	} else {
+		s.RemoveOption(emailKey)
+	}
 }
 
 func (c *Config) marshalPack() {
@@ -316,6 +516,33 @@ func (c *Config) marshalBranches() {
 	s.Subsections = newSubsections
 }
 
+func (c *Config) marshalURLs() {
+	s := c.Raw.Section(urlSection)
+	newSubsections := make(format.Subsections, 0, len(c.URLs))
+	added := make(map[string]bool)
+	for _, subsection := range s.Subsections {
+		if u, ok := c.URLs[subsection.Name]; ok {
+			newSubsections = append(newSubsections, u.marshal())
+			added[subsection.Name] = true
+		}
+	}
+
+	names := make([]string, 0, len(c.URLs))
+	for name := range c.URLs {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	for _, name := range names {
+		if !added[name] {
+			newSubsections = append(newSubsections, c.URLs[name].marshal())
+		}
+	}
+
+	s.Subsections = newSubsections
+}
+
 // RemoteConfig contains the configuration for a given remote repository.
 type RemoteConfig struct {
 	// Name of the remote
@@ -14,6 +14,10 @@ func (s *ConfigSuite) TestUnmarshall(c *C) {
         bare = true
 		worktree = foo
 		commentchar = bar
+		autocrlf = true
+		eol = crlf
+		sparsecheckout = true
+		sparsecheckoutcone = true
 [pack]
 		window = 20
 [remote "origin"]
@@ -42,6 +46,10 @@ func (s *ConfigSuite) TestUnmarshall(c *C) {
 	c.Assert(cfg.Core.IsBare, Equals, true)
 	c.Assert(cfg.Core.Worktree, Equals, "foo")
 	c.Assert(cfg.Core.CommentChar, Equals, "bar")
+	c.Assert(cfg.Core.AutoCRLF, Equals, "true")
+	c.Assert(cfg.Core.EOL, Equals, "crlf")
+	c.Assert(cfg.Core.SparseCheckout, Equals, true)
+	c.Assert(cfg.Core.SparseCheckoutCone, Equals, true)
 	c.Assert(cfg.Pack.Window, Equals, uint(20))
 	c.Assert(cfg.Remotes, HasLen, 3)
 	c.Assert(cfg.Remotes["origin"].Name, Equals, "origin")
@@ -60,10 +68,39 @@ func (s *ConfigSuite) TestUnmarshall(c *C) {
 	c.Assert(cfg.Branches["master"].Merge, Equals, plumbing.ReferenceName("refs/heads/master"))
 }
 
+func (s *ConfigSuite) TestUnmarshallUser(c *C) {
+	input := []byte(`[user]
+	name = John Doe
+	email = john@example.com
+[author]
+	name = Jane Doe
+[committer]
+	email = jane@example.com
+`)
+
+	cfg := NewConfig()
+	err := cfg.Unmarshal(input)
+	c.Assert(err, IsNil)
+
+	c.Assert(cfg.User.Name, Equals, "John Doe")
+	c.Assert(cfg.User.Email, Equals, "john@example.com")
+	c.Assert(cfg.Author.Name, Equals, "Jane Doe")
+	c.Assert(cfg.Author.Email, Equals, "")
+	c.Assert(cfg.Committer.Name, Equals, "")
+	c.Assert(cfg.Committer.Email, Equals, "jane@example.com")
+}
+
 func (s *ConfigSuite) TestMarshall(c *C) {
 	output := []byte(`[core]
 	bare = true
 	worktree = bar
+	autocrlf = input
+	sparseCheckout = true
+[user]
+	name = John Doe
+	email = john@example.com
+[committer]
+	name = Jane Doe
 [pack]
 	window = 20
 [remote "alt"]
@@ -85,6 +122,11 @@ func (s *ConfigSuite) TestMarshall(c *C) {
 	cfg := NewConfig()
 	cfg.Core.IsBare = true
 	cfg.Core.Worktree = "bar"
+	cfg.Core.AutoCRLF = "input"
+	cfg.Core.SparseCheckout = true
+	cfg.User.Name = "John Doe"
+	cfg.User.Email = "john@example.com"
+	cfg.Committer.Name = "Jane Doe"
 	cfg.Pack.Window = 20
 	cfg.Remotes["origin"] = &RemoteConfig{
 		Name: "origin",
@@ -124,6 +166,10 @@ func (s *ConfigSuite) TestUnmarshallMarshall(c *C) {
 	bare = true
 	worktree = foo
 	custom = ignored
+[user]
+	name = John Doe
+	email = john@example.com
+	signingKey = ignored
 [pack]
 	window = 20
 [remote "origin"]
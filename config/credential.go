@@ -0,0 +1,46 @@
+package config
+
+const (
+	credentialSection = "credential"
+	helperKey         = "helper"
+	usernameKey       = "username"
+	useHTTPPathKey    = "useHttpPath"
+)
+
+// Credential is the credential.* config applying to a URL, set by
+// credential.* and credential.<url>.*.
+type Credential struct {
+	// Helpers are the command lines of the credential helpers, an empty
+	// credential.helper resetting the list.
+	Helpers []string
+	// Username is the default username, if none is given by the URL.
+	Username string
+	// UseHTTPPath is true if the path of the HTTP URLs is given to the
+	// helpers, only the host being given otherwise.
+	UseHTTPPath bool
+}
+
+// CredentialOptions returns the credential config applying to the given URL.
+func (c *Config) CredentialOptions(url string) *Credential {
+	opts := c.URLOptions(credentialSection, url)
+
+	cred := &Credential{
+		Username:    opts.Get(usernameKey),
+		UseHTTPPath: isTrue(opts.Get(useHTTPPathKey)),
+	}
+
+	for _, o := range opts {
+		if !o.IsKey(helperKey) {
+			continue
+		}
+
+		if o.Value == "" {
+			cred.Helpers = nil
+			continue
+		}
+
+		cred.Helpers = append(cred.Helpers, o.Value)
+	}
+
+	return cred
+}
@@ -0,0 +1,37 @@
+package config
+
+import (
+	. "gopkg.in/check.v1"
+)
+
+type CredentialSuite struct{}
+
+var _ = Suite(&CredentialSuite{})
+
+func (s *CredentialSuite) TestCredentialOptions(c *C) {
+	cfg := NewConfig()
+	err := cfg.Unmarshal([]byte(`[credential]
+	helper = cache
+	username = foo
+[credential "https://example.com"]
+	helper =
+	helper = store --file /tmp/creds
+	useHttpPath = true
+[credential "https://example.org"]
+	helper = osxkeychain
+`))
+	c.Assert(err, IsNil)
+
+	c.Assert(cfg.CredentialOptions("https://example.com/foo.git"), DeepEquals, &Credential{
+		Helpers:     []string{"store --file /tmp/creds"},
+		Username:    "foo",
+		UseHTTPPath: true,
+	})
+
+	c.Assert(cfg.CredentialOptions("https://example.net/foo.git"), DeepEquals, &Credential{
+		Helpers:  []string{"cache"},
+		Username: "foo",
+	})
+
+	c.Assert(NewConfig().CredentialOptions("https://example.com"), DeepEquals, &Credential{})
+}
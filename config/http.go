@@ -0,0 +1,113 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	httpSection    = "http"
+	proxyKey       = "proxy"
+	sslCAInfoKey   = "sslCAInfo"
+	sslCertKey     = "sslCert"
+	sslKeyKey      = "sslKey"
+	sslVerifyKey   = "sslVerify"
+	extraHeaderKey = "extraHeader"
+
+	sslNoVerifyEnv = "GIT_SSL_NO_VERIFY"
+	sslCAInfoEnv   = "GIT_SSL_CAINFO"
+	sslCertEnv     = "GIT_SSL_CERT"
+	sslKeyEnv      = "GIT_SSL_KEY"
+)
+
+// HTTP is the http.* config applying to a URL, set by http.* and
+// http.<url>.*, and overridden by the GIT_SSL_* environment variables as git
+// does.
+type HTTP struct {
+	// Proxy is the URL of the HTTP proxy.
+	Proxy string
+	// SSLCAInfo is the path of the file of the certificates verifying the
+	// server, GIT_SSL_CAINFO if set.
+	SSLCAInfo string
+	// SSLCert is the path of the certificate of the client, GIT_SSL_CERT
+	// if set.
+	SSLCert string
+	// SSLKey is the path of the private key of the client, GIT_SSL_KEY if
+	// set.
+	SSLKey string
+	// SSLVerify is false if the certificate of the server is not verified,
+	// by http.sslVerify or GIT_SSL_NO_VERIFY. true by default.
+	SSLVerify bool
+	// ExtraHeaders are the headers added to the requests, an empty
+	// http.extraHeader resetting the list.
+	ExtraHeaders []string
+}
+
+// HTTPOptions returns the HTTP config applying to the given URL, the leading
+// "~/" of its paths being expanded to the home directory of the user.
+func (c *Config) HTTPOptions(url string) (*HTTP, error) {
+	opts := c.URLOptions(httpSection, url)
+
+	h := &HTTP{Proxy: opts.Get(proxyKey), SSLVerify: true}
+	for _, p := range []struct {
+		value *string
+		key   string
+		env   string
+	}{
+		{&h.SSLCAInfo, sslCAInfoKey, sslCAInfoEnv},
+		{&h.SSLCert, sslCertKey, sslCertEnv},
+		{&h.SSLKey, sslKeyKey, sslKeyEnv},
+	} {
+		v := opts.Get(p.key)
+		if env := os.Getenv(p.env); env != "" {
+			v = env
+		}
+
+		path, err := expandHome(v)
+		if err != nil {
+			return nil, err
+		}
+
+		*p.value = path
+	}
+
+	switch strings.ToLower(opts.Get(sslVerifyKey)) {
+	case "0", "false", "no", "off":
+		h.SSLVerify = false
+	}
+
+	if os.Getenv(sslNoVerifyEnv) != "" {
+		h.SSLVerify = false
+	}
+
+	for _, o := range opts {
+		if !o.IsKey(extraHeaderKey) {
+			continue
+		}
+
+		if o.Value == "" {
+			h.ExtraHeaders = nil
+			continue
+		}
+
+		h.ExtraHeaders = append(h.ExtraHeaders, o.Value)
+	}
+
+	return h, nil
+}
+
+// expandHome expands the leading "~/" of a path to the home directory of the
+// user, as git does with the pathname options.
+func expandHome(path string) (string, error) {
+	if !strings.HasPrefix(path, "~/") {
+		return path, nil
+	}
+
+	home, err := Home()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(home, path[2:]), nil
+}
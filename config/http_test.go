@@ -0,0 +1,88 @@
+package config
+
+import (
+	"os"
+
+	. "gopkg.in/check.v1"
+)
+
+type HTTPSuite struct {
+	restore map[string]*string
+}
+
+var _ = Suite(&HTTPSuite{})
+
+func (s *HTTPSuite) SetUpTest(c *C) {
+	s.restore = make(map[string]*string)
+	for _, k := range []string{
+		"HOME", sslNoVerifyEnv, sslCAInfoEnv, sslCertEnv, sslKeyEnv,
+	} {
+		if old, ok := os.LookupEnv(k); ok {
+			s.restore[k] = &old
+		} else {
+			s.restore[k] = nil
+		}
+
+		os.Unsetenv(k)
+	}
+
+	os.Setenv("HOME", "/home/foo")
+}
+
+func (s *HTTPSuite) TearDownTest(c *C) {
+	for k, v := range s.restore {
+		if v == nil {
+			os.Unsetenv(k)
+		} else {
+			os.Setenv(k, *v)
+		}
+	}
+}
+
+func (s *HTTPSuite) TestHTTPOptions(c *C) {
+	cfg := NewConfig()
+	err := cfg.Unmarshal([]byte(`[http]
+	sslCert = ~/cert.pem
+	sslCAInfo = ~/ca.pem
+	extraHeader = X-Foo: bar
+[http "https://example.com"]
+	proxy = proxy:3128
+[http "https://example.com/foo"]
+	sslVerify = no
+[http "https://example.org"]
+	extraHeader =
+	extraHeader = X-Bar: qux
+`))
+	c.Assert(err, IsNil)
+
+	h, err := cfg.HTTPOptions("https://example.com/foo")
+	c.Assert(err, IsNil)
+	c.Assert(h, DeepEquals, &HTTP{
+		Proxy:        "proxy:3128",
+		SSLCAInfo:    "/home/foo/ca.pem",
+		SSLCert:      "/home/foo/cert.pem",
+		ExtraHeaders: []string{"X-Foo: bar"},
+	})
+
+	h, err = cfg.HTTPOptions("https://example.org/foo")
+	c.Assert(err, IsNil)
+	c.Assert(h, DeepEquals, &HTTP{
+		SSLCAInfo:    "/home/foo/ca.pem",
+		SSLCert:      "/home/foo/cert.pem",
+		SSLVerify:    true,
+		ExtraHeaders: []string{"X-Bar: qux"},
+	})
+}
+
+func (s *HTTPSuite) TestHTTPOptionsEnv(c *C) {
+	os.Setenv(sslNoVerifyEnv, "1")
+	os.Setenv(sslCertEnv, "/etc/cert.pem")
+
+	cfg := NewConfig()
+	err := cfg.Unmarshal([]byte("[http]\n\tsslCert = ~/cert.pem\n"))
+	c.Assert(err, IsNil)
+
+	h, err := cfg.HTTPOptions("https://example.com/foo")
+	c.Assert(err, IsNil)
+	c.Assert(h, DeepEquals, &HTTP{SSLCert: "/etc/cert.pem"})
+}
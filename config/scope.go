@@ -0,0 +1,317 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+
+	format "gopkg.in/src-d/go-git.v4/plumbing/format/config"
+	"gopkg.in/src-d/go-git.v4/utils/ioutil"
+
+	"gopkg.in/src-d/go-billy.v4/osfs"
+)
+
+// Scope is the scope of a config file, defining where it is stored and its
+// precedence.
+type Scope int
+
+const (
+	// LocalScope is the config of the repository, $GIT_DIR/config.
+	LocalScope Scope = iota
+	// WorktreeScope is the config of the worktree, $GIT_DIR/config.worktree,
+	// only read when extensions.worktreeConfig is enabled.
+	WorktreeScope
+	// GlobalScope is the config of the user, ~/.gitconfig, or the file of
+	// the GIT_CONFIG_GLOBAL environment variable.
+	GlobalScope
+	// XDGScope is the config of the user in $XDG_CONFIG_HOME/git/config,
+	// ~/.config/git/config by default.
+	XDGScope
+	// SystemScope is the config of the system, /etc/gitconfig, or the file
+	// of the GIT_CONFIG_SYSTEM environment variable.
+	SystemScope
+)
+
+// Scopes are the scopes in ascending order of precedence, the values of the
+// last ones override the values of the first ones.
+var Scopes = []Scope{SystemScope, XDGScope, GlobalScope, LocalScope, WorktreeScope}
+
+// ErrUnsupportedScope is returned by Path, LoadConfig and SaveConfig for the
+// scopes of the repository, whose config is read and written by its storer.
+var ErrUnsupportedScope = errors.New("unsupported config scope")
+
+const (
+	systemConfigFile = "/etc/gitconfig"
+	globalConfigFile = ".gitconfig"
+	xdgConfigFile    = "git/config"
+	xdgConfigDir     = ".config"
+
+	globalConfigEnv   = "GIT_CONFIG_GLOBAL"
+	systemConfigEnv   = "GIT_CONFIG_SYSTEM"
+	noSystemConfigEnv = "GIT_CONFIG_NOSYSTEM"
+	xdgConfigHomeEnv  = "XDG_CONFIG_HOME"
+	homeEnv           = "HOME"
+)
+
+func (s Scope) String() string {
+	switch s {
+	case LocalScope:
+		return "local"
+	case WorktreeScope:
+		return "worktree"
+	case GlobalScope:
+		return "global"
+	case XDGScope:
+		return "xdg"
+	case SystemScope:
+		return "system"
+	default:
+		return "unknown"
+	}
+}
+
+// Path returns the path of the config file of a scope of the user or of the
+// system, as git does with the GIT_CONFIG_GLOBAL, GIT_CONFIG_SYSTEM,
+// GIT_CONFIG_NOSYSTEM, XDG_CONFIG_HOME and HOME environment variables. An
+// empty path is returned if the scope is disabled by the environment.
+func Path(scope Scope) (string, error) {
+	switch scope {
+	case SystemScope:
+		if isTrue(os.Getenv(noSystemConfigEnv)) {
+			return "", nil
+		}
+
+		if p := os.Getenv(systemConfigEnv); p != "" {
+			return p, nil
+		}
+
+		return systemConfigFile, nil
+	case GlobalScope, XDGScope:
+		if p, ok := os.LookupEnv(globalConfigEnv); ok {
+			if scope == XDGScope {
+				return "", nil
+			}
+
+			return p, nil
+		}
+
+		home, err := Home()
+		if err != nil {
+			return "", err
+		}
+
+		if scope == GlobalScope {
+			return filepath.Join(home, globalConfigFile), nil
+		}
+
+		xdg := os.Getenv(xdgConfigHomeEnv)
+		if xdg == "" {
+			xdg = filepath.Join(home, xdgConfigDir)
+		}
+
+		return filepath.Join(xdg, xdgConfigFile), nil
+	default:
+		return "", ErrUnsupportedScope
+	}
+}
+
+// Home returns the home directory of the user, from the HOME environment
+// variable or the user database.
+func Home() (string, error) {
+	if home := os.Getenv(homeEnv); home != "" {
+		return home, nil
+	}
+
+	usr, err := user.Current()
+	if err != nil {
+		return "", err
+	}
+
+	return usr.HomeDir, nil
+}
+
+func isTrue(v string) bool {
+	switch strings.ToLower(v) {
+	case "1", "true", "yes", "on":
+		return true
+	default:
+		return false
+	}
+}
+
+// LoadConfig loads the config of a scope of the user or of the system,
+// expanding its include directives as described by the given options, the
+// filesystem and the path of the file being set by LoadConfig. An empty config
+// is returned if the file doesn't exist or the scope is disabled.
+func LoadConfig(scope Scope, o *format.IncludeOptions) (c *Config, err error) {
+	path, err := Path(scope)
+	if err != nil || path == "" {
+		return NewConfig(), err
+	}
+
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return NewConfig(), nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer ioutil.CheckClose(f, &err)
+
+	opts := format.IncludeOptions{}
+	if o != nil {
+		opts = *o
+	}
+
+	if opts.Home == "" {
+		if opts.Home, err = Home(); err != nil {
+			return nil, err
+		}
+	}
+
+	opts.Filesystem, opts.Path = osfs.New("/"), path
+	return ReadConfig(f, &opts)
+}
+
+// SaveConfig writes the config to the file of a scope of the user or of the
+// system, creating it if needed. Only the options of the file itself are
+// written, not the ones of the files it includes.
+func SaveConfig(c *Config, scope Scope) (err error) {
+	path, err := Path(scope)
+	if err != nil {
+		return err
+	}
+
+	if path == "" {
+		return ErrUnsupportedScope
+	}
+
+	if err := c.Validate(); err != nil {
+		return err
+	}
+
+	b, err := c.MarshalScope(scope)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	defer ioutil.CheckClose(f, &err)
+
+	_, err = f.Write(b)
+	return err
+}
+
+// Origin is the origin of a config value.
+type Origin struct {
+	// Scope is the scope of the config file the value was read from.
+	Scope Scope
+	// Include is the path of the included file the value was read from,
+	// empty if it was read from the config file of the scope itself.
+	Include string
+}
+
+// ScopedConfig is the merged view of the configs of several scopes, the
+// embedded Config holding the values of every scope by order of precedence.
+// It must not be written, the config of a single scope must be written
+// instead.
+type ScopedConfig struct {
+	*Config
+	scopes map[Scope]*Config
+}
+
+// NewScopedConfig returns the merged view of the given configs, their fields
+// being set in their Raw as Marshal does.
+func NewScopedConfig(configs map[Scope]*Config) (*ScopedConfig, error) {
+	merged := NewConfig()
+	for _, s := range Scopes {
+		if cfg, ok := configs[s]; ok {
+			cfg.marshalScope(s)
+			mergeRaw(merged.Raw, cfg.Raw)
+		}
+	}
+
+	if err := merged.unmarshal(); err != nil {
+		return nil, err
+	}
+
+	return &ScopedConfig{Config: merged, scopes: configs}, nil
+}
+
+// mergeRaw appends the options of src to dst, keeping their origin.
+func mergeRaw(dst, src *format.Config) {
+	for _, s := range src.Sections {
+		section := dst.Section(s.Name)
+		section.Options = append(section.Options, s.Options...)
+
+		for _, ss := range s.Subsections {
+			sub := section.Subsection(ss.Name)
+			sub.Options = append(sub.Options, ss.Options...)
+		}
+	}
+}
+
+// Scope returns the config of the given scope, nil if not part of the
+// merged view.
+func (c *ScopedConfig) Scope(s Scope) *Config {
+	return c.scopes[s]
+}
+
+// Value returns the value of the given option, the last one of the scope with
+// the highest precedence defining it, and its origin. ok is false if the
+// option is not defined by any scope.
+func (c *ScopedConfig) Value(section, subsection, key string) (value string, origin Origin, ok bool) {
+	for i := len(Scopes) - 1; i >= 0; i-- {
+		cfg, found := c.scopes[Scopes[i]]
+		if !found || cfg.Raw == nil {
+			continue
+		}
+
+		if o := lookupOption(cfg.Raw, section, subsection, key); o != nil {
+			return o.Value, Origin{Scope: Scopes[i], Include: cfg.Raw.Origin(o)}, true
+		}
+	}
+
+	return "", Origin{}, false
+}
+
+// lookupOption returns the last option with the given key, without creating
+// the section or the subsection as format.Config.Section does.
+func lookupOption(raw *format.Config, section, subsection, key string) *format.Option {
+	var found *format.Option
+	for _, s := range raw.Sections {
+		if !s.IsName(section) {
+			continue
+		}
+
+		opts := s.Options
+		if subsection != "" {
+			opts = nil
+			for _, ss := range s.Subsections {
+				if ss.IsName(subsection) {
+					opts = append(opts, ss.Options...)
+				}
+			}
+		}
+
+		for _, o := range opts {
+			if o.IsKey(key) {
+				found = o
+			}
+		}
+	}
+
+	return found
+}
@@ -0,0 +1,231 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	. "gopkg.in/check.v1"
+	format "gopkg.in/src-d/go-git.v4/plumbing/format/config"
+)
+
+type ScopeSuite struct {
+	dir     string
+	restore map[string]*string
+}
+
+var _ = Suite(&ScopeSuite{})
+
+func (s *ScopeSuite) SetUpTest(c *C) {
+	var err error
+	s.dir, err = ioutil.TempDir("", "config-scope")
+	c.Assert(err, IsNil)
+
+	s.restore = make(map[string]*string)
+	s.setEnv("HOME", filepath.Join(s.dir, "home"))
+	s.setEnv("XDG_CONFIG_HOME", "")
+	s.setEnv("GIT_CONFIG_SYSTEM", filepath.Join(s.dir, "gitconfig"))
+	s.setEnv("GIT_CONFIG_NOSYSTEM", "")
+	s.unsetEnv("GIT_CONFIG_GLOBAL")
+}
+
+func (s *ScopeSuite) TearDownTest(c *C) {
+	for k, v := range s.restore {
+		if v == nil {
+			os.Unsetenv(k)
+		} else {
+			os.Setenv(k, *v)
+		}
+	}
+
+	c.Assert(os.RemoveAll(s.dir), IsNil)
+}
+
+func (s *ScopeSuite) setEnv(key, value string) {
+	s.saveEnv(key)
+	os.Setenv(key, value)
+}
+
+func (s *ScopeSuite) unsetEnv(key string) {
+	s.saveEnv(key)
+	os.Unsetenv(key)
+}
+
+func (s *ScopeSuite) saveEnv(key string) {
+	if _, ok := s.restore[key]; ok {
+		return
+	}
+
+	if old, ok := os.LookupEnv(key); ok {
+		s.restore[key] = &old
+	} else {
+		s.restore[key] = nil
+	}
+}
+
+func (s *ScopeSuite) writeFile(c *C, path, content string) {
+	c.Assert(os.MkdirAll(filepath.Dir(path), 0755), IsNil)
+	c.Assert(ioutil.WriteFile(path, []byte(content), 0644), IsNil)
+}
+
+func (s *ScopeSuite) TestPath(c *C) {
+	home := filepath.Join(s.dir, "home")
+
+	path, err := Path(GlobalScope)
+	c.Assert(err, IsNil)
+	c.Assert(path, Equals, filepath.Join(home, ".gitconfig"))
+
+	path, err = Path(XDGScope)
+	c.Assert(err, IsNil)
+	c.Assert(path, Equals, filepath.Join(home, ".config", "git", "config"))
+
+	path, err = Path(SystemScope)
+	c.Assert(err, IsNil)
+	c.Assert(path, Equals, filepath.Join(s.dir, "gitconfig"))
+
+	s.setEnv("XDG_CONFIG_HOME", filepath.Join(s.dir, "xdg"))
+	path, err = Path(XDGScope)
+	c.Assert(err, IsNil)
+	c.Assert(path, Equals, filepath.Join(s.dir, "xdg", "git", "config"))
+
+	s.setEnv("GIT_CONFIG_GLOBAL", filepath.Join(s.dir, "global"))
+	path, err = Path(GlobalScope)
+	c.Assert(err, IsNil)
+	c.Assert(path, Equals, filepath.Join(s.dir, "global"))
+
+	path, err = Path(XDGScope)
+	c.Assert(err, IsNil)
+	c.Assert(path, Equals, "")
+
+	s.setEnv("GIT_CONFIG_NOSYSTEM", "true")
+	path, err = Path(SystemScope)
+	c.Assert(err, IsNil)
+	c.Assert(path, Equals, "")
+
+	_, err = Path(LocalScope)
+	c.Assert(err, Equals, ErrUnsupportedScope)
+	_, err = Path(WorktreeScope)
+	c.Assert(err, Equals, ErrUnsupportedScope)
+}
+
+func (s *ScopeSuite) TestLoadConfig(c *C) {
+	home := filepath.Join(s.dir, "home")
+	s.writeFile(c, filepath.Join(home, ".gitconfig"), "[user]\n\tname = John Doe\n[include]\n\tpath = ~/.gitconfig.inc\n")
+	s.writeFile(c, filepath.Join(home, ".gitconfig.inc"), "[user]\n\temail = john@example.com\n")
+
+	cfg, err := LoadConfig(GlobalScope, nil)
+	c.Assert(err, IsNil)
+	c.Assert(cfg.User.Name, Equals, "John Doe")
+	c.Assert(cfg.User.Email, Equals, "john@example.com")
+
+	cfg, err = LoadConfig(XDGScope, nil)
+	c.Assert(err, IsNil)
+	c.Assert(cfg.User.Name, Equals, "")
+
+	_, err = LoadConfig(LocalScope, nil)
+	c.Assert(err, Equals, ErrUnsupportedScope)
+}
+
+func (s *ScopeSuite) TestLoadConfigIncludeIf(c *C) {
+	home := filepath.Join(s.dir, "home")
+	s.writeFile(c, filepath.Join(home, ".gitconfig"), "[includeIf \"gitdir:~/work/\"]\n\tpath = work.inc\n")
+	s.writeFile(c, filepath.Join(home, "work.inc"), "[user]\n\temail = john@work.example.com\n")
+
+	cfg, err := LoadConfig(GlobalScope, &format.IncludeOptions{
+		GitDir: filepath.Join(home, "work", "project", ".git"),
+	})
+	c.Assert(err, IsNil)
+	c.Assert(cfg.User.Email, Equals, "john@work.example.com")
+
+	cfg, err = LoadConfig(GlobalScope, &format.IncludeOptions{
+		GitDir: filepath.Join(home, "project", ".git"),
+	})
+	c.Assert(err, IsNil)
+	c.Assert(cfg.User.Email, Equals, "")
+}
+
+func (s *ScopeSuite) TestSaveConfig(c *C) {
+	home := filepath.Join(s.dir, "home")
+	s.writeFile(c, filepath.Join(home, ".gitconfig"), "[user]\n\tname = John Doe\n[include]\n\tpath = ~/.gitconfig.inc\n")
+	s.writeFile(c, filepath.Join(home, ".gitconfig.inc"), "[user]\n\temail = john@example.com\n")
+
+	cfg, err := LoadConfig(GlobalScope, nil)
+	c.Assert(err, IsNil)
+
+	cfg.User.Name = "Jane Doe"
+	c.Assert(SaveConfig(cfg, GlobalScope), IsNil)
+
+	b, err := ioutil.ReadFile(filepath.Join(home, ".gitconfig"))
+	c.Assert(err, IsNil)
+	c.Assert(string(b), Equals, "[user]\n\tname = Jane Doe\n[include]\n\tpath = ~/.gitconfig.inc\n")
+
+	cfg = NewConfig()
+	cfg.User.Name = "John Doe"
+	c.Assert(SaveConfig(cfg, XDGScope), IsNil)
+
+	b, err = ioutil.ReadFile(filepath.Join(home, ".config", "git", "config"))
+	c.Assert(err, IsNil)
+	c.Assert(string(b), Equals, "[user]\n\tname = John Doe\n")
+
+	c.Assert(SaveConfig(cfg, LocalScope), Equals, ErrUnsupportedScope)
+}
+
+func (s *ScopeSuite) TestScopedConfig(c *C) {
+	system := NewConfig()
+	system.Core.AutoCRLF = "input"
+	system.User.Name = "system"
+
+	global := NewConfig()
+	err := global.Unmarshal([]byte("[user]\n\tname = global\n\temail = global@example.com\n"))
+	c.Assert(err, IsNil)
+
+	local := NewConfig()
+	local.Core.IsBare = true
+	local.User.Email = "local@example.com"
+	local.Remotes["origin"] = &RemoteConfig{Name: "origin", URLs: []string{"https://example.com/foo"}}
+
+	inc := filepath.Join(s.dir, "foo.inc")
+	c.Assert(ioutil.WriteFile(inc, []byte("[user]\n\tname = included\n"), 0644), IsNil)
+
+	worktree := NewConfig()
+	d := format.NewDecoder(strings.NewReader("[include]\n\tpath = " + inc + "\n"))
+	c.Assert(d.DecodeWithIncludes(worktree.Raw, &format.IncludeOptions{}), IsNil)
+	c.Assert(worktree.unmarshal(), IsNil)
+
+	cfg, err := NewScopedConfig(map[Scope]*Config{
+		SystemScope:   system,
+		GlobalScope:   global,
+		LocalScope:    local,
+		WorktreeScope: worktree,
+	})
+	c.Assert(err, IsNil)
+
+	c.Assert(cfg.Core.IsBare, Equals, true)
+	c.Assert(cfg.Core.AutoCRLF, Equals, "input")
+	c.Assert(cfg.User.Name, Equals, "included")
+	c.Assert(cfg.User.Email, Equals, "local@example.com")
+	c.Assert(cfg.Remotes["origin"].URLs, DeepEquals, []string{"https://example.com/foo"})
+	c.Assert(cfg.Scope(GlobalScope), Equals, global)
+	c.Assert(cfg.Scope(XDGScope), IsNil)
+
+	for _, t := range []struct {
+		section, subsection, key string
+		value                    string
+		origin                   Origin
+	}{
+		{"core", "", "autocrlf", "input", Origin{Scope: SystemScope}},
+		{"core", "", "bare", "true", Origin{Scope: LocalScope}},
+		{"user", "", "email", "local@example.com", Origin{Scope: LocalScope}},
+		{"User", "", "Name", "included", Origin{Scope: WorktreeScope, Include: inc}},
+		{"remote", "origin", "url", "https://example.com/foo", Origin{Scope: LocalScope}},
+	} {
+		value, origin, ok := cfg.Value(t.section, t.subsection, t.key)
+		c.Assert(ok, Equals, true, Commentf("%s.%s", t.section, t.key))
+		c.Assert(value, Equals, t.value)
+		c.Assert(origin, Equals, t.origin)
+	}
+
+	_, _, ok := cfg.Value("remote", "upstream", "url")
+	c.Assert(ok, Equals, false)
+}
@@ -0,0 +1,115 @@
+package config
+
+import (
+	"errors"
+	"strings"
+
+	format "gopkg.in/src-d/go-git.v4/plumbing/format/config"
+)
+
+var errURLEmptyName = errors.New("url config: empty name")
+
+// URL contains the rules rewriting the URLs of the remotes to the base URL
+// Name, as the url.<base>.insteadOf and url.<base>.pushInsteadOf options.
+type URL struct {
+	// Name is the base URL the matching URLs are rewritten to.
+	Name string
+	// InsteadOfs are the prefixes of the URLs rewritten to Name.
+	InsteadOfs []string
+	// PushInsteadOfs are the prefixes of the URLs rewritten to Name only
+	// when pushing.
+	PushInsteadOfs []string
+
+	raw *format.Subsection
+}
+
+// Validate validates fields of url
+func (u *URL) Validate() error {
+	if u.Name == "" {
+		return errURLEmptyName
+	}
+
+	return nil
+}
+
+func (u *URL) marshal() *format.Subsection {
+	if u.raw == nil {
+		u.raw = &format.Subsection{}
+	}
+
+	u.raw.Name = u.Name
+
+	if len(u.InsteadOfs) == 0 {
+		u.raw.RemoveOption(insteadOfKey)
+	} else {
+		u.raw.SetOption(insteadOfKey, u.InsteadOfs...)
+	}
+
+	if len(u.PushInsteadOfs) == 0 {
+		u.raw.RemoveOption(pushInsteadOfKey)
+	} else {
+		u.raw.SetOption(pushInsteadOfKey, u.PushInsteadOfs...)
+	}
+
+	return u.raw
+}
+
+func (u *URL) unmarshal(s *format.Subsection) error {
+	u.raw = s
+
+	u.Name = u.raw.Name
+	u.InsteadOfs = append([]string(nil), u.raw.Options.GetAll(insteadOfKey)...)
+	u.PushInsteadOfs = append([]string(nil), u.raw.Options.GetAll(pushInsteadOfKey)...)
+
+	return u.Validate()
+}
+
+// RewriteURL returns the given URL rewritten by the insteadOf rule with the
+// longest matching prefix, or the URL itself if no rule matches.
+func (c *Config) RewriteURL(url string) string {
+	if rewritten, ok := c.rewriteURL(url, false); ok {
+		return rewritten
+	}
+
+	return url
+}
+
+// RewritePushURL returns the given URL rewritten for push by the
+// pushInsteadOf rule with the longest matching prefix. As git does, the
+// insteadOf rules are applied if no pushInsteadOf rule matches.
+func (c *Config) RewritePushURL(url string) string {
+	if rewritten, ok := c.rewriteURL(url, true); ok {
+		return rewritten
+	}
+
+	return c.RewriteURL(url)
+}
+
+func (c *Config) rewriteURL(url string, push bool) (string, bool) {
+	var base, prefix string
+	var found bool
+	for _, u := range c.URLs {
+		prefixes := u.InsteadOfs
+		if push {
+			prefixes = u.PushInsteadOfs
+		}
+
+		for _, p := range prefixes {
+			if !strings.HasPrefix(url, p) {
+				continue
+			}
+
+			// the rules being in a map, ties are broken by the base URL to
+			// rewrite the URL the same way every time.
+			if !found || len(p) > len(prefix) || len(p) == len(prefix) && u.Name < base {
+				base, prefix, found = u.Name, p, true
+			}
+		}
+	}
+
+	if !found {
+		return url, false
+	}
+
+	return base + url[len(prefix):], true
+}
@@ -0,0 +1,99 @@
+package config
+
+import (
+	. "gopkg.in/check.v1"
+)
+
+type URLSuite struct{}
+
+var _ = Suite(&URLSuite{})
+
+func (s *URLSuite) TestValidateName(c *C) {
+	good := URL{Name: "https://mirror.example.com/", InsteadOfs: []string{"https://github.com/"}}
+	bad := URL{InsteadOfs: []string{"https://github.com/"}}
+	c.Assert(good.Validate(), IsNil)
+	c.Assert(bad.Validate(), NotNil)
+}
+
+func (s *URLSuite) TestUnmarshalMarshal(c *C) {
+	input := []byte(`[core]
+	bare = false
+[url "https://mirror.example.com/github/"]
+	insteadOf = https://github.com/
+	insteadOf = gh:
+[url "ssh://git@github.com/"]
+	pushInsteadOf = https://github.com/
+`)
+
+	cfg := NewConfig()
+	c.Assert(cfg.Unmarshal(input), IsNil)
+	c.Assert(cfg.URLs, HasLen, 2)
+
+	mirror := cfg.URLs["https://mirror.example.com/github/"]
+	c.Assert(mirror.Name, Equals, "https://mirror.example.com/github/")
+	c.Assert(mirror.InsteadOfs, DeepEquals, []string{"https://github.com/", "gh:"})
+	c.Assert(mirror.PushInsteadOfs, HasLen, 0)
+	c.Assert(cfg.URLs["ssh://git@github.com/"].PushInsteadOfs, DeepEquals, []string{"https://github.com/"})
+
+	output, err := cfg.Marshal()
+	c.Assert(err, IsNil)
+	c.Assert(string(output), Equals, string(input))
+}
+
+func (s *URLSuite) TestMarshal(c *C) {
+	cfg := NewConfig()
+	cfg.URLs["https://mirror.example.com/"] = &URL{
+		Name:       "https://mirror.example.com/",
+		InsteadOfs: []string{"https://example.com/"},
+	}
+
+	output, err := cfg.Marshal()
+	c.Assert(err, IsNil)
+	c.Assert(string(output), Equals, `[core]
+	bare = false
+[url "https://mirror.example.com/"]
+	insteadOf = https://example.com/
+`)
+}
+
+func (s *URLSuite) TestValidateConfig(c *C) {
+	cfg := NewConfig()
+	cfg.URLs["foo"] = &URL{Name: "bar"}
+	c.Assert(cfg.Validate(), Equals, ErrInvalid)
+}
+
+func (s *URLSuite) TestRewriteURL(c *C) {
+	cfg := NewConfig()
+	cfg.URLs["https://mirror.example.com/github/"] = &URL{
+		Name:       "https://mirror.example.com/github/",
+		InsteadOfs: []string{"https://github.com/", "gh:"},
+	}
+	cfg.URLs["https://mirror.example.com/src-d/"] = &URL{
+		Name:       "https://mirror.example.com/src-d/",
+		InsteadOfs: []string{"https://github.com/src-d/"},
+	}
+	cfg.URLs["ssh://git@github.com/"] = &URL{
+		Name:           "ssh://git@github.com/",
+		PushInsteadOfs: []string{"https://github.com/"},
+	}
+
+	for _, t := range []struct {
+		url, fetch, push string
+	}{
+		{"https://github.com/git-fixtures/basic.git",
+			"https://mirror.example.com/github/git-fixtures/basic.git",
+			"ssh://git@github.com/git-fixtures/basic.git"},
+		{"https://github.com/src-d/go-git.git",
+			"https://mirror.example.com/src-d/go-git.git",
+			"ssh://git@github.com/src-d/go-git.git"},
+		{"gh:git-fixtures/basic.git",
+			"https://mirror.example.com/github/git-fixtures/basic.git",
+			"https://mirror.example.com/github/git-fixtures/basic.git"},
+		{"https://example.com/foo.git",
+			"https://example.com/foo.git",
+			"https://example.com/foo.git"},
+	} {
+		c.Assert(cfg.RewriteURL(t.url), Equals, t.fetch)
+		c.Assert(cfg.RewritePushURL(t.url), Equals, t.push)
+	}
+}
@@ -0,0 +1,170 @@
+package config
+
+import (
+	"net/url"
+	"sort"
+	"strings"
+
+	format "gopkg.in/src-d/go-git.v4/plumbing/format/config"
+)
+
+var defaultURLPorts = map[string]string{
+	"http":  "80",
+	"https": "443",
+	"git":   "9418",
+	"ssh":   "22",
+}
+
+// URLOptions returns the options of the given section applying to the given
+// URL, as git reads the <section>.<url>.* options: the options of the section
+// itself followed by the ones of the subsections whose name is a URL matching
+// the given one, by ascending order of specificity. The last value of an
+// option is thus the one of the subsection matching the URL best.
+func (c *Config) URLOptions(section, rawURL string) format.Options {
+	var s *format.Section
+	for _, cs := range c.Raw.Sections {
+		if cs.IsName(section) {
+			s = cs
+		}
+	}
+
+	if s == nil {
+		return nil
+	}
+
+	type match struct {
+		score   urlMatch
+		options format.Options
+	}
+
+	var matches []match
+	for _, ss := range s.Subsections {
+		if score, ok := matchURL(ss.Name, rawURL); ok {
+			matches = append(matches, match{score, ss.Options})
+		}
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].score.less(matches[j].score)
+	})
+
+	options := append(format.Options(nil), s.Options...)
+	for _, m := range matches {
+		options = append(options, m.options...)
+	}
+
+	return options
+}
+
+// urlMatch is the specificity of a match of a URL.
+type urlMatch struct {
+	exactHost bool
+	pathLen   int
+	user      bool
+}
+
+func (m urlMatch) less(o urlMatch) bool {
+	if m.exactHost != o.exactHost {
+		return o.exactHost
+	}
+
+	if m.pathLen != o.pathLen {
+		return m.pathLen < o.pathLen
+	}
+
+	return !m.user && o.user
+}
+
+// MatchURL reports whether the URL pattern of a <section>.<url>.* config
+// option matches the given URL, as git does: the schemes and ports must be
+// equal, the user must be equal if the pattern has one, each label of the
+// host of the pattern may be "*", and the path of the pattern must be a
+// prefix of the path of the URL ending at a slash.
+func MatchURL(pattern, rawURL string) bool {
+	_, ok := matchURL(pattern, rawURL)
+	return ok
+}
+
+func matchURL(pattern, rawURL string) (urlMatch, bool) {
+	p, err := url.Parse(pattern)
+	if err != nil || p.Host == "" {
+		return urlMatch{}, false
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return urlMatch{}, false
+	}
+
+	var m urlMatch
+	if !strings.EqualFold(p.Scheme, u.Scheme) {
+		return m, false
+	}
+
+	if p.User != nil {
+		if u.User == nil || p.User.Username() != u.User.Username() {
+			return m, false
+		}
+
+		m.user = true
+	}
+
+	if urlPort(p) != urlPort(u) {
+		return m, false
+	}
+
+	var ok bool
+	if m.exactHost, ok = matchHost(p.Hostname(), u.Hostname()); !ok {
+		return m, false
+	}
+
+	if m.pathLen, ok = matchPath(p.Path, u.Path); !ok {
+		return m, false
+	}
+
+	return m, true
+}
+
+func urlPort(u *url.URL) string {
+	if port := u.Port(); port != "" {
+		return port
+	}
+
+	return defaultURLPorts[strings.ToLower(u.Scheme)]
+}
+
+func matchHost(pattern, host string) (exact bool, ok bool) {
+	pl := strings.Split(strings.ToLower(pattern), ".")
+	hl := strings.Split(strings.ToLower(host), ".")
+	if len(pl) != len(hl) {
+		return false, false
+	}
+
+	exact = true
+	for i := range pl {
+		if pl[i] == "*" {
+			exact = false
+			continue
+		}
+
+		if pl[i] != hl[i] {
+			return false, false
+		}
+	}
+
+	return exact, true
+}
+
+func matchPath(pattern, path string) (int, bool) {
+	pattern = strings.TrimSuffix(pattern, "/")
+	path = strings.TrimSuffix(path, "/")
+	if pattern == "" {
+		return 0, true
+	}
+
+	if path != pattern && !strings.HasPrefix(path, pattern+"/") {
+		return 0, false
+	}
+
+	return len(pattern), true
+}
@@ -0,0 +1,63 @@
+package config
+
+import (
+	. "gopkg.in/check.v1"
+)
+
+type URLMatchSuite struct{}
+
+var _ = Suite(&URLMatchSuite{})
+
+func (s *URLMatchSuite) TestMatchURL(c *C) {
+	for _, t := range []struct {
+		pattern, url string
+		match        bool
+	}{
+		{"https://example.com", "https://example.com/foo/bar.git", true},
+		{"https://example.com/", "https://example.com/foo/bar.git", true},
+		{"https://example.com/foo", "https://example.com/foo/bar.git", true},
+		{"https://example.com/foo/", "https://example.com/foo/bar.git", true},
+		{"https://example.com/fo", "https://example.com/foo/bar.git", false},
+		{"https://example.com/foo/bar.git", "https://example.com/foo/bar.git", true},
+		{"http://example.com", "https://example.com/foo/bar.git", false},
+		{"https://example.com:443", "https://example.com/foo/bar.git", true},
+		{"https://example.com:8443", "https://example.com/foo/bar.git", false},
+		{"https://*.example.com", "https://git.example.com/foo/bar.git", true},
+		{"https://*.example.com", "https://example.com/foo/bar.git", false},
+		{"https://*.example.com", "https://a.git.example.com/foo/bar.git", false},
+		{"https://user@example.com", "https://user@example.com/foo/bar.git", true},
+		{"https://user@example.com", "https://example.com/foo/bar.git", false},
+		{"https://example.com", "https://user@example.com/foo/bar.git", true},
+		{"example.com", "https://example.com/foo/bar.git", false},
+	} {
+		c.Assert(MatchURL(t.pattern, t.url), Equals, t.match, Commentf("%s %s", t.pattern, t.url))
+	}
+}
+
+func (s *URLMatchSuite) TestURLOptions(c *C) {
+	cfg := NewConfig()
+	err := cfg.Unmarshal([]byte(`[credential "https://example.com/foo"]
+	helper = path
+[credential "https://*.example.com"]
+	helper = wildcard
+[credential]
+	helper = section
+	username = foo
+[credential "https://example.com"]
+	helper = host
+	username = bar
+[credential "https://other.example.com"]
+	helper = other
+`))
+	c.Assert(err, IsNil)
+
+	opts := cfg.URLOptions("credential", "https://example.com/foo/bar.git")
+	c.Assert(opts.GetAll("helper"), DeepEquals, []string{"section", "host", "path"})
+	c.Assert(opts.Get("username"), Equals, "bar")
+
+	opts = cfg.URLOptions("credential", "https://git.example.com/foo/bar.git")
+	c.Assert(opts.GetAll("helper"), DeepEquals, []string{"section", "wildcard"})
+	c.Assert(opts.Get("username"), Equals, "foo")
+
+	c.Assert(cfg.URLOptions("http", "https://example.com/foo/bar.git"), HasLen, 0)
+}
@@ -0,0 +1,141 @@
+package git
+
+import (
+	"errors"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/src-d/go-git.v4/config"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+)
+
+// ErrInvalidIdentityDate is returned when the date of GIT_AUTHOR_DATE or
+// GIT_COMMITTER_DATE can not be parsed.
+var ErrInvalidIdentityDate = errors.New("invalid identity date")
+
+const (
+	authorIdentity    = "author"
+	committerIdentity = "committer"
+
+	emailEnv = "EMAIL"
+)
+
+// identityDateLayouts are the layouts of the dates accepted in the environment,
+// besides the git internal format: RFC 2822 and ISO 8601.
+var identityDateLayouts = []string{
+	time.RFC1123Z,
+	"Mon, 2 Jan 2006 15:04:05 -0700",
+	time.RFC3339,
+	"2006-01-02 15:04:05 -0700",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+}
+
+// authorSignature returns the signature of the author, as git does: the name
+// and the email are read from GIT_AUTHOR_NAME and GIT_AUTHOR_EMAIL, then from
+// the author and the user config of every scope, the email from EMAIL at
+// last, the time from GIT_AUTHOR_DATE or now. nil is returned if the name or
+// the email is unknown.
+func (r *Repository) authorSignature() (*object.Signature, error) {
+	return r.identitySignature(authorIdentity)
+}
+
+// committerSignature returns the signature of the committer, as
+// authorSignature does with the GIT_COMMITTER_* variables and the committer
+// config.
+func (r *Repository) committerSignature() (*object.Signature, error) {
+	return r.identitySignature(committerIdentity)
+}
+
+func (r *Repository) identitySignature(kind string) (*object.Signature, error) {
+	cfg, err := r.MergedConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	name, email := configIdentity(cfg.Config, kind)
+
+	env := "GIT_" + strings.ToUpper(kind) + "_"
+	if v, ok := os.LookupEnv(env + "NAME"); ok {
+		name = v
+	}
+
+	if v, ok := os.LookupEnv(env + "EMAIL"); ok {
+		email = v
+	}
+
+	if email == "" {
+		email = os.Getenv(emailEnv)
+	}
+
+	if name == "" || email == "" {
+		return nil, nil
+	}
+
+	when := time.Now()
+	if v := os.Getenv(env + "DATE"); v != "" {
+		if when, err = parseIdentityDate(v); err != nil {
+			return nil, err
+		}
+	}
+
+	return &object.Signature{Name: name, Email: email, When: when}, nil
+}
+
+func configIdentity(cfg *config.Config, kind string) (name, email string) {
+	name, email = cfg.User.Name, cfg.User.Email
+
+	specific := cfg.Author
+	if kind == committerIdentity {
+		specific = cfg.Committer
+	}
+
+	if specific.Name != "" {
+		name = specific.Name
+	}
+
+	if specific.Email != "" {
+		email = specific.Email
+	}
+
+	return name, email
+}
+
+// parseIdentityDate parses the dates accepted by git in GIT_AUTHOR_DATE and
+// GIT_COMMITTER_DATE: the git internal format, "<unix timestamp> <offset>",
+// optionally prefixed by "@", RFC 2822 and ISO 8601.
+func parseIdentityDate(v string) (time.Time, error) {
+	v = strings.TrimSpace(v)
+	if t, ok := parseInternalDate(strings.TrimPrefix(v, "@")); ok {
+		return t, nil
+	}
+
+	for _, layout := range identityDateLayouts {
+		if t, err := time.Parse(layout, v); err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, ErrInvalidIdentityDate
+}
+
+func parseInternalDate(v string) (time.Time, bool) {
+	fields := strings.Fields(v)
+	if len(fields) != 2 || len(fields[1]) != 5 {
+		return time.Time{}, false
+	}
+
+	sec, err := strconv.ParseInt(fields[0], 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	tz, err := time.Parse("-0700", fields[1])
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	return time.Unix(sec, 0).In(tz.Location()), true
+}
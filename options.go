@@ -2,12 +2,17 @@ package git
 
 import (
 	"errors"
+	"path"
+	"path/filepath"
 	"regexp"
 	"strings"
 
 	"golang.org/x/crypto/openpgp"
+	"gopkg.in/src-d/go-billy.v4"
 	"gopkg.in/src-d/go-git.v4/config"
 	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/format/diff"
+	"gopkg.in/src-d/go-git.v4/plumbing/format/gitignore"
 	"gopkg.in/src-d/go-git.v4/plumbing/object"
 	"gopkg.in/src-d/go-git.v4/plumbing/protocol/packp/sideband"
 	"gopkg.in/src-d/go-git.v4/plumbing/transport"
@@ -227,6 +232,7 @@ type SubmoduleUpdateOptions struct {
 var (
 	ErrBranchHashExclusive  = errors.New("Branch and Hash are mutually exclusive")
 	ErrCreateRequiresBranch = errors.New("Branch is mandatory when Create is used")
+	ErrForceMergeExclusive  = errors.New("Force and Merge are mutually exclusive")
 )
 
 // CheckoutOptions describes how a checkout 31operation should be performed.
@@ -242,6 +248,11 @@ type CheckoutOptions struct {
 	// Force, if true when switching branches, proceed even if the index or the
 	// working tree differs from HEAD. This is used to throw away local changes
 	Force bool
+	// Merge, if true when switching branches, the local changes to the files
+	// that differ between HEAD and the commit being checked out are three-way
+	// merged into them, instead of refusing to checkout. The overlapping
+	// changes are left between conflict markers.
+	Merge bool
 }
 
 // Validate validates the fields and sets the default values.
@@ -254,6 +265,10 @@ func (o *CheckoutOptions) Validate() error {
 		return ErrCreateRequiresBranch
 	}
 
+	if o.Force && o.Merge {
+		return ErrForceMergeExclusive
+	}
+
 	if o.Branch == "" {
 		o.Branch = plumbing.Master
 	}
@@ -338,7 +353,30 @@ type LogOptions struct {
 }
 
 var (
-	ErrMissingAuthor = errors.New("author field is required")
+	ErrMissingAuthor         = errors.New("author field is required")
+	ErrMissingCommitter      = errors.New("committer field is required when the author of the amended commit is kept")
+	ErrAmendParentsExclusive = errors.New("amend and parents are mutually exclusive")
+	ErrNothingToAmend        = errors.New("nothing to amend")
+	ErrEmptyCommit           = errors.New("nothing to commit, the tree equals the tree of the parent")
+	ErrEmptyCommitMessage    = errors.New("empty commit message")
+	ErrInvalidCommitCleanup  = errors.New("invalid commit cleanup mode")
+)
+
+// CleanupMode defines how the message of a commit is cleaned up.
+type CleanupMode int8
+
+const (
+	// VerbatimCleanup does not change the message. This is the default.
+	VerbatimCleanup CleanupMode = iota
+	// WhitespaceCleanup strips the leading and trailing empty lines and the
+	// trailing whitespace, and collapses the consecutive empty lines.
+	WhitespaceCleanup
+	// StripCleanup is as WhitespaceCleanup, also stripping the comment lines,
+	// starting with core.commentChar.
+	StripCleanup
+	// ScissorsCleanup is as WhitespaceCleanup, also truncating the message
+	// from the scissors line, as the one added by git commit --verbose.
+	ScissorsCleanup
 )
 
 // CommitOptions describes how a commit operation should be performed.
@@ -346,22 +384,61 @@ type CommitOptions struct {
 	// All automatically stage files that have been modified and deleted, but
 	// new files you have not told Git about are not affected.
 	All bool
-	// Author is the author's signature of the commit.
+	// Author is the author's signature of the commit. If Author is nil, it
+	// is read from the GIT_AUTHOR_NAME, GIT_AUTHOR_EMAIL and GIT_AUTHOR_DATE
+	// environment variables and the author.* and user.* config, as git does.
 	Author *object.Signature
 	// Committer is the committer's signature of the commit. If Committer is
-	// nil the Author signature is used.
+	// nil the Author signature is used, or if Author is nil too, it is read
+	// from the GIT_COMMITTER_* environment variables and the committer.* and
+	// user.* config.
 	Committer *object.Signature
 	// Parents are the parents commits for the new commit, by default when
 	// len(Parents) is zero, the hash of HEAD reference is used.
 	Parents []plumbing.Hash
+	// Amend replaces the commit of HEAD by the new commit, with the same
+	// parents, message if the message given is empty, and extra headers. The
+	// author of HEAD is kept if Author is nil, then Committer is required.
+	Amend bool
+	// DisallowEmpty returns ErrEmptyCommit instead of creating a commit with
+	// the same tree as its parent, as git does without --allow-empty. The
+	// merge commits are always allowed.
+	DisallowEmpty bool
+	// Cleanup is how the commit message is cleaned up, by default it is
+	// recorded verbatim.
+	Cleanup CleanupMode
 	// SignKey denotes a key to sign the commit with. A nil value here means the
 	// commit will not be signed. The private key must be present and already
 	// decrypted.
 	SignKey *openpgp.Entity
+	// Signer signs the commit, such as an object.NewOpenPGPSigner, an SSH
+	// signer of sshsig.NewSigner or an object.ProgramSigner running an X.509
+	// signer. If nil, the commit is signed with SignKey, if any.
+	Signer object.Signer
+
+	// amendParents are the parents of the commit amended, resolved by
+	// Validate.
+	amendParents []plumbing.Hash
 }
 
 // Validate validates the fields and sets the default values.
 func (o *CommitOptions) Validate(r *Repository) error {
+	if o.Cleanup < VerbatimCleanup || o.Cleanup > ScissorsCleanup {
+		return ErrInvalidCommitCleanup
+	}
+
+	if o.Amend {
+		if err := o.validateAmend(r); err != nil {
+			return err
+		}
+	}
+
+	if o.Author == nil {
+		if err := o.loadIdentity(r); err != nil {
+			return err
+		}
+	}
+
 	if o.Author == nil {
 		return ErrMissingAuthor
 	}
@@ -370,7 +447,11 @@ func (o *CommitOptions) Validate(r *Repository) error {
 		o.Committer = o.Author
 	}
 
-	if len(o.Parents) == 0 {
+	if o.Signer == nil && o.SignKey != nil {
+		o.Signer = object.NewOpenPGPSigner(o.SignKey)
+	}
+
+	if len(o.Parents) == 0 && !o.Amend {
 		head, err := r.Head()
 		if err != nil && err != plumbing.ErrReferenceNotFound {
 			return err
@@ -384,6 +465,72 @@ func (o *CommitOptions) Validate(r *Repository) error {
 	return nil
 }
 
+// validateAmend sets the parents and the author of the commit amended.
+func (o *CommitOptions) validateAmend(r *Repository) error {
+	if len(o.Parents) != 0 {
+		return ErrAmendParentsExclusive
+	}
+
+	head, err := r.Head()
+	if err == plumbing.ErrReferenceNotFound {
+		return ErrNothingToAmend
+	}
+
+	if err != nil {
+		return err
+	}
+
+	commit, err := r.CommitObject(head.Hash())
+	if err != nil {
+		return err
+	}
+
+	o.amendParents = commit.ParentHashes
+	if o.Author != nil {
+		return nil
+	}
+
+	if o.Committer == nil {
+		if o.Committer, err = r.committerSignature(); err != nil {
+			return err
+		}
+	}
+
+	if o.Committer == nil {
+		return ErrMissingCommitter
+	}
+
+	author := commit.Author
+	o.Author = &author
+	return nil
+}
+
+// parents returns the parents of the new commit.
+func (o *CommitOptions) parents() []plumbing.Hash {
+	if o.Amend {
+		return o.amendParents
+	}
+
+	return o.Parents
+}
+
+// loadIdentity sets the author, and the committer if nil, from the
+// environment and the config.
+func (o *CommitOptions) loadIdentity(r *Repository) error {
+	author, err := r.authorSignature()
+	if err != nil {
+		return err
+	}
+
+	o.Author = author
+	if o.Committer != nil {
+		return nil
+	}
+
+	o.Committer, err = r.committerSignature()
+	return err
+}
+
 var (
 	ErrMissingName    = errors.New("name field is required")
 	ErrMissingTagger  = errors.New("tagger field is required")
@@ -392,7 +539,9 @@ var (
 
 // CreateTagOptions describes how a tag object should be created.
 type CreateTagOptions struct {
-	// Tagger defines the signature of the tag creator.
+	// Tagger defines the signature of the tag creator. If Tagger is nil, it
+	// is read as the committer of the commits, from the GIT_COMMITTER_*
+	// environment variables and the committer.* and user.* config.
 	Tagger *object.Signature
 	// Message defines the annotation of the tag. It is canonicalized during
 	// validation into the format expected by git - no leading whitespace and
@@ -401,10 +550,23 @@ type CreateTagOptions struct {
 	// SignKey denotes a key to sign the tag with. A nil value here means the tag
 	// will not be signed. The private key must be present and already decrypted.
 	SignKey *openpgp.Entity
+	// Signer signs the tag, such as an object.NewOpenPGPSigner, an SSH signer
+	// of sshsig.NewSigner or an object.ProgramSigner running an X.509 signer.
+	// If nil, the tag is signed with SignKey, if any.
+	Signer object.Signer
 }
 
 // Validate validates the fields and sets the default values.
 func (o *CreateTagOptions) Validate(r *Repository, hash plumbing.Hash) error {
+	if o.Tagger == nil {
+		tagger, err := r.committerSignature()
+		if err != nil {
+			return err
+		}
+
+		o.Tagger = tagger
+	}
+
 	if o.Tagger == nil {
 		return ErrMissingTagger
 	}
@@ -416,6 +578,10 @@ func (o *CreateTagOptions) Validate(r *Repository, hash plumbing.Hash) error {
 	// Canonicalize the message into the expected message format.
 	o.Message = strings.TrimSpace(o.Message) + "\n"
 
+	if o.Signer == nil && o.SignKey != nil {
+		o.Signer = object.NewOpenPGPSigner(o.SignKey)
+	}
+
 	return nil
 }
 
@@ -425,9 +591,47 @@ type ListOptions struct {
 	Auth transport.AuthMethod
 }
 
+var (
+	ErrIgnoredOnlyIgnoredExclusive = errors.New("Ignored and OnlyIgnored are mutually exclusive")
+)
+
 // CleanOptions describes how a clean should be performed.
 type CleanOptions struct {
+	// Dir recurses into the untracked directories, removing them if all
+	// their files are removed. By default the untracked directories are
+	// kept.
 	Dir bool
+	// Ignored removes also the files ignored by the .gitignore files and
+	// the Worktree.Excludes, like git clean -x. The Excludes are still used.
+	Ignored bool
+	// OnlyIgnored removes only the ignored files, like git clean -X.
+	OnlyIgnored bool
+	// Excludes are additional ignore patterns, like git clean -e.
+	Excludes []gitignore.Pattern
+	// Paths restricts the clean to the given files or directories, relative
+	// to the root of the worktree. Glob patterns are allowed, e.g. "*.o".
+	Paths []string
+	// Force removes also the untracked nested repositories, like
+	// git clean -ff. By default they are kept.
+	Force bool
+	// DryRun doesn't remove anything, only the paths that would be removed
+	// are returned.
+	DryRun bool
+}
+
+// Validate validates the fields and sets the default values.
+func (o *CleanOptions) Validate() error {
+	if o.Ignored && o.OnlyIgnored {
+		return ErrIgnoredOnlyIgnoredExclusive
+	}
+
+	paths := make([]string, 0, len(o.Paths))
+	for _, p := range o.Paths {
+		paths = append(paths, path.Clean(filepath.ToSlash(p)))
+	}
+
+	o.Paths = paths
+	return nil
 }
 
 // GrepOptions describes how a grep should be performed.
@@ -477,3 +681,313 @@ type PlainOpenOptions struct {
 
 // Validate validates the fields and sets the default values.
 func (o *PlainOpenOptions) Validate() error { return nil }
+
+// ArchiveFormat defines the format of the archive created by
+// Repository.Archive.
+type ArchiveFormat string
+
+const (
+	// TarArchive is a tar archive, with the pax header containing the commit
+	// hash when a commit is archived.
+	TarArchive ArchiveFormat = "tar"
+	// TarGzipArchive is a tar archive compressed with gzip.
+	TarGzipArchive ArchiveFormat = "tar.gz"
+	// ZipArchive is a zip archive, with the archive comment containing the
+	// commit hash when a commit is archived.
+	ZipArchive ArchiveFormat = "zip"
+)
+
+var (
+	ErrTreeOrRevision           = errors.New("ambiguous options, only one of Tree or Revision can be passed")
+	ErrUnsupportedArchiveFormat = errors.New("unsupported archive format")
+)
+
+// ArchiveOptions describes how an archive should be created.
+type ArchiveOptions struct {
+	// Tree is the hash of the tree to archive, it can also be the hash of a
+	// commit or a tag. If none of Tree and Revision are provided, HEAD is used.
+	Tree plumbing.Hash
+	// Revision is resolved to the tree to archive, it's mutually exclusive
+	// with Tree.
+	Revision plumbing.Revision
+	// Format of the archive, by default TarArchive.
+	Format ArchiveFormat
+	// Prefix is prepended to every path in the archive. A trailing slash is
+	// required to place the files inside of a directory.
+	Prefix string
+	// PathSpecs restricts the archive to the files matching any of the given
+	// paths or glob patterns, by default all the files are archived.
+	PathSpecs []string
+
+	// hash is the hash resolved by Validate from Tree or Revision, leaving
+	// them untouched.
+	hash plumbing.Hash
+}
+
+// Validate validates the fields and sets the default values.
+func (o *ArchiveOptions) Validate(r *Repository) error {
+	if !o.Tree.IsZero() && o.Revision != "" {
+		return ErrTreeOrRevision
+	}
+
+	o.hash = o.Tree
+	if o.hash.IsZero() {
+		rev := o.Revision
+		if rev == "" {
+			rev = plumbing.Revision(plumbing.HEAD)
+		}
+
+		h, err := r.ResolveRevision(rev)
+		if err != nil {
+			return err
+		}
+
+		o.hash = *h
+	}
+
+	switch o.Format {
+	case "":
+		o.Format = TarArchive
+	case TarArchive, TarGzipArchive, ZipArchive:
+	default:
+		return ErrUnsupportedArchiveFormat
+	}
+
+	return nil
+}
+
+var (
+	// ErrInvalidConePattern is returned when a pattern of the sparse
+	// checkout in cone mode is not a directory.
+	ErrInvalidConePattern = errors.New("invalid cone mode pattern, only directories are allowed")
+)
+
+// SparseCheckoutOptions describes the paths checked out in a sparse checkout.
+type SparseCheckoutOptions struct {
+	// Cone if true, Patterns are the directories checked out recursively,
+	// along with the files of the root and of their parent directories.
+	Cone bool
+	// Patterns are the paths checked out, in gitignore format if Cone is
+	// false, e.g. "/docs/" or "*.md".
+	Patterns []string
+}
+
+// Validate validates the fields and sets the default values, the directories
+// of the cone mode are normalized.
+func (o *SparseCheckoutOptions) Validate() error {
+	if !o.Cone {
+		return nil
+	}
+
+	dirs := make([]string, 0, len(o.Patterns))
+	for _, p := range o.Patterns {
+		dir := path.Clean("/" + filepath.ToSlash(p))[1:]
+		if dir == "" || strings.HasPrefix(p, "!") || strings.ContainsAny(dir, "*?[\\") {
+			return ErrInvalidConePattern
+		}
+
+		dirs = append(dirs, dir)
+	}
+
+	o.Patterns = dirs
+	return nil
+}
+
+var (
+	// ErrInvalidWorktreeName is returned when the name of a linked worktree
+	// is empty or not a valid directory name.
+	ErrInvalidWorktreeName = errors.New("invalid worktree name")
+)
+
+// AddWorktreeOptions describes how a linked worktree is added.
+type AddWorktreeOptions struct {
+	// Name of the worktree, its administrative files are kept at
+	// $GIT_DIR/worktrees/<name>. By default, the base name of the root of
+	// the worktree.
+	Name string
+	// Branch to be checked out in the worktree. If Branch and Hash are
+	// empty, the branch named after the worktree is checked out, being
+	// created from HEAD if it doesn't exist.
+	Branch plumbing.ReferenceName
+	// Hash is the commit checked out as a detached HEAD if Branch is empty,
+	// or the commit of the new branch if Create is true, HEAD by default.
+	Hash plumbing.Hash
+	// Create a new branch named Branch at Hash.
+	Create bool
+	// Force allows to check out a branch already checked out by other
+	// worktree.
+	Force bool
+}
+
+// Validate validates the fields and sets the default values.
+func (o *AddWorktreeOptions) Validate(worktree billy.Filesystem) error {
+	if o.Name == "" {
+		o.Name = filepath.Base(worktree.Root())
+	}
+
+	if o.Name == "" || o.Name == "." || o.Name == ".." ||
+		strings.ContainsAny(o.Name, "/\\") {
+		return ErrInvalidWorktreeName
+	}
+
+	if o.Create && o.Branch == "" {
+		return ErrCreateRequiresBranch
+	}
+
+	return nil
+}
+
+// RemoveWorktreeOptions describes how a linked worktree is removed.
+type RemoveWorktreeOptions struct {
+	// Force the removal of a worktree containing modified or untracked
+	// files, or locked.
+	Force bool
+}
+
+var (
+	// ErrNoRestorePaths is returned when no paths are given to Restore.
+	ErrNoRestorePaths = errors.New("paths to restore are required")
+)
+
+// RestoreOptions describes how a restore operation should be performed.
+type RestoreOptions struct {
+	// Source is the commit the paths are restored from. By default the
+	// worktree is restored from the index, and the index from HEAD.
+	Source plumbing.Hash
+	// Staged restores the index.
+	Staged bool
+	// Worktree restores the worktree, if Staged and Worktree are false the
+	// worktree is restored.
+	Worktree bool
+	// Paths are the files or directories to restore, relative to the root
+	// of the worktree. Glob patterns are allowed, e.g. "*.go" or "docs/*".
+	Paths []string
+}
+
+// Validate validates the fields and sets the default values.
+func (o *RestoreOptions) Validate(r *Repository) error {
+	if len(o.Paths) == 0 {
+		return ErrNoRestorePaths
+	}
+
+	if !o.Staged && !o.Worktree {
+		o.Worktree = true
+	}
+
+	if o.Staged && o.Source.IsZero() {
+		ref, err := r.Head()
+		if err != nil {
+			return err
+		}
+
+		o.Source = ref.Hash()
+	}
+
+	paths := make([]string, 0, len(o.Paths))
+	for _, p := range o.Paths {
+		paths = append(paths, path.Clean(filepath.ToSlash(p)))
+	}
+
+	o.Paths = paths
+	return nil
+}
+
+var (
+	ErrAllUpdateExclusive = errors.New("All and Update are mutually exclusive")
+	ErrMissingAddPaths    = errors.New("Paths are required unless All or Update are used")
+)
+
+// AddOptions describes how an add operation should be performed.
+type AddOptions struct {
+	// All adds the changes of all the files, new, modified or deleted, like
+	// git add -A. If Paths are given, only the files matching them.
+	All bool
+	// Update adds the changes of the files already in the index, modified or
+	// deleted, like git add -u. The new files are not added. If Paths are
+	// given, only the files matching them.
+	Update bool
+	// IntentToAdd records that the new files will be added later, without
+	// adding their content, like git add -N. The files are then listed as
+	// added in the worktree by Status, and are not committed until added.
+	IntentToAdd bool
+	// Force allows adding the ignored files matching Paths.
+	Force bool
+	// Paths are the files or directories to add, relative to the root of the
+	// worktree. Glob patterns are allowed, e.g. "*.go" or "docs/*".
+	// ErrPathspecNotMatched is returned if one of them matches no file.
+	Paths []string
+	// IgnoreMissing ignores the Paths matching no file, instead of returning
+	// ErrPathspecNotMatched.
+	IgnoreMissing bool
+}
+
+// Validate validates the fields and sets the default values.
+func (o *AddOptions) Validate() error {
+	if o.All && o.Update {
+		return ErrAllUpdateExclusive
+	}
+
+	if !o.All && !o.Update && len(o.Paths) == 0 {
+		return ErrMissingAddPaths
+	}
+
+	paths := make([]string, 0, len(o.Paths))
+	for _, p := range o.Paths {
+		paths = append(paths, path.Clean(filepath.ToSlash(p)))
+	}
+
+	o.Paths = paths
+	return nil
+}
+
+// ApplyOptions describes how a patch is applied to the index.
+type ApplyOptions struct {
+	// Reverse applies the patch in reverse, e.g. to unstage the changes of
+	// a patch from HEAD to the index, like git reset -p.
+	Reverse bool
+	// Select returns true if the chunk i of the file patch fp is applied, if
+	// nil all the chunks are applied. The added lines of the chunks not
+	// selected are skipped and the deleted ones are kept.
+	Select func(fp diff.FilePatch, i int) bool
+}
+
+// DiffOptions describes how a diff of the worktree should be performed.
+type DiffOptions struct {
+	// Staged diffs HEAD with the index, like git diff --cached. By default
+	// the index is diffed with the worktree, like git diff.
+	Staged bool
+	// Paths limits the diff to the files matching them, relative to the
+	// root of the worktree. Glob patterns are allowed, e.g. "*.go" or
+	// "docs/*".
+	Paths []string
+	// ContextLines is the number of lines of context around the changes
+	// when the patch is encoded, diff.DefaultContextLines if zero. A
+	// negative value encodes the patch without context lines.
+	ContextLines int
+}
+
+// Validate validates the fields and sets the default values.
+func (o *DiffOptions) Validate() error {
+	switch {
+	case o.ContextLines == 0:
+		o.ContextLines = diff.DefaultContextLines
+	case o.ContextLines < 0:
+		o.ContextLines = 0
+	}
+
+	paths := make([]string, 0, len(o.Paths))
+	for _, p := range o.Paths {
+		paths = append(paths, path.Clean(filepath.ToSlash(p)))
+	}
+
+	o.Paths = paths
+	return nil
+}
+
+// StatusOptions describes how a status operation should be performed.
+type StatusOptions struct {
+	// RefreshIndex writes to the index the stat information of the files
+	// found unchanged whose stat information didn't match, like
+	// git update-index --refresh, so they are not read by later calls.
+	RefreshIndex bool
+}
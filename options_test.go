@@ -1,8 +1,13 @@
 package git
 
 import (
+	"os"
+	"time"
+
 	. "gopkg.in/check.v1"
+	"gopkg.in/src-d/go-git.v4/plumbing"
 	"gopkg.in/src-d/go-git.v4/plumbing/object"
+	"gopkg.in/src-d/go-git.v4/storage/memory"
 )
 
 type OptionsSuite struct {
@@ -33,3 +38,170 @@ func (s *OptionsSuite) TestCommitOptionsCommitter(c *C) {
 
 	c.Assert(o.Committer, Equals, o.Author)
 }
+
+func (s *OptionsSuite) TestCommitOptionsIdentityFromConfig(c *C) {
+	r := s.newIdentityRepository(c)
+
+	o := CommitOptions{}
+	err := o.Validate(r)
+	c.Assert(err, IsNil)
+
+	c.Assert(o.Author.Name, Equals, "Jane Doe")
+	c.Assert(o.Author.Email, Equals, "john@example.com")
+	c.Assert(o.Author.When.IsZero(), Equals, false)
+	c.Assert(o.Committer.Name, Equals, "John Doe")
+	c.Assert(o.Committer.Email, Equals, "committer@example.com")
+}
+
+func (s *OptionsSuite) TestCommitOptionsIdentityFromEnv(c *C) {
+	r := s.newIdentityRepository(c)
+
+	defer setEnv(map[string]string{
+		"GIT_AUTHOR_NAME":     "Foo",
+		"GIT_AUTHOR_DATE":     "@1500000000 +0200",
+		"GIT_COMMITTER_EMAIL": "bar@example.com",
+		"GIT_COMMITTER_DATE":  "2017-07-14T02:40:00Z",
+	})()
+
+	o := CommitOptions{}
+	err := o.Validate(r)
+	c.Assert(err, IsNil)
+
+	c.Assert(o.Author.Name, Equals, "Foo")
+	c.Assert(o.Author.Email, Equals, "john@example.com")
+	c.Assert(o.Author.When.Unix(), Equals, int64(1500000000))
+	_, offset := o.Author.When.Zone()
+	c.Assert(offset, Equals, 2*60*60)
+	c.Assert(o.Committer.Name, Equals, "John Doe")
+	c.Assert(o.Committer.Email, Equals, "bar@example.com")
+	c.Assert(o.Committer.When.Unix(), Equals, int64(1500000000))
+}
+
+func (s *OptionsSuite) TestCommitOptionsIdentityEmailFromEnv(c *C) {
+	r, err := Init(memory.NewStorage(), nil)
+	c.Assert(err, IsNil)
+
+	cfg, err := r.Config()
+	c.Assert(err, IsNil)
+	cfg.User.Name = "John Doe"
+	c.Assert(r.Storer.SetConfig(cfg), IsNil)
+
+	o := CommitOptions{}
+	c.Assert(o.Validate(r), Equals, ErrMissingAuthor)
+
+	defer setEnv(map[string]string{"EMAIL": "john@example.com"})()
+
+	o = CommitOptions{}
+	c.Assert(o.Validate(r), IsNil)
+	c.Assert(o.Author.Email, Equals, "john@example.com")
+	c.Assert(o.Committer.Email, Equals, "john@example.com")
+}
+
+func (s *OptionsSuite) TestCommitOptionsIdentityInvalidDate(c *C) {
+	r := s.newIdentityRepository(c)
+
+	defer setEnv(map[string]string{"GIT_AUTHOR_DATE": "yesterday"})()
+
+	o := CommitOptions{}
+	c.Assert(o.Validate(r), Equals, ErrInvalidIdentityDate)
+}
+
+func (s *OptionsSuite) TestCommitOptionsAuthorKeepsCommitter(c *C) {
+	r := s.newIdentityRepository(c)
+
+	sig := &object.Signature{Name: "foo", Email: "foo@foo.foo"}
+	o := CommitOptions{Author: sig}
+	c.Assert(o.Validate(r), IsNil)
+	c.Assert(o.Committer, Equals, sig)
+}
+
+func (s *OptionsSuite) TestCommitOptionsAmendCommitterFromConfig(c *C) {
+	r := s.newIdentityRepository(c)
+
+	author := &object.Signature{Name: "foo", Email: "foo@foo.foo"}
+	commit := &object.Commit{Author: *author, Committer: *author, Message: "foo\n"}
+	obj := r.Storer.NewEncodedObject()
+	c.Assert(commit.Encode(obj), IsNil)
+	hash, err := r.Storer.SetEncodedObject(obj)
+	c.Assert(err, IsNil)
+	c.Assert(r.Storer.SetReference(plumbing.NewHashReference(plumbing.Master, hash)), IsNil)
+
+	o := CommitOptions{Amend: true}
+	c.Assert(o.Validate(r), IsNil)
+	c.Assert(o.Author.Name, Equals, "foo")
+	c.Assert(o.Committer.Name, Equals, "John Doe")
+	c.Assert(o.Committer.Email, Equals, "committer@example.com")
+}
+
+func (s *OptionsSuite) TestCreateTagOptionsTaggerFromConfig(c *C) {
+	r := s.newIdentityRepository(c)
+
+	o := CreateTagOptions{Message: "foo"}
+	err := o.Validate(r, plumbing.ZeroHash)
+	c.Assert(err, IsNil)
+	c.Assert(o.Tagger.Name, Equals, "John Doe")
+	c.Assert(o.Tagger.Email, Equals, "committer@example.com")
+
+	o = CreateTagOptions{Message: "foo"}
+	err = o.Validate(s.Repository, plumbing.ZeroHash)
+	c.Assert(err, Equals, ErrMissingTagger)
+}
+
+func (s *OptionsSuite) TestParseIdentityDate(c *C) {
+	expected := time.Date(2017, 7, 14, 2, 40, 0, 0, time.UTC)
+	for _, v := range []string{
+		"1500000000 +0000",
+		"@1500000000 +0000",
+		"Fri, 14 Jul 2017 02:40:00 +0000",
+		"2017-07-14T02:40:00Z",
+		"2017-07-14 02:40:00 +0000",
+		"2017-07-14T02:40:00",
+	} {
+		t, err := parseIdentityDate(v)
+		c.Assert(err, IsNil, Commentf("%s", v))
+		c.Assert(t.Equal(expected), Equals, true, Commentf("%s: %s", v, t))
+	}
+
+	_, err := parseIdentityDate("1500000000")
+	c.Assert(err, Equals, ErrInvalidIdentityDate)
+}
+
+func (s *OptionsSuite) newIdentityRepository(c *C) *Repository {
+	r, err := Init(memory.NewStorage(), nil)
+	c.Assert(err, IsNil)
+
+	cfg, err := r.Config()
+	c.Assert(err, IsNil)
+	cfg.User.Name = "John Doe"
+	cfg.User.Email = "john@example.com"
+	cfg.Author.Name = "Jane Doe"
+	cfg.Committer.Email = "committer@example.com"
+	c.Assert(r.Storer.SetConfig(cfg), IsNil)
+
+	return r
+}
+
+// setEnv sets the given environment variables, returning a function restoring
+// their previous values.
+func setEnv(env map[string]string) func() {
+	previous := make(map[string]*string, len(env))
+	for k, v := range env {
+		if old, ok := os.LookupEnv(k); ok {
+			previous[k] = &old
+		} else {
+			previous[k] = nil
+		}
+
+		os.Setenv(k, v)
+	}
+
+	return func() {
+		for k, v := range previous {
+			if v == nil {
+				os.Unsetenv(k)
+			} else {
+				os.Setenv(k, *v)
+			}
+		}
+	}
+}
@@ -10,6 +10,10 @@ type Config struct {
 	Comment  *Comment
 	Sections Sections
 	Includes Includes
+
+	// origins are the paths of the included files the options were read
+	// from.
+	origins map[*Option]string
 }
 
 // Includes is a list of Includes in a config file.
@@ -17,7 +21,12 @@ type Includes []*Include
 
 // Include is a reference to an included config file.
 type Include struct {
-	Path   string
+	// Path is the path of the file, as found in the directive.
+	Path string
+	// Condition is the condition of an includeIf directive, empty for an
+	// include directive.
+	Condition string
+	// Config is the config of the file, with its includes expanded.
 	Config *Config
 }
 
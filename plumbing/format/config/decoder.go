@@ -19,6 +19,10 @@ func NewDecoder(r io.Reader) *Decoder {
 // Decode reads the whole config from its input and stores it in the
 // value pointed to by config.
 func (d *Decoder) Decode(config *Config) error {
+	return d.decode(config, nil, 0)
+}
+
+func (d *Decoder) decode(config *Config, o *IncludeOptions, depth int) error {
 	cb := func(s string, ss string, k string, v string, bv bool) error {
 		if ss == "" && k == "" {
 			config.Section(s)
@@ -31,7 +35,11 @@ func (d *Decoder) Decode(config *Config) error {
 		}
 
 		config.AddOption(s, ss, k, v)
-		return nil
+		if o == nil || !isIncludeOption(s, ss, k) {
+			return nil
+		}
+
+		return config.include(v, ss, o, depth)
 	}
 	return gcfg.ReadWithCallback(d, cb)
 }
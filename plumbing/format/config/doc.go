@@ -91,6 +91,19 @@
 // 	relative to the configuration file in which the include directive was
 // 	found.  See below for examples.
 //
+// 	Conditional includes
+// 	~~~~~~~~~~~~~~~~~~~~
+//
+// 	You can include a config file from another conditionally by setting a
+// 	`includeIf.<condition>.path` variable to the name of the file to be
+// 	included. The condition starts with a keyword followed by a colon and
+// 	some data whose format and meaning depends on the keyword: `gitdir:`
+// 	and `gitdir/i:` match the location of the .git directory with a glob
+// 	pattern, case-insensitively for the latter, and `onbranch:` matches the
+// 	name of the branch currently checked out.
+//
+// 	The include directives are expanded by Decoder.DecodeWithIncludes.
+//
 //
 // 	Example
 // 	~~~~~~~
@@ -19,7 +19,7 @@ func NewEncoder(w io.Writer) *Encoder {
 // Encode writes the config in git config format to the stream of the encoder.
 func (e *Encoder) Encode(cfg *Config) error {
 	for _, s := range cfg.Sections {
-		if err := e.encodeSection(s); err != nil {
+		if err := e.encodeSection(cfg, s); err != nil {
 			return err
 		}
 	}
@@ -27,19 +27,19 @@ func (e *Encoder) Encode(cfg *Config) error {
 	return nil
 }
 
-func (e *Encoder) encodeSection(s *Section) error {
-	if len(s.Options) > 0 {
+func (e *Encoder) encodeSection(cfg *Config, s *Section) error {
+	if opts := cfg.own(s.Options); len(opts) > 0 {
 		if err := e.printf("[%s]\n", s.Name); err != nil {
 			return err
 		}
 
-		if err := e.encodeOptions(s.Options); err != nil {
+		if err := e.encodeOptions(opts); err != nil {
 			return err
 		}
 	}
 
 	for _, ss := range s.Subsections {
-		if err := e.encodeSubsection(s.Name, ss); err != nil {
+		if err := e.encodeSubsection(cfg, s.Name, ss); err != nil {
 			return err
 		}
 	}
@@ -47,13 +47,19 @@ func (e *Encoder) encodeSection(s *Section) error {
 	return nil
 }
 
-func (e *Encoder) encodeSubsection(sectionName string, s *Subsection) error {
+func (e *Encoder) encodeSubsection(cfg *Config, sectionName string, s *Subsection) error {
+	// the subsections with only options included are not encoded
+	opts := cfg.own(s.Options)
+	if len(opts) == 0 && len(s.Options) != 0 {
+		return nil
+	}
+
 	//TODO: escape
 	if err := e.printf("[%s \"%s\"]\n", sectionName, s.Name); err != nil {
 		return err
 	}
 
-	return e.encodeOptions(s.Options)
+	return e.encodeOptions(opts)
 }
 
 func (e *Encoder) encodeOptions(opts Options) error {
@@ -0,0 +1,284 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/src-d/go-billy.v4"
+	"gopkg.in/src-d/go-billy.v4/osfs"
+)
+
+const (
+	includeSection   = "include"
+	includeIfSection = "includeIf"
+	pathKey          = "path"
+
+	gitDirCondition     = "gitdir:"
+	gitDirFoldCondition = "gitdir/i:"
+	onBranchCondition   = "onbranch:"
+
+	// maxIncludeDepth is the maximum depth of the nested includes, as the
+	// one of git, to break the include loops.
+	maxIncludeDepth = 10
+)
+
+// ErrIncludeDepth is returned by DecodeWithIncludes when the includes are
+// nested too deeply, most likely because of an include loop.
+var ErrIncludeDepth = errors.New("exceeded maximum include depth")
+
+// IncludeOptions describes how the include directives of a config file are
+// expanded by DecodeWithIncludes.
+type IncludeOptions struct {
+	// Filesystem is the filesystem of the config file decoded, the relative
+	// paths of the included files are read from it. The relative includes
+	// are ignored if nil.
+	Filesystem billy.Filesystem
+	// Path is the path of the config file decoded in Filesystem, the
+	// relative paths are relative to its directory.
+	Path string
+	// Root is the filesystem the absolute paths, and the paths starting
+	// with "~/", are read from. The filesystem of the OS by default.
+	Root billy.Filesystem
+	// Home is the home directory the paths starting with "~/" are relative
+	// to.
+	Home string
+	// GitDir is the path of the $GIT_DIR of the repository, matched by the
+	// gitdir: conditions. These conditions never match if empty.
+	GitDir string
+	// Branch is the short name of the branch checked out, matched by the
+	// onbranch: conditions. These conditions never match if empty.
+	Branch string
+}
+
+// DecodeWithIncludes decodes the config as Decode, expanding the include.path
+// and includeIf.<condition>.path directives. The options of the files
+// included are added as if they were found at the location of the directive,
+// Config.Origin returning the path of their file, and the files are recorded in
+// Includes. The files not found and the conditions not matched are ignored,
+// as git does.
+//
+// The options included are not encoded by Encoder, only the ones of the file
+// itself are.
+func (d *Decoder) DecodeWithIncludes(config *Config, o *IncludeOptions) error {
+	return d.decode(config, o, 0)
+}
+
+func (c *Config) include(value, condition string, o *IncludeOptions, depth int) error {
+	if value == "" || (condition != "" && !o.matchCondition(condition)) {
+		return nil
+	}
+
+	if depth >= maxIncludeDepth {
+		return ErrIncludeDepth
+	}
+
+	fs, file := o.resolve(value)
+	if fs == nil {
+		return nil
+	}
+
+	f, err := fs.Open(file)
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	defer f.Close()
+
+	opts := *o
+	opts.Filesystem, opts.Path = fs, file
+
+	included := New()
+	if err := NewDecoder(f).decode(included, &opts, depth+1); err != nil {
+		return err
+	}
+
+	c.Includes = append(c.Includes, &Include{
+		Path:      value,
+		Condition: condition,
+		Config:    included,
+	})
+
+	c.addIncluded(included, fs.Join(fs.Root(), file))
+	return nil
+}
+
+// Origin returns the path of the included file the option was read from,
+// empty if the option is from the config file itself.
+func (c *Config) Origin(o *Option) string {
+	return c.origins[o]
+}
+
+// addIncluded adds the options of the included config to the config, with
+// their origin.
+func (c *Config) addIncluded(included *Config, origin string) {
+	for _, s := range included.Sections {
+		if len(s.Options) != 0 {
+			section := c.Section(s.Name)
+			section.Options = append(section.Options, c.withOrigin(included, s.Options, origin)...)
+		}
+
+		for _, ss := range s.Subsections {
+			if len(ss.Options) == 0 {
+				continue
+			}
+
+			sub := c.Section(s.Name).Subsection(ss.Name)
+			sub.Options = append(sub.Options, c.withOrigin(included, ss.Options, origin)...)
+		}
+	}
+}
+
+// withOrigin returns a copy of the options of the included config, read from
+// the file origin, unless included by it from another file.
+func (c *Config) withOrigin(included *Config, opts Options, origin string) Options {
+	if c.origins == nil {
+		c.origins = make(map[*Option]string)
+	}
+
+	result := make(Options, len(opts))
+	for i, o := range opts {
+		result[i] = &Option{o.Key, o.Value}
+		c.origins[result[i]] = origin
+		if nested := included.Origin(o); nested != "" {
+			c.origins[result[i]] = nested
+		}
+	}
+
+	return result
+}
+
+// own returns the options read from the config file itself, not included.
+func (c *Config) own(opts Options) Options {
+	var result Options
+	for _, o := range opts {
+		if c.Origin(o) == "" {
+			result = append(result, o)
+		}
+	}
+
+	return result
+}
+
+func isIncludeOption(section, subsection, key string) bool {
+	if !strings.EqualFold(key, pathKey) {
+		return false
+	}
+
+	if subsection == "" {
+		return strings.EqualFold(section, includeSection)
+	}
+
+	return strings.EqualFold(section, includeIfSection)
+}
+
+// resolve returns the filesystem and the path of an included file.
+func (o *IncludeOptions) resolve(p string) (billy.Filesystem, string) {
+	switch {
+	case strings.HasPrefix(p, "~/"):
+		root := o.root()
+		return root, root.Join(o.Home, p[2:])
+	case filepath.IsAbs(p):
+		return o.root(), p
+	case o.Filesystem == nil:
+		return nil, ""
+	default:
+		return o.Filesystem, o.Filesystem.Join(filepath.Dir(o.Path), p)
+	}
+}
+
+func (o *IncludeOptions) root() billy.Filesystem {
+	if o.Root == nil {
+		return osfs.New("/")
+	}
+
+	return o.Root
+}
+
+func (o *IncludeOptions) matchCondition(condition string) bool {
+	switch {
+	case strings.HasPrefix(condition, gitDirCondition):
+		return o.matchGitDir(condition[len(gitDirCondition):], false)
+	case strings.HasPrefix(condition, gitDirFoldCondition):
+		return o.matchGitDir(condition[len(gitDirFoldCondition):], true)
+	case strings.HasPrefix(condition, onBranchCondition):
+		pattern := condition[len(onBranchCondition):]
+		if strings.HasSuffix(pattern, "/") {
+			pattern += "**"
+		}
+
+		return o.Branch != "" && matchGlob(pattern, o.Branch, false)
+	default:
+		return false
+	}
+}
+
+// matchGitDir matches the $GIT_DIR with the pattern of a gitdir: condition,
+// "~/" and "./" are expanded, the patterns not absolute are prefixed by "**/"
+// and the ones ending with "/" are suffixed by "**".
+func (o *IncludeOptions) matchGitDir(pattern string, fold bool) bool {
+	if o.GitDir == "" || pattern == "" {
+		return false
+	}
+
+	switch {
+	case strings.HasPrefix(pattern, "~/"):
+		pattern = filepath.ToSlash(o.Home) + "/" + pattern[2:]
+	case strings.HasPrefix(pattern, "./") && o.Filesystem != nil:
+		dir := o.Filesystem.Join(o.Filesystem.Root(), filepath.Dir(o.Path))
+		pattern = filepath.ToSlash(dir) + "/" + pattern[2:]
+	}
+
+	pattern = filepath.ToSlash(pattern)
+	if !path.IsAbs(pattern) && filepath.VolumeName(pattern) == "" {
+		pattern = "**/" + pattern
+	}
+
+	if strings.HasSuffix(pattern, "/") {
+		pattern += "**"
+	}
+
+	return matchGlob(pattern, filepath.ToSlash(o.GitDir), fold)
+}
+
+// matchGlob matches a slash separated name with a glob pattern, where "**"
+// matches any number of path components.
+func matchGlob(pattern, name string, fold bool) bool {
+	if fold {
+		pattern, name = strings.ToLower(pattern), strings.ToLower(name)
+	}
+
+	return matchComponents(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+func matchComponents(pattern, name []string) bool {
+	for len(pattern) != 0 {
+		if pattern[0] == "**" {
+			for i := len(name); i >= 0; i-- {
+				if matchComponents(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+
+			return false
+		}
+
+		if len(name) == 0 {
+			return false
+		}
+
+		if ok, err := path.Match(pattern[0], name[0]); err != nil || !ok {
+			return false
+		}
+
+		pattern, name = pattern[1:], name[1:]
+	}
+
+	return len(name) == 0
+}
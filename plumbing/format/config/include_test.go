@@ -0,0 +1,183 @@
+package config
+
+import (
+	"bytes"
+
+	. "gopkg.in/check.v1"
+	"gopkg.in/src-d/go-billy.v4"
+	"gopkg.in/src-d/go-billy.v4/memfs"
+	"gopkg.in/src-d/go-billy.v4/util"
+)
+
+type IncludeSuite struct {
+	fs billy.Filesystem
+}
+
+var _ = Suite(&IncludeSuite{})
+
+func (s *IncludeSuite) SetUpTest(c *C) {
+	s.fs = memfs.New()
+
+	for path, content := range map[string]string{
+		"/home/user/.gitconfig": "[user]\n\tname = global\n[include]\n\tpath = .gitconfig.d/extra\n",
+		"/home/user/.gitconfig.d/extra": "[user]\n\temail = extra@example.com\n" +
+			"[remote \"origin\"]\n\turl = https://example.com/extra\n",
+		"/home/user/work.inc":  "[user]\n\temail = work@example.com\n",
+		"/home/user/topic.inc": "[core]\n\tautocrlf = input\n",
+		"/home/user/loop.inc":  "[include]\n\tpath = loop.inc\n",
+	} {
+		c.Assert(util.WriteFile(s.fs, path, []byte(content), 0644), IsNil)
+	}
+}
+
+func (s *IncludeSuite) decode(c *C, raw string, o *IncludeOptions) *Config {
+	if o.Filesystem == nil {
+		o.Filesystem, o.Path = s.fs, "/home/user/.gitconfig"
+	}
+
+	o.Root, o.Home = s.fs, "/home/user"
+
+	cfg := New()
+	err := NewDecoder(bytes.NewBufferString(raw)).DecodeWithIncludes(cfg, o)
+	c.Assert(err, IsNil)
+	return cfg
+}
+
+func (s *IncludeSuite) TestDecodeWithIncludes(c *C) {
+	cfg := s.decode(c, `[user]
+	name = before
+[include]
+	path = ~/.gitconfig
+[user]
+	email = after@example.com
+`, &IncludeOptions{})
+
+	user := cfg.Section("user")
+	c.Assert(user.Options.Get("name"), Equals, "global")
+	c.Assert(user.Options.Get("email"), Equals, "after@example.com")
+	c.Assert(user.Options.GetAll("email"), DeepEquals, []string{"extra@example.com", "after@example.com"})
+	c.Assert(cfg.Origin(user.Options[1]), Equals, "/home/user/.gitconfig")
+	c.Assert(cfg.Origin(user.Options[2]), Equals, "/home/user/.gitconfig.d/extra")
+	c.Assert(cfg.Origin(user.Options[3]), Equals, "")
+
+	origin := cfg.Section("remote").Subsection("origin")
+	c.Assert(origin.Options.Get("url"), Equals, "https://example.com/extra")
+
+	c.Assert(cfg.Includes, HasLen, 1)
+	c.Assert(cfg.Includes[0].Path, Equals, "~/.gitconfig")
+	c.Assert(cfg.Includes[0].Config.Includes, HasLen, 1)
+	c.Assert(cfg.Includes[0].Config.Includes[0].Path, Equals, ".gitconfig.d/extra")
+}
+
+func (s *IncludeSuite) TestEncodeWithIncludes(c *C) {
+	raw := `[user]
+	name = before
+[include]
+	path = ~/.gitconfig
+`
+	cfg := s.decode(c, raw, &IncludeOptions{})
+
+	buf := bytes.NewBuffer(nil)
+	c.Assert(NewEncoder(buf).Encode(cfg), IsNil)
+	c.Assert(buf.String(), Equals, raw)
+}
+
+func (s *IncludeSuite) TestDecodeWithIncludesMissing(c *C) {
+	cfg := s.decode(c, "[include]\n\tpath = missing\n", &IncludeOptions{})
+	c.Assert(cfg.Includes, HasLen, 0)
+
+	cfg = New()
+	err := NewDecoder(bytes.NewBufferString("[include]\n\tpath = work.inc\n")).
+		DecodeWithIncludes(cfg, &IncludeOptions{})
+	c.Assert(err, IsNil)
+	c.Assert(cfg.Includes, HasLen, 0)
+}
+
+func (s *IncludeSuite) TestDecodeWithIncludesLoop(c *C) {
+	cfg := New()
+	err := NewDecoder(bytes.NewBufferString("[include]\n\tpath = loop.inc\n")).
+		DecodeWithIncludes(cfg, &IncludeOptions{
+			Filesystem: s.fs,
+			Path:       "/home/user/.gitconfig",
+		})
+	c.Assert(err, Equals, ErrIncludeDepth)
+}
+
+func (s *IncludeSuite) TestDecodeWithIncludesGitDir(c *C) {
+	raw := `[includeIf "gitdir:~/work/"]
+	path = work.inc
+[includeIf "gitdir/i:PROJECT/.git"]
+	path = topic.inc
+`
+	for _, t := range []struct {
+		gitDir   string
+		email    string
+		autocrlf string
+	}{
+		{"/home/user/work/project/.git", "work@example.com", "input"},
+		{"/home/user/work/.git", "work@example.com", ""},
+		{"/home/user/other/Project/.git", "", "input"},
+		{"/home/user/other/.git", "", ""},
+		{"", "", ""},
+	} {
+		cfg := s.decode(c, raw, &IncludeOptions{GitDir: t.gitDir})
+		c.Assert(cfg.Section("user").Options.Get("email"), Equals, t.email, Commentf("%s", t.gitDir))
+		c.Assert(cfg.Section("core").Options.Get("autocrlf"), Equals, t.autocrlf, Commentf("%s", t.gitDir))
+	}
+}
+
+func (s *IncludeSuite) TestDecodeWithIncludesGitDirRelative(c *C) {
+	raw := "[includeIf \"gitdir:./work/\"]\n\tpath = work.inc\n"
+
+	cfg := s.decode(c, raw, &IncludeOptions{GitDir: "/home/user/work/project/.git"})
+	c.Assert(cfg.Section("user").Options.Get("email"), Equals, "work@example.com")
+
+	cfg = s.decode(c, raw, &IncludeOptions{GitDir: "/srv/work/project/.git"})
+	c.Assert(cfg.Section("user").Options.Get("email"), Equals, "")
+}
+
+func (s *IncludeSuite) TestDecodeWithIncludesOnBranch(c *C) {
+	raw := `[includeIf "onbranch:topic/"]
+	path = topic.inc
+[includeIf "onbranch:ma*"]
+	path = work.inc
+`
+	for _, t := range []struct {
+		branch   string
+		email    string
+		autocrlf string
+	}{
+		{"topic/foo", "", "input"},
+		{"topic/foo/bar", "", "input"},
+		{"master", "work@example.com", ""},
+		{"feature", "", ""},
+		{"", "", ""},
+	} {
+		cfg := s.decode(c, raw, &IncludeOptions{Branch: t.branch})
+		c.Assert(cfg.Section("user").Options.Get("email"), Equals, t.email, Commentf("%s", t.branch))
+		c.Assert(cfg.Section("core").Options.Get("autocrlf"), Equals, t.autocrlf, Commentf("%s", t.branch))
+	}
+}
+
+func (s *IncludeSuite) TestDecodeWithIncludesUnknownCondition(c *C) {
+	cfg := s.decode(c, "[includeIf \"hasconfig:remote.*.url:foo\"]\n\tpath = work.inc\n", &IncludeOptions{})
+	c.Assert(cfg.Includes, HasLen, 0)
+}
+
+func (s *IncludeSuite) TestMatchGlob(c *C) {
+	for _, t := range []struct {
+		pattern, name string
+		fold, match   bool
+	}{
+		{"/a/b/**", "/a/b/c/d", false, true},
+		{"/a/b/**", "/a/b", false, true},
+		{"**/b/.git", "/a/b/.git", false, true},
+		{"**/b/.git", "/a/c/.git", false, false},
+		{"/a/*/.git", "/a/b/.git", false, true},
+		{"/a/*/.git", "/a/b/c/.git", false, false},
+		{"/A/b", "/a/B", true, true},
+		{"/A/b", "/a/B", false, false},
+	} {
+		c.Assert(matchGlob(t.pattern, t.name, t.fold), Equals, t.match, Commentf("%s %s", t.pattern, t.name))
+	}
+}
@@ -243,7 +243,12 @@ func (c *hunksGenerator) addLineNumbers(la, lb int, linesBefore int, i int, op O
 			clb = 1
 		}
 	case c.ctxLines == 0:
+		// without context the range of the other side starts at the line
+		// before, unless the change also modifies it
 		clb = lb
+		if i != len(c.chunks)-1 && c.chunks[i+1].Type() == op {
+			clb = lb + 1
+		}
 	case i != len(c.chunks)-1:
 		next := c.chunks[i+1]
 		if next.Type() == op || next.Type() == Equal {
@@ -814,6 +814,46 @@ index 0adddcde4fd38042c354518351820eb06c417c82..553ae669c7a9303cf848fcc749a25692
 @@ -23 +22,0 @@ Y
 -Z
 `,
+}, {
+	patch: testPatch{
+		message: "",
+		filePatches: []testFilePatch{{
+			from: &testFile{
+				mode: filemode.Regular,
+				path: "onechunk.txt",
+				seed: "A\nB\nC\n",
+			},
+			to: &testFile{
+				mode: filemode.Regular,
+				path: "onechunk.txt",
+				seed: "A\nb\nC\n",
+			},
+
+			chunks: []testChunk{{
+				content: "A\n",
+				op:      Equal,
+			}, {
+				content: "B\n",
+				op:      Delete,
+			}, {
+				content: "b\n",
+				op:      Add,
+			}, {
+				content: "C\n",
+				op:      Equal,
+			}},
+		}},
+	},
+	desc:    "modified line with context to 0",
+	context: 0,
+	diff: `diff --git a/onechunk.txt b/onechunk.txt
+index b1e67221afe8461efd244b487afca22d46b95eb8..24938456d42b76634c499d30bb6db8c59df815ec 100644
+--- a/onechunk.txt
++++ b/onechunk.txt
+@@ -2 +2 @@ A
+-B
++b
+`,
 }}
 
 type testPatch struct {
@@ -0,0 +1,213 @@
+package fastimport
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/src-d/go-git.v4/plumbing"
+)
+
+const (
+	blobCommand       = "blob"
+	commitCommand     = "commit"
+	tagCommand        = "tag"
+	resetCommand      = "reset"
+	aliasCommand      = "alias"
+	checkpointCommand = "checkpoint"
+	progressCommand   = "progress"
+	featureCommand    = "feature"
+	optionCommand     = "option"
+	doneCommand       = "done"
+
+	markCmd        = "mark"
+	toCmd          = "to"
+	originalOIDCmd = "original-oid"
+	authorCmd      = "author"
+	committerCmd   = "committer"
+	taggerCmd      = "tagger"
+	encodingCmd    = "encoding"
+	dataCmd        = "data"
+	fromCmd        = "from"
+	mergeCmd       = "merge"
+
+	fileModifyCmd = "M"
+	fileDeleteCmd = "D"
+	fileCopyCmd   = "C"
+	fileRenameCmd = "R"
+	noteModifyCmd = "N"
+	deleteAllCmd  = "deleteall"
+
+	inlineDataRef = "inline"
+	markPrefix    = ":"
+	delimPrefix   = "<<"
+
+	doneFeature = "done"
+)
+
+// Marks maps the marks used in a stream to the hashes of the objects they
+// identify. It is the in-memory representation of the files written and read
+// by the `--export-marks` and `--import-marks` options of git fast-import.
+type Marks map[int]plumbing.Hash
+
+// Decode reads a marks file, with one `:<mark> <hash>` pair per line, adding
+// its content to m.
+func (m Marks) Decode(r io.Reader) error {
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if line == "" {
+			continue
+		}
+
+		parts := strings.SplitN(line, " ", 2)
+		if len(parts) != 2 || !strings.HasPrefix(parts[0], markPrefix) {
+			return fmt.Errorf("malformed mark line %q", line)
+		}
+
+		mark, err := strconv.Atoi(parts[0][1:])
+		if err != nil {
+			return fmt.Errorf("malformed mark line %q", line)
+		}
+
+		m[mark] = plumbing.NewHash(parts[1])
+	}
+
+	return s.Err()
+}
+
+// Encode writes m as a marks file, sorted by mark.
+func (m Marks) Encode(w io.Writer) error {
+	marks := make([]int, 0, len(m))
+	for mark := range m {
+		marks = append(marks, mark)
+	}
+
+	sort.Ints(marks)
+	for _, mark := range marks {
+		if _, err := fmt.Fprintf(w, ":%d %s\n", mark, m[mark]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// quotePath quotes p using the C-style quoting understood by fast-import, if
+// the path contains any character that would make the stream ambiguous.
+func quotePath(p string) string {
+	if !needsQuoting(p) {
+		return p
+	}
+
+	var buf bytes.Buffer
+	buf.WriteByte('"')
+	for i := 0; i < len(p); i++ {
+		c := p[i]
+		switch c {
+		case '"', '\\':
+			buf.WriteByte('\\')
+			buf.WriteByte(c)
+		case '\n':
+			buf.WriteString(`\n`)
+		case '\t':
+			buf.WriteString(`\t`)
+		default:
+			if c < 0x20 || c == 0x7f {
+				fmt.Fprintf(&buf, "\\%03o", c)
+				continue
+			}
+
+			buf.WriteByte(c)
+		}
+	}
+
+	buf.WriteByte('"')
+	return buf.String()
+}
+
+func needsQuoting(p string) bool {
+	if strings.HasPrefix(p, `"`) {
+		return true
+	}
+
+	for i := 0; i < len(p); i++ {
+		c := p[i]
+		if c < 0x20 || c == 0x7f || c == '"' || c == '\\' || c == ' ' {
+			return true
+		}
+	}
+
+	return false
+}
+
+// parsePath reads a path from the beginning of s, returning the path and the
+// remaining of s. If the path is not quoted and last is false, the path ends
+// at the first space, otherwise it takes the rest of the line.
+func parsePath(s string, last bool) (path, rest string, err error) {
+	if !strings.HasPrefix(s, `"`) {
+		if last {
+			return s, "", nil
+		}
+
+		i := strings.IndexByte(s, ' ')
+		if i == -1 {
+			return s, "", nil
+		}
+
+		return s[:i], s[i+1:], nil
+	}
+
+	var buf bytes.Buffer
+	for i := 1; i < len(s); i++ {
+		c := s[i]
+		switch c {
+		case '"':
+			return buf.String(), strings.TrimPrefix(s[i+1:], " "), nil
+		case '\\':
+			i++
+			if i >= len(s) {
+				return "", "", fmt.Errorf("malformed quoted path %q", s)
+			}
+
+			switch s[i] {
+			case 'a':
+				buf.WriteByte('\a')
+			case 'b':
+				buf.WriteByte('\b')
+			case 'f':
+				buf.WriteByte('\f')
+			case 'n':
+				buf.WriteByte('\n')
+			case 'r':
+				buf.WriteByte('\r')
+			case 't':
+				buf.WriteByte('\t')
+			case 'v':
+				buf.WriteByte('\v')
+			case '0', '1', '2', '3':
+				if i+3 > len(s) {
+					return "", "", fmt.Errorf("malformed quoted path %q", s)
+				}
+
+				n, err := strconv.ParseUint(s[i:i+3], 8, 8)
+				if err != nil {
+					return "", "", fmt.Errorf("malformed quoted path %q", s)
+				}
+
+				buf.WriteByte(byte(n))
+				i += 2
+			default:
+				buf.WriteByte(s[i])
+			}
+		default:
+			buf.WriteByte(c)
+		}
+	}
+
+	return "", "", fmt.Errorf("malformed quoted path %q", s)
+}
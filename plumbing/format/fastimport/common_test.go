@@ -0,0 +1,67 @@
+package fastimport
+
+import (
+	"bytes"
+	"testing"
+
+	"gopkg.in/src-d/go-git.v4/plumbing"
+
+	. "gopkg.in/check.v1"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type CommonSuite struct{}
+
+var _ = Suite(&CommonSuite{})
+
+func (s *CommonSuite) TestMarksEncodeDecode(c *C) {
+	m := Marks{
+		2:  plumbing.NewHash("6ecf0ef2c2dffb796033e5a02219af86ec6584e5"),
+		1:  plumbing.NewHash("a5b8b09e2f8fcb0bb99d3ccb0958157b40890d69"),
+		10: plumbing.NewHash("b8e471f58bcbca63b07bda20e428190409c2db47"),
+	}
+
+	buf := bytes.NewBuffer(nil)
+	c.Assert(m.Encode(buf), IsNil)
+	c.Assert(buf.String(), Equals, ""+
+		":1 a5b8b09e2f8fcb0bb99d3ccb0958157b40890d69\n"+
+		":2 6ecf0ef2c2dffb796033e5a02219af86ec6584e5\n"+
+		":10 b8e471f58bcbca63b07bda20e428190409c2db47\n",
+	)
+
+	decoded := make(Marks)
+	c.Assert(decoded.Decode(buf), IsNil)
+	c.Assert(decoded, DeepEquals, m)
+}
+
+func (s *CommonSuite) TestMarksDecodeMalformed(c *C) {
+	m := make(Marks)
+	err := m.Decode(bytes.NewBufferString("1 a5b8b09e2f8fcb0bb99d3ccb0958157b40890d69\n"))
+	c.Assert(err, NotNil)
+}
+
+func (s *CommonSuite) TestQuotePath(c *C) {
+	c.Assert(quotePath("foo/bar.go"), Equals, "foo/bar.go")
+	c.Assert(quotePath("foo bar"), Equals, `"foo bar"`)
+	c.Assert(quotePath(`"foo`), Equals, `"\"foo"`)
+	c.Assert(quotePath("foo\nbar\\"), Equals, `"foo\nbar\\"`)
+	c.Assert(quotePath("foo\x01"), Equals, `"foo\001"`)
+}
+
+func (s *CommonSuite) TestParsePath(c *C) {
+	for _, p := range []string{"foo", "foo bar", `"foo`, "foo\nbar\\", "foo\x01", "ñ"} {
+		path, rest, err := parsePath(quotePath(p)+" rest", false)
+		c.Assert(err, IsNil)
+		c.Assert(path, Equals, p)
+		c.Assert(rest, Equals, "rest")
+	}
+
+	path, rest, err := parsePath("foo bar", true)
+	c.Assert(err, IsNil)
+	c.Assert(path, Equals, "foo bar")
+	c.Assert(rest, Equals, "")
+
+	_, _, err = parsePath(`"foo`, true)
+	c.Assert(err, NotNil)
+}
@@ -0,0 +1,1092 @@
+package fastimport
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/filemode"
+	"gopkg.in/src-d/go-git.v4/plumbing/format/packfile"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+	"gopkg.in/src-d/go-git.v4/plumbing/protocol/packp/sideband"
+	"gopkg.in/src-d/go-git.v4/plumbing/storer"
+	"gopkg.in/src-d/go-git.v4/utils/ioutil"
+)
+
+// packWindow is the size of the window used to look for deltas when the
+// imported objects are written as a packfile.
+const packWindow = 10
+
+// Storer is the storage where the imported objects and references are written,
+// any storage.Storer satisfies it. If the Storer implements
+// storer.PackfileWriter the objects are written as packfiles, otherwise they
+// are written one by one.
+type Storer interface {
+	storer.EncodedObjectStorer
+	storer.ReferenceStorer
+}
+
+// Error is returned by the Decoder when the stream is malformed.
+type Error struct {
+	// Line is the number of the line where the error was found.
+	Line int
+	// Reason describes the error.
+	Reason string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("fast-import: line %d: %s", e.Line, e.Reason)
+}
+
+// A Decoder reads a fast-import stream and writes the resulting objects and
+// references into a Storer.
+type Decoder struct {
+	// Marks contains the marks defined in the stream, it can be filled before
+	// calling Decode to resolve marks from a previous import, the equivalent
+	// to the `--import-marks` option of git fast-import.
+	Marks Marks
+	// Progress is where the messages of the progress commands are written, if
+	// nil the messages are discarded.
+	Progress sideband.Progress
+
+	r    *bufio.Reader
+	s    Storer
+	line int
+	peek *string
+
+	objects *objectStorage
+	refs    map[plumbing.ReferenceName]plumbing.Hash
+	order   []plumbing.ReferenceName
+
+	// files is the content of the last commit written, so a linear history
+	// doesn't need to read the tree of the parent for every commit.
+	files     map[string]object.TreeEntry
+	filesFrom plumbing.Hash
+
+	requireDone bool
+}
+
+// NewDecoder returns a new decoder that reads from r and writes into s.
+func NewDecoder(r io.Reader, s Storer) *Decoder {
+	return &Decoder{
+		Marks: make(Marks),
+
+		r:       bufio.NewReader(r),
+		s:       s,
+		objects: newObjectStorage(),
+		refs:    make(map[plumbing.ReferenceName]plumbing.Hash),
+	}
+}
+
+// Decode reads the whole stream. Once all the commands are processed, the
+// objects are written to the storer followed by the updated references.
+func (d *Decoder) Decode() error {
+	for {
+		line, err := d.readLine()
+		if err == io.EOF {
+			if d.requireDone {
+				return d.error("stream ended without a done command")
+			}
+
+			break
+		}
+
+		if err != nil {
+			return err
+		}
+
+		cmd, args := splitCommand(line)
+		if cmd == doneCommand {
+			break
+		}
+
+		if err := d.decodeCommand(cmd, args); err != nil {
+			return err
+		}
+	}
+
+	return d.checkpoint()
+}
+
+func (d *Decoder) decodeCommand(cmd, args string) error {
+	switch cmd {
+	case "":
+		return nil
+	case blobCommand:
+		return d.decodeBlob()
+	case commitCommand:
+		return d.decodeCommit(plumbing.ReferenceName(args))
+	case tagCommand:
+		return d.decodeTag(args)
+	case resetCommand:
+		return d.decodeReset(plumbing.ReferenceName(args))
+	case aliasCommand:
+		return d.decodeAlias()
+	case checkpointCommand:
+		return d.checkpoint()
+	case progressCommand:
+		if d.Progress != nil {
+			_, err := fmt.Fprintln(d.Progress, args)
+			return err
+		}
+
+		return nil
+	case featureCommand:
+		if args == doneFeature {
+			d.requireDone = true
+		}
+
+		return nil
+	case optionCommand:
+		return nil
+	default:
+		if strings.HasPrefix(cmd, "#") {
+			return nil
+		}
+
+		return d.error("unsupported command %q", cmd)
+	}
+}
+
+func (d *Decoder) decodeBlob() error {
+	mark, err := d.readMark()
+	if err != nil {
+		return err
+	}
+
+	d.skipCommand(originalOIDCmd)
+
+	data, err := d.readData()
+	if err != nil {
+		return err
+	}
+
+	h, err := d.writeBlob(data)
+	if err != nil {
+		return err
+	}
+
+	d.setMark(mark, h)
+	return nil
+}
+
+func (d *Decoder) decodeCommit(name plumbing.ReferenceName) error {
+	if name == "" {
+		return d.error("commit without reference")
+	}
+
+	mark, err := d.readMark()
+	if err != nil {
+		return err
+	}
+
+	d.skipCommand(originalOIDCmd)
+
+	c := &object.Commit{}
+	author, hasAuthor, err := d.readSignature(authorCmd)
+	if err != nil {
+		return err
+	}
+
+	committer, hasCommitter, err := d.readSignature(committerCmd)
+	if err != nil {
+		return err
+	}
+
+	if !hasCommitter {
+		return d.error("commit without committer")
+	}
+
+	c.Committer = committer
+	c.Author = committer
+	if hasAuthor {
+		c.Author = author
+	}
+
+	c.Encoding, _, err = d.readOptionalCommand(encodingCmd)
+	if err != nil {
+		return err
+	}
+
+	msg, err := d.readData()
+	if err != nil {
+		return err
+	}
+
+	c.Message = string(msg)
+
+	from, hasFrom, err := d.readCommitish(fromCmd)
+	if err != nil {
+		return err
+	}
+
+	if !hasFrom {
+		from, err = d.currentTip(name)
+		if err != nil {
+			return err
+		}
+	}
+
+	if !from.IsZero() {
+		c.ParentHashes = append(c.ParentHashes, from)
+	}
+
+	for {
+		merge, ok, err := d.readCommitish(mergeCmd)
+		if err != nil {
+			return err
+		}
+
+		if !ok {
+			break
+		}
+
+		c.ParentHashes = append(c.ParentHashes, merge)
+	}
+
+	files, err := d.filesFromCommit(from)
+	if err != nil {
+		return err
+	}
+
+	if err := d.applyFileCommands(files); err != nil {
+		return err
+	}
+
+	if c.TreeHash, err = d.writeTree(files); err != nil {
+		return err
+	}
+
+	h, err := d.writeObject(c)
+	if err != nil {
+		return err
+	}
+
+	d.files, d.filesFrom = files, h
+	d.setMark(mark, h)
+	d.setReference(name, h)
+	return nil
+}
+
+func (d *Decoder) decodeTag(name string) error {
+	if name == "" {
+		return d.error("tag without name")
+	}
+
+	mark, err := d.readMark()
+	if err != nil {
+		return err
+	}
+
+	target, ok, err := d.readCommitish(fromCmd)
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		return d.error("tag %q without from command", name)
+	}
+
+	d.skipCommand(originalOIDCmd)
+
+	tagger, _, err := d.readSignature(taggerCmd)
+	if err != nil {
+		return err
+	}
+
+	msg, err := d.readData()
+	if err != nil {
+		return err
+	}
+
+	targetType, err := d.objectType(target)
+	if err != nil {
+		return err
+	}
+
+	t := &object.Tag{
+		Name:       name,
+		Tagger:     tagger,
+		Message:    string(msg),
+		TargetType: targetType,
+		Target:     target,
+	}
+
+	h, err := d.writeObject(t)
+	if err != nil {
+		return err
+	}
+
+	d.setMark(mark, h)
+	d.setReference(plumbing.ReferenceName(path.Join("refs", "tags", name)), h)
+	return nil
+}
+
+func (d *Decoder) decodeReset(name plumbing.ReferenceName) error {
+	if name == "" {
+		return d.error("reset without reference")
+	}
+
+	from, _, err := d.readCommitish(fromCmd)
+	if err != nil {
+		return err
+	}
+
+	d.setReference(name, from)
+	return nil
+}
+
+func (d *Decoder) decodeAlias() error {
+	mark, err := d.readMark()
+	if err != nil {
+		return err
+	}
+
+	to, ok, err := d.readCommitish(toCmd)
+	if err != nil {
+		return err
+	}
+
+	if mark == 0 || !ok {
+		return d.error("alias requires mark and to commands")
+	}
+
+	d.setMark(mark, to)
+	return nil
+}
+
+// filesFromCommit returns the files of the tree of the commit h, keyed by
+// their full path.
+func (d *Decoder) filesFromCommit(h plumbing.Hash) (map[string]object.TreeEntry, error) {
+	files := make(map[string]object.TreeEntry)
+	if h.IsZero() {
+		return files, nil
+	}
+
+	if h == d.filesFrom && d.files != nil {
+		// the previous commit is not modified, it will be discarded on the
+		// next commit
+		files, d.files, d.filesFrom = d.files, nil, plumbing.ZeroHash
+		return files, nil
+	}
+
+	c, err := object.GetCommit(d.objects.with(d.s), h)
+	if err != nil {
+		return nil, err
+	}
+
+	t, err := c.Tree()
+	if err != nil {
+		return nil, err
+	}
+
+	return files, d.addTreeFiles(files, "", t.Hash)
+}
+
+func (d *Decoder) addTreeFiles(files map[string]object.TreeEntry, base string, h plumbing.Hash) error {
+	t, err := object.GetTree(d.objects.with(d.s), h)
+	if err != nil {
+		return err
+	}
+
+	w := object.NewTreeWalker(t, true, nil)
+	defer w.Close()
+
+	for {
+		name, e, err := w.Next()
+		if err == io.EOF {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if e.Mode == filemode.Dir {
+			continue
+		}
+
+		files[path.Join(base, name)] = e
+	}
+}
+
+func (d *Decoder) applyFileCommands(files map[string]object.TreeEntry) error {
+	for {
+		line, err := d.readLine()
+		if err == io.EOF {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		cmd, args := splitCommand(line)
+		switch cmd {
+		case fileModifyCmd:
+			err = d.fileModify(files, args)
+		case fileDeleteCmd:
+			err = d.fileDelete(files, args)
+		case fileCopyCmd, fileRenameCmd:
+			err = d.fileCopy(files, args, cmd == fileRenameCmd)
+		case deleteAllCmd:
+			for name := range files {
+				delete(files, name)
+			}
+		case noteModifyCmd:
+			err = d.error("notemodify is not supported")
+		default:
+			d.unreadLine(line)
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (d *Decoder) fileModify(files map[string]object.TreeEntry, args string) error {
+	parts := strings.SplitN(args, " ", 3)
+	if len(parts) != 3 {
+		return d.error("malformed filemodify %q", args)
+	}
+
+	mode, err := parseMode(parts[0])
+	if err != nil {
+		return d.error("invalid mode %q", parts[0])
+	}
+
+	name, _, err := parsePath(parts[2], true)
+	if err != nil {
+		return d.error("%s", err)
+	}
+
+	var h plumbing.Hash
+	if parts[1] == inlineDataRef {
+		data, err := d.readData()
+		if err != nil {
+			return err
+		}
+
+		if h, err = d.writeBlob(data); err != nil {
+			return err
+		}
+	} else if h, err = d.resolveDataRef(parts[1]); err != nil {
+		return err
+	}
+
+	removeFiles(files, name)
+	if mode == filemode.Dir {
+		return d.addTreeFiles(files, name, h)
+	}
+
+	files[name] = object.TreeEntry{Name: path.Base(name), Mode: mode, Hash: h}
+	return nil
+}
+
+func (d *Decoder) fileDelete(files map[string]object.TreeEntry, args string) error {
+	name, _, err := parsePath(args, true)
+	if err != nil {
+		return d.error("%s", err)
+	}
+
+	removeFiles(files, name)
+	return nil
+}
+
+func (d *Decoder) fileCopy(files map[string]object.TreeEntry, args string, rename bool) error {
+	src, rest, err := parsePath(args, false)
+	if err != nil {
+		return d.error("%s", err)
+	}
+
+	dst, _, err := parsePath(rest, true)
+	if err != nil {
+		return d.error("%s", err)
+	}
+
+	copied := make(map[string]object.TreeEntry)
+	for name, e := range files {
+		switch {
+		case name == src:
+			copied[dst] = e
+		case strings.HasPrefix(name, src+"/"):
+			copied[path.Join(dst, name[len(src):])] = e
+		}
+	}
+
+	if len(copied) == 0 {
+		return d.error("path %q not found", src)
+	}
+
+	if rename {
+		removeFiles(files, src)
+	}
+
+	removeFiles(files, dst)
+	for name, e := range copied {
+		e.Name = path.Base(name)
+		files[name] = e
+	}
+
+	return nil
+}
+
+// removeFiles deletes the file name, or all the files under it if is a
+// directory, and any file that is one of its parent directories.
+func removeFiles(files map[string]object.TreeEntry, name string) {
+	if name == "" {
+		for n := range files {
+			delete(files, n)
+		}
+
+		return
+	}
+
+	prefix := name + "/"
+	for n := range files {
+		if n == name || strings.HasPrefix(n, prefix) || strings.HasPrefix(name, n+"/") {
+			delete(files, n)
+		}
+	}
+}
+
+func parseMode(s string) (filemode.FileMode, error) {
+	switch s {
+	case "644":
+		return filemode.Regular, nil
+	case "755":
+		return filemode.Executable, nil
+	}
+
+	m, err := filemode.New(s)
+	if err != nil {
+		return filemode.Empty, err
+	}
+
+	switch m {
+	case filemode.Regular, filemode.Deprecated, filemode.Executable,
+		filemode.Symlink, filemode.Submodule, filemode.Dir:
+		return m, nil
+	}
+
+	return filemode.Empty, fmt.Errorf("invalid mode %q", s)
+}
+
+// writeTree writes the trees representing files, returning the hash of the
+// root tree.
+func (d *Decoder) writeTree(files map[string]object.TreeEntry) (plumbing.Hash, error) {
+	trees := map[string]*object.Tree{"": {}}
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	for _, name := range names {
+		dir := path.Dir(name)
+		if dir == "." {
+			dir = ""
+		}
+
+		ensureTree(trees, dir)
+		e := files[name]
+		e.Name = path.Base(name)
+		trees[dir].Entries = append(trees[dir].Entries, e)
+	}
+
+	return d.writeTreeRecursive(trees, "")
+}
+
+func ensureTree(trees map[string]*object.Tree, dir string) {
+	if _, ok := trees[dir]; ok {
+		return
+	}
+
+	parent := path.Dir(dir)
+	if parent == "." {
+		parent = ""
+	}
+
+	ensureTree(trees, parent)
+	trees[dir] = &object.Tree{}
+	trees[parent].Entries = append(trees[parent].Entries, object.TreeEntry{
+		Name: path.Base(dir),
+		Mode: filemode.Dir,
+	})
+}
+
+func (d *Decoder) writeTreeRecursive(trees map[string]*object.Tree, dir string) (plumbing.Hash, error) {
+	t := trees[dir]
+	for i, e := range t.Entries {
+		if e.Mode != filemode.Dir {
+			continue
+		}
+
+		h, err := d.writeTreeRecursive(trees, path.Join(dir, e.Name))
+		if err != nil {
+			return plumbing.ZeroHash, err
+		}
+
+		t.Entries[i].Hash = h
+	}
+
+	sort.Sort(treeEntries(t.Entries))
+	return d.writeObject(t)
+}
+
+// treeEntries sorts the entries of a tree in the order required by git, where
+// the directories are compared as if they had a trailing slash.
+type treeEntries []object.TreeEntry
+
+func (es treeEntries) sortName(e object.TreeEntry) string {
+	if e.Mode == filemode.Dir {
+		return e.Name + "/"
+	}
+
+	return e.Name
+}
+
+func (es treeEntries) Len() int           { return len(es) }
+func (es treeEntries) Less(i, j int) bool { return es.sortName(es[i]) < es.sortName(es[j]) }
+func (es treeEntries) Swap(i, j int)      { es[i], es[j] = es[j], es[i] }
+
+func (d *Decoder) writeBlob(data []byte) (plumbing.Hash, error) {
+	obj := &plumbing.MemoryObject{}
+	obj.SetType(plumbing.BlobObject)
+	if _, err := obj.Write(data); err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	return d.addObject(obj)
+}
+
+func (d *Decoder) writeObject(o object.Object) (plumbing.Hash, error) {
+	obj := &plumbing.MemoryObject{}
+	if err := o.Encode(obj); err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	return d.addObject(obj)
+}
+
+func (d *Decoder) addObject(obj plumbing.EncodedObject) (plumbing.Hash, error) {
+	h := obj.Hash()
+	if _, ok := d.objects.objects[h]; ok {
+		return h, nil
+	}
+
+	if err := d.s.HasEncodedObject(h); err == nil {
+		return h, nil
+	}
+
+	return d.objects.SetEncodedObject(obj)
+}
+
+func (d *Decoder) objectType(h plumbing.Hash) (plumbing.ObjectType, error) {
+	obj, err := d.objects.with(d.s).EncodedObject(plumbing.AnyObject, h)
+	if err != nil {
+		return plumbing.InvalidObject, err
+	}
+
+	return obj.Type(), nil
+}
+
+// checkpoint writes all the pending objects and the updated references into
+// the storer.
+func (d *Decoder) checkpoint() error {
+	if err := d.writeObjects(); err != nil {
+		return err
+	}
+
+	for _, name := range d.order {
+		h := d.refs[name]
+		if h.IsZero() {
+			continue
+		}
+
+		if err := d.s.SetReference(plumbing.NewHashReference(name, h)); err != nil {
+			return err
+		}
+	}
+
+	d.order = nil
+	return nil
+}
+
+func (d *Decoder) writeObjects() error {
+	if len(d.objects.order) == 0 {
+		return nil
+	}
+
+	var err error
+	if pw, ok := d.s.(storer.PackfileWriter); ok {
+		err = d.writePackfile(pw)
+	} else {
+		for _, h := range d.objects.order {
+			if _, err = d.s.SetEncodedObject(d.objects.objects[h]); err != nil {
+				break
+			}
+		}
+	}
+
+	if err != nil {
+		return err
+	}
+
+	d.objects = newObjectStorage()
+	return nil
+}
+
+func (d *Decoder) writePackfile(pw storer.PackfileWriter) (err error) {
+	w, err := pw.PackfileWriter()
+	if err != nil {
+		return err
+	}
+
+	defer ioutil.CheckClose(w, &err)
+
+	e := packfile.NewEncoder(w, d.objects, false)
+	_, err = e.Encode(d.objects.order, packWindow)
+	return err
+}
+
+func (d *Decoder) setMark(mark int, h plumbing.Hash) {
+	if mark != 0 {
+		d.Marks[mark] = h
+	}
+}
+
+func (d *Decoder) setReference(name plumbing.ReferenceName, h plumbing.Hash) {
+	d.refs[name] = h
+	for _, n := range d.order {
+		if n == name {
+			return
+		}
+	}
+
+	d.order = append(d.order, name)
+}
+
+// currentTip returns the commit a branch is pointing to, first looking at the
+// branches updated by the stream and then at the storer.
+func (d *Decoder) currentTip(name plumbing.ReferenceName) (plumbing.Hash, error) {
+	if h, ok := d.refs[name]; ok {
+		return h, nil
+	}
+
+	ref, err := storer.ResolveReference(d.s, name)
+	if err == plumbing.ErrReferenceNotFound {
+		return plumbing.ZeroHash, nil
+	}
+
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	return ref.Hash(), nil
+}
+
+func (d *Decoder) resolveDataRef(ref string) (plumbing.Hash, error) {
+	if strings.HasPrefix(ref, markPrefix) {
+		mark, err := strconv.Atoi(ref[1:])
+		if err != nil {
+			return plumbing.ZeroHash, d.error("invalid mark %q", ref)
+		}
+
+		h, ok := d.Marks[mark]
+		if !ok {
+			return plumbing.ZeroHash, d.error("mark %q not declared", ref)
+		}
+
+		return h, nil
+	}
+
+	if len(ref) != 40 {
+		return plumbing.ZeroHash, d.error("invalid data reference %q", ref)
+	}
+
+	return plumbing.NewHash(ref), nil
+}
+
+// resolveCommitish resolves a mark, a hash or the name of a reference.
+func (d *Decoder) resolveCommitish(s string) (plumbing.Hash, error) {
+	s = strings.TrimSuffix(s, "^0")
+	if strings.HasPrefix(s, markPrefix) || plumbing.NewHash(s).String() == s {
+		return d.resolveDataRef(s)
+	}
+
+	h, err := d.currentTip(plumbing.ReferenceName(s))
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	if h.IsZero() {
+		return plumbing.ZeroHash, d.error("reference %q not found", s)
+	}
+
+	return h, nil
+}
+
+func (d *Decoder) readMark() (int, error) {
+	args, ok, err := d.readOptionalCommand(markCmd)
+	if err != nil || !ok {
+		return 0, err
+	}
+
+	if !strings.HasPrefix(args, markPrefix) {
+		return 0, d.error("invalid mark %q", args)
+	}
+
+	mark, err := strconv.Atoi(args[1:])
+	if err != nil || mark <= 0 {
+		return 0, d.error("invalid mark %q", args)
+	}
+
+	return mark, nil
+}
+
+func (d *Decoder) readSignature(cmd string) (object.Signature, bool, error) {
+	var sig object.Signature
+	args, ok, err := d.readOptionalCommand(cmd)
+	if err != nil || !ok {
+		return sig, ok, err
+	}
+
+	sig.Decode([]byte(args))
+	return sig, true, nil
+}
+
+func (d *Decoder) readCommitish(cmd string) (plumbing.Hash, bool, error) {
+	args, ok, err := d.readOptionalCommand(cmd)
+	if err != nil || !ok {
+		return plumbing.ZeroHash, ok, err
+	}
+
+	h, err := d.resolveCommitish(args)
+	return h, true, err
+}
+
+// readOptionalCommand reads the next line if it is the given command,
+// returning its arguments.
+func (d *Decoder) readOptionalCommand(cmd string) (string, bool, error) {
+	line, err := d.readLine()
+	if err == io.EOF {
+		return "", false, nil
+	}
+
+	if err != nil {
+		return "", false, err
+	}
+
+	c, args := splitCommand(line)
+	if c != cmd {
+		d.unreadLine(line)
+		return "", false, nil
+	}
+
+	return args, true, nil
+}
+
+func (d *Decoder) skipCommand(cmd string) {
+	_, _, _ = d.readOptionalCommand(cmd)
+}
+
+// readData reads a data command, in both the exact byte count and the
+// delimited formats.
+func (d *Decoder) readData() ([]byte, error) {
+	args, ok, err := d.readOptionalCommand(dataCmd)
+	if err != nil {
+		return nil, err
+	}
+
+	if !ok {
+		return nil, d.error("expected data command")
+	}
+
+	if strings.HasPrefix(args, delimPrefix) {
+		return d.readDelimitedData(args[len(delimPrefix):])
+	}
+
+	n, err := strconv.ParseInt(args, 10, 64)
+	if err != nil || n < 0 {
+		return nil, d.error("invalid data length %q", args)
+	}
+
+	data := make([]byte, n)
+	if _, err := io.ReadFull(d.r, data); err != nil {
+		return nil, d.error("unexpected end of data")
+	}
+
+	d.line += bytes.Count(data, []byte("\n"))
+	if b, err := d.r.Peek(1); err == nil && b[0] == '\n' {
+		_, _ = d.r.ReadByte()
+		d.line++
+	}
+
+	return data, nil
+}
+
+func (d *Decoder) readDelimitedData(delim string) ([]byte, error) {
+	var buf bytes.Buffer
+	for {
+		line, err := d.readLine()
+		if err == io.EOF {
+			return nil, d.error("delimiter %q not found", delim)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		if line == delim {
+			return buf.Bytes(), nil
+		}
+
+		buf.WriteString(line)
+		buf.WriteByte('\n')
+	}
+}
+
+func (d *Decoder) readLine() (string, error) {
+	if d.peek != nil {
+		line := *d.peek
+		d.peek = nil
+		return line, nil
+	}
+
+	line, err := d.r.ReadString('\n')
+	if err == io.EOF && line != "" {
+		err = nil
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	d.line++
+	return strings.TrimSuffix(line, "\n"), nil
+}
+
+func (d *Decoder) unreadLine(line string) {
+	d.peek = &line
+}
+
+func (d *Decoder) error(format string, args ...interface{}) error {
+	return &Error{Line: d.line, Reason: fmt.Sprintf(format, args...)}
+}
+
+func splitCommand(line string) (cmd, args string) {
+	parts := strings.SplitN(line, " ", 2)
+	if len(parts) == 2 {
+		return parts[0], parts[1]
+	}
+
+	return parts[0], ""
+}
+
+// objectStorage holds the objects created by the stream until they are written
+// to the storer.
+type objectStorage struct {
+	objects map[plumbing.Hash]plumbing.EncodedObject
+	order   []plumbing.Hash
+}
+
+func newObjectStorage() *objectStorage {
+	return &objectStorage{objects: make(map[plumbing.Hash]plumbing.EncodedObject)}
+}
+
+func (s *objectStorage) NewEncodedObject() plumbing.EncodedObject {
+	return &plumbing.MemoryObject{}
+}
+
+func (s *objectStorage) SetEncodedObject(obj plumbing.EncodedObject) (plumbing.Hash, error) {
+	h := obj.Hash()
+	if _, ok := s.objects[h]; !ok {
+		s.objects[h] = obj
+		s.order = append(s.order, h)
+	}
+
+	return h, nil
+}
+
+func (s *objectStorage) EncodedObject(t plumbing.ObjectType, h plumbing.Hash) (plumbing.EncodedObject, error) {
+	obj, ok := s.objects[h]
+	if !ok || (t != plumbing.AnyObject && obj.Type() != t) {
+		return nil, plumbing.ErrObjectNotFound
+	}
+
+	return obj, nil
+}
+
+func (s *objectStorage) IterEncodedObjects(t plumbing.ObjectType) (storer.EncodedObjectIter, error) {
+	var series []plumbing.EncodedObject
+	for _, h := range s.order {
+		if obj := s.objects[h]; t == plumbing.AnyObject || obj.Type() == t {
+			series = append(series, obj)
+		}
+	}
+
+	return storer.NewEncodedObjectSliceIter(series), nil
+}
+
+func (s *objectStorage) HasEncodedObject(h plumbing.Hash) error {
+	if _, ok := s.objects[h]; !ok {
+		return plumbing.ErrObjectNotFound
+	}
+
+	return nil
+}
+
+func (s *objectStorage) EncodedObjectSize(h plumbing.Hash) (int64, error) {
+	obj, ok := s.objects[h]
+	if !ok {
+		return 0, plumbing.ErrObjectNotFound
+	}
+
+	return obj.Size(), nil
+}
+
+// with returns a storer that looks up first the pending objects and then the
+// objects already in fallback.
+func (s *objectStorage) with(fallback storer.EncodedObjectStorer) storer.EncodedObjectStorer {
+	return &layeredStorage{objectStorage: s, fallback: fallback}
+}
+
+type layeredStorage struct {
+	*objectStorage
+	fallback storer.EncodedObjectStorer
+}
+
+func (s *layeredStorage) EncodedObject(t plumbing.ObjectType, h plumbing.Hash) (plumbing.EncodedObject, error) {
+	obj, err := s.objectStorage.EncodedObject(t, h)
+	if err == plumbing.ErrObjectNotFound {
+		return s.fallback.EncodedObject(t, h)
+	}
+
+	return obj, err
+}
+
+func (s *layeredStorage) HasEncodedObject(h plumbing.Hash) error {
+	if err := s.objectStorage.HasEncodedObject(h); err == nil {
+		return nil
+	}
+
+	return s.fallback.HasEncodedObject(h)
+}
+
+func (s *layeredStorage) EncodedObjectSize(h plumbing.Hash) (int64, error) {
+	size, err := s.objectStorage.EncodedObjectSize(h)
+	if err == plumbing.ErrObjectNotFound {
+		return s.fallback.EncodedObjectSize(h)
+	}
+
+	return size, err
+}
@@ -0,0 +1,245 @@
+package fastimport
+
+import (
+	"bytes"
+	"sort"
+	"strings"
+
+	"gopkg.in/src-d/go-billy.v4/memfs"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/cache"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+	"gopkg.in/src-d/go-git.v4/storage/filesystem"
+	"gopkg.in/src-d/go-git.v4/storage/memory"
+
+	. "gopkg.in/check.v1"
+)
+
+type DecoderSuite struct{}
+
+var _ = Suite(&DecoderSuite{})
+
+const stream = `feature done
+blob
+mark :1
+data 6
+hello
+
+blob
+mark :2
+data <<EOF
+world
+EOF
+
+commit refs/heads/master
+mark :3
+author John Doe <john@doe.org> 1257894000 +0100
+committer Jane Doe <jane@doe.org> 1257894060 +0100
+data 8
+initial
+M 100644 :1 README
+M 755 :2 "bin/run me"
+M 120000 inline link
+data 6
+README
+
+# a comment
+progress first commit imported
+commit refs/heads/master
+mark :4
+committer Jane Doe <jane@doe.org> 1257894120 +0100
+encoding ISO-8859-1
+data 7
+rename
+R README docs/README
+C "bin/run me" bin/copy
+
+reset refs/heads/branch
+from :3
+
+commit refs/heads/branch
+mark :5
+committer Jane Doe <jane@doe.org> 1257894180 +0100
+data 10
+deleteall
+deleteall
+M 644 :1 other
+
+commit refs/heads/master
+mark :6
+committer Jane Doe <jane@doe.org> 1257894240 +0100
+data 6
+merge
+from :4
+merge refs/heads/branch
+D bin
+
+tag v1.0.0
+from :6
+tagger Jane Doe <jane@doe.org> 1257894300 +0100
+data 8
+release
+done
+`
+
+func (s *DecoderSuite) TestDecode(c *C) {
+	sto := memory.NewStorage()
+	progress := bytes.NewBuffer(nil)
+
+	d := NewDecoder(strings.NewReader(stream), sto)
+	d.Progress = progress
+	c.Assert(d.Decode(), IsNil)
+	c.Assert(progress.String(), Equals, "first commit imported\n")
+	c.Assert(d.Marks, HasLen, 6)
+
+	first, err := object.GetCommit(sto, d.Marks[3])
+	c.Assert(err, IsNil)
+	c.Assert(first.Author.Name, Equals, "John Doe")
+	c.Assert(first.Committer.Name, Equals, "Jane Doe")
+	c.Assert(first.Message, Equals, "initial\n")
+	c.Assert(first.NumParents(), Equals, 0)
+	assertFiles(c, first, "README", "bin/run me", "link")
+
+	file, err := first.File("bin/run me")
+	c.Assert(err, IsNil)
+	c.Assert(file.Mode.String(), Equals, "0100755")
+	content, err := file.Contents()
+	c.Assert(err, IsNil)
+	c.Assert(content, Equals, "world\n")
+
+	second, err := object.GetCommit(sto, d.Marks[4])
+	c.Assert(err, IsNil)
+	c.Assert(second.Author.Name, Equals, "Jane Doe")
+	c.Assert(second.Encoding, Equals, "ISO-8859-1")
+	c.Assert(second.ParentHashes, DeepEquals, []plumbing.Hash{d.Marks[3]})
+	assertFiles(c, second, "bin/copy", "bin/run me", "docs/README", "link")
+
+	branch, err := object.GetCommit(sto, d.Marks[5])
+	c.Assert(err, IsNil)
+	c.Assert(branch.ParentHashes, DeepEquals, []plumbing.Hash{d.Marks[3]})
+	assertFiles(c, branch, "other")
+
+	merge, err := object.GetCommit(sto, d.Marks[6])
+	c.Assert(err, IsNil)
+	c.Assert(merge.ParentHashes, DeepEquals, []plumbing.Hash{d.Marks[4], d.Marks[5]})
+	assertFiles(c, merge, "docs/README", "link")
+
+	ref, err := sto.Reference(plumbing.Master)
+	c.Assert(err, IsNil)
+	c.Assert(ref.Hash(), Equals, d.Marks[6])
+
+	ref, err = sto.Reference("refs/heads/branch")
+	c.Assert(err, IsNil)
+	c.Assert(ref.Hash(), Equals, d.Marks[5])
+
+	ref, err = sto.Reference("refs/tags/v1.0.0")
+	c.Assert(err, IsNil)
+
+	tag, err := object.GetTag(sto, ref.Hash())
+	c.Assert(err, IsNil)
+	c.Assert(tag.Name, Equals, "v1.0.0")
+	c.Assert(tag.Target, Equals, d.Marks[6])
+	c.Assert(tag.TargetType, Equals, plumbing.CommitObject)
+	c.Assert(tag.Message, Equals, "release\n")
+}
+
+func (s *DecoderSuite) TestDecodePackfileWriter(c *C) {
+	sto := filesystem.NewStorage(memfs.New(), cache.NewObjectLRUDefault())
+
+	d := NewDecoder(strings.NewReader(stream), sto)
+	c.Assert(d.Decode(), IsNil)
+
+	ref, err := sto.Reference(plumbing.Master)
+	c.Assert(err, IsNil)
+
+	commit, err := object.GetCommit(sto, ref.Hash())
+	c.Assert(err, IsNil)
+	assertFiles(c, commit, "docs/README", "link")
+
+	packs, err := sto.ObjectPacks()
+	c.Assert(err, IsNil)
+	c.Assert(packs, HasLen, 1)
+}
+
+func (s *DecoderSuite) TestDecodeExistingBranch(c *C) {
+	sto := memory.NewStorage()
+	c.Assert(NewDecoder(strings.NewReader(stream), sto).Decode(), IsNil)
+
+	ref, err := sto.Reference(plumbing.Master)
+	c.Assert(err, IsNil)
+
+	d := NewDecoder(strings.NewReader(`commit refs/heads/master
+committer Jane Doe <jane@doe.org> 1257894360 +0100
+data 5
+next
+M 644 inline NEWS
+data 4
+news
+`), sto)
+	c.Assert(d.Decode(), IsNil)
+
+	next, err := sto.Reference(plumbing.Master)
+	c.Assert(err, IsNil)
+
+	commit, err := object.GetCommit(sto, next.Hash())
+	c.Assert(err, IsNil)
+	c.Assert(commit.ParentHashes, DeepEquals, []plumbing.Hash{ref.Hash()})
+	assertFiles(c, commit, "NEWS", "docs/README", "link")
+}
+
+func (s *DecoderSuite) TestDecodeImportedMarks(c *C) {
+	sto := memory.NewStorage()
+	first := NewDecoder(strings.NewReader(stream), sto)
+	c.Assert(first.Decode(), IsNil)
+
+	d := NewDecoder(strings.NewReader(`alias
+mark :10
+to :6
+
+reset refs/heads/old
+from :10
+`), sto)
+	d.Marks = first.Marks
+	c.Assert(d.Decode(), IsNil)
+	c.Assert(d.Marks[10], Equals, first.Marks[6])
+
+	ref, err := sto.Reference("refs/heads/old")
+	c.Assert(err, IsNil)
+	c.Assert(ref.Hash(), Equals, first.Marks[6])
+}
+
+func (s *DecoderSuite) TestDecodeErrors(c *C) {
+	for _, input := range []string{
+		"foo\n",
+		"feature done\n",
+		"blob\ndata 10\nfoo\n",
+		"blob\ndata <<EOF\nfoo\n",
+		"commit refs/heads/master\ndata 0\n",
+		"commit refs/heads/master\ncommitter foo <foo@bar> 0 +0000\ndata 0\nM 644 :1 foo\n",
+		"commit refs/heads/master\ncommitter foo <foo@bar> 0 +0000\ndata 0\nM 999 inline foo\n",
+		"commit refs/heads/master\ncommitter foo <foo@bar> 0 +0000\ndata 0\nfrom refs/heads/missing\n",
+		"commit refs/heads/master\ncommitter foo <foo@bar> 0 +0000\ndata 0\nR foo bar\n",
+		"tag v1.0.0\ndata 0\n",
+	} {
+		err := NewDecoder(strings.NewReader(input), memory.NewStorage()).Decode()
+		c.Assert(err, NotNil, Commentf("input: %q", input))
+
+		_, ok := err.(*Error)
+		c.Assert(ok, Equals, true, Commentf("input: %q", input))
+	}
+}
+
+func assertFiles(c *C, commit *object.Commit, expected ...string) {
+	files, err := commit.Files()
+	c.Assert(err, IsNil)
+
+	var names []string
+	err = files.ForEach(func(f *object.File) error {
+		names = append(names, f.Name)
+		return nil
+	})
+	c.Assert(err, IsNil)
+
+	sort.Strings(names)
+	c.Assert(names, DeepEquals, expected)
+}
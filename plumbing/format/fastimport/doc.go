@@ -0,0 +1,27 @@
+// Package fastimport implements encoding and decoding of the stream format
+// used by git fast-export and git fast-import.
+//
+// The stream is a sequence of commands, each of them describing a blob, a
+// commit, an annotated tag or the update of a reference. It is mainly used to
+// migrate repositories from other version control systems and to rewrite
+// history in bulk:
+//
+//	blob
+//	mark :1
+//	data 6
+//	hello
+//
+//	commit refs/heads/master
+//	mark :2
+//	author John Doe <john@doe.org> 1257894000 +0100
+//	committer John Doe <john@doe.org> 1257894000 +0100
+//	data 15
+//	initial import
+//	M 100644 :1 README
+//
+// The Encoder writes the commits reachable from a reference, in the same way
+// as `git fast-export`, and the Decoder reads a stream writing the resulting
+// objects and references into a storer, in the same way as `git fast-import`.
+//
+// https://git-scm.com/docs/git-fast-import
+package fastimport
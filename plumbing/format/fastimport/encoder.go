@@ -0,0 +1,419 @@
+package fastimport
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/filemode"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+	"gopkg.in/src-d/go-git.v4/plumbing/storer"
+	"gopkg.in/src-d/go-git.v4/utils/merkletrie"
+)
+
+// ErrUnsupportedTagTarget is returned by Encoder.Encode when a tag points to
+// an object that is not a commit.
+var ErrUnsupportedTagTarget = errors.New("only tags pointing to commits can be exported")
+
+// An Encoder writes a fast-export stream to an output stream.
+type Encoder struct {
+	w *bufio.Writer
+	s storer.EncodedObjectStorer
+
+	marks    map[plumbing.Hash]int
+	lastMark int
+}
+
+// NewEncoder returns a new encoder that writes to w the objects read from s.
+func NewEncoder(w io.Writer, s storer.EncodedObjectStorer) *Encoder {
+	return &Encoder{
+		w:     bufio.NewWriter(w),
+		s:     s,
+		marks: make(map[plumbing.Hash]int),
+	}
+}
+
+// UseMarks tells the encoder that the objects in m were already exported by
+// a previous run, so they are referenced by its mark instead of being written
+// again. This is the equivalent to the `--import-marks` option of git
+// fast-export.
+func (e *Encoder) UseMarks(m Marks) {
+	for mark, h := range m {
+		e.marks[h] = mark
+		if mark > e.lastMark {
+			e.lastMark = mark
+		}
+	}
+}
+
+// Marks returns the marks assigned to every exported blob and commit, it can
+// be used to resume an incremental export with UseMarks.
+func (e *Encoder) Marks() Marks {
+	m := make(Marks, len(e.marks))
+	for h, mark := range e.marks {
+		m[mark] = h
+	}
+
+	return m
+}
+
+// Encode writes to the stream the commits reachable from tip that are not
+// reachable from any of the exclude hashes, updating the reference name to
+// tip. It is the equivalent to `git fast-export ^exclude name`.
+//
+// If tip is an annotated tag, the commits are written followed by the tag.
+// When no commit is left after the exclusion, a reset command updating the
+// reference is written.
+func (e *Encoder) Encode(name plumbing.ReferenceName, tip plumbing.Hash, exclude ...plumbing.Hash) error {
+	if err := e.encode(name, tip, exclude); err != nil {
+		return err
+	}
+
+	return e.w.Flush()
+}
+
+func (e *Encoder) encode(name plumbing.ReferenceName, tip plumbing.Hash, exclude []plumbing.Hash) error {
+	obj, err := object.GetObject(e.s, tip)
+	if err != nil {
+		return err
+	}
+
+	var tag *object.Tag
+	var commit *object.Commit
+	switch o := obj.(type) {
+	case *object.Commit:
+		commit = o
+	case *object.Tag:
+		if o.TargetType != plumbing.CommitObject {
+			return ErrUnsupportedTagTarget
+		}
+
+		tag = o
+		if commit, err = o.Commit(); err != nil {
+			return err
+		}
+	default:
+		return ErrUnsupportedTagTarget
+	}
+
+	commits, err := e.commits(commit, exclude)
+	if err != nil {
+		return err
+	}
+
+	if len(commits) == 0 && tag == nil {
+		return e.encodeReset(name, commit.Hash)
+	}
+
+	for _, c := range commits {
+		if err := e.encodeCommit(name, c); err != nil {
+			return err
+		}
+	}
+
+	if tag != nil {
+		return e.encodeTag(tag)
+	}
+
+	return nil
+}
+
+// commits returns the commits to be exported in topological order, parents
+// before children.
+func (e *Encoder) commits(tip *object.Commit, exclude []plumbing.Hash) ([]*object.Commit, error) {
+	seen := make(map[plumbing.Hash]bool)
+	for h := range e.marks {
+		seen[h] = true
+	}
+
+	for _, h := range exclude {
+		c, err := e.resolveCommit(h)
+		if err != nil {
+			return nil, err
+		}
+
+		iter := object.NewCommitPreorderIter(c, seen, nil)
+		err = iter.ForEach(func(c *object.Commit) error {
+			seen[c.Hash] = true
+			return nil
+		})
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if seen[tip.Hash] {
+		return nil, nil
+	}
+
+	pending := make(map[plumbing.Hash]*object.Commit)
+	iter := object.NewCommitPreorderIter(tip, seen, nil)
+	err := iter.ForEach(func(c *object.Commit) error {
+		pending[c.Hash] = c
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return sortTopologically(tip, pending), nil
+}
+
+func (e *Encoder) resolveCommit(h plumbing.Hash) (*object.Commit, error) {
+	obj, err := object.GetObject(e.s, h)
+	if err != nil {
+		return nil, err
+	}
+
+	switch o := obj.(type) {
+	case *object.Commit:
+		return o, nil
+	case *object.Tag:
+		return o.Commit()
+	default:
+		return nil, object.ErrUnsupportedObject
+	}
+}
+
+// sortTopologically returns the commits in pending reachable from tip, in a
+// order where every commit is preceded by its parents.
+func sortTopologically(tip *object.Commit, pending map[plumbing.Hash]*object.Commit) []*object.Commit {
+	type frame struct {
+		c    *object.Commit
+		next int
+	}
+
+	var sorted []*object.Commit
+	visited := map[plumbing.Hash]bool{tip.Hash: true}
+	stack := []*frame{{c: tip}}
+	for len(stack) > 0 {
+		f := stack[len(stack)-1]
+		if f.next < len(f.c.ParentHashes) {
+			p := f.c.ParentHashes[f.next]
+			f.next++
+
+			if parent, ok := pending[p]; ok && !visited[p] {
+				visited[p] = true
+				stack = append(stack, &frame{c: parent})
+			}
+
+			continue
+		}
+
+		sorted = append(sorted, f.c)
+		stack = stack[:len(stack)-1]
+	}
+
+	return sorted
+}
+
+func (e *Encoder) encodeReset(name plumbing.ReferenceName, h plumbing.Hash) error {
+	return e.printf("%s %s\n%s %s\n\n", resetCommand, name, fromCmd, e.commitish(h))
+}
+
+func (e *Encoder) encodeCommit(name plumbing.ReferenceName, c *object.Commit) error {
+	changes, err := e.changes(c)
+	if err != nil {
+		return err
+	}
+
+	for _, ch := range changes {
+		if ch.To.Name == "" || ch.To.TreeEntry.Mode == filemode.Submodule {
+			continue
+		}
+
+		if err := e.encodeBlob(ch.To.TreeEntry.Hash); err != nil {
+			return err
+		}
+	}
+
+	if len(c.ParentHashes) == 0 {
+		if err := e.printf("%s %s\n", resetCommand, name); err != nil {
+			return err
+		}
+	}
+
+	e.lastMark++
+	e.marks[c.Hash] = e.lastMark
+
+	if err := e.printf("%s %s\n%s :%d\n", commitCommand, name, markCmd, e.lastMark); err != nil {
+		return err
+	}
+
+	if err := e.encodeSignature(authorCmd, &c.Author); err != nil {
+		return err
+	}
+
+	if err := e.encodeSignature(committerCmd, &c.Committer); err != nil {
+		return err
+	}
+
+	if c.Encoding != "" {
+		if err := e.printf("%s %s\n", encodingCmd, c.Encoding); err != nil {
+			return err
+		}
+	}
+
+	if err := e.encodeData([]byte(c.Message)); err != nil {
+		return err
+	}
+
+	for i, p := range c.ParentHashes {
+		cmd := mergeCmd
+		if i == 0 {
+			cmd = fromCmd
+		}
+
+		if err := e.printf("%s %s\n", cmd, e.commitish(p)); err != nil {
+			return err
+		}
+	}
+
+	for _, ch := range changes {
+		if err := e.encodeChange(ch); err != nil {
+			return err
+		}
+	}
+
+	return e.printf("\n")
+}
+
+// changes returns the changes of c against its first parent, with all the
+// deletions placed before any other change, so a file can be replaced by a
+// directory with the same name.
+func (e *Encoder) changes(c *object.Commit) (object.Changes, error) {
+	to, err := c.Tree()
+	if err != nil {
+		return nil, err
+	}
+
+	from := &object.Tree{}
+	if len(c.ParentHashes) != 0 {
+		parent, err := c.Parent(0)
+		if err != nil {
+			return nil, err
+		}
+
+		if from, err = parent.Tree(); err != nil {
+			return nil, err
+		}
+	}
+
+	changes, err := object.DiffTree(from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(changes, func(i, j int) bool {
+		return changes[i].To.Name == "" && changes[j].To.Name != ""
+	})
+
+	return changes, nil
+}
+
+func (e *Encoder) encodeChange(ch *object.Change) error {
+	a, err := ch.Action()
+	if err != nil {
+		return err
+	}
+
+	if a == merkletrie.Delete {
+		return e.printf("%s %s\n", fileDeleteCmd, quotePath(ch.From.Name))
+	}
+
+	entry := ch.To.TreeEntry
+	ref := e.commitish(entry.Hash)
+	if entry.Mode == filemode.Submodule {
+		ref = entry.Hash.String()
+	}
+
+	return e.printf("%s %06o %s %s\n", fileModifyCmd, uint32(entry.Mode), ref, quotePath(ch.To.Name))
+}
+
+func (e *Encoder) encodeBlob(h plumbing.Hash) (err error) {
+	if _, ok := e.marks[h]; ok {
+		return nil
+	}
+
+	obj, err := e.s.EncodedObject(plumbing.BlobObject, h)
+	if err != nil {
+		return err
+	}
+
+	e.lastMark++
+	e.marks[h] = e.lastMark
+
+	if err := e.printf("%s\n%s :%d\n%s %d\n", blobCommand, markCmd, e.lastMark, dataCmd, obj.Size()); err != nil {
+		return err
+	}
+
+	r, err := obj.Reader()
+	if err != nil {
+		return err
+	}
+
+	defer r.Close()
+	if _, err := io.Copy(e.w, r); err != nil {
+		return err
+	}
+
+	return e.printf("\n")
+}
+
+func (e *Encoder) encodeTag(t *object.Tag) error {
+	if err := e.printf("%s %s\n%s %s\n", tagCommand, t.Name, fromCmd, e.commitish(t.Target)); err != nil {
+		return err
+	}
+
+	if t.Tagger.Email != "" || t.Tagger.Name != "" {
+		if err := e.encodeSignature(taggerCmd, &t.Tagger); err != nil {
+			return err
+		}
+	}
+
+	if err := e.encodeData([]byte(t.Message + t.PGPSignature)); err != nil {
+		return err
+	}
+
+	return e.printf("\n")
+}
+
+func (e *Encoder) encodeSignature(cmd string, s *object.Signature) error {
+	if err := e.printf("%s ", cmd); err != nil {
+		return err
+	}
+
+	if err := s.Encode(e.w); err != nil {
+		return err
+	}
+
+	return e.printf("\n")
+}
+
+func (e *Encoder) encodeData(data []byte) error {
+	if err := e.printf("%s %d\n", dataCmd, len(data)); err != nil {
+		return err
+	}
+
+	_, err := e.w.Write(data)
+	return err
+}
+
+// commitish returns the mark of h if it was exported, otherwise the hash.
+func (e *Encoder) commitish(h plumbing.Hash) string {
+	if mark, ok := e.marks[h]; ok {
+		return fmt.Sprintf(":%d", mark)
+	}
+
+	return h.String()
+}
+
+func (e *Encoder) printf(format string, args ...interface{}) error {
+	_, err := fmt.Fprintf(e.w, format, args...)
+	return err
+}
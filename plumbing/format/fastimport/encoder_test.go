@@ -0,0 +1,118 @@
+package fastimport
+
+import (
+	"bytes"
+	"strings"
+
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/cache"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+	"gopkg.in/src-d/go-git.v4/storage/filesystem"
+	"gopkg.in/src-d/go-git.v4/storage/memory"
+
+	. "gopkg.in/check.v1"
+	"gopkg.in/src-d/go-git-fixtures.v3"
+)
+
+type EncoderSuite struct {
+	fixtures.Suite
+	Storer *filesystem.Storage
+}
+
+var _ = Suite(&EncoderSuite{})
+
+func (s *EncoderSuite) SetUpSuite(c *C) {
+	s.Suite.SetUpSuite(c)
+	s.Storer = filesystem.NewStorage(fixtures.Basic().One().DotGit(), cache.NewObjectLRUDefault())
+}
+
+var basicHead = plumbing.NewHash("6ecf0ef2c2dffb796033e5a02219af86ec6584e5")
+
+func (s *EncoderSuite) TestEncodeRoundTrip(c *C) {
+	buf := bytes.NewBuffer(nil)
+	e := NewEncoder(buf, s.Storer)
+	c.Assert(e.Encode(plumbing.Master, basicHead), IsNil)
+
+	sto := memory.NewStorage()
+	d := NewDecoder(buf, sto)
+	c.Assert(d.Decode(), IsNil)
+
+	ref, err := sto.Reference(plumbing.Master)
+	c.Assert(err, IsNil)
+	c.Assert(ref.Hash(), Equals, basicHead)
+
+	c.Assert(d.Marks, DeepEquals, e.Marks())
+
+	commit, err := object.GetCommit(sto, plumbing.NewHash("1669dce138d9b841a518c64b10914d88f5e488ea"))
+	c.Assert(err, IsNil)
+	c.Assert(commit.NumParents(), Equals, 2)
+}
+
+func (s *EncoderSuite) TestEncodeExclude(c *C) {
+	buf := bytes.NewBuffer(nil)
+	e := NewEncoder(buf, s.Storer)
+	parent := plumbing.NewHash("918c48b83bd081e863dbe1b80f8998f058cd8294")
+	c.Assert(e.Encode(plumbing.Master, basicHead, parent), IsNil)
+
+	stream := buf.String()
+	c.Assert(strings.HasPrefix(stream, "blob\n"), Equals, true)
+	c.Assert(strings.Count(stream, "commit refs/heads/master\n"), Equals, 1)
+	c.Assert(strings.Contains(stream, "from "+parent.String()+"\n"), Equals, true)
+}
+
+func (s *EncoderSuite) TestEncodeIncremental(c *C) {
+	buf := bytes.NewBuffer(nil)
+	parent := plumbing.NewHash("918c48b83bd081e863dbe1b80f8998f058cd8294")
+
+	e := NewEncoder(buf, s.Storer)
+	c.Assert(e.Encode(plumbing.Master, parent), IsNil)
+	marks := e.Marks()
+
+	e = NewEncoder(buf, s.Storer)
+	e.UseMarks(marks)
+	c.Assert(e.Encode(plumbing.Master, basicHead), IsNil)
+
+	sto := memory.NewStorage()
+	c.Assert(NewDecoder(buf, sto).Decode(), IsNil)
+
+	ref, err := sto.Reference(plumbing.Master)
+	c.Assert(err, IsNil)
+	c.Assert(ref.Hash(), Equals, basicHead)
+}
+
+func (s *EncoderSuite) TestEncodeNothingToExport(c *C) {
+	buf := bytes.NewBuffer(nil)
+	e := NewEncoder(buf, s.Storer)
+	c.Assert(e.Encode(plumbing.Master, basicHead, basicHead), IsNil)
+	c.Assert(buf.String(), Equals, "reset refs/heads/master\nfrom "+basicHead.String()+"\n\n")
+}
+
+func (s *EncoderSuite) TestEncodeTag(c *C) {
+	f := fixtures.ByTag("tags").One()
+	sto := filesystem.NewStorage(f.DotGit(), cache.NewObjectLRUDefault())
+
+	ref, err := sto.Reference("refs/tags/annotated-tag")
+	c.Assert(err, IsNil)
+
+	buf := bytes.NewBuffer(nil)
+	e := NewEncoder(buf, sto)
+	c.Assert(e.Encode(ref.Name(), ref.Hash()), IsNil)
+
+	imported := memory.NewStorage()
+	c.Assert(NewDecoder(buf, imported).Decode(), IsNil)
+
+	tagRef, err := imported.Reference(ref.Name())
+	c.Assert(err, IsNil)
+	c.Assert(tagRef.Hash(), Equals, ref.Hash())
+}
+
+func (s *EncoderSuite) TestEncodeUnsupportedTagTarget(c *C) {
+	f := fixtures.ByTag("tags").One()
+	sto := filesystem.NewStorage(f.DotGit(), cache.NewObjectLRUDefault())
+
+	ref, err := sto.Reference("refs/tags/tree-tag")
+	c.Assert(err, IsNil)
+
+	e := NewEncoder(bytes.NewBuffer(nil), sto)
+	c.Assert(e.Encode(ref.Name(), ref.Hash()), Equals, ErrUnsupportedTagTarget)
+}
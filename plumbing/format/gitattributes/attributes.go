@@ -0,0 +1,202 @@
+package gitattributes
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"strings"
+)
+
+const (
+	commentPrefix = "#"
+	macroPrefix   = "[attr]"
+	unsetPrefix   = "-"
+	unspecPrefix  = "!"
+	valueSep      = "="
+)
+
+var (
+	// ErrMacroNotAllowed is returned when a macro is defined in a file where
+	// macros are not allowed, only top-level files can define macros.
+	ErrMacroNotAllowed = errors.New("macro not allowed")
+	// ErrInvalidAttributeName is returned when an attribute name is invalid.
+	ErrInvalidAttributeName = errors.New("invalid attribute name")
+	// ErrNegativePattern is returned when a pattern starts with !, negative
+	// patterns are forbidden in gitattributes files.
+	ErrNegativePattern = errors.New("negative patterns are forbidden")
+)
+
+// Attribute defines the state of an attribute for a given path.
+type Attribute interface {
+	// Name returns the name of the attribute.
+	Name() string
+	// IsSet returns true if the attribute is set, e.g. "text".
+	IsSet() bool
+	// IsUnset returns true if the attribute is unset, e.g. "-text".
+	IsUnset() bool
+	// IsUnspecified returns true if the attribute is reset to unspecified,
+	// e.g. "!text".
+	IsUnspecified() bool
+	// IsValueSet returns true if the attribute is set to a value, e.g.
+	// "eol=lf".
+	IsValueSet() bool
+	// Value returns the value of the attribute, it is "true" if set, "false"
+	// if unset and empty if unspecified.
+	Value() string
+	// String returns the attribute as written in a gitattributes file.
+	String() string
+}
+
+type attributeState int
+
+const (
+	setState attributeState = iota
+	unsetState
+	unspecifiedState
+	valueState
+)
+
+type attribute struct {
+	name  string
+	state attributeState
+	value string
+}
+
+// NewAttribute returns an attribute parsed from its representation in a
+// gitattributes file: "name", "-name", "!name" or "name=value".
+func NewAttribute(s string) (Attribute, error) {
+	a := &attribute{}
+	switch {
+	case strings.HasPrefix(s, unsetPrefix):
+		a.name, a.state = s[1:], unsetState
+	case strings.HasPrefix(s, unspecPrefix):
+		a.name, a.state = s[1:], unspecifiedState
+	case strings.Contains(s, valueSep):
+		parts := strings.SplitN(s, valueSep, 2)
+		a.name, a.state, a.value = parts[0], valueState, parts[1]
+	default:
+		a.name = s
+	}
+
+	if !validAttributeName(a.name) {
+		return nil, ErrInvalidAttributeName
+	}
+
+	return a, nil
+}
+
+func validAttributeName(name string) bool {
+	if name == "" || strings.HasPrefix(name, unsetPrefix) {
+		return false
+	}
+
+	for _, c := range name {
+		if !(c == '-' || c == '.' || c == '_' ||
+			('0' <= c && c <= '9') || ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z')) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (a *attribute) Name() string        { return a.name }
+func (a *attribute) IsSet() bool         { return a.state == setState }
+func (a *attribute) IsUnset() bool       { return a.state == unsetState }
+func (a *attribute) IsUnspecified() bool { return a.state == unspecifiedState }
+func (a *attribute) IsValueSet() bool    { return a.state == valueState }
+
+func (a *attribute) Value() string {
+	switch a.state {
+	case setState:
+		return "true"
+	case unsetState:
+		return "false"
+	case valueState:
+		return a.value
+	default:
+		return ""
+	}
+}
+
+func (a *attribute) String() string {
+	switch a.state {
+	case unsetState:
+		return unsetPrefix + a.name
+	case unspecifiedState:
+		return unspecPrefix + a.name
+	case valueState:
+		return a.name + valueSep + a.value
+	default:
+		return a.name
+	}
+}
+
+// MatchAttribute is a line of a gitattributes file, it assigns the given
+// attributes to the paths matching Pattern. If Name is not empty, the line is
+// the definition of the macro Name and Pattern is nil.
+type MatchAttribute struct {
+	Name       string
+	Pattern    Pattern
+	Attributes []Attribute
+}
+
+// ParseAttributesLine parses a line of a gitattributes file, placed in the
+// directory domain. allowMacro defines if macro definitions are accepted.
+func ParseAttributesLine(line string, domain []string, allowMacro bool) (m MatchAttribute, err error) {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return m, nil
+	}
+
+	switch {
+	case strings.HasPrefix(fields[0], macroPrefix):
+		if !allowMacro {
+			return m, ErrMacroNotAllowed
+		}
+
+		m.Name = fields[0][len(macroPrefix):]
+		if !validAttributeName(m.Name) {
+			return m, ErrInvalidAttributeName
+		}
+	case strings.HasPrefix(fields[0], unspecPrefix):
+		return m, ErrNegativePattern
+	default:
+		m.Pattern = ParsePattern(fields[0], domain)
+	}
+
+	for _, f := range fields[1:] {
+		a, err := NewAttribute(f)
+		if err != nil {
+			return m, err
+		}
+
+		m.Attributes = append(m.Attributes, a)
+	}
+
+	return m, nil
+}
+
+// ReadAttributes reads the lines of a gitattributes file, placed in the
+// directory domain. allowMacro defines if macro definitions are accepted,
+// they are only allowed in top-level files. As git does, the invalid lines are
+// ignored.
+func ReadAttributes(r io.Reader, domain []string, allowMacro bool) ([]MatchAttribute, error) {
+	var attrs []MatchAttribute
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, commentPrefix) {
+			continue
+		}
+
+		m, err := ParseAttributesLine(line, domain, allowMacro)
+		if err != nil {
+			continue
+		}
+
+		attrs = append(attrs, m)
+	}
+
+	return attrs, s.Err()
+}
@@ -0,0 +1,88 @@
+package gitattributes
+
+import (
+	"strings"
+	"testing"
+
+	. "gopkg.in/check.v1"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type AttributesSuite struct{}
+
+var _ = Suite(&AttributesSuite{})
+
+func (s *AttributesSuite) TestNewAttribute(c *C) {
+	a, err := NewAttribute("text")
+	c.Assert(err, IsNil)
+	c.Assert(a.Name(), Equals, "text")
+	c.Assert(a.IsSet(), Equals, true)
+	c.Assert(a.Value(), Equals, "true")
+	c.Assert(a.String(), Equals, "text")
+
+	a, err = NewAttribute("-text")
+	c.Assert(err, IsNil)
+	c.Assert(a.Name(), Equals, "text")
+	c.Assert(a.IsUnset(), Equals, true)
+	c.Assert(a.Value(), Equals, "false")
+	c.Assert(a.String(), Equals, "-text")
+
+	a, err = NewAttribute("!text")
+	c.Assert(err, IsNil)
+	c.Assert(a.IsUnspecified(), Equals, true)
+	c.Assert(a.Value(), Equals, "")
+	c.Assert(a.String(), Equals, "!text")
+
+	a, err = NewAttribute("eol=crlf")
+	c.Assert(err, IsNil)
+	c.Assert(a.Name(), Equals, "eol")
+	c.Assert(a.IsValueSet(), Equals, true)
+	c.Assert(a.Value(), Equals, "crlf")
+	c.Assert(a.String(), Equals, "eol=crlf")
+}
+
+func (s *AttributesSuite) TestNewAttributeInvalid(c *C) {
+	for _, attr := range []string{"-", "--text", "te$t", "=foo"} {
+		_, err := NewAttribute(attr)
+		c.Assert(err, Equals, ErrInvalidAttributeName, Commentf("attribute: %q", attr))
+	}
+}
+
+func (s *AttributesSuite) TestParseAttributesLine(c *C) {
+	m, err := ParseAttributesLine("*.go  text eol=lf -diff", []string{"foo"}, false)
+	c.Assert(err, IsNil)
+	c.Assert(m.Name, Equals, "")
+	c.Assert(m.Pattern.Match([]string{"foo", "bar.go"}), Equals, true)
+	c.Assert(m.Attributes, HasLen, 3)
+	c.Assert(m.Attributes[1].String(), Equals, "eol=lf")
+
+	m, err = ParseAttributesLine("[attr]vendored -diff -merge", nil, true)
+	c.Assert(err, IsNil)
+	c.Assert(m.Name, Equals, "vendored")
+	c.Assert(m.Pattern, IsNil)
+	c.Assert(m.Attributes, HasLen, 2)
+
+	_, err = ParseAttributesLine("[attr]vendored -diff", nil, false)
+	c.Assert(err, Equals, ErrMacroNotAllowed)
+
+	_, err = ParseAttributesLine("!*.go text", nil, false)
+	c.Assert(err, Equals, ErrNegativePattern)
+}
+
+func (s *AttributesSuite) TestReadAttributes(c *C) {
+	attrs, err := ReadAttributes(strings.NewReader(`
+# comment
+*.go text
+
+[attr]vendored -diff
+!*.txt text
+*.sh te$t eol=lf
+*.png binary
+`), nil, true)
+	c.Assert(err, IsNil)
+	c.Assert(attrs, HasLen, 3)
+	c.Assert(attrs[0].Attributes[0].String(), Equals, "text")
+	c.Assert(attrs[1].Name, Equals, "vendored")
+	c.Assert(attrs[2].Attributes[0].String(), Equals, "binary")
+}
@@ -0,0 +1,146 @@
+package gitattributes
+
+import (
+	"os"
+	"os/user"
+	"strings"
+
+	"gopkg.in/src-d/go-billy.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing/format/config"
+	gioutil "gopkg.in/src-d/go-git.v4/utils/ioutil"
+)
+
+const (
+	coreSection       = "core"
+	attributesfile    = "attributesfile"
+	gitDir            = ".git"
+	gitattributesFile = ".gitattributes"
+	gitconfigFile     = ".gitconfig"
+	xdgAttributesFile = "git/attributes"
+	systemFile        = "/etc/gitattributes"
+)
+
+// ReadAttributesFile reads the lines of the attributes file at path, placed
+// in the directory domain. It returns nil if the file doesn't exist.
+func ReadAttributesFile(fs billy.Filesystem, path []string, attributesFile string, allowMacro bool) (attrs []MatchAttribute, err error) {
+	f, err := fs.Open(fs.Join(append(path, attributesFile)...))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer gioutil.CheckClose(f, &err)
+	return ReadAttributes(f, path, allowMacro)
+}
+
+// ReadPatterns reads the .gitattributes files recursively traversing through
+// the directory structure. The result is in the ascending order of priority
+// (last higher).
+func ReadPatterns(fs billy.Filesystem, path []string) ([]MatchAttribute, error) {
+	attrs, err := ReadAttributesFile(fs, path, gitattributesFile, len(path) == 0)
+	if err != nil {
+		return nil, err
+	}
+
+	fis, err := fs.ReadDir(fs.Join(path...))
+	if os.IsNotExist(err) {
+		return attrs, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, fi := range fis {
+		if !fi.IsDir() || fi.Name() == gitDir {
+			continue
+		}
+
+		subattrs, err := ReadPatterns(fs, append(path, fi.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		attrs = append(attrs, subattrs...)
+	}
+
+	return attrs, nil
+}
+
+func loadPatterns(fs billy.Filesystem, path string) ([]MatchAttribute, error) {
+	f, err := fs.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+	return ReadAttributes(f, nil, true)
+}
+
+// LoadGlobalPatterns loads the gitattributes lines from the file declared in
+// the core.attributesfile property of the user's ~/.gitconfig file, or from
+// $XDG_CONFIG_HOME/git/attributes if the property is not declared. If the
+// file doesn't exist the function will return nil.
+//
+// The function assumes fs is rooted at the root filesystem.
+func LoadGlobalPatterns(fs billy.Filesystem) ([]MatchAttribute, error) {
+	usr, err := user.Current()
+	if err != nil {
+		return nil, err
+	}
+
+	path, err := globalAttributesFile(fs, usr.HomeDir)
+	if err != nil {
+		return nil, err
+	}
+
+	return loadPatterns(fs, path)
+}
+
+func globalAttributesFile(fs billy.Filesystem, home string) (path string, err error) {
+	f, err := fs.Open(fs.Join(home, gitconfigFile))
+	if err != nil && !os.IsNotExist(err) {
+		return "", err
+	}
+
+	if err == nil {
+		defer gioutil.CheckClose(f, &err)
+
+		raw := config.New()
+		if err := config.NewDecoder(f).Decode(raw); err != nil {
+			return "", err
+		}
+
+		path := raw.Section(coreSection).Options.Get(attributesfile)
+		if strings.HasPrefix(path, "~/") {
+			path = fs.Join(home, path[2:])
+		}
+
+		if path != "" {
+			return path, nil
+		}
+	}
+
+	xdg := os.Getenv("XDG_CONFIG_HOME")
+	if xdg == "" {
+		xdg = fs.Join(home, ".config")
+	}
+
+	return fs.Join(xdg, xdgAttributesFile), nil
+}
+
+// LoadSystemPatterns loads the gitattributes lines from the system wide
+// /etc/gitattributes file. If the file doesn't exist the function will return
+// nil.
+//
+// The function assumes fs is rooted at the root filesystem.
+func LoadSystemPatterns(fs billy.Filesystem) ([]MatchAttribute, error) {
+	return loadPatterns(fs, systemFile)
+}
@@ -0,0 +1,91 @@
+package gitattributes
+
+import (
+	"os"
+	"os/user"
+
+	. "gopkg.in/check.v1"
+	"gopkg.in/src-d/go-billy.v4"
+	"gopkg.in/src-d/go-billy.v4/memfs"
+	"gopkg.in/src-d/go-billy.v4/util"
+)
+
+type DirSuite struct {
+	home string
+}
+
+var _ = Suite(&DirSuite{})
+
+func (s *DirSuite) SetUpSuite(c *C) {
+	usr, err := user.Current()
+	c.Assert(err, IsNil)
+	s.home = usr.HomeDir
+}
+
+func (s *DirSuite) TestReadPatterns(c *C) {
+	fs := memfs.New()
+	c.Assert(util.WriteFile(fs, ".gitattributes", []byte("[attr]foo text\n*.go foo\n"), 0644), IsNil)
+	c.Assert(util.WriteFile(fs, "vendor/.gitattributes", []byte("[attr]bar text\n*.go -text\n"), 0644), IsNil)
+	c.Assert(util.WriteFile(fs, ".git/.gitattributes", []byte("*.go eol=crlf\n"), 0644), IsNil)
+	c.Assert(fs.MkdirAll("empty", os.ModePerm), IsNil)
+
+	attrs, err := ReadPatterns(fs, nil)
+	c.Assert(err, IsNil)
+	c.Assert(attrs, HasLen, 3)
+	c.Assert(attrs[0].Name, Equals, "foo")
+	c.Assert(attrs[2].Attributes[0].String(), Equals, "-text")
+
+	m := NewMatcher(attrs)
+	results, _ := m.Match([]string{"main.go"}, nil)
+	c.Assert(results["text"].IsSet(), Equals, true)
+
+	results, _ = m.Match([]string{"vendor", "lib.go"}, nil)
+	c.Assert(results["text"].IsUnset(), Equals, true)
+}
+
+func (s *DirSuite) writeGlobalConfig(c *C, fs billy.Filesystem, content string) {
+	err := util.WriteFile(fs, fs.Join(s.home, gitconfigFile), []byte(content), 0644)
+	c.Assert(err, IsNil)
+}
+
+func (s *DirSuite) TestLoadGlobalPatterns(c *C) {
+	fs := memfs.New()
+	s.writeGlobalConfig(c, fs, "[core]\n\tattributesfile = ~/.gitattributes_global\n")
+	err := util.WriteFile(fs, fs.Join(s.home, ".gitattributes_global"), []byte("*.go text\n"), 0644)
+	c.Assert(err, IsNil)
+
+	attrs, err := LoadGlobalPatterns(fs)
+	c.Assert(err, IsNil)
+	c.Assert(attrs, HasLen, 1)
+	c.Assert(attrs[0].Pattern.Match([]string{"main.go"}), Equals, true)
+}
+
+func (s *DirSuite) TestLoadGlobalPatternsXDG(c *C) {
+	defer os.Setenv("XDG_CONFIG_HOME", os.Getenv("XDG_CONFIG_HOME"))
+	os.Setenv("XDG_CONFIG_HOME", "")
+
+	fs := memfs.New()
+	s.writeGlobalConfig(c, fs, "[core]\n")
+	err := util.WriteFile(fs, fs.Join(s.home, ".config", "git", "attributes"), []byte("*.go text\n*.png binary\n"), 0644)
+	c.Assert(err, IsNil)
+
+	attrs, err := LoadGlobalPatterns(fs)
+	c.Assert(err, IsNil)
+	c.Assert(attrs, HasLen, 2)
+}
+
+func (s *DirSuite) TestLoadGlobalPatternsMissing(c *C) {
+	attrs, err := LoadGlobalPatterns(memfs.New())
+	c.Assert(err, IsNil)
+	c.Assert(attrs, HasLen, 0)
+}
+
+func (s *DirSuite) TestLoadSystemPatterns(c *C) {
+	fs := memfs.New()
+	err := util.WriteFile(fs, systemFile, []byte("[attr]foo text\n*.go foo\n"), 0644)
+	c.Assert(err, IsNil)
+
+	attrs, err := LoadSystemPatterns(fs)
+	c.Assert(err, IsNil)
+	c.Assert(attrs, HasLen, 2)
+}
@@ -0,0 +1,35 @@
+// Package gitattributes implements the parsing and matching of gitattributes
+// files, assigning attributes to the paths of a repository:
+//
+//   Format
+//   ======
+//
+//		- A gitattributes file is a simple text file where each line is of the
+//		  form "pattern attr1 attr2 ...". The pattern and the attributes are
+//		  separated by whitespaces, leading and trailing whitespaces are
+//		  ignored and lines starting with # are comments.
+//
+//		- Each attribute can be in one of these states for a given path:
+//		  set ("text"), unset ("-text"), set to a value ("eol=lf") or
+//		  unspecified, when no pattern matches the path or it is reset to
+//		  this state with "!text".
+//
+//		- When more than one pattern matches the path, a later line overrides
+//		  an earlier line. The files with higher precedence are the ones in
+//		  $GIT_DIR/info/attributes, followed by the .gitattributes files of
+//		  the directory containing the path up to the root of the worktree,
+//		  and finally the file pointed by core.attributesFile and the system
+//		  wide $(prefix)/etc/gitattributes.
+//
+//		- The rules of the patterns are the same as in .gitignore files, with
+//		  the exceptions that negative patterns are forbidden and that
+//		  patterns matching a directory do not recursively match the paths
+//		  inside that directory.
+//
+//		- Macro attributes can be defined only in top-level files, with lines
+//		  of the form "[attr]binary -diff -merge -text". When a macro is
+//		  set, all the attributes it contains are assigned. The built-in
+//		  macro "binary" is always defined.
+//
+// https://git-scm.com/docs/gitattributes
+package gitattributes
@@ -0,0 +1,95 @@
+package gitattributes
+
+// Matcher defines a matcher for the lines of a stack of gitattributes files.
+type Matcher interface {
+	// Match returns the attributes assigned to the given path, as the result
+	// of the lines in the order of priorities. If attributes is not empty,
+	// only the attributes with the given names are returned. The bool is true
+	// if any attribute was found.
+	Match(path []string, attributes []string) (map[string]Attribute, bool)
+}
+
+// builtinMacros are the macros defined by git, they can be redefined by the
+// gitattributes files.
+var builtinMacros = map[string][]Attribute{
+	"binary": {
+		&attribute{name: "diff", state: unsetState},
+		&attribute{name: "merge", state: unsetState},
+		&attribute{name: "text", state: unsetState},
+	},
+}
+
+type matcher struct {
+	stack  []MatchAttribute
+	macros map[string][]Attribute
+}
+
+// NewMatcher constructs a new matcher. The lines must be given in the order of
+// increasing priority. That is the system and global files first, then the
+// .gitattributes of the root of the repository, then the .gitattributes
+// files down the path and finally the $GIT_DIR/info/attributes file.
+func NewMatcher(stack []MatchAttribute) Matcher {
+	m := &matcher{macros: make(map[string][]Attribute)}
+	for name, attrs := range builtinMacros {
+		m.macros[name] = attrs
+	}
+
+	for _, ma := range stack {
+		if ma.Name != "" {
+			m.macros[ma.Name] = ma.Attributes
+			continue
+		}
+
+		m.stack = append(m.stack, ma)
+	}
+
+	return m
+}
+
+func (m *matcher) Match(path []string, attributes []string) (map[string]Attribute, bool) {
+	assigned := make(map[string]Attribute)
+	for i := len(m.stack) - 1; i >= 0; i-- {
+		ma := m.stack[i]
+		if !ma.Pattern.Match(path) {
+			continue
+		}
+
+		m.assign(assigned, ma.Attributes)
+	}
+
+	var filter map[string]bool
+	if len(attributes) != 0 {
+		filter = make(map[string]bool, len(attributes))
+		for _, name := range attributes {
+			filter[name] = true
+		}
+	}
+
+	result := make(map[string]Attribute)
+	for name, a := range assigned {
+		if a.IsUnspecified() || (filter != nil && !filter[name]) {
+			continue
+		}
+
+		result[name] = a
+	}
+
+	return result, len(result) > 0
+}
+
+// assign assigns the attributes not assigned yet, a later attribute in the
+// same line takes precedence over an earlier one. The attributes of the macros
+// being set are assigned too.
+func (m *matcher) assign(assigned map[string]Attribute, attrs []Attribute) {
+	for i := len(attrs) - 1; i >= 0; i-- {
+		a := attrs[i]
+		if _, ok := assigned[a.Name()]; ok {
+			continue
+		}
+
+		assigned[a.Name()] = a
+		if macro, ok := m.macros[a.Name()]; ok && a.IsSet() {
+			m.assign(assigned, macro)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package gitattributes
+
+import (
+	"strings"
+
+	. "gopkg.in/check.v1"
+)
+
+type MatcherSuite struct{}
+
+var _ = Suite(&MatcherSuite{})
+
+func (s *MatcherSuite) TestMatch(c *C) {
+	root, err := ReadAttributes(strings.NewReader(`
+* text=auto
+*.go text eol=lf
+*.png binary
+*.txt -text text
+[attr]vendored -diff linguist-vendored
+`), nil, true)
+	c.Assert(err, IsNil)
+
+	sub, err := ReadAttributes(strings.NewReader(`
+*.go -text
+* vendored
+*.txt !text
+`), []string{"vendor"}, false)
+	c.Assert(err, IsNil)
+
+	m := NewMatcher(append(root, sub...))
+
+	results, matched := m.Match([]string{"main.go"}, nil)
+	c.Assert(matched, Equals, true)
+	c.Assert(results, HasLen, 2)
+	c.Assert(results["text"].IsSet(), Equals, true)
+	c.Assert(results["eol"].Value(), Equals, "lf")
+
+	results, _ = m.Match([]string{"image.png"}, nil)
+	c.Assert(results, HasLen, 4)
+	c.Assert(results["binary"].IsSet(), Equals, true)
+	c.Assert(results["text"].IsUnset(), Equals, true)
+	c.Assert(results["diff"].IsUnset(), Equals, true)
+	c.Assert(results["merge"].IsUnset(), Equals, true)
+
+	results, _ = m.Match([]string{"README.txt"}, nil)
+	c.Assert(results["text"].IsSet(), Equals, true)
+
+	results, _ = m.Match([]string{"vendor", "lib.go"}, nil)
+	c.Assert(results["text"].IsUnset(), Equals, true)
+	c.Assert(results["eol"].Value(), Equals, "lf")
+	c.Assert(results["diff"].IsUnset(), Equals, true)
+	c.Assert(results["linguist-vendored"].IsSet(), Equals, true)
+
+	results, _ = m.Match([]string{"vendor", "notes.txt"}, []string{"text", "diff"})
+	c.Assert(results, HasLen, 1)
+	c.Assert(results["diff"].IsUnset(), Equals, true)
+
+	results, matched = m.Match([]string{"main.go"}, []string{"filter"})
+	c.Assert(matched, Equals, false)
+	c.Assert(results, HasLen, 0)
+}
+
+func (s *MatcherSuite) TestMatchRedefineMacro(c *C) {
+	attrs, err := ReadAttributes(strings.NewReader(`
+[attr]binary -text
+*.png binary
+`), nil, true)
+	c.Assert(err, IsNil)
+
+	results, _ := NewMatcher(attrs).Match([]string{"image.png"}, nil)
+	c.Assert(results, HasLen, 2)
+	c.Assert(results["text"].IsUnset(), Equals, true)
+}
@@ -0,0 +1,99 @@
+package gitattributes
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+const (
+	patternDirSep  = "/"
+	zeroToManyDirs = "**"
+)
+
+// Pattern defines a gitattributes pattern.
+type Pattern interface {
+	// Match matches the given path to the pattern.
+	Match(path []string) bool
+}
+
+type pattern struct {
+	domain  []string
+	pattern []string
+}
+
+// ParsePattern parses a gitattributes pattern string into the Pattern
+// structure, domain is the directory of the file where the pattern is defined.
+func ParsePattern(p string, domain []string) Pattern {
+	return &pattern{
+		domain:  domain,
+		pattern: strings.Split(p, patternDirSep),
+	}
+}
+
+func (p *pattern) Match(path []string) bool {
+	if len(path) <= len(p.domain) {
+		return false
+	}
+
+	for i, e := range p.domain {
+		if path[i] != e {
+			return false
+		}
+	}
+
+	path = path[len(p.domain):]
+
+	// a pattern without slashes matches the name at any level
+	if len(p.pattern) == 1 {
+		match, err := filepath.Match(p.pattern[0], path[len(path)-1])
+		return err == nil && match
+	}
+
+	pattern := p.pattern
+	if pattern[0] == "" {
+		// leading slash, anchored to the domain
+		pattern = pattern[1:]
+	}
+
+	// patterns ending with a slash only match directories, so they never
+	// match a file
+	if pattern[len(pattern)-1] == "" {
+		return false
+	}
+
+	return globMatch(pattern, path)
+}
+
+// globMatch matches the path components to the pattern components, where "**"
+// matches zero or more directories.
+func globMatch(pattern, path []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == zeroToManyDirs {
+			pattern = pattern[1:]
+			if len(pattern) == 0 {
+				return len(path) > 0
+			}
+
+			for i := 0; i < len(path); i++ {
+				if globMatch(pattern, path[i:]) {
+					return true
+				}
+			}
+
+			return false
+		}
+
+		if len(path) == 0 {
+			return false
+		}
+
+		match, err := filepath.Match(pattern[0], path[0])
+		if err != nil || !match {
+			return false
+		}
+
+		pattern, path = pattern[1:], path[1:]
+	}
+
+	return len(path) == 0
+}
@@ -0,0 +1,46 @@
+package gitattributes
+
+import (
+	"strings"
+
+	. "gopkg.in/check.v1"
+)
+
+type PatternSuite struct{}
+
+var _ = Suite(&PatternSuite{})
+
+func (s *PatternSuite) TestMatch(c *C) {
+	for _, t := range []struct {
+		pattern string
+		domain  []string
+		path    string
+		match   bool
+	}{
+		{"*.go", nil, "foo.go", true},
+		{"*.go", nil, "foo/bar.go", true},
+		{"*.go", nil, "foo.go/bar", false},
+		{"foo", nil, "bar/foo", true},
+		{"/foo", nil, "foo", true},
+		{"/foo", nil, "bar/foo", false},
+		{"foo/*.go", nil, "foo/bar.go", true},
+		{"foo/*.go", nil, "foo/baz/bar.go", false},
+		{"foo/*.go", nil, "baz/foo/bar.go", false},
+		{"foo/", nil, "foo", false},
+		{"**/foo", nil, "foo", true},
+		{"**/foo", nil, "a/b/foo", true},
+		{"foo/**", nil, "foo/a/b", true},
+		{"foo/**", nil, "foo", false},
+		{"a/**/b", nil, "a/b", true},
+		{"a/**/b", nil, "a/x/y/b", true},
+		{"a/**/b", nil, "a/x/y/c", false},
+		{"*.go", []string{"foo"}, "foo/bar.go", true},
+		{"*.go", []string{"foo"}, "bar.go", false},
+		{"*.go", []string{"foo"}, "baz/bar.go", false},
+		{"bar/*.go", []string{"foo"}, "foo/bar/baz.go", true},
+	} {
+		p := ParsePattern(t.pattern, t.domain)
+		c.Assert(p.Match(strings.Split(t.path, "/")), Equals, t.match,
+			Commentf("pattern: %q, domain: %v, path: %q", t.pattern, t.domain, t.path))
+	}
+}
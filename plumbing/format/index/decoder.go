@@ -25,6 +25,10 @@ var (
 	// the read content
 	ErrInvalidChecksum = errors.New("invalid checksum")
 
+	// ErrMalformedUntrackedCache is returned by Decode when the untracked
+	// cache extension is malformed
+	ErrMalformedUntrackedCache = errors.New("malformed untracked cache extension")
+
 	errUnknownExtension = errors.New("unknown extension")
 )
 
@@ -205,8 +209,8 @@ func (d *Decoder) padEntry(idx *Index, e *Entry, read int) error {
 }
 
 func (d *Decoder) readExtensions(idx *Index) error {
-	// TODO: support 'Split index' and 'Untracked cache' extensions, take in
-	// count that they are not supported by jgit or libgit
+	// TODO: support 'Split index' extension, take in count that it is not
+	// supported by jgit or libgit
 
 	var expected []byte
 	var err error
@@ -261,6 +265,33 @@ func (d *Decoder) readExtension(idx *Index, header []byte) error {
 		if err := d.Decode(idx.ResolveUndo); err != nil {
 			return err
 		}
+	case bytes.Equal(header, untrackedExtSignature):
+		r, err := d.getExtensionReader()
+		if err != nil {
+			return err
+		}
+
+		idx.UntrackedCache = &UntrackedCache{}
+		d := &untrackedCacheDecoder{r: r}
+		if err := d.Decode(idx.UntrackedCache); err != nil {
+			return err
+		}
+
+		// skips the trailing NUL
+		if _, err := io.Copy(ioutil.Discard, r); err != nil {
+			return err
+		}
+	case bytes.Equal(header, fsMonitorExtSignature):
+		r, err := d.getExtensionReader()
+		if err != nil {
+			return err
+		}
+
+		idx.FSMonitor = &FSMonitor{}
+		d := &fsMonitorDecoder{r}
+		if err := d.Decode(idx.FSMonitor, idx.Entries); err != nil {
+			return err
+		}
 	default:
 		return errUnknownExtension
 	}
@@ -449,3 +480,227 @@ func (d *resolveUndoDecoder) readStage(e *ResolveUndoEntry, s Stage) error {
 
 	return nil
 }
+
+type untrackedCacheDecoder struct {
+	r    io.Reader
+	dirs []*UntrackedCacheDir
+}
+
+func (d *untrackedCacheDecoder) Decode(uc *UntrackedCache) error {
+	ident, err := d.readBytes()
+	if err != nil {
+		return err
+	}
+
+	uc.Ident = string(ident)
+
+	if err := readStatData(d.r, &uc.InfoExclude.Stat); err != nil {
+		return err
+	}
+
+	if err := readStatData(d.r, &uc.ExcludesFile.Stat); err != nil {
+		return err
+	}
+
+	if uc.DirFlags, err = binary.ReadUint32(d.r); err != nil {
+		return err
+	}
+
+	if err := binary.Read(d.r, &uc.InfoExclude.Hash, &uc.ExcludesFile.Hash); err != nil {
+		return err
+	}
+
+	name, err := binary.ReadUntil(d.r, '\x00')
+	if err != nil {
+		return err
+	}
+
+	uc.ExcludePerDir = string(name)
+
+	count, err := binary.ReadVariableWidthInt(d.r)
+	if err == io.EOF || (err == nil && count == 0) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if uc.Root, err = d.readDir(); err != nil {
+		return err
+	}
+
+	if int64(len(d.dirs)) != count {
+		return ErrMalformedUntrackedCache
+	}
+
+	return d.readDirsData()
+}
+
+func (d *untrackedCacheDecoder) readBytes() ([]byte, error) {
+	n, err := binary.ReadVariableWidthInt(d.r)
+	if err != nil {
+		return nil, err
+	}
+
+	b := make([]byte, n)
+	_, err = io.ReadFull(d.r, b)
+	return b, err
+}
+
+func (d *untrackedCacheDecoder) readDir() (*UntrackedCacheDir, error) {
+	untracked, err := binary.ReadVariableWidthInt(d.r)
+	if err != nil {
+		return nil, err
+	}
+
+	dirs, err := binary.ReadVariableWidthInt(d.r)
+	if err != nil {
+		return nil, err
+	}
+
+	name, err := binary.ReadUntil(d.r, '\x00')
+	if err != nil {
+		return nil, err
+	}
+
+	dir := &UntrackedCacheDir{Name: string(name)}
+	d.dirs = append(d.dirs, dir)
+
+	for i := int64(0); i < untracked; i++ {
+		name, err := binary.ReadUntil(d.r, '\x00')
+		if err != nil {
+			return nil, err
+		}
+
+		dir.Untracked = append(dir.Untracked, string(name))
+	}
+
+	for i := int64(0); i < dirs; i++ {
+		sub, err := d.readDir()
+		if err != nil {
+			return nil, err
+		}
+
+		dir.Dirs = append(dir.Dirs, sub)
+	}
+
+	return dir, nil
+}
+
+func (d *untrackedCacheDecoder) readDirsData() error {
+	var valid, checkOnly, hashValid ewahBitmap
+	for _, b := range []*ewahBitmap{&valid, &checkOnly, &hashValid} {
+		if err := b.Decode(d.r); err != nil {
+			return err
+		}
+	}
+
+	err := checkOnly.Each(func(i int) error {
+		if i >= len(d.dirs) {
+			return ErrMalformedUntrackedCache
+		}
+
+		d.dirs[i].CheckOnly = true
+		return nil
+	})
+
+	if err != nil {
+		return err
+	}
+
+	err = valid.Each(func(i int) error {
+		if i >= len(d.dirs) {
+			return ErrMalformedUntrackedCache
+		}
+
+		d.dirs[i].Valid = true
+		return readStatData(d.r, &d.dirs[i].Stat)
+	})
+
+	if err != nil {
+		return err
+	}
+
+	return hashValid.Each(func(i int) error {
+		if i >= len(d.dirs) {
+			return ErrMalformedUntrackedCache
+		}
+
+		return binary.Read(d.r, &d.dirs[i].ExcludeHash)
+	})
+}
+
+func readStatData(r io.Reader, s *StatData) error {
+	var sec, nsec, msec, mnsec uint32
+	if err := binary.Read(r,
+		&sec, &nsec,
+		&msec, &mnsec,
+		&s.Dev, &s.Inode,
+		&s.UID, &s.GID,
+		&s.Size,
+	); err != nil {
+		return err
+	}
+
+	if sec != 0 || nsec != 0 {
+		s.CreatedAt = time.Unix(int64(sec), int64(nsec))
+	}
+
+	if msec != 0 || mnsec != 0 {
+		s.ModifiedAt = time.Unix(int64(msec), int64(mnsec))
+	}
+
+	return nil
+}
+
+type fsMonitorDecoder struct {
+	r io.Reader
+}
+
+func (d *fsMonitorDecoder) Decode(m *FSMonitor, entries []*Entry) error {
+	version, err := binary.ReadUint32(d.r)
+	if err != nil {
+		return err
+	}
+
+	switch version {
+	case 1:
+		ts, err := binary.ReadUint64(d.r)
+		if err != nil {
+			return err
+		}
+
+		m.Token = strconv.FormatUint(ts, 10)
+	case 2:
+		token, err := binary.ReadUntil(d.r, '\x00')
+		if err != nil {
+			return err
+		}
+
+		m.Token = string(token)
+	default:
+		return ErrUnsupportedVersion
+	}
+
+	// the size of the bitmap is not needed, it is read as is
+	if _, err := binary.ReadUint32(d.r); err != nil {
+		return err
+	}
+
+	var dirty ewahBitmap
+	if err := dirty.Decode(d.r); err != nil {
+		return err
+	}
+
+	if int(dirty.size) > len(entries) {
+		return ErrMalformedBitmap
+	}
+
+	// the entries past the bitmap were added after it was written
+	for i, e := range entries {
+		e.FSMonitorValid = i < int(dirty.size) && !dirty.Get(i)
+	}
+
+	return nil
+}
@@ -13,8 +13,9 @@ import (
 )
 
 var (
-	// EncodeVersionSupported is the range of supported index versions
-	EncodeVersionSupported uint32 = 2
+	// EncodeVersionSupported is the maximum supported index version, the
+	// versions from DecodeVersionSupported.Min up to it can be encoded
+	EncodeVersionSupported uint32 = 3
 
 	// ErrInvalidTimestamp is returned by Encode if a Index with a Entry with
 	// negative timestamp values
@@ -36,9 +37,9 @@ func NewEncoder(w io.Writer) *Encoder {
 
 // Encode writes the Index to the stream of the encoder.
 func (e *Encoder) Encode(idx *Index) error {
-	// TODO: support versions v3 and v4
-	// TODO: support extensions
-	if idx.Version != EncodeVersionSupported {
+	// TODO: support version v4
+	// TODO: support 'Cached tree' and 'Resolve undo' extensions
+	if idx.Version < DecodeVersionSupported.Min || idx.Version > EncodeVersionSupported {
 		return ErrUnsupportedVersion
 	}
 
@@ -50,6 +51,10 @@ func (e *Encoder) Encode(idx *Index) error {
 		return err
 	}
 
+	if err := e.encodeExtensions(idx); err != nil {
+		return err
+	}
+
 	return e.encodeFooter()
 }
 
@@ -65,11 +70,15 @@ func (e *Encoder) encodeEntries(idx *Index) error {
 	sort.Sort(byName(idx.Entries))
 
 	for _, entry := range idx.Entries {
-		if err := e.encodeEntry(entry); err != nil {
+		if err := e.encodeEntry(idx, entry); err != nil {
 			return err
 		}
 
 		wrote := entryHeaderLength + len(entry.Name)
+		if entry.isExtended() {
+			wrote += 2
+		}
+
 		if err := e.padEntry(wrote); err != nil {
 			return err
 		}
@@ -78,8 +87,9 @@ func (e *Encoder) encodeEntries(idx *Index) error {
 	return nil
 }
 
-func (e *Encoder) encodeEntry(entry *Entry) error {
-	if entry.IntentToAdd || entry.SkipWorktree {
+func (e *Encoder) encodeEntry(idx *Index, entry *Entry) error {
+	// the extended flags were introduced in version 3
+	if entry.isExtended() && idx.Version < 3 {
 		return ErrUnsupportedVersion
 	}
 
@@ -100,6 +110,10 @@ func (e *Encoder) encodeEntry(entry *Entry) error {
 		flags |= nameMask
 	}
 
+	if entry.isExtended() {
+		flags |= entryExtended
+	}
+
 	flow := []interface{}{
 		sec, nsec,
 		msec, mnsec,
@@ -113,6 +127,19 @@ func (e *Encoder) encodeEntry(entry *Entry) error {
 		flags,
 	}
 
+	if entry.isExtended() {
+		var extended uint16
+		if entry.IntentToAdd {
+			extended |= intentToAddMask
+		}
+
+		if entry.SkipWorktree {
+			extended |= skipWorkTreeMask
+		}
+
+		flow = append(flow, extended)
+	}
+
 	if err := binary.Write(e.w, flow...); err != nil {
 		return err
 	}
@@ -121,6 +148,10 @@ func (e *Encoder) encodeEntry(entry *Entry) error {
 }
 
 func (e *Encoder) timeToUint32(t *time.Time) (uint32, uint32, error) {
+	return timeToUint32(t)
+}
+
+func timeToUint32(t *time.Time) (uint32, uint32, error) {
 	if t.IsZero() {
 		return 0, 0, nil
 	}
@@ -139,6 +170,37 @@ func (e *Encoder) padEntry(wrote int) error {
 	return err
 }
 
+func (e *Encoder) encodeExtensions(idx *Index) error {
+	if idx.UntrackedCache != nil {
+		buf := bytes.NewBuffer(nil)
+		enc := &untrackedCacheEncoder{w: buf}
+		if err := enc.Encode(idx.UntrackedCache); err != nil {
+			return err
+		}
+
+		if err := e.encodeExtension(untrackedExtSignature, buf.Bytes()); err != nil {
+			return err
+		}
+	}
+
+	if idx.FSMonitor != nil {
+		buf := bytes.NewBuffer(nil)
+		if err := encodeFSMonitor(buf, idx.FSMonitor, idx.Entries); err != nil {
+			return err
+		}
+
+		if err := e.encodeExtension(fsMonitorExtSignature, buf.Bytes()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (e *Encoder) encodeExtension(signature []byte, data []byte) error {
+	return binary.Write(e.w, signature, uint32(len(data)), data)
+}
+
 func (e *Encoder) encodeFooter() error {
 	return binary.Write(e.w, e.hash.Sum(nil))
 }
@@ -148,3 +210,185 @@ type byName []*Entry
 func (l byName) Len() int           { return len(l) }
 func (l byName) Swap(i, j int)      { l[i], l[j] = l[j], l[i] }
 func (l byName) Less(i, j int) bool { return l[i].Name < l[j].Name }
+
+type untrackedCacheEncoder struct {
+	w    io.Writer
+	dirs []*UntrackedCacheDir
+}
+
+func (e *untrackedCacheEncoder) Encode(uc *UntrackedCache) error {
+	if err := binary.WriteVariableWidthInt(e.w, int64(len(uc.Ident))); err != nil {
+		return err
+	}
+
+	if err := binary.Write(e.w, []byte(uc.Ident)); err != nil {
+		return err
+	}
+
+	if err := writeStatData(e.w, &uc.InfoExclude.Stat); err != nil {
+		return err
+	}
+
+	if err := writeStatData(e.w, &uc.ExcludesFile.Stat); err != nil {
+		return err
+	}
+
+	if err := binary.Write(e.w,
+		uc.DirFlags,
+		uc.InfoExclude.Hash[:],
+		uc.ExcludesFile.Hash[:],
+		[]byte(uc.ExcludePerDir+"\x00"),
+	); err != nil {
+		return err
+	}
+
+	if uc.Root == nil {
+		return binary.WriteVariableWidthInt(e.w, 0)
+	}
+
+	dirs := bytes.NewBuffer(nil)
+	if err := e.encodeDir(dirs, uc.Root); err != nil {
+		return err
+	}
+
+	if err := binary.WriteVariableWidthInt(e.w, int64(len(e.dirs))); err != nil {
+		return err
+	}
+
+	if err := binary.Write(e.w, dirs.Bytes()); err != nil {
+		return err
+	}
+
+	if err := e.encodeDirsData(); err != nil {
+		return err
+	}
+
+	return binary.Write(e.w, []byte{0})
+}
+
+func (e *untrackedCacheEncoder) encodeDir(w io.Writer, d *UntrackedCacheDir) error {
+	e.dirs = append(e.dirs, d)
+
+	// the untracked files of an invalid directory are not known
+	var untracked []string
+	if d.Valid {
+		untracked = d.Untracked
+	}
+
+	if err := binary.WriteVariableWidthInt(w, int64(len(untracked))); err != nil {
+		return err
+	}
+
+	if err := binary.WriteVariableWidthInt(w, int64(len(d.Dirs))); err != nil {
+		return err
+	}
+
+	if err := binary.Write(w, []byte(d.Name+"\x00")); err != nil {
+		return err
+	}
+
+	for _, name := range untracked {
+		if err := binary.Write(w, []byte(name+"\x00")); err != nil {
+			return err
+		}
+	}
+
+	for _, sub := range d.Dirs {
+		if err := e.encodeDir(w, sub); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (e *untrackedCacheEncoder) encodeDirsData() error {
+	valid := newEWAHBitmap(len(e.dirs))
+	checkOnly := newEWAHBitmap(len(e.dirs))
+	hashValid := newEWAHBitmap(len(e.dirs))
+	for i, d := range e.dirs {
+		if d.Valid {
+			valid.Set(i)
+		}
+
+		if d.Valid && d.CheckOnly {
+			checkOnly.Set(i)
+		}
+
+		if !d.ExcludeHash.IsZero() {
+			hashValid.Set(i)
+		}
+	}
+
+	for _, b := range []*ewahBitmap{valid, checkOnly, hashValid} {
+		if err := b.Encode(e.w); err != nil {
+			return err
+		}
+	}
+
+	for _, d := range e.dirs {
+		if !d.Valid {
+			continue
+		}
+
+		if err := writeStatData(e.w, &d.Stat); err != nil {
+			return err
+		}
+	}
+
+	for _, d := range e.dirs {
+		if d.ExcludeHash.IsZero() {
+			continue
+		}
+
+		if err := binary.Write(e.w, d.ExcludeHash[:]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func writeStatData(w io.Writer, s *StatData) error {
+	sec, nsec, err := timeToUint32(&s.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	msec, mnsec, err := timeToUint32(&s.ModifiedAt)
+	if err != nil {
+		return err
+	}
+
+	return binary.Write(w,
+		sec, nsec,
+		msec, mnsec,
+		s.Dev, s.Inode,
+		s.UID, s.GID,
+		s.Size,
+	)
+}
+
+// encodeFSMonitor writes the version 2 of the extension, with the token and
+// the bitmap of the entries not flagged as Entry.FSMonitorValid, in the
+// order of entries.
+func encodeFSMonitor(w io.Writer, m *FSMonitor, entries []*Entry) error {
+	dirty := newEWAHBitmap(len(entries))
+	for i, e := range entries {
+		if !e.FSMonitorValid {
+			dirty.Set(i)
+		}
+	}
+
+	buf := bytes.NewBuffer(nil)
+	if err := dirty.Encode(buf); err != nil {
+		return err
+	}
+
+	return binary.Write(w,
+		uint32(2),
+		[]byte(m.Token+"\x00"),
+		uint32(buf.Len()),
+		buf.Bytes(),
+	)
+}
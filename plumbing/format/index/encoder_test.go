@@ -55,8 +55,47 @@ func (s *IndexSuite) TestEncode(c *C) {
 
 }
 
+func (s *IndexSuite) TestEncodeV3(c *C) {
+	idx := &Index{
+		Version: 3,
+		Entries: []*Entry{{
+			CreatedAt:    time.Now(),
+			ModifiedAt:   time.Now(),
+			Hash:         plumbing.NewHash("e25b29c8946e0e192fae2edc1dabf7be71e8ecf3"),
+			Name:         "foo",
+			Size:         42,
+			SkipWorktree: true,
+		}, {
+			CreatedAt:   time.Now(),
+			ModifiedAt:  time.Now(),
+			Name:        "bar",
+			IntentToAdd: true,
+		}, {
+			CreatedAt:  time.Now(),
+			ModifiedAt: time.Now(),
+			Name:       "qux",
+			Size:       82,
+		}},
+	}
+
+	buf := bytes.NewBuffer(nil)
+	e := NewEncoder(buf)
+	err := e.Encode(idx)
+	c.Assert(err, IsNil)
+
+	output := &Index{}
+	d := NewDecoder(buf)
+	err = d.Decode(output)
+	c.Assert(err, IsNil)
+
+	c.Assert(cmp.Equal(idx, output), Equals, true)
+	c.Assert(output.Entries[0].IntentToAdd, Equals, true)
+	c.Assert(output.Entries[1].SkipWorktree, Equals, true)
+	c.Assert(output.Entries[2].Name, Equals, "qux")
+}
+
 func (s *IndexSuite) TestEncodeUnsuportedVersion(c *C) {
-	idx := &Index{Version: 3}
+	idx := &Index{Version: 4}
 
 	buf := bytes.NewBuffer(nil)
 	e := NewEncoder(buf)
@@ -87,3 +126,86 @@ func (s *IndexSuite) TestEncodeWithSkipWorktreeUnsuportedVersion(c *C) {
 	err := e.Encode(idx)
 	c.Assert(err, Equals, ErrUnsupportedVersion)
 }
+
+func (s *IndexSuite) TestEncodeUntrackedCache(c *C) {
+	mtime := time.Unix(1500000000, 42)
+	idx := &Index{
+		Version: 2,
+		UntrackedCache: &UntrackedCache{
+			Ident:         "Location /foo, system Linux\x00",
+			DirFlags:      6,
+			ExcludePerDir: ".gitignore",
+			InfoExclude: UntrackedCacheExclude{
+				Stat: StatData{ModifiedAt: mtime, Size: 240},
+				Hash: plumbing.NewHash("e25b29c8946e0e192fae2edc1dabf7be71e8ecf3"),
+			},
+			Root: &UntrackedCacheDir{
+				Untracked:   []string{"bar", "qux/"},
+				Valid:       true,
+				Stat:        StatData{ModifiedAt: mtime, Inode: 42},
+				ExcludeHash: plumbing.NewHash("beed5994208e84c68b967c022f14e2629328918f"),
+				Dirs: []*UntrackedCacheDir{{
+					Name: "foo",
+				}, {
+					Name:      "qux",
+					Untracked: []string{"baz"},
+					Valid:     true,
+					CheckOnly: true,
+					Stat:      StatData{ModifiedAt: mtime},
+				}},
+			},
+		},
+	}
+
+	buf := bytes.NewBuffer(nil)
+	err := NewEncoder(buf).Encode(idx)
+	c.Assert(err, IsNil)
+
+	output := &Index{}
+	err = NewDecoder(buf).Decode(output)
+	c.Assert(err, IsNil)
+
+	c.Assert(cmp.Equal(idx, output), Equals, true)
+}
+
+func (s *IndexSuite) TestEncodeFSMonitor(c *C) {
+	idx := &Index{
+		Version: 2,
+		Entries: []*Entry{
+			{Name: "foo", FSMonitorValid: true},
+			{Name: "bar"},
+			{Name: "qux", FSMonitorValid: true},
+		},
+		FSMonitor: &FSMonitor{Token: "foo:42"},
+	}
+
+	buf := bytes.NewBuffer(nil)
+	err := NewEncoder(buf).Encode(idx)
+	c.Assert(err, IsNil)
+
+	output := &Index{}
+	err = NewDecoder(buf).Decode(output)
+	c.Assert(err, IsNil)
+
+	c.Assert(cmp.Equal(idx, output), Equals, true)
+	c.Assert(output.FSMonitor.Token, Equals, "foo:42")
+	c.Assert(output.Entries[0].Name, Equals, "bar")
+	c.Assert(output.Entries[0].FSMonitorValid, Equals, false)
+	c.Assert(output.Entries[1].FSMonitorValid, Equals, true)
+}
+
+func (s *IndexSuite) TestEncodeFSMonitorValidWithoutExtension(c *C) {
+	idx := &Index{
+		Version: 2,
+		Entries: []*Entry{{Name: "foo", FSMonitorValid: true}},
+	}
+
+	buf := bytes.NewBuffer(nil)
+	err := NewEncoder(buf).Encode(idx)
+	c.Assert(err, IsNil)
+
+	output := &Index{}
+	err = NewDecoder(buf).Decode(output)
+	c.Assert(err, IsNil)
+	c.Assert(output.Entries[0].FSMonitorValid, Equals, false)
+}
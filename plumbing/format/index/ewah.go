@@ -0,0 +1,150 @@
+package index
+
+import (
+	"errors"
+	"io"
+
+	"gopkg.in/src-d/go-git.v4/utils/binary"
+)
+
+// ErrMalformedBitmap is returned by Decode when an ewah bitmap of an extension
+// is malformed.
+var ErrMalformedBitmap = errors.New("malformed ewah bitmap")
+
+const (
+	ewahRunLengthBits = 32
+	ewahLiteralBits   = 31
+)
+
+// ewahBitmap is an uncompressed bitmap, encoded and decoded in the ewah
+// compressed format used by the index extensions.
+// https://github.com/git/git/blob/master/Documentation/technical/bitmap-format.txt
+type ewahBitmap struct {
+	size  uint32
+	words []uint64
+}
+
+func newEWAHBitmap(size int) *ewahBitmap {
+	return &ewahBitmap{
+		size:  uint32(size),
+		words: make([]uint64, (size+63)/64),
+	}
+}
+
+// Set sets the bit i.
+func (b *ewahBitmap) Set(i int) {
+	b.words[i/64] |= 1 << uint(i%64)
+}
+
+// Get returns true if the bit i is set.
+func (b *ewahBitmap) Get(i int) bool {
+	if i/64 >= len(b.words) {
+		return false
+	}
+
+	return b.words[i/64]&(1<<uint(i%64)) != 0
+}
+
+// Each calls f with every set bit, in order.
+func (b *ewahBitmap) Each(f func(i int) error) error {
+	for i := 0; i < int(b.size); i++ {
+		if b.Get(i) {
+			if err := f(i); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// Decode reads an ewah bitmap from r: the size in bits, the number of words,
+// the words and the position of the last run length word.
+func (b *ewahBitmap) Decode(r io.Reader) error {
+	size, err := binary.ReadUint32(r)
+	if err != nil {
+		return err
+	}
+
+	count, err := binary.ReadUint32(r)
+	if err != nil {
+		return err
+	}
+
+	compressed := make([]uint64, count)
+	for i := range compressed {
+		if compressed[i], err = binary.ReadUint64(r); err != nil {
+			return err
+		}
+	}
+
+	if _, err := binary.ReadUint32(r); err != nil {
+		return err
+	}
+
+	*b = *newEWAHBitmap(int(size))
+
+	var pos int
+	for i := 0; i < len(compressed); {
+		rlw := compressed[i]
+		i++
+
+		running := rlw&1 != 0
+		runLength := int(rlw >> 1 & (1<<ewahRunLengthBits - 1))
+		literals := int(rlw >> (1 + ewahRunLengthBits) & (1<<ewahLiteralBits - 1))
+
+		if pos+runLength+literals > len(b.words) || i+literals > len(compressed) {
+			return ErrMalformedBitmap
+		}
+
+		for j := 0; j < runLength; j++ {
+			if running {
+				b.words[pos] = ^uint64(0)
+			}
+
+			pos++
+		}
+
+		pos += copy(b.words[pos:], compressed[i:i+literals])
+		i += literals
+	}
+
+	// the bits past the size are ignored
+	if r := b.size % 64; r != 0 && len(b.words) != 0 {
+		b.words[len(b.words)-1] &= 1<<r - 1
+	}
+
+	return nil
+}
+
+// Encode writes the bitmap to w, as a sequence of literal words preceded by
+// run length words without runs.
+func (b *ewahBitmap) Encode(w io.Writer) error {
+	var compressed []uint64
+	var rlwPos int
+
+	words := b.words
+	for first := true; first || len(words) != 0; first = false {
+		n := len(words)
+		if n > 1<<ewahLiteralBits-1 {
+			n = 1<<ewahLiteralBits - 1
+		}
+
+		rlwPos = len(compressed)
+		compressed = append(compressed, uint64(n)<<(1+ewahRunLengthBits))
+		compressed = append(compressed, words[:n]...)
+		words = words[n:]
+	}
+
+	if err := binary.Write(w, b.size, uint32(len(compressed))); err != nil {
+		return err
+	}
+
+	for _, word := range compressed {
+		if err := binary.WriteUint64(w, word); err != nil {
+			return err
+		}
+	}
+
+	return binary.WriteUint32(w, uint32(rlwPos))
+}
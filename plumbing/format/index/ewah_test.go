@@ -0,0 +1,63 @@
+package index
+
+import (
+	"bytes"
+
+	. "gopkg.in/check.v1"
+)
+
+type EWAHSuite struct{}
+
+var _ = Suite(&EWAHSuite{})
+
+func (s *EWAHSuite) TestEncodeDecode(c *C) {
+	b := newEWAHBitmap(130)
+	b.Set(0)
+	b.Set(64)
+	b.Set(129)
+
+	buf := bytes.NewBuffer(nil)
+	c.Assert(b.Encode(buf), IsNil)
+
+	var output ewahBitmap
+	c.Assert(output.Decode(buf), IsNil)
+	c.Assert(output.size, Equals, uint32(130))
+
+	var bits []int
+	c.Assert(output.Each(func(i int) error {
+		bits = append(bits, i)
+		return nil
+	}), IsNil)
+	c.Assert(bits, DeepEquals, []int{0, 64, 129})
+}
+
+func (s *EWAHSuite) TestDecodeRun(c *C) {
+	// 70 bits, a run of one word of ones and a literal word, as written by git
+	data := []byte{
+		0, 0, 0, 70,
+		0, 0, 0, 2,
+		0, 0, 0, 2, 0, 0, 0, 3,
+		0, 0, 0, 0, 0, 0, 0, 0xff,
+		0, 0, 0, 0,
+	}
+
+	var b ewahBitmap
+	c.Assert(b.Decode(bytes.NewReader(data)), IsNil)
+	c.Assert(b.Get(0), Equals, true)
+	c.Assert(b.Get(63), Equals, true)
+	c.Assert(b.Get(64), Equals, true)
+	c.Assert(b.Get(69), Equals, true)
+	c.Assert(b.Get(70), Equals, false)
+}
+
+func (s *EWAHSuite) TestDecodeMalformed(c *C) {
+	data := []byte{
+		0, 0, 0, 1,
+		0, 0, 0, 1,
+		0, 0, 0, 0, 0, 0, 0, 5,
+		0, 0, 0, 0,
+	}
+
+	var b ewahBitmap
+	c.Assert(b.Decode(bytes.NewReader(data)), Equals, ErrMalformedBitmap)
+}
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"gopkg.in/src-d/go-git.v4/plumbing"
@@ -21,6 +22,8 @@ var (
 	indexSignature          = []byte{'D', 'I', 'R', 'C'}
 	treeExtSignature        = []byte{'T', 'R', 'E', 'E'}
 	resolveUndoExtSignature = []byte{'R', 'E', 'U', 'C'}
+	untrackedExtSignature   = []byte{'U', 'N', 'T', 'R'}
+	fsMonitorExtSignature   = []byte{'F', 'S', 'M', 'N'}
 )
 
 // Stage during merge
@@ -50,6 +53,13 @@ type Index struct {
 	Cache *Tree
 	// ResolveUndo represents the 'Resolve undo' extension
 	ResolveUndo *ResolveUndo
+	// UntrackedCache represents the 'Untracked cache' extension
+	UntrackedCache *UntrackedCache
+	// FSMonitor represents the 'File System Monitor cache' extension
+	FSMonitor *FSMonitor
+	// ModTime is the time the index was written, zero if unknown. It is not
+	// encoded, the storages set it when the index is read or written.
+	ModTime time.Time
 }
 
 // Add creates a new Entry and returns it. The caller should first check that
@@ -59,6 +69,10 @@ func (i *Index) Add(path string) *Entry {
 		Name: filepath.ToSlash(path),
 	}
 
+	if i.UntrackedCache != nil {
+		i.UntrackedCache.Invalidate(e.Name)
+	}
+
 	i.Entries = append(i.Entries, e)
 	return e
 }
@@ -78,6 +92,10 @@ func (i *Index) Entry(path string) (*Entry, error) {
 // Remove remove the entry that match the give path and returns deleted entry.
 func (i *Index) Remove(path string) (*Entry, error) {
 	path = filepath.ToSlash(path)
+	if i.UntrackedCache != nil {
+		i.UntrackedCache.Invalidate(path)
+	}
+
 	for index, e := range i.Entries {
 		if e.Name == path {
 			i.Entries = append(i.Entries[:index], i.Entries[index+1:]...)
@@ -106,6 +124,65 @@ func (i *Index) Glob(pattern string) (matches []*Entry, err error) {
 	return
 }
 
+// IsRacilyClean returns true if the entry was modified in the same second the
+// index was written or later, so its content may have changed without changing
+// its stat information. All the entries are racily clean if the ModTime of the
+// index is unknown.
+// https://git-scm.com/docs/racy-git
+func (i *Index) IsRacilyClean(e *Entry) bool {
+	if i.ModTime.IsZero() {
+		return true
+	}
+
+	return !e.ModifiedAt.Before(i.ModTime.Truncate(time.Second))
+}
+
+// SmudgeRacilyClean sets to zero the size of the racily clean entries, before
+// the index is written again. Once written, the ModTime of the index would no
+// longer flag them as racily clean, the size makes their stat information not
+// match the files, so they are hashed to detect changes.
+//
+// Only the entries modified in the same second as ModTime and not refreshed
+// since the index was read are smudged, the ones matching their entry in the
+// index returned by read, the index as written at ModTime. read is only called
+// if there are racily clean entries. Nothing is done if ModTime is unknown.
+func (i *Index) SmudgeRacilyClean(read func() (*Index, error)) error {
+	if i.ModTime.IsZero() {
+		return nil
+	}
+
+	t := i.ModTime.Truncate(time.Second)
+	var racy []*Entry
+	for _, e := range i.Entries {
+		if e.ModifiedAt.Truncate(time.Second).Equal(t) {
+			racy = append(racy, e)
+		}
+	}
+
+	if len(racy) == 0 {
+		return nil
+	}
+
+	old, err := read()
+	if err != nil {
+		return err
+	}
+
+	written := make(map[string]*Entry, len(old.Entries))
+	for _, e := range old.Entries {
+		written[e.Name] = e
+	}
+
+	for _, e := range racy {
+		o, ok := written[e.Name]
+		if ok && o.Hash == e.Hash && o.Size == e.Size && o.ModifiedAt.Equal(e.ModifiedAt) {
+			e.Size = 0
+		}
+	}
+
+	return nil
+}
+
 // String is equivalent to `git ls-files --stage --debug`
 func (i *Index) String() string {
 	buf := bytes.NewBuffer(nil)
@@ -145,6 +222,16 @@ type Entry struct {
 	// IntentToAdd record only the fact that the path will be added later
 	// https://git-scm.com/docs/git-add ("git add -N")
 	IntentToAdd bool
+	// FSMonitorValid is set if the file monitor reported no changes of the
+	// path since the token of the FSMonitor extension. It is only encoded if
+	// the index has the FSMonitor extension.
+	FSMonitorValid bool
+}
+
+// isExtended returns true if the entry has any of the extended flags set,
+// only supported by the index version 3 or later.
+func (e *Entry) isExtended() bool {
+	return e.IntentToAdd || e.SkipWorktree
 }
 
 func (e Entry) String() string {
@@ -193,3 +280,93 @@ type ResolveUndoEntry struct {
 	Path   string
 	Stages map[Stage]plumbing.Hash
 }
+
+// UntrackedCache saves the untracked files of the directories of the worktree,
+// with the stat information of the directories, so a directory is not read
+// again if it did not change.
+type UntrackedCache struct {
+	// Ident describes the environment where the cache can be used, a
+	// sequence of NUL-terminated strings.
+	Ident string
+	// InfoExclude and ExcludesFile are the stat information and hashes of
+	// the $GIT_DIR/info/exclude file and the core.excludesFile file.
+	InfoExclude, ExcludesFile UntrackedCacheExclude
+	// DirFlags are the flags used to read the directories, see struct
+	// dir_struct of git.
+	DirFlags uint32
+	// ExcludePerDir is the name of the per-directory exclude file, usually
+	// ".gitignore".
+	ExcludePerDir string
+	// Root is the root directory of the worktree, nil if the cache is empty.
+	Root *UntrackedCacheDir
+}
+
+// UntrackedCacheExclude is an exclude file of the untracked cache.
+type UntrackedCacheExclude struct {
+	Stat StatData
+	// Hash of the file content, zero if the file does not exist.
+	Hash plumbing.Hash
+}
+
+// UntrackedCacheDir is a directory of the untracked cache.
+type UntrackedCacheDir struct {
+	// Name of the directory, relative to its parent, empty for the root.
+	Name string
+	// Untracked are the names of the untracked files and directories, the
+	// directories end with a slash.
+	Untracked []string
+	// Dirs are the subdirectories.
+	Dirs []*UntrackedCacheDir
+	// Valid is set if Untracked and Stat are valid.
+	Valid bool
+	// CheckOnly is the "check-only" bit of the directory, see
+	// read_directory_recursive() of git.
+	CheckOnly bool
+	// Stat is the stat information of the directory, when Valid.
+	Stat StatData
+	// ExcludeHash is the hash of the per-directory exclude file, zero if it
+	// does not exist.
+	ExcludeHash plumbing.Hash
+}
+
+// Dir returns the subdirectory with the given name, nil if not found.
+func (d *UntrackedCacheDir) Dir(name string) *UntrackedCacheDir {
+	for _, sub := range d.Dirs {
+		if sub.Name == name {
+			return sub
+		}
+	}
+
+	return nil
+}
+
+// Invalidate invalidates the directories containing the given path, e.g.
+// because it was added to or removed from the index, so they are read again.
+func (c *UntrackedCache) Invalidate(path string) {
+	d := c.Root
+	parts := strings.Split(path, "/")
+	for _, name := range parts {
+		if d == nil {
+			return
+		}
+
+		d.Valid = false
+		d.Untracked = nil
+		d = d.Dir(name)
+	}
+}
+
+// StatData is the stat information of a file or directory.
+type StatData struct {
+	CreatedAt, ModifiedAt time.Time
+	Dev, Inode            uint32
+	UID, GID              uint32
+	Size                  uint32
+}
+
+// FSMonitor is the file system monitor extension, it records the token of the
+// file monitor since which the entries flagged as Entry.FSMonitorValid have
+// not changed.
+type FSMonitor struct {
+	Token string
+}
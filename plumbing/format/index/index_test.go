@@ -2,6 +2,7 @@ package index
 
 import (
 	"path/filepath"
+	"time"
 
 	. "gopkg.in/check.v1"
 )
@@ -74,3 +75,65 @@ func (s *IndexSuite) TestIndexGlob(c *C) {
 	c.Assert(err, IsNil)
 	c.Assert(m, HasLen, 1)
 }
+
+func (s *IndexSuite) TestIndexIsRacilyClean(c *C) {
+	t := time.Date(2018, 1, 1, 10, 0, 0, 500, time.UTC)
+	idx := &Index{ModTime: t}
+
+	c.Assert(idx.IsRacilyClean(&Entry{ModifiedAt: t.Add(-time.Second)}), Equals, false)
+	c.Assert(idx.IsRacilyClean(&Entry{ModifiedAt: t.Add(-time.Nanosecond)}), Equals, true)
+	c.Assert(idx.IsRacilyClean(&Entry{ModifiedAt: t.Add(time.Second)}), Equals, true)
+
+	idx.ModTime = time.Time{}
+	c.Assert(idx.IsRacilyClean(&Entry{ModifiedAt: t.Add(-time.Hour)}), Equals, true)
+}
+
+func (s *IndexSuite) TestIndexSmudgeRacilyClean(c *C) {
+	t := time.Date(2018, 1, 1, 10, 0, 0, 500, time.UTC)
+	entries := func() []*Entry {
+		return []*Entry{
+			{Name: "foo", Size: 42, ModifiedAt: t.Add(-time.Second)},
+			{Name: "bar", Size: 42, ModifiedAt: t.Add(-time.Nanosecond)},
+			{Name: "qux", Size: 42, ModifiedAt: t.Add(-time.Millisecond)},
+			{Name: "baz", Size: 42, ModifiedAt: t.Add(time.Second)},
+		}
+	}
+
+	read := func() (*Index, error) {
+		return &Index{Entries: entries(), ModTime: t}, nil
+	}
+
+	idx := &Index{Entries: entries()}
+	c.Assert(idx.SmudgeRacilyClean(read), IsNil)
+	for _, e := range idx.Entries {
+		c.Assert(e.Size, Equals, uint32(42))
+	}
+
+	// qux was refreshed after the index was read
+	idx.ModTime = t
+	idx.Entries[2].ModifiedAt = t.Add(-2 * time.Millisecond)
+	c.Assert(idx.SmudgeRacilyClean(read), IsNil)
+	c.Assert(idx.Entries[0].Size, Equals, uint32(42))
+	c.Assert(idx.Entries[1].Size, Equals, uint32(0))
+	c.Assert(idx.Entries[2].Size, Equals, uint32(42))
+	c.Assert(idx.Entries[3].Size, Equals, uint32(42))
+}
+
+func (s *IndexSuite) TestUntrackedCacheInvalidate(c *C) {
+	foo := &UntrackedCacheDir{Name: "foo", Valid: true, Untracked: []string{"bar"}}
+	qux := &UntrackedCacheDir{Name: "qux", Valid: true}
+	idx := &Index{UntrackedCache: &UntrackedCache{
+		Root: &UntrackedCacheDir{Valid: true, Dirs: []*UntrackedCacheDir{foo, qux}},
+	}}
+
+	idx.Add("foo/bar")
+	c.Assert(idx.UntrackedCache.Root.Valid, Equals, false)
+	c.Assert(foo.Valid, Equals, false)
+	c.Assert(foo.Untracked, HasLen, 0)
+	c.Assert(qux.Valid, Equals, true)
+
+	qux.Valid = true
+	_, err := idx.Remove("qux/baz")
+	c.Assert(err, Equals, ErrEntryNotFound)
+	c.Assert(qux.Valid, Equals, false)
+}
@@ -0,0 +1,287 @@
+package sshsig
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	certAuthorityOption = "cert-authority"
+	namespacesOption    = "namespaces"
+	validAfterOption    = "valid-after"
+	validBeforeOption   = "valid-before"
+)
+
+// ErrSignerNotAllowed is returned by Verifier.Verify when the key of the
+// signature is not allowed by the allowed signers.
+var ErrSignerNotAllowed = errors.New("ssh signature key not allowed")
+
+// AllowedSigner is an entry of an allowed signers file, as configured in git
+// by gpg.ssh.allowedSignersFile, and described in the ALLOWED SIGNERS section
+// of ssh-keygen(1).
+type AllowedSigner struct {
+	// Principals are the patterns of the principals allowed to sign.
+	Principals []string
+	// CertAuthority is true if Key is a certificate authority, trusted to
+	// sign the certificates of the keys of the principals.
+	CertAuthority bool
+	// Namespaces are the patterns of the namespaces of the signatures
+	// allowed, any namespace is allowed if empty.
+	Namespaces []string
+	// ValidAfter is the time since the key is valid, if not zero.
+	ValidAfter time.Time
+	// ValidBefore is the time until the key is valid, if not zero.
+	ValidBefore time.Time
+	// Key is the public key of the signer or of the certificate authority.
+	Key ssh.PublicKey
+}
+
+// ParseAllowedSigners parses an allowed signers file: one entry per line, with
+// the principals, the options and the public key.
+func ParseAllowedSigners(r io.Reader) ([]*AllowedSigner, error) {
+	var signers []*AllowedSigner
+	s := bufio.NewScanner(r)
+	for n := 1; s.Scan(); n++ {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+
+		signer, err := parseAllowedSigner(line)
+		if err != nil {
+			return nil, fmt.Errorf("allowed signers, line %d: %s", n, err)
+		}
+
+		signers = append(signers, signer)
+	}
+
+	return signers, s.Err()
+}
+
+func parseAllowedSigner(line string) (*AllowedSigner, error) {
+	principals, rest := splitField(line)
+	if principals == "" || rest == "" {
+		return nil, errors.New("missing key")
+	}
+
+	key, _, options, _, err := ssh.ParseAuthorizedKey([]byte(rest))
+	if err != nil {
+		return nil, err
+	}
+
+	signer := &AllowedSigner{
+		Principals: strings.Split(strings.Trim(principals, `"`), ","),
+		Key:        key,
+	}
+
+	for _, o := range options {
+		name, value := o, ""
+		if i := strings.IndexByte(o, '='); i != -1 {
+			name, value = o[:i], strings.Trim(o[i+1:], `"`)
+		}
+
+		switch strings.ToLower(name) {
+		case certAuthorityOption:
+			signer.CertAuthority = true
+		case namespacesOption:
+			signer.Namespaces = strings.Split(value, ",")
+		case validAfterOption:
+			signer.ValidAfter, err = parseTime(value)
+		case validBeforeOption:
+			signer.ValidBefore, err = parseTime(value)
+		default:
+			err = fmt.Errorf("unknown option %q", name)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return signer, nil
+}
+
+// splitField splits the first field of the line, optionally quoted.
+func splitField(line string) (field, rest string) {
+	end := strings.IndexAny(line, " \t")
+	if line[0] == '"' {
+		if i := strings.IndexByte(line[1:], '"'); i != -1 {
+			end = i + 2
+		}
+	}
+
+	if end == -1 || end > len(line) {
+		return line, ""
+	}
+
+	return line[:end], strings.TrimSpace(line[end:])
+}
+
+// parseTime parses the times of the allowed signers: YYYYMMDD[HHMM[SS]], in
+// UTC if suffixed by Z and in local time otherwise.
+func parseTime(value string) (time.Time, error) {
+	loc := time.Local
+	if strings.HasSuffix(value, "Z") {
+		value, loc = value[:len(value)-1], time.UTC
+	}
+
+	var layout string
+	switch len(value) {
+	case 8:
+		layout = "20060102"
+	case 12:
+		layout = "200601021504"
+	case 14:
+		layout = "20060102150405"
+	default:
+		return time.Time{}, fmt.Errorf("invalid time %q", value)
+	}
+
+	return time.ParseInLocation(layout, value, loc)
+}
+
+// allows returns true if the signer is allowed to sign in namespace at the
+// given time.
+func (s *AllowedSigner) allows(namespace string, now time.Time) bool {
+	if len(s.Namespaces) != 0 && !matchList(s.Namespaces, namespace) {
+		return false
+	}
+
+	if !s.ValidAfter.IsZero() && now.Before(s.ValidAfter) {
+		return false
+	}
+
+	return s.ValidBefore.IsZero() || now.Before(s.ValidBefore)
+}
+
+// principal returns the principal allowed to sign with the given key, false
+// if the key is not allowed by the signer.
+func (s *AllowedSigner) principal(key ssh.PublicKey, now time.Time) (string, bool) {
+	cert, ok := key.(*ssh.Certificate)
+	if !ok {
+		if s.CertAuthority || !bytes.Equal(key.Marshal(), s.Key.Marshal()) {
+			return "", false
+		}
+
+		return strings.Join(s.Principals, ","), true
+	}
+
+	if !s.CertAuthority || cert.CertType != ssh.UserCert ||
+		!bytes.Equal(cert.SignatureKey.Marshal(), s.Key.Marshal()) {
+		return "", false
+	}
+
+	checker := &ssh.CertChecker{Clock: func() time.Time { return now }}
+	for _, p := range cert.ValidPrincipals {
+		if matchList(s.Principals, p) && checker.CheckCert(p, cert) == nil {
+			return p, true
+		}
+	}
+
+	return "", false
+}
+
+// matchList returns true if s matches any pattern of the list, and none of
+// the negated patterns, starting with "!".
+func matchList(patterns []string, s string) bool {
+	var matched bool
+	for _, p := range patterns {
+		if strings.HasPrefix(p, "!") {
+			if matchPattern(p[1:], s) {
+				return false
+			}
+
+			continue
+		}
+
+		if matchPattern(p, s) {
+			matched = true
+		}
+	}
+
+	return matched
+}
+
+// matchPattern matches s with a pattern of ssh, where "*" matches any
+// sequence of characters and "?" any single character.
+func matchPattern(pattern, s string) bool {
+	for len(pattern) != 0 {
+		switch pattern[0] {
+		case '*':
+			for i := len(s); i >= 0; i-- {
+				if matchPattern(pattern[1:], s[i:]) {
+					return true
+				}
+			}
+
+			return false
+		case '?':
+			if len(s) == 0 {
+				return false
+			}
+		default:
+			if len(s) == 0 || s[0] != pattern[0] {
+				return false
+			}
+		}
+
+		pattern, s = pattern[1:], s[1:]
+	}
+
+	return len(s) == 0
+}
+
+// Verifier verifies the SSH signatures of the commits and the tags, made in
+// the git namespace with the keys allowed by the allowed signers. It is an
+// implementation of object.Verifier.
+type Verifier struct {
+	// AllowedSigners are the signers allowed.
+	AllowedSigners []*AllowedSigner
+	// Clock returns the time the signers are checked at, time.Now if nil.
+	Clock func() time.Time
+}
+
+// NewVerifier returns a Verifier of the signers of the given allowed signers
+// file.
+func NewVerifier(allowedSigners io.Reader) (*Verifier, error) {
+	signers, err := ParseAllowedSigners(allowedSigners)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Verifier{AllowedSigners: signers}, nil
+}
+
+// Verify verifies the armored SSH signature of the message, returning the
+// principal of the signer. ErrSignerNotAllowed is returned if the signature
+// is good but the key is not allowed.
+func (v *Verifier) Verify(message io.Reader, signature []byte) (string, error) {
+	key, err := Verify(message, signature, GitNamespace)
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	if v.Clock != nil {
+		now = v.Clock()
+	}
+
+	for _, s := range v.AllowedSigners {
+		if !s.allows(GitNamespace, now) {
+			continue
+		}
+
+		if p, ok := s.principal(key, now); ok {
+			return p, nil
+		}
+	}
+
+	return "", ErrSignerNotAllowed
+}
@@ -0,0 +1,121 @@
+package sshsig
+
+import (
+	"crypto/rand"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ed25519"
+	"golang.org/x/crypto/ssh"
+
+	. "gopkg.in/check.v1"
+)
+
+type AllowedSignersSuite struct{}
+
+var _ = Suite(&AllowedSignersSuite{})
+
+func (s *AllowedSignersSuite) TestParseAllowedSigners(c *C) {
+	signers, err := ParseAllowedSigners(strings.NewReader(`
+# comment
+foo@example.com,bar@example.com ` + ed25519PublicKey + `
+"*@example.com" cert-authority,namespaces="git,file",valid-after="20200101",valid-before="20300101120000Z" ` + ed25519PublicKey + `
+`))
+	c.Assert(err, IsNil)
+	c.Assert(signers, HasLen, 2)
+
+	c.Assert(signers[0].Principals, DeepEquals, []string{"foo@example.com", "bar@example.com"})
+	c.Assert(signers[0].CertAuthority, Equals, false)
+	c.Assert(signers[0].Namespaces, HasLen, 0)
+	c.Assert(signers[0].Key.Type(), Equals, "ssh-ed25519")
+
+	c.Assert(signers[1].Principals, DeepEquals, []string{"*@example.com"})
+	c.Assert(signers[1].CertAuthority, Equals, true)
+	c.Assert(signers[1].Namespaces, DeepEquals, []string{"git", "file"})
+	c.Assert(signers[1].ValidAfter.Equal(time.Date(2020, 1, 1, 0, 0, 0, 0, time.Local)), Equals, true)
+	c.Assert(signers[1].ValidBefore.Equal(time.Date(2030, 1, 1, 12, 0, 0, 0, time.UTC)), Equals, true)
+}
+
+func (s *AllowedSignersSuite) TestParseAllowedSignersMalformed(c *C) {
+	_, err := ParseAllowedSigners(strings.NewReader("foo@example.com\n"))
+	c.Assert(err, ErrorMatches, "allowed signers, line 1: missing key")
+
+	_, err = ParseAllowedSigners(strings.NewReader("\nfoo@example.com foo=bar " + ed25519PublicKey))
+	c.Assert(err, ErrorMatches, `allowed signers, line 2: unknown option "foo"`)
+}
+
+func (s *AllowedSignersSuite) TestVerifier(c *C) {
+	v, err := NewVerifier(strings.NewReader("foo@example.com " + ed25519PublicKey))
+	c.Assert(err, IsNil)
+
+	principal, err := v.Verify(strings.NewReader("hello\n"), []byte(ed25519Signature))
+	c.Assert(err, IsNil)
+	c.Assert(principal, Equals, "foo@example.com")
+
+	_, err = v.Verify(strings.NewReader("hello\n"), []byte(rsaSignature))
+	c.Assert(err, Equals, ErrSignerNotAllowed)
+}
+
+func (s *AllowedSignersSuite) TestVerifierNotAllowed(c *C) {
+	for _, line := range []string{
+		`foo@example.com namespaces="file" `,
+		`foo@example.com valid-before="20200101" `,
+		`foo@example.com valid-after="21000101" `,
+		`foo@example.com cert-authority `,
+	} {
+		v, err := NewVerifier(strings.NewReader(line + ed25519PublicKey))
+		c.Assert(err, IsNil)
+
+		_, err = v.Verify(strings.NewReader("hello\n"), []byte(ed25519Signature))
+		c.Assert(err, Equals, ErrSignerNotAllowed)
+	}
+}
+
+func (s *AllowedSignersSuite) TestVerifierCertAuthority(c *C) {
+	ca := s.newSigner(c)
+	user := s.newSigner(c)
+
+	cert := &ssh.Certificate{
+		Key:             user.PublicKey(),
+		CertType:        ssh.UserCert,
+		ValidPrincipals: []string{"foo@example.com"},
+		ValidBefore:     ssh.CertTimeInfinity,
+	}
+	c.Assert(cert.SignCert(rand.Reader, ca), IsNil)
+
+	signer, err := ssh.NewCertSigner(cert, user)
+	c.Assert(err, IsNil)
+
+	sig, err := NewSigner(signer).Sign(strings.NewReader("hello\n"))
+	c.Assert(err, IsNil)
+
+	authority := string(ssh.MarshalAuthorizedKey(ca.PublicKey()))
+	v, err := NewVerifier(strings.NewReader("*@example.com cert-authority " + authority))
+	c.Assert(err, IsNil)
+
+	principal, err := v.Verify(strings.NewReader("hello\n"), sig)
+	c.Assert(err, IsNil)
+	c.Assert(principal, Equals, "foo@example.com")
+
+	v, err = NewVerifier(strings.NewReader("bar@example.com cert-authority " + authority))
+	c.Assert(err, IsNil)
+
+	_, err = v.Verify(strings.NewReader("hello\n"), sig)
+	c.Assert(err, Equals, ErrSignerNotAllowed)
+}
+
+func (s *AllowedSignersSuite) newSigner(c *C) ssh.Signer {
+	_, k, err := ed25519.GenerateKey(rand.Reader)
+	c.Assert(err, IsNil)
+
+	signer, err := NewSignerFromKey(k)
+	c.Assert(err, IsNil)
+	return signer
+}
+
+func (s *AllowedSignersSuite) TestMatchList(c *C) {
+	c.Assert(matchList([]string{"*@example.com"}, "foo@example.com"), Equals, true)
+	c.Assert(matchList([]string{"f?o@example.com"}, "foo@example.com"), Equals, true)
+	c.Assert(matchList([]string{"*@example.com", "!foo@*"}, "foo@example.com"), Equals, false)
+	c.Assert(matchList([]string{"bar@example.com"}, "foo@example.com"), Equals, false)
+}
@@ -0,0 +1,325 @@
+// Package sshsig implements the SSH signatures, as created by ssh-keygen -Y
+// sign and used by git to sign commits and tags when gpg.format is ssh, and
+// the allowed signers files used to verify them.
+//
+// https://github.com/openssh/openssh-portable/blob/master/PROTOCOL.sshsig
+package sshsig
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"errors"
+	"hash"
+	"io"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	// GitNamespace is the namespace of the signatures made by git.
+	GitNamespace = "git"
+
+	magicPreamble = "SSHSIG"
+	sigVersion    = 1
+
+	armorBegin      = "-----BEGIN SSH SIGNATURE-----"
+	armorEnd        = "-----END SSH SIGNATURE-----"
+	armorLineLength = 70
+
+	hashSHA256 = "sha256"
+	hashSHA512 = "sha512"
+
+	sigAlgoRSASHA256 = "rsa-sha2-256"
+	sigAlgoRSASHA512 = "rsa-sha2-512"
+)
+
+var (
+	// ErrMalformedSignature is returned by Verify when the signature is not
+	// a valid armored SSH signature.
+	ErrMalformedSignature = errors.New("malformed ssh signature")
+	// ErrUnsupportedVersion is returned by Verify when the version of the
+	// signature is not supported.
+	ErrUnsupportedVersion = errors.New("unsupported ssh signature version")
+	// ErrUnsupportedHashAlgorithm is returned by Verify when the hash
+	// algorithm of the signature is not supported.
+	ErrUnsupportedHashAlgorithm = errors.New("unsupported ssh signature hash algorithm")
+	// ErrNamespaceMismatch is returned by Verify when the signature was made
+	// for another namespace.
+	ErrNamespaceMismatch = errors.New("ssh signature namespace mismatch")
+	// ErrSHA1RSASignature is returned by Sign and Verify for the RSA
+	// signatures using SHA-1, not allowed by SSH signatures.
+	ErrSHA1RSASignature = errors.New("rsa ssh signatures using sha1 are not allowed")
+)
+
+// signedData is the data signed, after the magic preamble.
+type signedData struct {
+	Namespace     string
+	Reserved      string
+	HashAlgorithm string
+	Hash          []byte
+}
+
+// signature is the signature blob, after the magic preamble.
+type signature struct {
+	Version       uint32
+	PublicKey     []byte
+	Namespace     string
+	Reserved      string
+	HashAlgorithm string
+	Signature     []byte
+}
+
+// algorithmSigner is implemented by the signers able to sign with a given
+// signature algorithm, as ssh.AlgorithmSigner of newer versions of
+// golang.org/x/crypto/ssh. It is required to sign with RSA keys.
+type algorithmSigner interface {
+	ssh.Signer
+	SignWithAlgorithm(rand io.Reader, data []byte, algorithm string) (*ssh.Signature, error)
+}
+
+// Sign signs the message for the given namespace, returning the armored
+// signature. The signers of RSA keys must be able to sign with the
+// rsa-sha2-512 algorithm, as the signers returned by NewSignerFromKey.
+func Sign(s ssh.Signer, namespace string, message io.Reader) ([]byte, error) {
+	data, err := signedMessage(namespace, hashSHA512, message)
+	if err != nil {
+		return nil, err
+	}
+
+	var sig *ssh.Signature
+	if s.PublicKey().Type() == ssh.KeyAlgoRSA {
+		as, ok := s.(algorithmSigner)
+		if !ok {
+			return nil, ErrSHA1RSASignature
+		}
+
+		sig, err = as.SignWithAlgorithm(rand.Reader, data, sigAlgoRSASHA512)
+	} else {
+		sig, err = s.Sign(rand.Reader, data)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	blob := append([]byte(magicPreamble), ssh.Marshal(&signature{
+		Version:       sigVersion,
+		PublicKey:     s.PublicKey().Marshal(),
+		Namespace:     namespace,
+		HashAlgorithm: hashSHA512,
+		Signature:     ssh.Marshal(sig),
+	})...)
+
+	return armor(blob), nil
+}
+
+// Verify verifies the armored signature of the message, made for the given
+// namespace, returning the public key of the signer. The key is not checked,
+// it must be looked up in the keys allowed, as Verifier does.
+func Verify(message io.Reader, armored []byte, namespace string) (ssh.PublicKey, error) {
+	blob, err := dearmor(armored)
+	if err != nil {
+		return nil, err
+	}
+
+	if !bytes.HasPrefix(blob, []byte(magicPreamble)) {
+		return nil, ErrMalformedSignature
+	}
+
+	var sig signature
+	if err := ssh.Unmarshal(blob[len(magicPreamble):], &sig); err != nil {
+		return nil, ErrMalformedSignature
+	}
+
+	if sig.Version != sigVersion {
+		return nil, ErrUnsupportedVersion
+	}
+
+	if sig.Namespace != namespace {
+		return nil, ErrNamespaceMismatch
+	}
+
+	pub, err := ssh.ParsePublicKey(sig.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &ssh.Signature{}
+	if err := ssh.Unmarshal(sig.Signature, s); err != nil {
+		return nil, ErrMalformedSignature
+	}
+
+	data, err := signedMessage(namespace, sig.HashAlgorithm, message)
+	if err != nil {
+		return nil, err
+	}
+
+	key := pub
+	if cert, ok := pub.(*ssh.Certificate); ok {
+		key = cert.Key
+	}
+
+	if err := verifySignature(key, data, s); err != nil {
+		return nil, err
+	}
+
+	return pub, nil
+}
+
+// signedMessage returns the data signed for the message: the magic preamble,
+// the namespace and the hash of the message.
+func signedMessage(namespace, algorithm string, message io.Reader) ([]byte, error) {
+	var h hash.Hash
+	switch algorithm {
+	case hashSHA256:
+		h = sha256.New()
+	case hashSHA512:
+		h = sha512.New()
+	default:
+		return nil, ErrUnsupportedHashAlgorithm
+	}
+
+	if _, err := io.Copy(h, message); err != nil {
+		return nil, err
+	}
+
+	return append([]byte(magicPreamble), ssh.Marshal(&signedData{
+		Namespace:     namespace,
+		HashAlgorithm: algorithm,
+		Hash:          h.Sum(nil),
+	})...), nil
+}
+
+// verifySignature verifies the signature of data made with key, the RSA
+// signatures using SHA-2 are verified here, since they are not supported by
+// all the versions of golang.org/x/crypto/ssh.
+func verifySignature(key ssh.PublicKey, data []byte, sig *ssh.Signature) error {
+	if key.Type() != ssh.KeyAlgoRSA {
+		return key.Verify(data, sig)
+	}
+
+	var h crypto.Hash
+	switch sig.Format {
+	case sigAlgoRSASHA256:
+		h = crypto.SHA256
+	case sigAlgoRSASHA512:
+		h = crypto.SHA512
+	default:
+		return ErrSHA1RSASignature
+	}
+
+	ck, ok := key.(ssh.CryptoPublicKey)
+	if !ok {
+		return ErrMalformedSignature
+	}
+
+	pub, ok := ck.CryptoPublicKey().(*rsa.PublicKey)
+	if !ok {
+		return ErrMalformedSignature
+	}
+
+	d := h.New()
+	d.Write(data)
+	return rsa.VerifyPKCS1v15(pub, h, d.Sum(nil), sig.Blob)
+}
+
+func armor(blob []byte) []byte {
+	encoded := base64.StdEncoding.EncodeToString(blob)
+
+	var b bytes.Buffer
+	b.WriteString(armorBegin + "\n")
+	for len(encoded) > armorLineLength {
+		b.WriteString(encoded[:armorLineLength] + "\n")
+		encoded = encoded[armorLineLength:]
+	}
+
+	b.WriteString(encoded + "\n")
+	b.WriteString(armorEnd + "\n")
+	return b.Bytes()
+}
+
+func dearmor(armored []byte) ([]byte, error) {
+	lines := strings.Split(strings.TrimSpace(string(armored)), "\n")
+	if len(lines) < 2 ||
+		strings.TrimSpace(lines[0]) != armorBegin ||
+		strings.TrimSpace(lines[len(lines)-1]) != armorEnd {
+		return nil, ErrMalformedSignature
+	}
+
+	var encoded string
+	for _, l := range lines[1 : len(lines)-1] {
+		encoded += strings.TrimSpace(l)
+	}
+
+	blob, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, ErrMalformedSignature
+	}
+
+	return blob, nil
+}
+
+// NewSignerFromKey returns a signer of the given private key, as
+// ssh.NewSignerFromKey, able to sign with RSA keys using SHA-2.
+func NewSignerFromKey(key interface{}) (ssh.Signer, error) {
+	k, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return ssh.NewSignerFromKey(key)
+	}
+
+	s, err := ssh.NewSignerFromKey(k)
+	if err != nil {
+		return nil, err
+	}
+
+	return &rsaSigner{Signer: s, key: k}, nil
+}
+
+type rsaSigner struct {
+	ssh.Signer
+	key *rsa.PrivateKey
+}
+
+func (s *rsaSigner) SignWithAlgorithm(rand io.Reader, data []byte, algorithm string) (*ssh.Signature, error) {
+	var h crypto.Hash
+	switch algorithm {
+	case sigAlgoRSASHA256:
+		h = crypto.SHA256
+	case sigAlgoRSASHA512:
+		h = crypto.SHA512
+	default:
+		return s.Signer.Sign(rand, data)
+	}
+
+	d := h.New()
+	d.Write(data)
+	blob, err := rsa.SignPKCS1v15(rand, s.key, h, d.Sum(nil))
+	if err != nil {
+		return nil, err
+	}
+
+	return &ssh.Signature{Format: algorithm, Blob: blob}, nil
+}
+
+// Signer signs the commits and the tags with an SSH key, in the git
+// namespace. It is an implementation of object.Signer.
+type Signer struct {
+	signer ssh.Signer
+}
+
+// NewSigner returns a Signer signing with the given ssh.Signer, such as a key
+// returned by NewSignerFromKey or a key of an SSH agent.
+func NewSigner(s ssh.Signer) *Signer {
+	return &Signer{s}
+}
+
+// Sign returns the armored SSH signature of the message.
+func (s *Signer) Sign(message io.Reader) ([]byte, error) {
+	return Sign(s.signer, GitNamespace, message)
+}
@@ -0,0 +1,109 @@
+package sshsig
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/ed25519"
+	"golang.org/x/crypto/ssh"
+
+	. "gopkg.in/check.v1"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type SSHSigSuite struct{}
+
+var _ = Suite(&SSHSigSuite{})
+
+// signatures created by ssh-keygen -Y sign -n git, of the message "hello\n"
+const (
+	ed25519PublicKey = "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIKk6QgLiZgX14tLux/VZP/FjP/IaRyzFhQx6OewcSe+U test"
+	ed25519Signature = `-----BEGIN SSH SIGNATURE-----
+U1NIU0lHAAAAAQAAADMAAAALc3NoLWVkMjU1MTkAAAAgqTpCAuJmBfXi0u7H9Vk/8WM/8h
+pHLMWFDHo57BxJ75QAAAADZ2l0AAAAAAAAAAZzaGE1MTIAAABTAAAAC3NzaC1lZDI1NTE5
+AAAAQBH+ZAdQ4KNu2PVDrjv+4ojVFh7bD4fX8WZhIs1WHS6JDpq3cIx8cAmcqfgBa957eW
+YA/4u9nGe1GlNDA4PPLAM=
+-----END SSH SIGNATURE-----
+`
+	rsaSignature = `-----BEGIN SSH SIGNATURE-----
+U1NIU0lHAAAAAQAAARcAAAAHc3NoLXJzYQAAAAMBAAEAAAEBAMUWUcXTCdKOIJjRLJErDJ
+7wg6biGGAYXgUBXbgTKqWiqy6KC7ccJzPEUtYkjTE2PG28fG75EkZJ21P7B6Nd3+MxkpmB
+SzOq0uHExvFhFz+lItLZyFJ1XAoqV3jbvWhC66iZihpYY5Cpej7x+1LqGb/5CNaf0+4S2X
++4l/oBZ6pKuL4+lVNnPkjh9udMBAfULqvJAiMbvTjNAN+EFSSF2cYHm9GSmxNzgbL+TJiS
+bT7tbwRfaWk95M4bqdl87UGjaEh4BBTrvfqY8Mbi+jhj0WtQDNQAahyhVpVj3GiyZom1cD
+5K9GHfXuCoxnoyyYf08Cr8ePyJEcA9Z8h68GrRyGsAAAADZ2l0AAAAAAAAAAZzaGE1MTIA
+AAEUAAAADHJzYS1zaGEyLTUxMgAAAQBLM97n78uAVXDI/5en+lr0wB8Le3h12DrMVUq6xZ
+GL9msWbtZj4m6nDbpU2cePyclQrQ7bs443qL72/lNGEwm1pEQR3kipNM4AX8zfyNsxMK70
+KNtCtyIsJt9Ez/ad4jCALxScTNDCh5PDrIosDzQLuk1PqLeL6OyriVzYJEivcHEPi8MUni
+NDjRGp71XYc98z39vU+dTUl8S2ZkxU+1+69Fep5waaI10htRVnxn9A3lHhLWpTxsh20GnM
+2cJZWQ38EbCKAyk4xOmLBLLCqdK+9m/WMqycxkgsZgQZSHXJaU5/PGzl4qpJhiD2o/+qqL
+Rcr1DYng29EhjxPyZiJIXh
+-----END SSH SIGNATURE-----
+`
+)
+
+func (s *SSHSigSuite) TestVerify(c *C) {
+	expected, _, _, _, err := ssh.ParseAuthorizedKey([]byte(ed25519PublicKey))
+	c.Assert(err, IsNil)
+
+	key, err := Verify(strings.NewReader("hello\n"), []byte(ed25519Signature), GitNamespace)
+	c.Assert(err, IsNil)
+	c.Assert(key.Marshal(), DeepEquals, expected.Marshal())
+
+	key, err = Verify(strings.NewReader("hello\n"), []byte(rsaSignature), GitNamespace)
+	c.Assert(err, IsNil)
+	c.Assert(key.Type(), Equals, ssh.KeyAlgoRSA)
+}
+
+func (s *SSHSigSuite) TestVerifyBadSignature(c *C) {
+	_, err := Verify(strings.NewReader("bye\n"), []byte(ed25519Signature), GitNamespace)
+	c.Assert(err, NotNil)
+
+	_, err = Verify(strings.NewReader("bye\n"), []byte(rsaSignature), GitNamespace)
+	c.Assert(err, NotNil)
+}
+
+func (s *SSHSigSuite) TestVerifyNamespaceMismatch(c *C) {
+	_, err := Verify(strings.NewReader("hello\n"), []byte(ed25519Signature), "file")
+	c.Assert(err, Equals, ErrNamespaceMismatch)
+}
+
+func (s *SSHSigSuite) TestVerifyMalformed(c *C) {
+	_, err := Verify(strings.NewReader("hello\n"), []byte("foo"), GitNamespace)
+	c.Assert(err, Equals, ErrMalformedSignature)
+
+	_, err = Verify(strings.NewReader("hello\n"), []byte(armorBegin+"\nZm9v\n"+armorEnd), GitNamespace)
+	c.Assert(err, Equals, ErrMalformedSignature)
+}
+
+func (s *SSHSigSuite) TestSign(c *C) {
+	_, ed25519Key, err := ed25519.GenerateKey(rand.Reader)
+	c.Assert(err, IsNil)
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	c.Assert(err, IsNil)
+
+	for _, k := range []interface{}{ed25519Key, rsaKey} {
+		signer, err := NewSignerFromKey(k)
+		c.Assert(err, IsNil)
+
+		sig, err := Sign(signer, GitNamespace, strings.NewReader("hello\n"))
+		c.Assert(err, IsNil)
+
+		key, err := Verify(strings.NewReader("hello\n"), sig, GitNamespace)
+		c.Assert(err, IsNil)
+		c.Assert(key.Marshal(), DeepEquals, signer.PublicKey().Marshal())
+	}
+}
+
+func (s *SSHSigSuite) TestSignRSAWithSHA1(c *C) {
+	k, err := rsa.GenerateKey(rand.Reader, 1024)
+	c.Assert(err, IsNil)
+	signer, err := ssh.NewSignerFromKey(k)
+	c.Assert(err, IsNil)
+
+	_, err = Sign(signer, GitNamespace, strings.NewReader("hello\n"))
+	c.Assert(err, Equals, ErrSHA1RSASignature)
+}
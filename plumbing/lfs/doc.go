@@ -0,0 +1,18 @@
+// Package lfs implements the support of Git LFS (Large File Storage)
+// repositories: the pointer files stored in the blobs in place of the large
+// files, the storages of the LFS objects and a filter driver converting
+// between both, to be assigned to the files with the filter=lfs attribute.
+//
+// The pointers are text files with the version of the spec, the sha256 of the
+// content of the file and its size:
+//
+//   version https://git-lfs.github.com/spec/v1
+//   oid sha256:4d7a214614ab2935c943f9e0ff69d22eadbb8f32b1258daaa5e2ca24d17e2393
+//   size 12345
+//
+// The LFS objects are stored locally in a FilesystemStorage, usually rooted at
+// $GIT_DIR/lfs/objects, and remotely in a server implementing the LFS batch
+// API, accessed with an HTTPStorage.
+//
+// More info: https://github.com/git-lfs/git-lfs/blob/master/docs/spec.md
+package lfs
@@ -0,0 +1,89 @@
+package lfs
+
+import (
+	"bytes"
+)
+
+// Filter is the filter driver of the files with the filter=lfs attribute. It
+// replaces the content of the files by pointers when they are added to the
+// index, keeping the content in the local storage, and replaces the pointers
+// by the content of the files when they are checked out.
+type Filter struct {
+	// Local is the storage where the objects are kept, usually rooted at
+	// $GIT_DIR/lfs/objects.
+	Local Storage
+	// Remote, if not nil, is the storage where the objects missing in
+	// Local are downloaded from, usually a HTTPStorage.
+	Remote Storage
+}
+
+// NewFilter returns a new Filter with the given local and remote storages,
+// remote can be nil.
+func NewFilter(local, remote Storage) *Filter {
+	return &Filter{Local: local, Remote: remote}
+}
+
+// DryClean returns the pointer of the content of the file, as Clean does,
+// without storing it. It is used when the file is only compared with the
+// index, e.g. by Status.
+func (f *Filter) DryClean(path string, content []byte) ([]byte, error) {
+	if IsPointer(content) {
+		return content, nil
+	}
+
+	return []byte(NewPointer(content).String()), nil
+}
+
+// Clean stores the content of the file in the local storage, returning its
+// pointer. The content already being a pointer is returned as is.
+func (f *Filter) Clean(path string, content []byte) ([]byte, error) {
+	if IsPointer(content) {
+		return content, nil
+	}
+
+	p := NewPointer(content)
+	err := f.Local.HasObject(p)
+	if err == ErrObjectNotFound {
+		err = f.Local.SetObject(p, bytes.NewReader(content))
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return []byte(p.String()), nil
+}
+
+// Smudge returns the content of the object of the pointer, from the local
+// storage or downloaded from the remote one. If content is not a pointer, it
+// is returned as is.
+func (f *Filter) Smudge(path string, content []byte) ([]byte, error) {
+	var p Pointer
+	if err := p.Decode(bytes.NewReader(content)); err != nil {
+		if err == ErrInvalidPointer {
+			return content, nil
+		}
+
+		return nil, err
+	}
+
+	buf := bytes.NewBuffer(make([]byte, 0, p.Size))
+	err := readObject(f.Local, p, buf)
+	switch {
+	case err == nil:
+		return buf.Bytes(), nil
+	case err != ErrObjectNotFound || f.Remote == nil:
+		return nil, err
+	}
+
+	buf.Reset()
+	if err := readObject(f.Remote, p, buf); err != nil {
+		return nil, err
+	}
+
+	if err := f.Local.SetObject(p, bytes.NewReader(buf.Bytes())); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,79 @@
+package lfs
+
+import (
+	"strings"
+
+	. "gopkg.in/check.v1"
+	"gopkg.in/src-d/go-billy.v4/memfs"
+)
+
+type FilterSuite struct{}
+
+var _ = Suite(&FilterSuite{})
+
+func (s *FilterSuite) TestClean(c *C) {
+	local := NewFilesystemStorage(memfs.New())
+	f := NewFilter(local, nil)
+
+	content, err := f.Clean("foo.bin", []byte("foo"))
+	c.Assert(err, IsNil)
+	c.Assert(string(content), Equals, fooPointer)
+	c.Assert(local.HasObject(NewPointer([]byte("foo"))), IsNil)
+
+	content, err = f.Clean("foo.bin", []byte(fooPointer))
+	c.Assert(err, IsNil)
+	c.Assert(string(content), Equals, fooPointer)
+}
+
+func (s *FilterSuite) TestDryClean(c *C) {
+	local := NewFilesystemStorage(memfs.New())
+	f := NewFilter(local, nil)
+
+	content, err := f.DryClean("foo.bin", []byte("foo"))
+	c.Assert(err, IsNil)
+	c.Assert(string(content), Equals, fooPointer)
+	c.Assert(local.HasObject(NewPointer([]byte("foo"))), Equals, ErrObjectNotFound)
+
+	content, err = f.DryClean("foo.bin", []byte(fooPointer))
+	c.Assert(err, IsNil)
+	c.Assert(string(content), Equals, fooPointer)
+}
+
+func (s *FilterSuite) TestSmudge(c *C) {
+	local := NewFilesystemStorage(memfs.New())
+	c.Assert(local.SetObject(NewPointer([]byte("foo")), strings.NewReader("foo")), IsNil)
+
+	f := NewFilter(local, nil)
+	content, err := f.Smudge("foo.bin", []byte(fooPointer))
+	c.Assert(err, IsNil)
+	c.Assert(string(content), Equals, "foo")
+
+	content, err = f.Smudge("foo.bin", []byte("bar"))
+	c.Assert(err, IsNil)
+	c.Assert(string(content), Equals, "bar")
+}
+
+func (s *FilterSuite) TestSmudgeNotFound(c *C) {
+	f := NewFilter(NewFilesystemStorage(memfs.New()), nil)
+	_, err := f.Smudge("foo.bin", []byte(fooPointer))
+	c.Assert(err, Equals, ErrObjectNotFound)
+}
+
+func (s *FilterSuite) TestSmudgeRemote(c *C) {
+	server := newLFSServer()
+	defer server.Close()
+
+	p := NewPointer([]byte("foo"))
+	c.Assert(server.storage.SetObject(p, strings.NewReader("foo")), IsNil)
+
+	remote := NewHTTPStorage(Endpoint(server.URL+"/repo"), nil)
+	remote.Header.Set("Authorization", "Bearer secret")
+
+	local := NewFilesystemStorage(memfs.New())
+	f := NewFilter(local, remote)
+
+	content, err := f.Smudge("foo.bin", []byte(fooPointer))
+	c.Assert(err, IsNil)
+	c.Assert(string(content), Equals, "foo")
+	c.Assert(local.HasObject(p), IsNil)
+}
@@ -0,0 +1,249 @@
+package lfs
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"gopkg.in/src-d/go-git.v4/utils/ioutil"
+)
+
+const (
+	mediaType      = "application/vnd.git-lfs+json"
+	batchPath      = "/objects/batch"
+	basicTransfer  = "basic"
+	downloadAction = "download"
+	uploadAction   = "upload"
+	verifyAction   = "verify"
+	endpointSuffix = "/info/lfs"
+)
+
+// Endpoint returns the default LFS server endpoint of a remote with the given
+// http(s) URL, as git-lfs does when lfs.url is not configured.
+func Endpoint(url string) string {
+	url = strings.TrimSuffix(url, "/")
+	if !strings.HasSuffix(url, ".git") {
+		url += ".git"
+	}
+
+	return url + endpointSuffix
+}
+
+// HTTPStorage is a Storage backed by a LFS server, using the batch API and
+// the basic transfer adapter.
+type HTTPStorage struct {
+	// Endpoint is the URL of the LFS server, e.g. the one returned by the
+	// Endpoint function.
+	Endpoint string
+	// Header contains additional headers sent in the requests to the
+	// server, e.g. the Authorization header.
+	Header http.Header
+
+	client *http.Client
+}
+
+// NewHTTPStorage returns a HTTPStorage for the LFS server at endpoint, if
+// client is nil http.DefaultClient is used.
+func NewHTTPStorage(endpoint string, client *http.Client) *HTTPStorage {
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	return &HTTPStorage{
+		Endpoint: strings.TrimSuffix(endpoint, "/"),
+		Header:   make(http.Header),
+		client:   client,
+	}
+}
+
+// Object downloads the content of the object of the pointer p.
+func (s *HTTPStorage) Object(p Pointer) (io.ReadCloser, error) {
+	obj, err := s.batch(downloadAction, p)
+	if err != nil {
+		return nil, err
+	}
+
+	a, ok := obj.Actions[downloadAction]
+	if !ok {
+		return nil, fmt.Errorf("lfs: missing download action for %s", p.Oid)
+	}
+
+	res, err := s.do(http.MethodGet, a, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return res.Body, nil
+}
+
+// HasObject returns nil if the object of the pointer p is in the server.
+func (s *HTTPStorage) HasObject(p Pointer) error {
+	_, err := s.batch(downloadAction, p)
+	return err
+}
+
+// SetObject uploads the object of the pointer p, reading its content from r.
+// Nothing is uploaded if the server already has the object.
+func (s *HTTPStorage) SetObject(p Pointer, r io.Reader) (err error) {
+	obj, err := s.batch(uploadAction, p)
+	if err != nil {
+		return err
+	}
+
+	a, ok := obj.Actions[uploadAction]
+	if !ok {
+		return nil
+	}
+
+	buf := bytes.NewBuffer(nil)
+	if err := copyObject(buf, r, p); err != nil {
+		return err
+	}
+
+	res, err := s.do(http.MethodPut, a, buf)
+	if err != nil {
+		return err
+	}
+
+	if err := res.Body.Close(); err != nil {
+		return err
+	}
+
+	v, ok := obj.Actions[verifyAction]
+	if !ok {
+		return nil
+	}
+
+	body, err := json.Marshal(batchObject{Oid: p.Oid, Size: p.Size})
+	if err != nil {
+		return err
+	}
+
+	res, err = s.do(http.MethodPost, v, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	return res.Body.Close()
+}
+
+type batchRequest struct {
+	Operation string        `json:"operation"`
+	Transfers []string      `json:"transfers"`
+	Objects   []batchObject `json:"objects"`
+}
+
+type batchResponse struct {
+	Transfer string        `json:"transfer"`
+	Objects  []batchObject `json:"objects"`
+	Message  string        `json:"message"`
+}
+
+type batchObject struct {
+	Oid     string                  `json:"oid"`
+	Size    int64                   `json:"size"`
+	Actions map[string]*batchAction `json:"actions,omitempty"`
+	Error   *batchError             `json:"error,omitempty"`
+}
+
+type batchAction struct {
+	Href   string            `json:"href"`
+	Header map[string]string `json:"header,omitempty"`
+}
+
+type batchError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (s *HTTPStorage) batch(operation string, p Pointer) (obj *batchObject, err error) {
+	body, err := json.Marshal(batchRequest{
+		Operation: operation,
+		Transfers: []string{basicTransfer},
+		Objects:   []batchObject{{Oid: p.Oid, Size: p.Size}},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, s.Endpoint+batchPath, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Accept", mediaType)
+	req.Header.Set("Content-Type", mediaType)
+
+	res, err := s.doRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	defer ioutil.CheckClose(res.Body, &err)
+
+	var batch batchResponse
+	if err := json.NewDecoder(res.Body).Decode(&batch); err != nil {
+		return nil, err
+	}
+
+	if batch.Transfer != "" && batch.Transfer != basicTransfer {
+		return nil, fmt.Errorf("lfs: unsupported transfer adapter %q", batch.Transfer)
+	}
+
+	for _, o := range batch.Objects {
+		if o.Oid != p.Oid {
+			continue
+		}
+
+		switch {
+		case o.Error == nil:
+			return &o, nil
+		case o.Error.Code == http.StatusNotFound:
+			return nil, ErrObjectNotFound
+		default:
+			return nil, fmt.Errorf("lfs: object %s: %s", p.Oid, o.Error.Message)
+		}
+	}
+
+	return nil, ErrObjectNotFound
+}
+
+func (s *HTTPStorage) do(method string, a *batchAction, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequest(method, a.Href, body)
+	if err != nil {
+		return nil, err
+	}
+
+	for k, v := range a.Header {
+		req.Header.Set(k, v)
+	}
+
+	return s.doRequest(req)
+}
+
+func (s *HTTPStorage) doRequest(req *http.Request) (*http.Response, error) {
+	for k, v := range s.Header {
+		if _, ok := req.Header[k]; !ok {
+			req.Header[k] = v
+		}
+	}
+
+	res, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if res.StatusCode >= http.StatusOK && res.StatusCode < http.StatusMultipleChoices {
+		return res, nil
+	}
+
+	defer res.Body.Close()
+	if res.StatusCode == http.StatusNotFound {
+		return nil, ErrObjectNotFound
+	}
+
+	return nil, fmt.Errorf("lfs: unexpected status code %d: %s %s", res.StatusCode, req.Method, req.URL)
+}
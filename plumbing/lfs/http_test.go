@@ -0,0 +1,155 @@
+package lfs
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	. "gopkg.in/check.v1"
+	"gopkg.in/src-d/go-billy.v4/memfs"
+)
+
+// lfsServer is a minimal LFS server, keeping the objects in a
+// FilesystemStorage.
+type lfsServer struct {
+	*httptest.Server
+	storage  *FilesystemStorage
+	requests []string
+}
+
+func newLFSServer() *lfsServer {
+	s := &lfsServer{storage: NewFilesystemStorage(memfs.New())}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+	return s
+}
+
+func (s *lfsServer) handle(w http.ResponseWriter, r *http.Request) {
+	s.requests = append(s.requests, r.Method+" "+r.URL.Path)
+	if r.Header.Get("Authorization") != "Bearer secret" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	switch {
+	case r.URL.Path == "/repo.git/info/lfs/objects/batch":
+		s.batch(w, r)
+	case strings.HasPrefix(r.URL.Path, "/objects/"):
+		s.object(w, r, strings.TrimPrefix(r.URL.Path, "/objects/"))
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (s *lfsServer) batch(w http.ResponseWriter, r *http.Request) {
+	var req batchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	res := batchResponse{Transfer: basicTransfer}
+	for _, o := range req.Objects {
+		p := Pointer{Oid: o.Oid, Size: o.Size}
+		action := &batchAction{
+			Href:   s.URL + "/objects/" + o.Oid,
+			Header: map[string]string{"Authorization": "Bearer secret"},
+		}
+
+		exists := s.storage.HasObject(p) == nil
+		switch {
+		case req.Operation == downloadAction && exists:
+			o.Actions = map[string]*batchAction{downloadAction: action}
+		case req.Operation == downloadAction:
+			o.Error = &batchError{Code: http.StatusNotFound, Message: "not found"}
+		case !exists:
+			o.Actions = map[string]*batchAction{uploadAction: action}
+		}
+
+		res.Objects = append(res.Objects, o)
+	}
+
+	w.Header().Set("Content-Type", mediaType)
+	json.NewEncoder(w).Encode(res)
+}
+
+func (s *lfsServer) object(w http.ResponseWriter, r *http.Request, oid string) {
+	switch r.Method {
+	case http.MethodGet:
+		obj, err := s.storage.Object(Pointer{Oid: oid})
+		if err != nil {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		defer obj.Close()
+		content, _ := ioutil.ReadAll(obj)
+		w.Write(content)
+	case http.MethodPut:
+		p := Pointer{Oid: oid, Size: r.ContentLength}
+		if err := s.storage.SetObject(p, r.Body); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}
+}
+
+type HTTPStorageSuite struct {
+	server *lfsServer
+}
+
+var _ = Suite(&HTTPStorageSuite{})
+
+func (s *HTTPStorageSuite) SetUpTest(c *C) {
+	s.server = newLFSServer()
+}
+
+func (s *HTTPStorageSuite) TearDownTest(c *C) {
+	s.server.Close()
+}
+
+func (s *HTTPStorageSuite) newStorage() *HTTPStorage {
+	st := NewHTTPStorage(Endpoint(s.server.URL+"/repo"), nil)
+	st.Header.Set("Authorization", "Bearer secret")
+	return st
+}
+
+func (s *HTTPStorageSuite) TestEndpoint(c *C) {
+	c.Assert(Endpoint("https://example.com/foo/bar"), Equals, "https://example.com/foo/bar.git/info/lfs")
+	c.Assert(Endpoint("https://example.com/foo/bar.git/"), Equals, "https://example.com/foo/bar.git/info/lfs")
+}
+
+func (s *HTTPStorageSuite) TestSetObject(c *C) {
+	st := s.newStorage()
+	p := NewPointer([]byte("foo"))
+
+	c.Assert(st.HasObject(p), Equals, ErrObjectNotFound)
+	c.Assert(st.SetObject(p, strings.NewReader("foo")), IsNil)
+	c.Assert(s.server.storage.HasObject(p), IsNil)
+	c.Assert(st.HasObject(p), IsNil)
+
+	r, err := st.Object(p)
+	c.Assert(err, IsNil)
+	content, err := ioutil.ReadAll(r)
+	c.Assert(err, IsNil)
+	c.Assert(r.Close(), IsNil)
+	c.Assert(string(content), Equals, "foo")
+
+	// the object is not uploaded again
+	s.server.requests = nil
+	c.Assert(st.SetObject(p, strings.NewReader("foo")), IsNil)
+	c.Assert(s.server.requests, DeepEquals, []string{
+		"POST /repo.git/info/lfs/objects/batch",
+	})
+}
+
+func (s *HTTPStorageSuite) TestObjectNotFound(c *C) {
+	_, err := s.newStorage().Object(NewPointer([]byte("foo")))
+	c.Assert(err, Equals, ErrObjectNotFound)
+}
+
+func (s *HTTPStorageSuite) TestUnauthorized(c *C) {
+	st := NewHTTPStorage(Endpoint(s.server.URL+"/repo"), nil)
+	err := st.HasObject(NewPointer([]byte("foo")))
+	c.Assert(err, ErrorMatches, "lfs: unexpected status code 401: .*")
+}
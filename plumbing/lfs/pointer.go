@@ -0,0 +1,147 @@
+package lfs
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const (
+	// Version is the URL identifying the version of the pointer spec.
+	Version = "https://git-lfs.github.com/spec/v1"
+	// MaxPointerSize is the maximum size of a pointer file.
+	MaxPointerSize = 1024
+
+	versionKey = "version"
+	oidKey     = "oid"
+	sizeKey    = "size"
+	oidPrefix  = "sha256:"
+)
+
+var (
+	// ErrInvalidPointer is returned when the content is not a valid LFS
+	// pointer.
+	ErrInvalidPointer = errors.New("invalid lfs pointer")
+)
+
+// Pointer is the content of a blob representing a file stored in LFS.
+type Pointer struct {
+	// Oid is the hex encoded sha256 of the content of the file.
+	Oid string
+	// Size is the size of the content of the file.
+	Size int64
+}
+
+// NewPointer returns the pointer of the given content.
+func NewPointer(content []byte) Pointer {
+	sum := sha256.Sum256(content)
+	return Pointer{
+		Oid:  hex.EncodeToString(sum[:]),
+		Size: int64(len(content)),
+	}
+}
+
+// Decode reads a pointer from r. ErrInvalidPointer is returned if the content
+// of r is not a pointer.
+func (p *Pointer) Decode(r io.Reader) error {
+	content, err := readPointerContent(r)
+	if err != nil {
+		return err
+	}
+
+	return p.decode(content)
+}
+
+func readPointerContent(r io.Reader) ([]byte, error) {
+	buf := bytes.NewBuffer(nil)
+	n, err := io.CopyN(buf, r, MaxPointerSize+1)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	if n > MaxPointerSize {
+		return nil, ErrInvalidPointer
+	}
+
+	return buf.Bytes(), nil
+}
+
+func (p *Pointer) decode(content []byte) error {
+	var version, oid, size string
+
+	s := bufio.NewScanner(bytes.NewReader(content))
+	for s.Scan() {
+		line := s.Text()
+		if line == "" {
+			continue
+		}
+
+		sep := strings.IndexByte(line, ' ')
+		if sep == -1 {
+			return ErrInvalidPointer
+		}
+
+		key, value := line[:sep], line[sep+1:]
+		switch {
+		case version == "" && key != versionKey:
+			return ErrInvalidPointer
+		case key == versionKey:
+			version = value
+		case key == oidKey:
+			oid = value
+		case key == sizeKey:
+			size = value
+		}
+	}
+
+	if version != Version || !strings.HasPrefix(oid, oidPrefix) {
+		return ErrInvalidPointer
+	}
+
+	p.Oid = oid[len(oidPrefix):]
+	if len(p.Oid) != sha256.Size*2 {
+		return ErrInvalidPointer
+	}
+
+	if _, err := hex.DecodeString(p.Oid); err != nil {
+		return ErrInvalidPointer
+	}
+
+	var err error
+	if p.Size, err = strconv.ParseInt(size, 10, 64); err != nil || p.Size < 0 {
+		return ErrInvalidPointer
+	}
+
+	return nil
+}
+
+// Encode writes the pointer to w.
+func (p Pointer) Encode(w io.Writer) error {
+	_, err := io.WriteString(w, p.String())
+	return err
+}
+
+// String returns the content of the pointer file.
+func (p Pointer) String() string {
+	return fmt.Sprintf("%s %s\n%s %s%s\n%s %d\n",
+		versionKey, Version,
+		oidKey, oidPrefix, p.Oid,
+		sizeKey, p.Size,
+	)
+}
+
+// IsPointer returns true if content is a valid pointer.
+func IsPointer(content []byte) bool {
+	if len(content) > MaxPointerSize {
+		return false
+	}
+
+	var p Pointer
+	return p.decode(content) == nil
+}
@@ -0,0 +1,74 @@
+package lfs
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	. "gopkg.in/check.v1"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type PointerSuite struct{}
+
+var _ = Suite(&PointerSuite{})
+
+const (
+	fooOid     = "2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae"
+	fooPointer = "version https://git-lfs.github.com/spec/v1\n" +
+		"oid sha256:" + fooOid + "\n" +
+		"size 3\n"
+)
+
+func (s *PointerSuite) TestNewPointer(c *C) {
+	p := NewPointer([]byte("foo"))
+	c.Assert(p.Oid, Equals, fooOid)
+	c.Assert(p.Size, Equals, int64(3))
+	c.Assert(p.String(), Equals, fooPointer)
+}
+
+func (s *PointerSuite) TestDecode(c *C) {
+	var p Pointer
+	err := p.Decode(strings.NewReader(fooPointer))
+	c.Assert(err, IsNil)
+	c.Assert(p, DeepEquals, NewPointer([]byte("foo")))
+}
+
+func (s *PointerSuite) TestDecodeExtraKeys(c *C) {
+	content := "version https://git-lfs.github.com/spec/v1\n" +
+		"ext-0-foo sha256:" + fooOid + "\n" +
+		"oid sha256:" + fooOid + "\n" +
+		"size 3\n"
+
+	var p Pointer
+	err := p.Decode(strings.NewReader(content))
+	c.Assert(err, IsNil)
+	c.Assert(p.Oid, Equals, fooOid)
+}
+
+func (s *PointerSuite) TestDecodeInvalid(c *C) {
+	for _, content := range []string{
+		"",
+		"foo",
+		"oid sha256:" + fooOid + "\nversion https://git-lfs.github.com/spec/v1\nsize 3\n",
+		"version https://git-lfs.github.com/spec/v1\noid sha256:foo\nsize 3\n",
+		"version https://git-lfs.github.com/spec/v1\noid md5:" + fooOid + "\nsize 3\n",
+		"version https://git-lfs.github.com/spec/v1\noid sha256:" + fooOid + "\nsize -3\n",
+		"version https://git-lfs.github.com/spec/v2\noid sha256:" + fooOid + "\nsize 3\n",
+		fooPointer + strings.Repeat("\n", MaxPointerSize),
+	} {
+		var p Pointer
+		err := p.Decode(strings.NewReader(content))
+		c.Assert(err, Equals, ErrInvalidPointer, Commentf("content: %q", content))
+		c.Assert(IsPointer([]byte(content)), Equals, false)
+	}
+}
+
+func (s *PointerSuite) TestEncode(c *C) {
+	buf := bytes.NewBuffer(nil)
+	err := NewPointer([]byte("foo")).Encode(buf)
+	c.Assert(err, IsNil)
+	c.Assert(buf.String(), Equals, fooPointer)
+	c.Assert(IsPointer(buf.Bytes()), Equals, true)
+}
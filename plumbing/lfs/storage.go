@@ -0,0 +1,132 @@
+package lfs
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+
+	"gopkg.in/src-d/go-billy.v4"
+	"gopkg.in/src-d/go-git.v4/utils/ioutil"
+)
+
+var (
+	// ErrObjectNotFound is returned when the object of a pointer is not
+	// found in a storage.
+	ErrObjectNotFound = errors.New("lfs object not found")
+	// ErrObjectMismatch is returned when the content of an object doesn't
+	// match the oid or the size of its pointer.
+	ErrObjectMismatch = errors.New("lfs object doesn't match its pointer")
+)
+
+// Storage stores the content of the files of the LFS pointers.
+type Storage interface {
+	// Object returns a reader of the content of the object of the pointer p.
+	// ErrObjectNotFound is returned if the object is not in the storage.
+	Object(p Pointer) (io.ReadCloser, error)
+	// SetObject stores the object of the pointer p, reading its content
+	// from r.
+	SetObject(p Pointer, r io.Reader) error
+	// HasObject returns nil if the object of the pointer p is in the
+	// storage, ErrObjectNotFound otherwise.
+	HasObject(p Pointer) error
+}
+
+// FilesystemStorage is a Storage keeping the objects in a billy.Filesystem,
+// using the same layout as git-lfs in $GIT_DIR/lfs/objects.
+type FilesystemStorage struct {
+	fs billy.Filesystem
+}
+
+// NewFilesystemStorage returns a FilesystemStorage keeping the objects in
+// the given filesystem.
+func NewFilesystemStorage(fs billy.Filesystem) *FilesystemStorage {
+	return &FilesystemStorage{fs: fs}
+}
+
+func (s *FilesystemStorage) path(p Pointer) string {
+	if len(p.Oid) < 4 {
+		return p.Oid
+	}
+
+	return s.fs.Join(p.Oid[0:2], p.Oid[2:4], p.Oid)
+}
+
+// Object returns a reader of the content of the object of the pointer p.
+func (s *FilesystemStorage) Object(p Pointer) (io.ReadCloser, error) {
+	f, err := s.fs.Open(s.path(p))
+	if os.IsNotExist(err) {
+		return nil, ErrObjectNotFound
+	}
+
+	return f, err
+}
+
+// HasObject returns nil if the object of the pointer p is in the storage.
+func (s *FilesystemStorage) HasObject(p Pointer) error {
+	fi, err := s.fs.Stat(s.path(p))
+	if os.IsNotExist(err) || (err == nil && fi.Size() != p.Size) {
+		return ErrObjectNotFound
+	}
+
+	return err
+}
+
+// SetObject stores the object of the pointer p, the content read from r is
+// verified against the pointer before it is stored.
+func (s *FilesystemStorage) SetObject(p Pointer, r io.Reader) (err error) {
+	path := s.path(p)
+	if err := s.fs.MkdirAll(s.fs.Join(path, ".."), 0755); err != nil {
+		return err
+	}
+
+	tmp, err := s.fs.TempFile(s.fs.Join(path, ".."), "tmp_obj_")
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if err != nil {
+			_ = s.fs.Remove(tmp.Name())
+		}
+	}()
+
+	if err = copyObject(tmp, r, p); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+
+	return s.fs.Rename(tmp.Name(), path)
+}
+
+// copyObject copies the content of the object of p from r to w, returning
+// ErrObjectMismatch if it doesn't match the pointer.
+func copyObject(w io.Writer, r io.Reader, p Pointer) error {
+	h := sha256.New()
+	n, err := io.Copy(io.MultiWriter(w, h), r)
+	if err != nil {
+		return err
+	}
+
+	if n != p.Size || hex.EncodeToString(h.Sum(nil)) != p.Oid {
+		return ErrObjectMismatch
+	}
+
+	return nil
+}
+
+// readObject reads the whole content of the object of p from s.
+func readObject(s Storage, p Pointer, w io.Writer) (err error) {
+	r, err := s.Object(p)
+	if err != nil {
+		return err
+	}
+
+	defer ioutil.CheckClose(r, &err)
+	return copyObject(w, r, p)
+}
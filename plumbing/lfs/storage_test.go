@@ -0,0 +1,65 @@
+package lfs
+
+import (
+	"bytes"
+	"io/ioutil"
+	"strings"
+
+	. "gopkg.in/check.v1"
+	"gopkg.in/src-d/go-billy.v4/memfs"
+)
+
+type FilesystemStorageSuite struct{}
+
+var _ = Suite(&FilesystemStorageSuite{})
+
+func (s *FilesystemStorageSuite) TestSetObject(c *C) {
+	fs := memfs.New()
+	st := NewFilesystemStorage(fs)
+
+	p := NewPointer([]byte("foo"))
+	c.Assert(st.HasObject(p), Equals, ErrObjectNotFound)
+
+	_, err := st.Object(p)
+	c.Assert(err, Equals, ErrObjectNotFound)
+
+	err = st.SetObject(p, strings.NewReader("foo"))
+	c.Assert(err, IsNil)
+	c.Assert(st.HasObject(p), IsNil)
+
+	_, err = fs.Stat("2c/26/" + fooOid)
+	c.Assert(err, IsNil)
+
+	r, err := st.Object(p)
+	c.Assert(err, IsNil)
+	content, err := ioutil.ReadAll(r)
+	c.Assert(err, IsNil)
+	c.Assert(r.Close(), IsNil)
+	c.Assert(string(content), Equals, "foo")
+}
+
+func (s *FilesystemStorageSuite) TestSetObjectMismatch(c *C) {
+	fs := memfs.New()
+	st := NewFilesystemStorage(fs)
+
+	p := NewPointer([]byte("foo"))
+	err := st.SetObject(p, strings.NewReader("bar"))
+	c.Assert(err, Equals, ErrObjectMismatch)
+	c.Assert(st.HasObject(p), Equals, ErrObjectNotFound)
+
+	fis, err := fs.ReadDir("2c/26")
+	c.Assert(err, IsNil)
+	c.Assert(fis, HasLen, 0)
+}
+
+func (s *FilesystemStorageSuite) TestReadObjectMismatch(c *C) {
+	fs := memfs.New()
+	st := NewFilesystemStorage(fs)
+
+	p := NewPointer([]byte("foo"))
+	c.Assert(st.SetObject(p, strings.NewReader("foo")), IsNil)
+
+	p.Size = 4
+	err := readObject(st, p, bytes.NewBuffer(nil))
+	c.Assert(err, Equals, ErrObjectMismatch)
+}
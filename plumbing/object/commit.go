@@ -2,11 +2,11 @@ package object
 
 import (
 	"bufio"
-	"bytes"
 	"context"
 	"errors"
 	"fmt"
 	"io"
+	stdioutil "io/ioutil"
 	"strings"
 
 	"golang.org/x/crypto/openpgp"
@@ -20,6 +20,10 @@ const (
 	beginpgp  string = "-----BEGIN PGP SIGNATURE-----"
 	endpgp    string = "-----END PGP SIGNATURE-----"
 	headerpgp string = "gpgsig"
+
+	headerEncoding  = "encoding"
+	headerMergeTag  = "mergetag"
+	headerpgpsha256 = "gpgsig-sha256"
 )
 
 // Hash represents the hash of an object
@@ -38,7 +42,8 @@ type Commit struct {
 	// Committer is the one performing the commit, might be different from
 	// Author.
 	Committer Signature
-	// PGPSignature is the PGP signature of the commit.
+	// PGPSignature is the armored signature of the commit, an OpenPGP, SSH or
+	// X.509 signature despite its name.
 	PGPSignature string
 	// Message is the commit message, contains arbitrary text.
 	Message string
@@ -46,8 +51,18 @@ type Commit struct {
 	TreeHash plumbing.Hash
 	// ParentHashes are the hashes of the parent commits of the commit.
 	ParentHashes []plumbing.Hash
-
-	s storer.EncodedObjectStorer
+	// Encoding is the encoding of the message, empty if it is UTF-8.
+	Encoding string
+	// MergeTag is the content of the tag object merged by the commit, as
+	// added by git merge when merging an annotated tag.
+	MergeTag string
+	// ExtraHeaders are the headers of the commit not known by go-git.
+	ExtraHeaders []ExtraHeader
+
+	// headers is the order of the optional headers of the decoded commit,
+	// used to encode them in the same order.
+	headers []string
+	s       storer.EncodedObjectStorer
 }
 
 // GetCommit gets a commit from an object storer and decodes it.
@@ -173,59 +188,59 @@ func (c *Commit) Decode(o plumbing.EncodedObject) (err error) {
 
 	r := bufio.NewReader(reader)
 
-	var message bool
-	var pgpsig bool
-	for {
-		line, err := r.ReadBytes('\n')
-		if err != nil && err != io.EOF {
-			return err
-		}
-
-		if pgpsig {
-			if len(line) > 0 && line[0] == ' ' {
-				line = bytes.TrimLeft(line, " ")
-				c.PGPSignature += string(line)
-				continue
-			} else {
-				pgpsig = false
+	err = readHeaders(r, func(key, value string) error {
+		switch key {
+		case "tree":
+			c.TreeHash = plumbing.NewHash(value)
+			return nil
+		case "parent":
+			c.ParentHashes = append(c.ParentHashes, plumbing.NewHash(value))
+			return nil
+		case "author":
+			c.Author.Decode([]byte(value))
+			return nil
+		case "committer":
+			c.Committer.Decode([]byte(value))
+			return nil
+		case headerEncoding:
+			if c.Encoding == "" {
+				c.Encoding = value
+				break
 			}
-		}
 
-		if !message {
-			line = bytes.TrimSpace(line)
-			if len(line) == 0 {
-				message = true
-				continue
+			c.ExtraHeaders = append(c.ExtraHeaders, ExtraHeader{key, value})
+		case headerMergeTag:
+			if c.MergeTag == "" {
+				c.MergeTag = value + "\n"
+				break
 			}
 
-			split := bytes.SplitN(line, []byte{' '}, 2)
-
-			var data []byte
-			if len(split) == 2 {
-				data = split[1]
+			c.ExtraHeaders = append(c.ExtraHeaders, ExtraHeader{key, value})
+		case headerpgp:
+			if c.PGPSignature == "" {
+				c.PGPSignature = value + "\n"
+				break
 			}
 
-			switch string(split[0]) {
-			case "tree":
-				c.TreeHash = plumbing.NewHash(string(data))
-			case "parent":
-				c.ParentHashes = append(c.ParentHashes, plumbing.NewHash(string(data)))
-			case "author":
-				c.Author.Decode(data)
-			case "committer":
-				c.Committer.Decode(data)
-			case headerpgp:
-				c.PGPSignature += string(data) + "\n"
-				pgpsig = true
-			}
-		} else {
-			c.Message += string(line)
+			c.ExtraHeaders = append(c.ExtraHeaders, ExtraHeader{key, value})
+		default:
+			c.ExtraHeaders = append(c.ExtraHeaders, ExtraHeader{key, value})
 		}
 
-		if err == io.EOF {
-			return nil
-		}
+		c.headers = append(c.headers, key)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	message, err := stdioutil.ReadAll(r)
+	if err != nil {
+		return err
 	}
+
+	c.Message = string(message)
+	return nil
 }
 
 // Encode transforms a Commit into a plumbing.EncodedObject.
@@ -268,29 +283,60 @@ func (b *Commit) encode(o plumbing.EncodedObject, includeSig bool) (err error) {
 		return err
 	}
 
-	if b.PGPSignature != "" && includeSig {
-		if _, err = fmt.Fprint(w, "\n"+headerpgp+" "); err != nil {
-			return err
-		}
+	if _, err = fmt.Fprint(w, "\n"); err != nil {
+		return err
+	}
 
-		// Split all the signature lines and re-write with a left padding and
-		// newline. Use join for this so it's clear that a newline should not be
-		// added after this section, as it will be added when the message is
-		// printed.
-		signature := strings.TrimSuffix(b.PGPSignature, "\n")
-		lines := strings.Split(signature, "\n")
-		if _, err = fmt.Fprint(w, strings.Join(lines, "\n ")); err != nil {
+	for _, h := range b.optionalHeaders(includeSig) {
+		if err = writeHeader(w, h.Key, h.Value); err != nil {
 			return err
 		}
 	}
 
-	if _, err = fmt.Fprintf(w, "\n\n%s", b.Message); err != nil {
+	if _, err = fmt.Fprintf(w, "\n%s", b.Message); err != nil {
 		return err
 	}
 
 	return err
 }
 
+// optionalHeaders returns the headers written after the committer: encoding,
+// mergetag, the extra headers and gpgsig, or in the order they were decoded.
+// The signatures are not included if includeSig is false.
+func (b *Commit) optionalHeaders(includeSig bool) []ExtraHeader {
+	var headers []ExtraHeader
+	if b.Encoding != "" {
+		headers = append(headers, ExtraHeader{headerEncoding, b.Encoding})
+	}
+
+	if b.MergeTag != "" {
+		headers = append(headers, ExtraHeader{headerMergeTag, strings.TrimSuffix(b.MergeTag, "\n")})
+	}
+
+	for _, h := range b.ExtraHeaders {
+		if includeSig || (h.Key != headerpgp && h.Key != headerpgpsha256) {
+			headers = append(headers, h)
+		}
+	}
+
+	if b.PGPSignature != "" && includeSig {
+		headers = append(headers, ExtraHeader{headerpgp, strings.TrimSuffix(b.PGPSignature, "\n")})
+	}
+
+	sorted := make([]ExtraHeader, 0, len(headers))
+	for _, key := range b.headers {
+		for i, h := range headers {
+			if h.Key == key {
+				sorted = append(sorted, h)
+				headers = append(headers[:i], headers[i+1:]...)
+				break
+			}
+		}
+	}
+
+	return append(sorted, headers...)
+}
+
 // Stats shows the status of commit.
 func (c *Commit) Stats() (FileStats, error) {
 	// Get the previous commit.
@@ -350,6 +396,27 @@ func (c *Commit) Verify(armoredKeyRing string) (*openpgp.Entity, error) {
 	return openpgp.CheckArmoredDetachedSignature(keyring, er, signature)
 }
 
+// VerifySignature verifies the signature of the commit with the given Verifier,
+// whatever its format is, returning the identity of the signer. ErrUnsigned is
+// returned if the commit is not signed.
+func (c *Commit) VerifySignature(v Verifier) (string, error) {
+	if c.PGPSignature == "" {
+		return "", ErrUnsigned
+	}
+
+	encoded := &plumbing.MemoryObject{}
+	if err := c.encode(encoded, false); err != nil {
+		return "", err
+	}
+
+	er, err := encoded.Reader()
+	if err != nil {
+		return "", err
+	}
+
+	return v.Verify(er, []byte(c.PGPSignature))
+}
+
 func indent(t string) string {
 	var output []string
 	for _, line := range strings.Split(t, "\n") {
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"io"
+	"io/ioutil"
 	"strings"
 	"time"
 
@@ -374,6 +375,133 @@ RUysgqjcpT8+iQM1PblGfHR4XAhuOqN5Fx06PSaFZhqvWFezJ28/CLyX5q+oIVk=
 	c.Assert(decoded.PGPSignature, Equals, commit.PGPSignature)
 }
 
+func (s *SuiteCommit) TestDecodeEncodeExtraHeaders(c *C) {
+	mergetag := "object 9ae5ec19e1d1fdb2dd75c03d0ee35cd7dd7ab3ca\n" +
+		"type commit\n" +
+		"tag v1.0.0\n" +
+		"tagger Foo <foo@example.local> 1136239445 -0700\n" +
+		"\n" +
+		"v1.0.0\n" +
+		"-----BEGIN PGP SIGNATURE-----\n" +
+		"\n" +
+		"iQEcBAABAgAGBQJTZbQlAAoJEF0+sviABDDrZbQH/09PfE51KPVPlanr6q1v4/Ut\n" +
+		"-----END PGP SIGNATURE-----\n"
+
+	raw := "tree eba74343e2f15d62adedfd8c883ee0262b5c8021\n" +
+		"parent 35e85108805c84807bc66a02d91535e1e24b38b9\n" +
+		"parent b8e471f58bcbca63b07bda20e428190409c2db47\n" +
+		"author Foo <foo@example.local> 1136239445 -0700\n" +
+		"committer Bar <bar@example.local> 1136239445 -0700\n" +
+		"encoding ISO-8859-1\n" +
+		"mergetag " + strings.Replace(strings.TrimSuffix(mergetag, "\n"), "\n", "\n ", -1) + "\n" +
+		"change-id qpvuntsmwlqtpsluzzsnyyzlmlwvmlnu\n" +
+		"gpgsig -----BEGIN PGP SIGNATURE-----\n" +
+		" \n" +
+		" iQEcBAABAgAGBQJTZbQlAAoJEF0+sviABDDrZbQH/09PfE51KPVPlanr6q1v4/Ut\n" +
+		" -----END PGP SIGNATURE-----\n" +
+		"gpgsig-sha256 -----BEGIN PGP SIGNATURE-----\n" +
+		" -----END PGP SIGNATURE-----\n" +
+		"\n" +
+		"Merge tag 'v1.0.0'\n"
+
+	obj := &plumbing.MemoryObject{}
+	obj.SetType(plumbing.CommitObject)
+	_, err := obj.Write([]byte(raw))
+	c.Assert(err, IsNil)
+
+	commit := &Commit{}
+	c.Assert(commit.Decode(obj), IsNil)
+	c.Assert(commit.ParentHashes, HasLen, 2)
+	c.Assert(commit.Committer.Name, Equals, "Bar")
+	c.Assert(commit.Encoding, Equals, "ISO-8859-1")
+	c.Assert(commit.MergeTag, Equals, mergetag)
+	c.Assert(commit.PGPSignature, Equals, "-----BEGIN PGP SIGNATURE-----\n"+
+		"\n"+
+		"iQEcBAABAgAGBQJTZbQlAAoJEF0+sviABDDrZbQH/09PfE51KPVPlanr6q1v4/Ut\n"+
+		"-----END PGP SIGNATURE-----\n")
+	c.Assert(commit.ExtraHeaders, DeepEquals, []ExtraHeader{
+		{"change-id", "qpvuntsmwlqtpsluzzsnyyzlmlwvmlnu"},
+		{"gpgsig-sha256", "-----BEGIN PGP SIGNATURE-----\n-----END PGP SIGNATURE-----"},
+	})
+	c.Assert(commit.Message, Equals, "Merge tag 'v1.0.0'\n")
+
+	encoded := &plumbing.MemoryObject{}
+	c.Assert(commit.Encode(encoded), IsNil)
+	c.Assert(encoded.Hash(), Equals, obj.Hash())
+
+	tag := &Tag{}
+	o := &plumbing.MemoryObject{}
+	o.SetType(plumbing.TagObject)
+	_, err = o.Write([]byte(mergetag))
+	c.Assert(err, IsNil)
+	c.Assert(tag.Decode(o), IsNil)
+	c.Assert(tag.Name, Equals, "v1.0.0")
+
+	// the signatures are not included in the payload signed
+	payload := &plumbing.MemoryObject{}
+	c.Assert(commit.encode(payload, false), IsNil)
+	r, err := payload.Reader()
+	c.Assert(err, IsNil)
+	content, err := ioutil.ReadAll(r)
+	c.Assert(err, IsNil)
+	c.Assert(strings.Contains(string(content), "gpgsig"), Equals, false)
+	c.Assert(strings.Contains(string(content), "change-id"), Equals, true)
+}
+
+func (s *SuiteCommit) TestDecodeEncodeHeadersOrder(c *C) {
+	raw := "tree eba74343e2f15d62adedfd8c883ee0262b5c8021\n" +
+		"author Foo <foo@example.local> 1136239445 -0700\n" +
+		"committer Bar <bar@example.local> 1136239445 -0700\n" +
+		"gpgsig -----BEGIN PGP SIGNATURE-----\n" +
+		" -----END PGP SIGNATURE-----\n" +
+		"foo bar\n" +
+		" baz\n" +
+		"encoding ISO-8859-1\n" +
+		"\n" +
+		"Message\n"
+
+	obj := &plumbing.MemoryObject{}
+	obj.SetType(plumbing.CommitObject)
+	_, err := obj.Write([]byte(raw))
+	c.Assert(err, IsNil)
+
+	commit := &Commit{}
+	c.Assert(commit.Decode(obj), IsNil)
+	c.Assert(commit.ExtraHeaders, DeepEquals, []ExtraHeader{{"foo", "bar\nbaz"}})
+
+	encoded := &plumbing.MemoryObject{}
+	c.Assert(commit.Encode(encoded), IsNil)
+	c.Assert(encoded.Hash(), Equals, obj.Hash())
+
+	// the headers set are written in the canonical order
+	commit = &Commit{
+		TreeHash:     commit.TreeHash,
+		Author:       commit.Author,
+		Committer:    commit.Committer,
+		PGPSignature: commit.PGPSignature,
+		ExtraHeaders: commit.ExtraHeaders,
+		Encoding:     commit.Encoding,
+		Message:      commit.Message,
+	}
+
+	encoded = &plumbing.MemoryObject{}
+	c.Assert(commit.Encode(encoded), IsNil)
+	r, err := encoded.Reader()
+	c.Assert(err, IsNil)
+	content, err := ioutil.ReadAll(r)
+	c.Assert(err, IsNil)
+	c.Assert(string(content), Equals, "tree eba74343e2f15d62adedfd8c883ee0262b5c8021\n"+
+		"author Foo <foo@example.local> 1136239445 -0700\n"+
+		"committer Bar <bar@example.local> 1136239445 -0700\n"+
+		"encoding ISO-8859-1\n"+
+		"foo bar\n"+
+		" baz\n"+
+		"gpgsig -----BEGIN PGP SIGNATURE-----\n"+
+		" -----END PGP SIGNATURE-----\n"+
+		"\n"+
+		"Message\n")
+}
+
 func (s *SuiteCommit) TestStat(c *C) {
 	aCommit := s.commit(c, plumbing.NewHash("6ecf0ef2c2dffb796033e5a02219af86ec6584e5"))
 	fileStats, err := aCommit.Stats()
@@ -464,6 +592,17 @@ sYyf9RfOnw/KUFAQbdtvLx3ikODQC+D3KBtuKI9ISHQfgw==
 
 	_, ok := e.Identities["Sunny <me@darkowlzz.space>"]
 	c.Assert(ok, Equals, true)
+
+	verifier, err := NewOpenPGPVerifier(armoredKeyRing)
+	c.Assert(err, IsNil)
+
+	id, err := commit.VerifySignature(verifier)
+	c.Assert(err, IsNil)
+	c.Assert(id, Equals, "Sunny <me@darkowlzz.space>")
+
+	commit.PGPSignature = ""
+	_, err = commit.VerifySignature(verifier)
+	c.Assert(err, Equals, ErrUnsigned)
 }
 
 func (s *SuiteCommit) TestPatchCancel(c *C) {
@@ -3,11 +3,13 @@
 package object
 
 import (
+	"bufio"
 	"bytes"
 	"errors"
 	"fmt"
 	"io"
 	"strconv"
+	"strings"
 	"time"
 
 	"gopkg.in/src-d/go-git.v4/plumbing"
@@ -164,6 +166,69 @@ func (s *Signature) String() string {
 	return fmt.Sprintf("%s <%s>", s.Name, s.Email)
 }
 
+// ExtraHeader is a header of a commit or a tag not known by go-git, such as
+// gpgsig-sha256 or any custom header. They are kept, in the same order, to
+// encode the object without changes.
+type ExtraHeader struct {
+	// Key is the name of the header.
+	Key string
+	// Value is the value of the header, the lines of a multi-line value
+	// are joined with "\n".
+	Value string
+}
+
+// readHeaders calls f with every header read from r, up to the empty line
+// starting the message. The continuation lines of a multi-line header start
+// with a space, that is removed from the value.
+func readHeaders(r *bufio.Reader, f func(key, value string) error) error {
+	var key, value string
+	var pending bool
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return err
+		}
+
+		if pending && strings.HasPrefix(line, " ") {
+			value += "\n" + strings.TrimSuffix(line[1:], "\n")
+		} else {
+			if pending {
+				if err := f(key, value); err != nil {
+					return err
+				}
+
+				pending = false
+			}
+
+			line = strings.TrimSuffix(line, "\n")
+			if len(line) == 0 {
+				return nil // Start of message
+			}
+
+			split := strings.SplitN(line, " ", 2)
+			key, value, pending = split[0], "", true
+			if len(split) == 2 {
+				value = split[1]
+			}
+		}
+
+		if err == io.EOF {
+			if pending {
+				return f(key, value)
+			}
+
+			return nil
+		}
+	}
+}
+
+// writeHeader writes a header to w, writing every line of a multi-line value
+// as a continuation line.
+func writeHeader(w io.Writer, key, value string) error {
+	_, err := fmt.Fprintf(w, "%s %s\n", key, strings.Replace(value, "\n", "\n ", -1))
+	return err
+}
+
 // ObjectIter provides an iterator for a set of objects.
 type ObjectIter struct {
 	storer.EncodedObjectIter
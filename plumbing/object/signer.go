@@ -0,0 +1,227 @@
+package object
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+
+	"golang.org/x/crypto/openpgp"
+)
+
+// signatureArmors are the first lines of the armored signatures supported by
+// git: OpenPGP, SSH and X.509.
+var signatureArmors = []string{
+	beginpgp,
+	"-----BEGIN PGP MESSAGE-----",
+	"-----BEGIN SSH SIGNATURE-----",
+	"-----BEGIN SIGNED MESSAGE-----",
+}
+
+var (
+	// ErrUnsigned is returned by VerifySignature when the object is not
+	// signed.
+	ErrUnsigned = errors.New("object not signed")
+	// ErrBadSignature is returned by a ProgramVerifier when the program does
+	// not report a good signature.
+	ErrBadSignature = errors.New("bad signature")
+)
+
+// Signer signs the commits and the tags, the message being the content of the
+// encoded object without signature. The signature returned is armored, and is
+// stored in the PGPSignature field of the object, whatever the format is.
+type Signer interface {
+	Sign(message io.Reader) ([]byte, error)
+}
+
+// Verifier verifies the armored signature of the commits and the tags,
+// returning the identity of the signer if the signature is good.
+type Verifier interface {
+	Verify(message io.Reader, signature []byte) (string, error)
+}
+
+// NewOpenPGPSigner returns a Signer signing with the given OpenPGP entity,
+// its private key must be present and already decrypted.
+func NewOpenPGPSigner(e *openpgp.Entity) Signer {
+	return &openPGPSigner{e}
+}
+
+type openPGPSigner struct {
+	e *openpgp.Entity
+}
+
+func (s *openPGPSigner) Sign(message io.Reader) ([]byte, error) {
+	var b bytes.Buffer
+	if err := openpgp.ArmoredDetachSign(&b, s.e, message, nil); err != nil {
+		return nil, err
+	}
+
+	return b.Bytes(), nil
+}
+
+// NewOpenPGPVerifier returns a Verifier of the signatures made by the keys of
+// the given armored keyring. The identity returned is the primary identity of
+// the key.
+func NewOpenPGPVerifier(armoredKeyRing string) (Verifier, error) {
+	keyring, err := openpgp.ReadArmoredKeyRing(strings.NewReader(armoredKeyRing))
+	if err != nil {
+		return nil, err
+	}
+
+	return &openPGPVerifier{keyring}, nil
+}
+
+type openPGPVerifier struct {
+	keyring openpgp.EntityList
+}
+
+func (v *openPGPVerifier) Verify(message io.Reader, signature []byte) (string, error) {
+	e, err := openpgp.CheckArmoredDetachedSignature(v.keyring, message, bytes.NewReader(signature))
+	if err != nil {
+		return "", err
+	}
+
+	return primaryIdentity(e), nil
+}
+
+func primaryIdentity(e *openpgp.Entity) string {
+	var names []string
+	for name, id := range e.Identities {
+		if id.SelfSignature != nil && id.SelfSignature.IsPrimaryId != nil && *id.SelfSignature.IsPrimaryId {
+			return name
+		}
+
+		names = append(names, name)
+	}
+
+	if len(names) == 0 {
+		return ""
+	}
+
+	sort.Strings(names)
+	return names[0]
+}
+
+// ProgramSigner is a Signer running an external program, with the arguments
+// and the protocol used by git with the gpg.program and gpg.x509.program
+// options. It is the hook to sign with gpg, or with an X.509 signer such as
+// gpgsm or smimesign.
+type ProgramSigner struct {
+	// Program is the name or the path of the program.
+	Program string
+	// Key is the key to sign with, as given by user.signingKey.
+	Key string
+}
+
+// Sign runs the program with the message as input, returning its output.
+func (s *ProgramSigner) Sign(message io.Reader) ([]byte, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(s.Program, "--status-fd=2", "-bsau", s.Key)
+	cmd.Stdin = message
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err := cmd.Run()
+	if err != nil || !strings.Contains("\n"+stderr.String(), "\n[GNUPG:] SIG_CREATED ") {
+		return nil, fmt.Errorf("%s failed to sign the data: %s", s.Program, strings.TrimSpace(stderr.String()))
+	}
+
+	return stdout.Bytes(), nil
+}
+
+// ProgramVerifier is a Verifier running an external program, with the
+// arguments and the protocol used by git with the gpg.program and
+// gpg.x509.program options. It is the hook to verify with gpg, or with an
+// X.509 verifier such as gpgsm or smimesign.
+type ProgramVerifier struct {
+	// Program is the name or the path of the program.
+	Program string
+}
+
+// Verify runs the program with the message as input, returning the signer
+// reported by the program, or ErrBadSignature if the signature is not good.
+func (v *ProgramVerifier) Verify(message io.Reader, signature []byte) (id string, err error) {
+	f, err := ioutil.TempFile("", "signature")
+	if err != nil {
+		return "", err
+	}
+
+	defer func() {
+		if rerr := os.Remove(f.Name()); err == nil {
+			err = rerr
+		}
+	}()
+
+	_, err = f.Write(signature)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	var stdout bytes.Buffer
+	cmd := exec.Command(v.Program, "--status-fd=1", "--verify", f.Name(), "-")
+	cmd.Stdin = message
+	cmd.Stdout = &stdout
+
+	// the status is read even if the program fails, to report the signature
+	// as bad
+	_ = cmd.Run()
+
+	var good bool
+	for _, line := range strings.Split(stdout.String(), "\n") {
+		fields := strings.SplitN(line, " ", 4)
+		if len(fields) < 3 || fields[0] != "[GNUPG:]" {
+			continue
+		}
+
+		switch fields[1] {
+		case "GOODSIG":
+			good = true
+			if len(fields) == 4 {
+				id = fields[3]
+			}
+		case "BADSIG", "ERRSIG", "EXPSIG", "EXPKEYSIG", "REVKEYSIG":
+			return "", ErrBadSignature
+		}
+	}
+
+	if !good {
+		return "", ErrBadSignature
+	}
+
+	return id, nil
+}
+
+// splitSignature splits the armored signature appended to the message of a
+// tag, starting at the last line beginning with a known armor, as git does.
+func splitSignature(data []byte) (message, signature []byte) {
+	for start := 0; start < len(data); {
+		for _, armor := range signatureArmors {
+			if bytes.HasPrefix(data[start:], []byte(armor)) {
+				message, signature = data[:start], data[start:]
+				break
+			}
+		}
+
+		eol := bytes.IndexByte(data[start:], '\n')
+		if eol == -1 {
+			break
+		}
+
+		start += eol + 1
+	}
+
+	if signature == nil {
+		return data, nil
+	}
+
+	return message, signature
+}
@@ -0,0 +1,96 @@
+package object
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	. "gopkg.in/check.v1"
+)
+
+type SignerSuite struct {
+	dir string
+}
+
+var _ = Suite(&SignerSuite{})
+
+func (s *SignerSuite) SetUpTest(c *C) {
+	if runtime.GOOS == "windows" {
+		c.Skip("the programs are shell scripts")
+	}
+
+	var err error
+	s.dir, err = ioutil.TempDir("", "signer")
+	c.Assert(err, IsNil)
+}
+
+func (s *SignerSuite) TearDownTest(c *C) {
+	c.Assert(os.RemoveAll(s.dir), IsNil)
+}
+
+// program writes a shell script, implementing the gpg protocol used by git.
+func (s *SignerSuite) program(c *C, script string) string {
+	name := filepath.Join(s.dir, "gpg")
+	c.Assert(ioutil.WriteFile(name, []byte("#!/bin/sh\n"+script), 0755), IsNil)
+	return name
+}
+
+func (s *SignerSuite) TestProgramSigner(c *C) {
+	signer := &ProgramSigner{
+		Program: s.program(c, `
+test "$*" = "--status-fd=2 -bsau foo" || exit 1
+echo "[GNUPG:] SIG_CREATED D 1 8 00 1 ABCD" >&2
+echo "-----BEGIN SIGNED MESSAGE-----"
+cat
+echo "-----END SIGNED MESSAGE-----"
+`),
+		Key: "foo",
+	}
+
+	sig, err := signer.Sign(strings.NewReader("foo\n"))
+	c.Assert(err, IsNil)
+	c.Assert(string(sig), Equals, "-----BEGIN SIGNED MESSAGE-----\nfoo\n-----END SIGNED MESSAGE-----\n")
+}
+
+func (s *SignerSuite) TestProgramSignerFailure(c *C) {
+	signer := &ProgramSigner{
+		Program: s.program(c, "echo no key >&2\n"),
+		Key:     "foo",
+	}
+
+	_, err := signer.Sign(strings.NewReader("foo\n"))
+	c.Assert(err, ErrorMatches, ".* failed to sign the data: no key")
+}
+
+func (s *SignerSuite) TestProgramVerifier(c *C) {
+	verifier := &ProgramVerifier{
+		Program: s.program(c, `
+test "$1 $2 $4" = "--status-fd=1 --verify -" || exit 1
+if test "$(cat)" = "foo" && test "$(cat "$3")" = "signature"; then
+	echo "[GNUPG:] GOODSIG ABCD Foo <foo@example.local>"
+else
+	echo "[GNUPG:] BADSIG ABCD Foo <foo@example.local>"
+	exit 1
+fi
+`),
+	}
+
+	id, err := verifier.Verify(strings.NewReader("foo\n"), []byte("signature"))
+	c.Assert(err, IsNil)
+	c.Assert(id, Equals, "Foo <foo@example.local>")
+
+	_, err = verifier.Verify(strings.NewReader("bar\n"), []byte("signature"))
+	c.Assert(err, Equals, ErrBadSignature)
+}
+
+func (s *SignerSuite) TestSplitSignature(c *C) {
+	message, signature := splitSignature([]byte("foo\n-----BEGIN PGP SIGNATURE-----\nbar\n"))
+	c.Assert(string(message), Equals, "foo\n")
+	c.Assert(string(signature), Equals, "-----BEGIN PGP SIGNATURE-----\nbar\n")
+
+	message, signature = splitSignature([]byte("foo -----BEGIN PGP SIGNATURE-----\n"))
+	c.Assert(string(message), Equals, "foo -----BEGIN PGP SIGNATURE-----\n")
+	c.Assert(signature, IsNil)
+}
@@ -2,9 +2,7 @@ package object
 
 import (
 	"bufio"
-	"bytes"
 	"fmt"
-	"io"
 	stdioutil "io/ioutil"
 	"strings"
 
@@ -33,12 +31,15 @@ type Tag struct {
 	Tagger Signature
 	// Message is an arbitrary text message.
 	Message string
-	// PGPSignature is the PGP signature of the tag.
+	// PGPSignature is the armored signature of the tag, an OpenPGP, SSH or
+	// X.509 signature despite its name.
 	PGPSignature string
 	// TargetType is the object type of the target.
 	TargetType plumbing.ObjectType
 	// Target is the hash of the target object.
 	Target plumbing.Hash
+	// ExtraHeaders are the headers of the tag not known by go-git.
+	ExtraHeaders []ExtraHeader
 
 	s storer.EncodedObjectStorer
 }
@@ -94,36 +95,24 @@ func (t *Tag) Decode(o plumbing.EncodedObject) (err error) {
 	defer ioutil.CheckClose(reader, &err)
 
 	r := bufio.NewReader(reader)
-	for {
-		var line []byte
-		line, err = r.ReadBytes('\n')
-		if err != nil && err != io.EOF {
-			return err
-		}
-
-		line = bytes.TrimSpace(line)
-		if len(line) == 0 {
-			break // Start of message
-		}
-
-		split := bytes.SplitN(line, []byte{' '}, 2)
-		switch string(split[0]) {
+	err = readHeaders(r, func(key, value string) (err error) {
+		switch key {
 		case "object":
-			t.Target = plumbing.NewHash(string(split[1]))
+			t.Target = plumbing.NewHash(value)
 		case "type":
-			t.TargetType, err = plumbing.ParseObjectType(string(split[1]))
-			if err != nil {
-				return err
-			}
+			t.TargetType, err = plumbing.ParseObjectType(value)
 		case "tag":
-			t.Name = string(split[1])
+			t.Name = value
 		case "tagger":
-			t.Tagger.Decode(split[1])
+			t.Tagger.Decode([]byte(value))
+		default:
+			t.ExtraHeaders = append(t.ExtraHeaders, ExtraHeader{key, value})
 		}
 
-		if err == io.EOF {
-			return nil
-		}
+		return err
+	})
+	if err != nil {
+		return err
 	}
 
 	data, err := stdioutil.ReadAll(r)
@@ -131,36 +120,9 @@ func (t *Tag) Decode(o plumbing.EncodedObject) (err error) {
 		return err
 	}
 
-	var pgpsig bool
-	// Check if data contains PGP signature.
-	if bytes.Contains(data, []byte(beginpgp)) {
-		// Split the lines at newline.
-		messageAndSig := bytes.Split(data, []byte("\n"))
-
-		for _, l := range messageAndSig {
-			if pgpsig {
-				if bytes.Contains(l, []byte(endpgp)) {
-					t.PGPSignature += endpgp + "\n"
-					pgpsig = false
-				} else {
-					t.PGPSignature += string(l) + "\n"
-				}
-				continue
-			}
-
-			// Check if it's the beginning of a PGP signature.
-			if bytes.Contains(l, []byte(beginpgp)) {
-				t.PGPSignature += beginpgp + "\n"
-				pgpsig = true
-				continue
-			}
-
-			t.Message += string(l) + "\n"
-		}
-	} else {
-		t.Message = string(data)
-	}
-
+	message, signature := splitSignature(data)
+	t.Message = string(message)
+	t.PGPSignature = string(signature)
 	return nil
 }
 
@@ -187,7 +149,17 @@ func (t *Tag) encode(o plumbing.EncodedObject, includeSig bool) (err error) {
 		return err
 	}
 
-	if _, err = fmt.Fprint(w, "\n\n"); err != nil {
+	if _, err = fmt.Fprint(w, "\n"); err != nil {
+		return err
+	}
+
+	for _, h := range t.ExtraHeaders {
+		if err = writeHeader(w, h.Key, h.Value); err != nil {
+			return err
+		}
+	}
+
+	if _, err = fmt.Fprint(w, "\n"); err != nil {
 		return err
 	}
 
@@ -300,6 +272,27 @@ func (t *Tag) Verify(armoredKeyRing string) (*openpgp.Entity, error) {
 	return openpgp.CheckArmoredDetachedSignature(keyring, er, signature)
 }
 
+// VerifySignature verifies the signature of the tag with the given Verifier,
+// whatever its format is, returning the identity of the signer. ErrUnsigned is
+// returned if the tag is not signed.
+func (t *Tag) VerifySignature(v Verifier) (string, error) {
+	if t.PGPSignature == "" {
+		return "", ErrUnsigned
+	}
+
+	encoded := &plumbing.MemoryObject{}
+	if err := t.encode(encoded, false); err != nil {
+		return "", err
+	}
+
+	er, err := encoded.Reader()
+	if err != nil {
+		return "", err
+	}
+
+	return v.Verify(er, []byte(t.PGPSignature))
+}
+
 // TagIter provides an iterator for a set of tags.
 type TagIter struct {
 	storer.EncodedObjectIter
@@ -205,6 +205,65 @@ func (s *TagSuite) TestTagEncodeDecodeIdempotent(c *C) {
 	}
 }
 
+func (s *TagSuite) TestDecodeEncodeExtraHeaders(c *C) {
+	raw := "object 9ae5ec19e1d1fdb2dd75c03d0ee35cd7dd7ab3ca\n" +
+		"type commit\n" +
+		"tag v1.0.0\n" +
+		"tagger Foo <foo@example.local> 1136239445 -0700\n" +
+		"gpgsig-sha256 -----BEGIN PGP SIGNATURE-----\n" +
+		" \n" +
+		" -----END PGP SIGNATURE-----\n" +
+		"foo bar\n" +
+		"\n" +
+		"v1.0.0\n"
+
+	obj := &plumbing.MemoryObject{}
+	obj.SetType(plumbing.TagObject)
+	_, err := obj.Write([]byte(raw))
+	c.Assert(err, IsNil)
+
+	tag := &Tag{}
+	c.Assert(tag.Decode(obj), IsNil)
+	c.Assert(tag.Name, Equals, "v1.0.0")
+	c.Assert(tag.Message, Equals, "v1.0.0\n")
+	c.Assert(tag.ExtraHeaders, DeepEquals, []ExtraHeader{
+		{"gpgsig-sha256", "-----BEGIN PGP SIGNATURE-----\n\n-----END PGP SIGNATURE-----"},
+		{"foo", "bar"},
+	})
+
+	encoded := &plumbing.MemoryObject{}
+	c.Assert(tag.Encode(encoded), IsNil)
+	c.Assert(encoded.Hash(), Equals, obj.Hash())
+}
+
+func (s *TagSuite) TestDecodeEncodeSSHSignature(c *C) {
+	signature := "-----BEGIN SSH SIGNATURE-----\n" +
+		"U1NIU0lHAAAAAQAAADMAAAALc3NoLWVkMjU1MTkAAAAgqTpCAuJmBfXi0u7H9Vk/8WM/8h\n" +
+		"-----END SSH SIGNATURE-----\n"
+
+	raw := "object 9ae5ec19e1d1fdb2dd75c03d0ee35cd7dd7ab3ca\n" +
+		"type commit\n" +
+		"tag v1.0.0\n" +
+		"tagger Foo <foo@example.local> 1136239445 -0700\n" +
+		"\n" +
+		"v1.0.0\n" +
+		signature
+
+	obj := &plumbing.MemoryObject{}
+	obj.SetType(plumbing.TagObject)
+	_, err := obj.Write([]byte(raw))
+	c.Assert(err, IsNil)
+
+	tag := &Tag{}
+	c.Assert(tag.Decode(obj), IsNil)
+	c.Assert(tag.Message, Equals, "v1.0.0\n")
+	c.Assert(tag.PGPSignature, Equals, signature)
+
+	encoded := &plumbing.MemoryObject{}
+	c.Assert(tag.Encode(encoded), IsNil)
+	c.Assert(encoded.Hash(), Equals, obj.Hash())
+}
+
 func (s *TagSuite) TestString(c *C) {
 	tag := s.tag(c, plumbing.NewHash("b742a2a9fa0afcfa9a6fad080980fbc26b007c69"))
 	c.Assert(tag.String(), Equals, ""+
@@ -374,4 +433,15 @@ sYyf9RfOnw/KUFAQbdtvLx3ikODQC+D3KBtuKI9ISHQfgw==
 
 	_, ok := e.Identities["Sunny <me@darkowlzz.space>"]
 	c.Assert(ok, Equals, true)
+
+	verifier, err := NewOpenPGPVerifier(armoredKeyRing)
+	c.Assert(err, IsNil)
+
+	id, err := tag.VerifySignature(verifier)
+	c.Assert(err, IsNil)
+	c.Assert(id, Equals, "Sunny <me@darkowlzz.space>")
+
+	tag.PGPSignature = ""
+	_, err = tag.VerifySignature(verifier)
+	c.Assert(err, Equals, ErrUnsigned)
 }
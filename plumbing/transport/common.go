@@ -66,6 +66,24 @@ type AuthMethod interface {
 	Name() string
 }
 
+// AuthProvider is an AuthMethod providing the credentials of an endpoint once
+// it requires authentication, as the credential helpers of git do. It can't be
+// used by the transports directly, the sessions must be started without auth
+// method first, then with the one returned by Fill if the endpoint returns
+// ErrAuthenticationRequired.
+type AuthProvider interface {
+	AuthMethod
+	// Fill returns the auth method with the credentials of the endpoint,
+	// nil if there are none.
+	Fill(*Endpoint) (AuthMethod, error)
+	// Approve reports that the auth method returned by Fill was accepted by
+	// the endpoint.
+	Approve(*Endpoint, AuthMethod) error
+	// Reject reports that the auth method returned by Fill was rejected by
+	// the endpoint.
+	Reject(*Endpoint, AuthMethod) error
+}
+
 // UploadPackSession represents a git-upload-pack session.
 // A git-upload-pack session has two steps: reference discovery
 // (AdvertisedReferences) and uploading pack (UploadPack).
@@ -0,0 +1,127 @@
+package credential
+
+import (
+	"sync"
+	"time"
+)
+
+// DefaultCacheTimeout is the time the credentials are kept by a Cache, if it
+// has no timeout, the default one of git-credential-cache.
+const DefaultCacheTimeout = 900 * time.Second
+
+// Cache is the in-process equivalent of git-credential-cache, keeping the
+// credentials in memory for a limited time. The zero value and the caches
+// returned by NewHelper share the memory of the process, the caches returned
+// by NewCache have their own.
+type Cache struct {
+	// Timeout is the time the credentials are kept, DefaultCacheTimeout if
+	// zero.
+	Timeout time.Duration
+
+	m *cacheMemory
+}
+
+type cacheMemory struct {
+	sync.Mutex
+	entries []*cacheEntry
+}
+
+type cacheEntry struct {
+	credential *Credential
+	expiration time.Time
+}
+
+var defaultCacheMemory = &cacheMemory{}
+
+// NewCache returns a new Cache keeping the credentials for the given time.
+func NewCache(timeout time.Duration) *Cache {
+	return &Cache{Timeout: timeout, m: &cacheMemory{}}
+}
+
+func (c *Cache) memory() *cacheMemory {
+	if c.m == nil {
+		return defaultCacheMemory
+	}
+
+	return c.m
+}
+
+// Get returns the cached credential matching the given one.
+func (c *Cache) Get(cred *Credential) (*Credential, error) {
+	m := c.memory()
+	m.Lock()
+	defer m.Unlock()
+
+	m.expire()
+	for _, e := range m.entries {
+		if e.credential.Matches(cred) {
+			r := *e.credential
+			return &r, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// Store caches the given credential, replacing the cached credentials with
+// the same protocol, host, path and username.
+func (c *Cache) Store(cred *Credential) error {
+	if !cred.isComplete() {
+		return nil
+	}
+
+	timeout := c.Timeout
+	if timeout == 0 {
+		timeout = DefaultCacheTimeout
+	}
+
+	m := c.memory()
+	m.Lock()
+	defer m.Unlock()
+
+	m.remove(cred)
+	stored := *cred
+	stored.Quit = false
+	m.entries = append(m.entries, &cacheEntry{
+		credential: &stored,
+		expiration: time.Now().Add(timeout),
+	})
+
+	return nil
+}
+
+// Erase removes the cached credentials matching the given one.
+func (c *Cache) Erase(cred *Credential) error {
+	m := c.memory()
+	m.Lock()
+	defer m.Unlock()
+
+	m.remove(cred)
+	return nil
+}
+
+func (m *cacheMemory) remove(cred *Credential) {
+	pattern := *cred
+	pattern.Password = ""
+
+	entries := m.entries[:0]
+	for _, e := range m.entries {
+		if !e.credential.Matches(&pattern) {
+			entries = append(entries, e)
+		}
+	}
+
+	m.entries = entries
+}
+
+func (m *cacheMemory) expire() {
+	now := time.Now()
+	entries := m.entries[:0]
+	for _, e := range m.entries {
+		if now.Before(e.expiration) {
+			entries = append(entries, e)
+		}
+	}
+
+	m.entries = entries
+}
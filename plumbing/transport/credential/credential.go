@@ -0,0 +1,253 @@
+// Package credential implements the git credential helper protocol, asking
+// credential helpers for the credentials of the endpoints requiring
+// authentication, and reporting them whether the credentials were accepted.
+//
+// See https://git-scm.com/docs/gitcredentials and
+// https://git-scm.com/docs/git-credential
+package credential
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+
+	"gopkg.in/src-d/go-git.v4/plumbing/transport"
+)
+
+var (
+	// ErrInvalidAttribute is returned by Decode when a line isn't a
+	// key=value attribute.
+	ErrInvalidAttribute = errors.New("invalid credential attribute")
+	// ErrInvalidValue is returned by Encode when a value contains a new
+	// line or a NUL character.
+	ErrInvalidValue = errors.New("invalid credential value")
+)
+
+const (
+	protocolKey = "protocol"
+	hostKey     = "host"
+	pathKey     = "path"
+	usernameKey = "username"
+	passwordKey = "password"
+	urlKey      = "url"
+	quitKey     = "quit"
+)
+
+var defaultPorts = map[string]int{
+	"http":  80,
+	"https": 443,
+	"ssh":   22,
+}
+
+// Credential is the description of a credential exchanged with the helpers,
+// its attributes being the ones of the git credential helper protocol.
+type Credential struct {
+	// Protocol is the protocol of the endpoint, e.g. https.
+	Protocol string
+	// Host is the host of the endpoint, with its port if not the default
+	// one of the protocol.
+	Host string
+	// Path is the path of the repository.
+	Path string
+	// Username is the username of the credential.
+	Username string
+	// Password is the password of the credential.
+	Password string
+	// Quit if true, no more helpers must be asked for the credential.
+	Quit bool
+}
+
+// NewCredential returns the credential describing the given endpoint. The
+// path is only part of the credentials of HTTP endpoints if useHTTPPath is
+// true, as git does with credential.useHttpPath.
+func NewCredential(ep *transport.Endpoint, useHTTPPath bool) *Credential {
+	c := &Credential{
+		Protocol: ep.Protocol,
+		Host:     ep.Host,
+		Path:     strings.TrimPrefix(ep.Path, "/"),
+		Username: ep.User,
+		Password: ep.Password,
+	}
+
+	if ep.Port != 0 && ep.Port != defaultPorts[ep.Protocol] {
+		c.Host = fmt.Sprintf("%s:%d", ep.Host, ep.Port)
+	}
+
+	if !useHTTPPath && isHTTP(c.Protocol) {
+		c.Path = ""
+	}
+
+	return c
+}
+
+func isHTTP(protocol string) bool {
+	return protocol == "http" || protocol == "https"
+}
+
+// Decode reads the attributes of the credential from r, one key=value line
+// per attribute, until an empty line or the end of r. The url attribute sets
+// the attributes found in the URL, and the unknown attributes are ignored.
+func (c *Credential) Decode(r io.Reader) error {
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		line := s.Text()
+		if line == "" {
+			break
+		}
+
+		i := strings.IndexByte(line, '=')
+		if i < 0 {
+			return ErrInvalidAttribute
+		}
+
+		if err := c.set(line[:i], line[i+1:]); err != nil {
+			return err
+		}
+	}
+
+	return s.Err()
+}
+
+func (c *Credential) set(key, value string) error {
+	switch key {
+	case protocolKey:
+		c.Protocol = value
+	case hostKey:
+		c.Host = value
+	case pathKey:
+		c.Path = value
+	case usernameKey:
+		c.Username = value
+	case passwordKey:
+		c.Password = value
+	case quitKey:
+		c.Quit = isTrue(value)
+	case urlKey:
+		u, err := parseURL(value)
+		if err != nil {
+			return err
+		}
+
+		*c = *u
+	}
+
+	return nil
+}
+
+func isTrue(v string) bool {
+	switch strings.ToLower(v) {
+	case "1", "true", "yes", "on":
+		return true
+	default:
+		return false
+	}
+}
+
+// Encode writes the non-empty attributes of the credential to w, one
+// key=value line per attribute, followed by an empty line.
+func (c *Credential) Encode(w io.Writer) error {
+	var buf strings.Builder
+	for _, a := range []struct{ key, value string }{
+		{protocolKey, c.Protocol},
+		{hostKey, c.Host},
+		{pathKey, c.Path},
+		{usernameKey, c.Username},
+		{passwordKey, c.Password},
+	} {
+		if a.value == "" {
+			continue
+		}
+
+		if strings.ContainsAny(a.value, "\n\x00") {
+			return ErrInvalidValue
+		}
+
+		fmt.Fprintf(&buf, "%s=%s\n", a.key, a.value)
+	}
+
+	buf.WriteString("\n")
+	_, err := io.WriteString(w, buf.String())
+	return err
+}
+
+// URL returns the credential as a URL, with its username and password, as
+// stored by the store helper.
+func (c *Credential) URL() string {
+	u := &url.URL{
+		Scheme: c.Protocol,
+		Host:   c.Host,
+	}
+
+	if c.Path != "" {
+		u.Path = "/" + c.Path
+	}
+
+	if c.Username != "" {
+		if c.Password != "" {
+			u.User = url.UserPassword(c.Username, c.Password)
+		} else {
+			u.User = url.User(c.Username)
+		}
+	}
+
+	return u.String()
+}
+
+func parseURL(s string) (*Credential, error) {
+	u, err := url.Parse(s)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Credential{
+		Protocol: u.Scheme,
+		Host:     u.Host,
+		Path:     strings.TrimPrefix(u.Path, "/"),
+	}
+
+	if u.User != nil {
+		c.Username = u.User.Username()
+		c.Password, _ = u.User.Password()
+	}
+
+	return c, nil
+}
+
+// Matches reports whether the credential matches the given one, as git
+// does: its protocol, host, path and username must be equal to the ones of
+// the given credential, unless they are empty in the given credential.
+func (c *Credential) Matches(o *Credential) bool {
+	return matchAttribute(o.Protocol, c.Protocol) &&
+		matchAttribute(o.Host, c.Host) &&
+		matchAttribute(o.Path, c.Path) &&
+		matchAttribute(o.Username, c.Username)
+}
+
+func matchAttribute(want, have string) bool {
+	return want == "" || want == have
+}
+
+// merge sets the non-empty attributes of o in the credential.
+func (c *Credential) merge(o *Credential) {
+	for _, a := range []struct{ dst, src *string }{
+		{&c.Protocol, &o.Protocol},
+		{&c.Host, &o.Host},
+		{&c.Path, &o.Path},
+		{&c.Username, &o.Username},
+		{&c.Password, &o.Password},
+	} {
+		if *a.src != "" {
+			*a.dst = *a.src
+		}
+	}
+
+	c.Quit = c.Quit || o.Quit
+}
+
+// isComplete reports whether the credential has a username and a password.
+func (c *Credential) isComplete() bool {
+	return c.Username != "" && c.Password != ""
+}
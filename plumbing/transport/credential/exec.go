@@ -0,0 +1,88 @@
+package credential
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	getAction   = "get"
+	storeAction = "store"
+	eraseAction = "erase"
+)
+
+// ExecHelper is a Helper running an external program speaking the git
+// credential helper protocol, as the helpers of credential.helper that aren't
+// implemented in-process.
+type ExecHelper struct {
+	// Command is the helper as found in credential.helper: a name, running
+	// git credential-<name>, an absolute path, running the program, or a
+	// shell command starting with "!". It may be followed by arguments.
+	Command string
+}
+
+// Get runs the helper with the get action.
+func (h *ExecHelper) Get(c *Credential) (*Credential, error) {
+	out, err := h.run(getAction, c)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Credential{}
+	if err := r.Decode(bytes.NewReader(out)); err != nil {
+		return nil, err
+	}
+
+	if *r == (Credential{}) {
+		return nil, nil
+	}
+
+	return r, nil
+}
+
+// Store runs the helper with the store action.
+func (h *ExecHelper) Store(c *Credential) error {
+	_, err := h.run(storeAction, c)
+	return err
+}
+
+// Erase runs the helper with the erase action.
+func (h *ExecHelper) Erase(c *Credential) error {
+	_, err := h.run(eraseAction, c)
+	return err
+}
+
+func (h *ExecHelper) run(action string, c *Credential) ([]byte, error) {
+	in := bytes.NewBuffer(nil)
+	if err := c.Encode(in); err != nil {
+		return nil, err
+	}
+
+	var stderr bytes.Buffer
+	cmd := exec.Command("sh", "-c", h.command()+" "+action)
+	cmd.Stdin = in
+	cmd.Stderr = &stderr
+
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("credential helper %q: %s: %s",
+			h.Command, err, strings.TrimSpace(stderr.String()))
+	}
+
+	return out, nil
+}
+
+// command returns the shell command running the helper, as git does.
+func (h *ExecHelper) command() string {
+	switch {
+	case strings.HasPrefix(h.Command, "!"):
+		return h.Command[1:]
+	case filepath.IsAbs(h.Command):
+		return h.Command
+	default:
+		return "git credential-" + h.Command
+	}
+}
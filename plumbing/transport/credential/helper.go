@@ -0,0 +1,102 @@
+package credential
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrInvalidHelperOption is returned by NewHelper when an option of a store
+// or cache helper is invalid.
+var ErrInvalidHelperOption = errors.New("invalid credential helper option")
+
+// Helper is a credential helper.
+type Helper interface {
+	// Get returns the credential matching the given one, with its username
+	// and password, nil if the helper has none.
+	Get(*Credential) (*Credential, error)
+	// Store stores the given credential, accepted by its endpoint.
+	Store(*Credential) error
+	// Erase erases the credentials matching the given one, rejected by its
+	// endpoint.
+	Erase(*Credential) error
+}
+
+const (
+	storeHelper   = "store"
+	cacheHelper   = "cache"
+	fileOption    = "--file"
+	timeoutOption = "--timeout"
+)
+
+// NewHelper returns the helper of a credential.helper value. The store and
+// cache helpers, with their --file and --timeout options, are implemented
+// in-process by Store and Cache, the other ones are run by an ExecHelper.
+func NewHelper(value string) (Helper, error) {
+	fields := strings.Fields(value)
+	if len(fields) == 0 || strings.HasPrefix(value, "!") {
+		return &ExecHelper{Command: value}, nil
+	}
+
+	switch fields[0] {
+	case storeHelper:
+		s := &Store{}
+		err := parseHelperOptions(fields[1:], fileOption, func(v string) error {
+			s.Files = []string{expandHome(v)}
+			return nil
+		})
+
+		return s, err
+	case cacheHelper:
+		c := &Cache{}
+		err := parseHelperOptions(fields[1:], timeoutOption, func(v string) error {
+			seconds, err := strconv.Atoi(v)
+			if err != nil || seconds < 0 {
+				return ErrInvalidHelperOption
+			}
+
+			c.Timeout = time.Duration(seconds) * time.Second
+			return nil
+		})
+
+		return c, err
+	default:
+		return &ExecHelper{Command: value}, nil
+	}
+}
+
+// parseHelperOptions parses the options of a helper, the only supported one
+// being the given option, as --option=value or --option value.
+func parseHelperOptions(args []string, option string, set func(string) error) error {
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case strings.HasPrefix(arg, option+"="):
+			if err := set(arg[len(option)+1:]); err != nil {
+				return err
+			}
+		case arg == option && i+1 < len(args):
+			i++
+			if err := set(args[i]); err != nil {
+				return err
+			}
+		default:
+			return ErrInvalidHelperOption
+		}
+	}
+
+	return nil
+}
+
+// expandHome expands the ~/ prefix of a path, as the shell running the
+// helpers does.
+func expandHome(path string) string {
+	if !strings.HasPrefix(path, "~/") {
+		return path
+	}
+
+	return filepath.Join(os.Getenv("HOME"), path[2:])
+}
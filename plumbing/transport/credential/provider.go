@@ -0,0 +1,126 @@
+package credential
+
+import (
+	"gopkg.in/src-d/go-git.v4/plumbing/transport"
+	"gopkg.in/src-d/go-git.v4/plumbing/transport/http"
+	"gopkg.in/src-d/go-git.v4/plumbing/transport/ssh"
+)
+
+// ProviderName is the name of the Provider auth method.
+const ProviderName = "credential-helper"
+
+// Provider is a transport.AuthProvider asking the credential helpers for the
+// credentials of the endpoints, as git credential fill does, and reporting
+// them whether the credentials were accepted, as git credential approve and
+// reject do. It provides http.BasicAuth for the HTTP endpoints and
+// ssh.Password for the SSH ones.
+type Provider struct {
+	// Helpers are the helpers asked for the credentials, in order, until
+	// one returns a username and a password.
+	Helpers []Helper
+	// Username is the username of the credentials of the endpoints without
+	// one, as credential.username.
+	Username string
+	// UseHTTPPath if true, the path of the HTTP endpoints is part of their
+	// credentials, as credential.useHttpPath.
+	UseHTTPPath bool
+}
+
+// Name returns the name of the auth method.
+func (p *Provider) Name() string {
+	return ProviderName
+}
+
+func (p *Provider) String() string {
+	return p.Name()
+}
+
+// Fill asks the helpers for the credentials of the endpoint, returning the
+// auth method with them, nil if no helper has them.
+func (p *Provider) Fill(ep *transport.Endpoint) (transport.AuthMethod, error) {
+	c := p.credential(ep)
+	for _, h := range p.Helpers {
+		if c.isComplete() || c.Quit {
+			break
+		}
+
+		r, err := h.Get(c)
+		if err != nil {
+			return nil, err
+		}
+
+		if r != nil {
+			c.merge(r)
+		}
+	}
+
+	if !c.isComplete() {
+		return nil, nil
+	}
+
+	return newAuthMethod(ep, c), nil
+}
+
+// Approve tells the helpers to store the credentials of the auth method.
+func (p *Provider) Approve(ep *transport.Endpoint, auth transport.AuthMethod) error {
+	c, ok := p.authCredential(ep, auth)
+	if !ok {
+		return nil
+	}
+
+	for _, h := range p.Helpers {
+		if err := h.Store(c); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Reject tells the helpers to erase the credentials of the auth method.
+func (p *Provider) Reject(ep *transport.Endpoint, auth transport.AuthMethod) error {
+	c, ok := p.authCredential(ep, auth)
+	if !ok {
+		return nil
+	}
+
+	for _, h := range p.Helpers {
+		if err := h.Erase(c); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (p *Provider) credential(ep *transport.Endpoint) *Credential {
+	c := NewCredential(ep, p.UseHTTPPath)
+	if c.Username == "" {
+		c.Username = p.Username
+	}
+
+	return c
+}
+
+// authCredential returns the credential of an auth method returned by Fill.
+func (p *Provider) authCredential(ep *transport.Endpoint, auth transport.AuthMethod) (*Credential, bool) {
+	c := p.credential(ep)
+	switch a := auth.(type) {
+	case *http.BasicAuth:
+		c.Username, c.Password = a.Username, a.Password
+	case *ssh.Password:
+		c.Username, c.Password = a.User, a.Password
+	default:
+		return nil, false
+	}
+
+	return c, true
+}
+
+func newAuthMethod(ep *transport.Endpoint, c *Credential) transport.AuthMethod {
+	if ep.Protocol == "ssh" {
+		return &ssh.Password{User: c.Username, Password: c.Password}
+	}
+
+	return &http.BasicAuth{Username: c.Username, Password: c.Password}
+}
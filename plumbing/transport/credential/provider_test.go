@@ -0,0 +1,129 @@
+package credential
+
+import (
+	"errors"
+
+	"gopkg.in/src-d/go-git.v4/plumbing/transport"
+	"gopkg.in/src-d/go-git.v4/plumbing/transport/http"
+	"gopkg.in/src-d/go-git.v4/plumbing/transport/ssh"
+
+	. "gopkg.in/check.v1"
+)
+
+type ProviderSuite struct{}
+
+var _ = Suite(&ProviderSuite{})
+
+type mockHelper struct {
+	get    *Credential
+	err    error
+	asked  []Credential
+	stored []Credential
+	erased []Credential
+}
+
+func (h *mockHelper) Get(c *Credential) (*Credential, error) {
+	h.asked = append(h.asked, *c)
+	return h.get, h.err
+}
+
+func (h *mockHelper) Store(c *Credential) error {
+	h.stored = append(h.stored, *c)
+	return nil
+}
+
+func (h *mockHelper) Erase(c *Credential) error {
+	h.erased = append(h.erased, *c)
+	return nil
+}
+
+func (s *ProviderSuite) TestFill(c *C) {
+	first := &mockHelper{get: &Credential{Username: "foo"}}
+	second := &mockHelper{get: &Credential{Password: "bar"}}
+	third := &mockHelper{get: &Credential{Password: "qux"}}
+	p := &Provider{Helpers: []Helper{first, second, third}}
+	c.Assert(p.Name(), Equals, ProviderName)
+
+	ep, err := transport.NewEndpoint("https://example.com/basic.git")
+	c.Assert(err, IsNil)
+
+	auth, err := p.Fill(ep)
+	c.Assert(err, IsNil)
+	c.Assert(auth, DeepEquals, &http.BasicAuth{Username: "foo", Password: "bar"})
+
+	c.Assert(first.asked, DeepEquals, []Credential{{Protocol: "https", Host: "example.com"}})
+	c.Assert(second.asked, DeepEquals, []Credential{{Protocol: "https", Host: "example.com", Username: "foo"}})
+	c.Assert(third.asked, HasLen, 0)
+}
+
+func (s *ProviderSuite) TestFillUsername(c *C) {
+	h := &mockHelper{get: &Credential{Password: "bar"}}
+	p := &Provider{Helpers: []Helper{h}, Username: "foo", UseHTTPPath: true}
+
+	ep, err := transport.NewEndpoint("https://example.com/basic.git")
+	c.Assert(err, IsNil)
+
+	auth, err := p.Fill(ep)
+	c.Assert(err, IsNil)
+	c.Assert(auth, DeepEquals, &http.BasicAuth{Username: "foo", Password: "bar"})
+	c.Assert(h.asked, DeepEquals, []Credential{
+		{Protocol: "https", Host: "example.com", Path: "basic.git", Username: "foo"},
+	})
+}
+
+func (s *ProviderSuite) TestFillQuit(c *C) {
+	first := &mockHelper{get: &Credential{Username: "foo", Quit: true}}
+	second := &mockHelper{get: &Credential{Password: "bar"}}
+	p := &Provider{Helpers: []Helper{first, second}}
+
+	ep, err := transport.NewEndpoint("https://example.com/basic.git")
+	c.Assert(err, IsNil)
+
+	auth, err := p.Fill(ep)
+	c.Assert(err, IsNil)
+	c.Assert(auth, IsNil)
+	c.Assert(second.asked, HasLen, 0)
+}
+
+func (s *ProviderSuite) TestFillError(c *C) {
+	h := &mockHelper{err: errors.New("foo")}
+	p := &Provider{Helpers: []Helper{h}}
+
+	ep, err := transport.NewEndpoint("https://example.com/basic.git")
+	c.Assert(err, IsNil)
+
+	_, err = p.Fill(ep)
+	c.Assert(err, ErrorMatches, "foo")
+}
+
+func (s *ProviderSuite) TestFillSSH(c *C) {
+	h := &mockHelper{get: &Credential{Password: "bar"}}
+	p := &Provider{Helpers: []Helper{h}}
+
+	ep, err := transport.NewEndpoint("ssh://foo@example.com:2222/basic.git")
+	c.Assert(err, IsNil)
+
+	auth, err := p.Fill(ep)
+	c.Assert(err, IsNil)
+	c.Assert(auth, DeepEquals, &ssh.Password{User: "foo", Password: "bar"})
+	c.Assert(h.asked, DeepEquals, []Credential{
+		{Protocol: "ssh", Host: "example.com:2222", Path: "basic.git", Username: "foo"},
+	})
+}
+
+func (s *ProviderSuite) TestApproveReject(c *C) {
+	h := &mockHelper{}
+	p := &Provider{Helpers: []Helper{h}}
+
+	ep, err := transport.NewEndpoint("https://example.com/basic.git")
+	c.Assert(err, IsNil)
+
+	auth := &http.BasicAuth{Username: "foo", Password: "bar"}
+	c.Assert(p.Approve(ep, auth), IsNil)
+	c.Assert(p.Reject(ep, auth), IsNil)
+	c.Assert(p.Approve(ep, &http.TokenAuth{Token: "foo"}), IsNil)
+
+	expected := []Credential{{Protocol: "https", Host: "example.com", Username: "foo", Password: "bar"}}
+	c.Assert(h.stored, DeepEquals, expected)
+	c.Assert(h.erased, DeepEquals, expected)
+}
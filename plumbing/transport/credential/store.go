@@ -0,0 +1,198 @@
+package credential
+
+import (
+	"bufio"
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	storeFile    = ".git-credentials"
+	xdgStoreFile = "git/credentials"
+	xdgConfigDir = ".config"
+)
+
+// Store is the in-process equivalent of git-credential-store, storing the
+// credentials in plain text files, one URL with its username and password
+// per line.
+type Store struct {
+	// Files are the files the credentials are read from, the first one
+	// being the one they are written to. If empty, ~/.git-credentials and
+	// $XDG_CONFIG_HOME/git/credentials are used.
+	Files []string
+}
+
+// files returns the files of the store, as git-credential-store does.
+func (s *Store) files() []string {
+	if len(s.Files) != 0 {
+		return s.Files
+	}
+
+	home := os.Getenv("HOME")
+	xdg := os.Getenv("XDG_CONFIG_HOME")
+	if xdg == "" && home != "" {
+		xdg = filepath.Join(home, xdgConfigDir)
+	}
+
+	var files []string
+	if home != "" {
+		files = append(files, filepath.Join(home, storeFile))
+	}
+
+	if xdg != "" {
+		files = append(files, filepath.Join(xdg, xdgStoreFile))
+	}
+
+	return files
+}
+
+// Get returns the first stored credential matching the given one.
+func (s *Store) Get(c *Credential) (*Credential, error) {
+	for _, f := range s.files() {
+		entries, err := readStoreFile(f)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, e := range entries {
+			if e.Matches(c) && e.isComplete() {
+				return e, nil
+			}
+		}
+	}
+
+	return nil, nil
+}
+
+// Store stores the given credential in the first file of the store,
+// replacing the stored credentials with the same protocol, host, path and
+// username.
+func (s *Store) Store(c *Credential) error {
+	if c.Protocol == "" || c.Host == "" || !c.isComplete() {
+		return nil
+	}
+
+	files := s.files()
+	if len(files) == 0 {
+		return nil
+	}
+
+	return rewriteStoreFile(files[0], c, c)
+}
+
+// Erase removes the credentials matching the given one from the files of
+// the store.
+func (s *Store) Erase(c *Credential) error {
+	if c.Protocol == "" && c.Host == "" {
+		return nil
+	}
+
+	for _, f := range s.files() {
+		if err := rewriteStoreFile(f, c, nil); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func readStoreFile(path string) ([]*Credential, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+
+	var entries []*Credential
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		// as git-credential-store does, the invalid lines are ignored.
+		e, err := parseURL(line)
+		if err != nil || e.Protocol == "" || e.Host == "" {
+			continue
+		}
+
+		entries = append(entries, e)
+	}
+
+	return entries, scanner.Err()
+}
+
+// rewriteStoreFile rewrites the store file without the credentials matching
+// remove, and with add appended if not nil.
+func rewriteStoreFile(path string, remove, add *Credential) error {
+	entries, err := readStoreFile(path)
+	if err != nil {
+		return err
+	}
+
+	pattern := *remove
+	pattern.Password = ""
+
+	var removed int
+	buf := bytes.NewBuffer(nil)
+	for _, e := range entries {
+		if e.Matches(&pattern) {
+			removed++
+			continue
+		}
+
+		buf.WriteString(e.URL() + "\n")
+	}
+
+	if add != nil {
+		buf.WriteString(add.URL() + "\n")
+	} else if removed == 0 {
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+
+	return writeFileAtomic(path, buf.Bytes())
+}
+
+// writeFileAtomic writes the file through a temporary file renamed over it,
+// readable only by its owner.
+func writeFileAtomic(path string, content []byte) (err error) {
+	f, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".*.lock")
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if err != nil {
+			os.Remove(f.Name())
+		}
+	}()
+
+	if err = f.Chmod(0600); err != nil {
+		f.Close()
+		return err
+	}
+
+	if _, err = f.Write(content); err != nil {
+		f.Close()
+		return err
+	}
+
+	if err = f.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), path)
+}
@@ -0,0 +1,366 @@
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// DefaultMaxChallengeRounds is the maximum number of challenges answered for
+// a request, if ChallengeAuth.MaxRounds is zero.
+const DefaultMaxChallengeRounds = 10
+
+// Challenge is a challenge of the WWW-Authenticate header of a response.
+type Challenge struct {
+	// Scheme is the auth scheme of the challenge, e.g. Basic.
+	Scheme string
+	// Token is the token68 of the challenge, if any, e.g. the token of the
+	// server in a Negotiate exchange.
+	Token string
+	// Params are the auth-params of the challenge, by lower-cased name.
+	Params map[string]string
+}
+
+// ChallengeHandler answers the challenges of an auth scheme.
+type ChallengeHandler interface {
+	// Scheme returns the auth scheme of the challenges answered, e.g. Bearer.
+	Scheme() string
+	// Authorize returns the value of the Authorization header answering the
+	// challenge of the response to the request. previous is the value sent
+	// with the request by this handler, empty if none, the challenge then
+	// rejecting it or continuing a multi-round exchange. An empty value is
+	// returned if the challenge can't be answered.
+	Authorize(req *http.Request, c *Challenge, previous string) (string, error)
+}
+
+// ChallengeAuth is an AuthMethod answering the challenges of the server: the
+// requests are sent without Authorization header and, if the response is a
+// 401, sent again with the answer of the first of its handlers supporting one
+// of the challenges of the response, until they are accepted or no handler
+// answers. The answer accepted is then sent up front with the next requests
+// of the session.
+type ChallengeAuth struct {
+	// Handlers are the handlers of the challenges, in order of preference.
+	Handlers []ChallengeHandler
+	// MaxRounds is the maximum number of challenges answered for a request,
+	// DefaultMaxChallengeRounds if zero.
+	MaxRounds int
+
+	m sync.Mutex
+}
+
+// NewChallengeAuth returns a ChallengeAuth with the given handlers.
+func NewChallengeAuth(handlers ...ChallengeHandler) *ChallengeAuth {
+	return &ChallengeAuth{Handlers: handlers}
+}
+
+// Register adds the handler of a challenge scheme, replacing the one of the
+// same scheme, if any.
+func (a *ChallengeAuth) Register(h ChallengeHandler) {
+	a.m.Lock()
+	defer a.m.Unlock()
+
+	for i, o := range a.Handlers {
+		if strings.EqualFold(o.Scheme(), h.Scheme()) {
+			a.Handlers[i] = h
+			return
+		}
+	}
+
+	a.Handlers = append(a.Handlers, h)
+}
+
+// setAuth does nothing, the Authorization header only being sent once
+// challenged, by session.do.
+func (a *ChallengeAuth) setAuth(r *http.Request) {}
+
+// Name is name of the auth
+func (a *ChallengeAuth) Name() string {
+	return "http-challenge-auth"
+}
+
+func (a *ChallengeAuth) String() string {
+	a.m.Lock()
+	defer a.m.Unlock()
+
+	var schemes []string
+	for _, h := range a.Handlers {
+		schemes = append(schemes, h.Scheme())
+	}
+
+	return fmt.Sprintf("%s - %s", a.Name(), strings.Join(schemes, ", "))
+}
+
+// acceptedChallenge is the answer to a challenge accepted by the server.
+type acceptedChallenge struct {
+	scheme, authz string
+}
+
+// do sends the request, answering the challenges of its 401 responses. The
+// answer accepted is kept in accepted, and sent up front with the next
+// requests, as long as the server accepts it.
+func (a *ChallengeAuth) do(
+	req *http.Request, send func(*http.Request) (*http.Response, error),
+	accepted *acceptedChallenge,
+) (*http.Response, error) {
+
+	max := a.MaxRounds
+	if max == 0 {
+		max = DefaultMaxChallengeRounds
+	}
+
+	scheme, sent := accepted.scheme, accepted.authz
+	upfront := sent != ""
+	if upfront {
+		req = setAuthorization(req, sent)
+	}
+
+	rejected := make(map[string]bool)
+	for round := 0; ; round++ {
+		res, err := send(req)
+		if err != nil {
+			return nil, err
+		}
+
+		if res.StatusCode != http.StatusUnauthorized {
+			*accepted = acceptedChallenge{scheme, sent}
+			return res, nil
+		}
+
+		*accepted = acceptedChallenge{}
+		if round == max || (req.Body != nil && req.GetBody == nil) {
+			return res, nil
+		}
+
+		challenges := ParseChallenges(res.Header["Www-Authenticate"])
+		authz, h, err := a.answer(req, challenges, scheme, sent, rejected, upfront && round == 0)
+		if err != nil {
+			_ = res.Body.Close()
+			return nil, err
+		}
+
+		if authz == "" {
+			return res, nil
+		}
+
+		_ = res.Body.Close()
+		if req, err = rewindRequest(req); err != nil {
+			return nil, err
+		}
+
+		req = setAuthorization(req, authz)
+		scheme, sent = h, authz
+	}
+}
+
+// answer returns the answer to the challenges, as authorize does. If the
+// answer sent up front was rejected, its handler is asked to renew it, e.g. a
+// Bearer token expired since, and if it can't, the challenges are answered as
+// the ones of a new exchange.
+func (a *ChallengeAuth) answer(
+	req *http.Request, challenges []*Challenge,
+	scheme, sent string, rejected map[string]bool, upfront bool,
+) (string, string, error) {
+
+	if !upfront {
+		return a.authorize(req, challenges, scheme, sent, rejected)
+	}
+
+	authz, h, err := a.authorize(req, challenges, scheme, sent, make(map[string]bool))
+	if err != nil || authz != "" {
+		return authz, h, err
+	}
+
+	return a.authorize(req, challenges, "", "", rejected)
+}
+
+// setAuthorization returns a copy of the request with the given
+// Authorization header.
+func setAuthorization(req *http.Request, authz string) *http.Request {
+	r := req.WithContext(req.Context())
+	r.Header = make(http.Header, len(req.Header)+1)
+	for k, v := range req.Header {
+		r.Header[k] = v
+	}
+
+	r.Header.Set("Authorization", authz)
+	return r
+}
+
+// authorize returns the answer to the first challenge answered by a handler,
+// and the scheme of the handler. The handlers not answering are marked as
+// rejected, not to be asked again.
+func (a *ChallengeAuth) authorize(
+	req *http.Request, challenges []*Challenge,
+	scheme, sent string, rejected map[string]bool,
+) (string, string, error) {
+
+	a.m.Lock()
+	handlers := append([]ChallengeHandler(nil), a.Handlers...)
+	a.m.Unlock()
+
+	for _, h := range handlers {
+		s := strings.ToLower(h.Scheme())
+		if rejected[s] {
+			continue
+		}
+
+		c := findChallenge(challenges, s)
+		if c == nil {
+			continue
+		}
+
+		var previous string
+		if s == scheme {
+			previous = sent
+		}
+
+		authz, err := h.Authorize(req, c, previous)
+		if err != nil {
+			return "", "", err
+		}
+
+		if authz == "" {
+			rejected[s] = true
+			continue
+		}
+
+		return authz, s, nil
+	}
+
+	return "", "", nil
+}
+
+func findChallenge(challenges []*Challenge, scheme string) *Challenge {
+	for _, c := range challenges {
+		if strings.EqualFold(c.Scheme, scheme) {
+			return c
+		}
+	}
+
+	return nil
+}
+
+// ParseChallenges parses the challenges of the WWW-Authenticate headers, as
+// described by RFC 7235. The invalid part of a header, if any, is ignored.
+func ParseChallenges(headers []string) []*Challenge {
+	var challenges []*Challenge
+	for _, h := range headers {
+		p := &challengeParser{s: h}
+		challenges = append(challenges, p.parse()...)
+	}
+
+	return challenges
+}
+
+type challengeParser struct {
+	s string
+	i int
+}
+
+func (p *challengeParser) parse() []*Challenge {
+	var challenges []*Challenge
+	var c *Challenge
+	for {
+		p.skip(" \t,")
+		name := p.token()
+		if name == "" {
+			return challenges
+		}
+
+		p.skip(" \t")
+		if c != nil && p.peek() == '=' {
+			p.i++
+			p.skip(" \t")
+			c.Params[strings.ToLower(name)] = p.value()
+			continue
+		}
+
+		c = &Challenge{Scheme: name, Params: make(map[string]string)}
+		c.Token = p.token68()
+		challenges = append(challenges, c)
+	}
+}
+
+func (p *challengeParser) peek() byte {
+	if p.i >= len(p.s) {
+		return 0
+	}
+
+	return p.s[p.i]
+}
+
+func (p *challengeParser) skip(chars string) {
+	for p.i < len(p.s) && strings.IndexByte(chars, p.s[p.i]) >= 0 {
+		p.i++
+	}
+}
+
+func (p *challengeParser) span(valid func(byte) bool) string {
+	start := p.i
+	for p.i < len(p.s) && valid(p.s[p.i]) {
+		p.i++
+	}
+
+	return p.s[start:p.i]
+}
+
+func (p *challengeParser) token() string {
+	return p.span(isTokenChar)
+}
+
+// token68 returns the token68 at the current position, if it is followed by
+// the end of the challenge, or the empty string.
+func (p *challengeParser) token68() string {
+	start := p.i
+	t := p.span(isToken68Char)
+	if t == "" {
+		return ""
+	}
+
+	t += p.span(func(b byte) bool { return b == '=' })
+	p.skip(" \t")
+	if c := p.peek(); c != ',' && c != 0 {
+		p.i = start
+		return ""
+	}
+
+	return t
+}
+
+func (p *challengeParser) value() string {
+	if p.peek() != '"' {
+		return p.token()
+	}
+
+	var buf strings.Builder
+	for p.i++; p.i < len(p.s); p.i++ {
+		switch b := p.s[p.i]; b {
+		case '"':
+			p.i++
+			return buf.String()
+		case '\\':
+			if p.i+1 < len(p.s) {
+				p.i++
+				buf.WriteByte(p.s[p.i])
+			}
+		default:
+			buf.WriteByte(b)
+		}
+	}
+
+	return buf.String()
+}
+
+func isTokenChar(b byte) bool {
+	return isAlphaNum(b) || strings.IndexByte("!#$%&'*+-.^_`|~", b) >= 0
+}
+
+func isToken68Char(b byte) bool {
+	return isAlphaNum(b) || strings.IndexByte("-._~+/", b) >= 0
+}
+
+func isAlphaNum(b byte) bool {
+	return 'a' <= b && b <= 'z' || 'A' <= b && b <= 'Z' || '0' <= b && b <= '9'
+}
@@ -0,0 +1,137 @@
+package http
+
+import (
+	"encoding/base64"
+	"net/http"
+	"sync"
+)
+
+const (
+	basicScheme     = "Basic"
+	bearerScheme    = "Bearer"
+	negotiateScheme = "Negotiate"
+)
+
+// BasicChallengeHandler answers the Basic challenges with a username and a
+// password, once, the credentials being rejected if challenged again.
+type BasicChallengeHandler struct {
+	Username, Password string
+}
+
+// Scheme returns Basic.
+func (h *BasicChallengeHandler) Scheme() string {
+	return basicScheme
+}
+
+// Authorize returns the Basic credentials, unless they were rejected.
+func (h *BasicChallengeHandler) Authorize(req *http.Request, c *Challenge, previous string) (string, error) {
+	if previous != "" {
+		return "", nil
+	}
+
+	credentials := base64.StdEncoding.EncodeToString([]byte(h.Username + ":" + h.Password))
+	return basicScheme + " " + credentials, nil
+}
+
+// TokenSource returns the tokens answering the Bearer challenges, e.g. the
+// access tokens of an OAuth2 client.
+type TokenSource interface {
+	// Token returns the current token. If refresh is true, the token
+	// previously returned was rejected and must be renewed, e.g. with the
+	// refresh token of OAuth2. An empty token is returned if there is none.
+	Token(refresh bool) (string, error)
+}
+
+// TokenSourceFunc is a TokenSource calling a function.
+type TokenSourceFunc func(refresh bool) (string, error)
+
+// Token calls the function.
+func (f TokenSourceFunc) Token(refresh bool) (string, error) {
+	return f(refresh)
+}
+
+// BearerChallengeHandler answers the Bearer challenges with the tokens of a
+// token source, asking it for a new token when the current one is rejected.
+type BearerChallengeHandler struct {
+	TokenSource TokenSource
+}
+
+// Scheme returns Bearer.
+func (h *BearerChallengeHandler) Scheme() string {
+	return bearerScheme
+}
+
+// Authorize returns the current token or, if it was rejected, a new one.
+func (h *BearerChallengeHandler) Authorize(req *http.Request, c *Challenge, previous string) (string, error) {
+	token, err := h.TokenSource.Token(previous != "")
+	if err != nil || token == "" {
+		return "", err
+	}
+
+	authz := bearerScheme + " " + token
+	if authz == previous {
+		return "", nil
+	}
+
+	return authz, nil
+}
+
+// SecurityContext is the context of the client in a Negotiate exchange, as
+// implemented by the GSS-API mechanisms, e.g. Kerberos through SPNEGO.
+type SecurityContext interface {
+	// Step processes the token of the server, nil for the first step, and
+	// returns the token sent to the server.
+	Step(token []byte) ([]byte, error)
+}
+
+// NegotiateChallengeHandler answers the Negotiate challenges described by
+// RFC 4559, the tokens being exchanged with the server by a security context
+// until it accepts them.
+type NegotiateChallengeHandler struct {
+	// NewContext returns a new security context for the host of the server.
+	NewContext func(host string) (SecurityContext, error)
+
+	m   sync.Mutex
+	ctx SecurityContext
+}
+
+// Scheme returns Negotiate.
+func (h *NegotiateChallengeHandler) Scheme() string {
+	return negotiateScheme
+}
+
+// Authorize starts a new exchange with the server, or continues it if the
+// challenge has a token.
+func (h *NegotiateChallengeHandler) Authorize(req *http.Request, c *Challenge, previous string) (string, error) {
+	h.m.Lock()
+	defer h.m.Unlock()
+
+	var in []byte
+	switch {
+	case c.Token == "" && previous != "":
+		h.ctx = nil
+		return "", nil
+	case c.Token == "":
+		ctx, err := h.NewContext(req.URL.Hostname())
+		if err != nil {
+			return "", err
+		}
+
+		h.ctx = ctx
+	case h.ctx == nil || previous == "":
+		return "", nil
+	default:
+		var err error
+		if in, err = base64.StdEncoding.DecodeString(c.Token); err != nil {
+			return "", err
+		}
+	}
+
+	out, err := h.ctx.Step(in)
+	if err != nil {
+		h.ctx = nil
+		return "", err
+	}
+
+	return negotiateScheme + " " + base64.StdEncoding.EncodeToString(out), nil
+}
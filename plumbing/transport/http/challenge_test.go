@@ -0,0 +1,488 @@
+package http
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/cgi"
+	"net/http/httptest"
+	"net/url"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/protocol/packp"
+	"gopkg.in/src-d/go-git.v4/plumbing/transport"
+
+	. "gopkg.in/check.v1"
+	"gopkg.in/src-d/go-git-fixtures.v3"
+)
+
+type ChallengeSuite struct {
+	fixtures.Suite
+}
+
+var _ = Suite(&ChallengeSuite{})
+
+func (s *ChallengeSuite) TestParseChallenges(c *C) {
+	for _, t := range []struct {
+		headers    []string
+		challenges []*Challenge
+	}{{
+		[]string{`Basic realm="git"`},
+		[]*Challenge{{Scheme: "Basic", Params: map[string]string{"realm": "git"}}},
+	}, {
+		[]string{`Negotiate`, `Basic realm="a \"b\"", charset=UTF-8`},
+		[]*Challenge{
+			{Scheme: "Negotiate", Params: map[string]string{}},
+			{Scheme: "Basic", Params: map[string]string{"realm": `a "b"`, "charset": "UTF-8"}},
+		},
+	}, {
+		[]string{`Bearer realm="git", error="invalid_token", Negotiate YIIBLg==, Foo`},
+		[]*Challenge{
+			{Scheme: "Bearer", Params: map[string]string{"realm": "git", "error": "invalid_token"}},
+			{Scheme: "Negotiate", Token: "YIIBLg==", Params: map[string]string{}},
+			{Scheme: "Foo", Params: map[string]string{}},
+		},
+	}, {
+		[]string{`Custom Nonce = abc , , Basic`},
+		[]*Challenge{
+			{Scheme: "Custom", Params: map[string]string{"nonce": "abc"}},
+			{Scheme: "Basic", Params: map[string]string{}},
+		},
+	}, {
+		[]string{``, `"invalid"`},
+		nil,
+	}} {
+		c.Assert(ParseChallenges(t.headers), DeepEquals, t.challenges,
+			Commentf("headers %q", t.headers))
+	}
+}
+
+// challengeServer is a server answering with 401 and the challenges returned
+// by a function, unless it returns none, the requests being then served.
+type challengeServer struct {
+	sync.Mutex
+	*httptest.Server
+	authorizations []string
+}
+
+func newChallengeServer(challenges func(authz string) []string, h http.Handler) *challengeServer {
+	s := &challengeServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authz := r.Header.Get("Authorization")
+		s.Lock()
+		s.authorizations = append(s.authorizations, authz)
+		s.Unlock()
+
+		if cs := challenges(authz); len(cs) != 0 {
+			w.Header()["Www-Authenticate"] = cs
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		if h == nil {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		h.ServeHTTP(w, r)
+	}))
+
+	return s
+}
+
+func (s *ChallengeSuite) advertisedReferences(c *C, auth transport.AuthMethod, rawURL string) error {
+	ep, err := transport.NewEndpoint(rawURL)
+	c.Assert(err, IsNil)
+
+	r, err := DefaultClient.NewUploadPackSession(ep, auth)
+	c.Assert(err, IsNil)
+	defer r.Close()
+
+	_, err = r.AdvertisedReferences()
+	return err
+}
+
+func basic(user, pass string) string {
+	return "Basic " + base64.StdEncoding.EncodeToString([]byte(user+":"+pass))
+}
+
+func (s *ChallengeSuite) TestBasic(c *C) {
+	server := newChallengeServer(func(authz string) []string {
+		if authz == basic("foo", "bar") {
+			return nil
+		}
+
+		return []string{`Basic realm="git"`}
+	}, nil)
+	defer server.Close()
+
+	auth := NewChallengeAuth(&BasicChallengeHandler{"foo", "bar"})
+	err := s.advertisedReferences(c, auth, server.URL+"/repo")
+	c.Assert(err, Equals, transport.ErrRepositoryNotFound)
+	c.Assert(server.authorizations, DeepEquals, []string{"", basic("foo", "bar")})
+}
+
+func (s *ChallengeSuite) TestBasicRejected(c *C) {
+	server := newChallengeServer(func(authz string) []string {
+		return []string{`Basic realm="git"`}
+	}, nil)
+	defer server.Close()
+
+	auth := NewChallengeAuth(&BasicChallengeHandler{"foo", "qux"})
+	err := s.advertisedReferences(c, auth, server.URL+"/repo")
+	c.Assert(err, Equals, transport.ErrAuthenticationRequired)
+	c.Assert(server.authorizations, DeepEquals, []string{"", basic("foo", "qux")})
+}
+
+func (s *ChallengeSuite) TestUnsupportedScheme(c *C) {
+	server := newChallengeServer(func(authz string) []string {
+		return []string{`Digest realm="git", nonce="abc"`}
+	}, nil)
+	defer server.Close()
+
+	auth := NewChallengeAuth(&BasicChallengeHandler{"foo", "bar"})
+	err := s.advertisedReferences(c, auth, server.URL+"/repo")
+	c.Assert(err, Equals, transport.ErrAuthenticationRequired)
+	c.Assert(server.authorizations, DeepEquals, []string{""})
+}
+
+// identityProvider is an OAuth2 token endpoint issuing a new access token
+// for each refresh token grant.
+type identityProvider struct {
+	sync.Mutex
+	*httptest.Server
+	issued []string
+}
+
+func newIdentityProvider() *identityProvider {
+	p := &identityProvider{}
+	p.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil ||
+			r.Form.Get("grant_type") != "refresh_token" ||
+			r.Form.Get("refresh_token") != "refresh" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		p.Lock()
+		token := fmt.Sprintf("access-%d", len(p.issued)+1)
+		p.issued = append(p.issued, token)
+		p.Unlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]string{
+			"access_token": token,
+			"token_type":   "Bearer",
+		})
+	}))
+
+	return p
+}
+
+func (p *identityProvider) valid(token string) bool {
+	p.Lock()
+	defer p.Unlock()
+	return len(p.issued) != 0 && p.issued[len(p.issued)-1] == token
+}
+
+// tokenSource returns a token source starting with the given access token,
+// refreshing it with the identity provider.
+func (p *identityProvider) tokenSource(token string) TokenSource {
+	return TokenSourceFunc(func(refresh bool) (string, error) {
+		if !refresh {
+			return token, nil
+		}
+
+		res, err := http.PostForm(p.URL, url.Values{
+			"grant_type":    {"refresh_token"},
+			"refresh_token": {"refresh"},
+		})
+		if err != nil {
+			return "", err
+		}
+
+		defer res.Body.Close()
+		if res.StatusCode != http.StatusOK {
+			return "", fmt.Errorf("token endpoint: %s", res.Status)
+		}
+
+		var body struct {
+			AccessToken string `json:"access_token"`
+		}
+
+		if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+			return "", err
+		}
+
+		token = body.AccessToken
+		return token, nil
+	})
+}
+
+func (s *ChallengeSuite) TestBearerRefresh(c *C) {
+	idp := newIdentityProvider()
+	defer idp.Close()
+
+	server := newChallengeServer(func(authz string) []string {
+		if strings.HasPrefix(authz, "Bearer ") && idp.valid(authz[len("Bearer "):]) {
+			return nil
+		}
+
+		if authz == "" {
+			return []string{`Bearer realm="git"`}
+		}
+
+		return []string{`Bearer realm="git", error="invalid_token"`}
+	}, nil)
+	defer server.Close()
+
+	auth := NewChallengeAuth(&BearerChallengeHandler{idp.tokenSource("expired")})
+	err := s.advertisedReferences(c, auth, server.URL+"/repo")
+	c.Assert(err, Equals, transport.ErrRepositoryNotFound)
+	c.Assert(server.authorizations, DeepEquals, []string{"", "Bearer expired", "Bearer access-1"})
+
+	err = s.advertisedReferences(c, auth, server.URL+"/repo")
+	c.Assert(err, Equals, transport.ErrRepositoryNotFound)
+	c.Assert(idp.issued, DeepEquals, []string{"access-1"})
+}
+
+func (s *ChallengeSuite) TestBearerRefreshError(c *C) {
+	server := newChallengeServer(func(authz string) []string {
+		return []string{`Bearer realm="git"`}
+	}, nil)
+	defer server.Close()
+
+	errRefresh := errors.New("refresh")
+	auth := NewChallengeAuth(&BearerChallengeHandler{TokenSourceFunc(func(refresh bool) (string, error) {
+		if refresh {
+			return "", errRefresh
+		}
+
+		return "token", nil
+	})})
+
+	err := s.advertisedReferences(c, auth, server.URL+"/repo")
+	c.Assert(err, Equals, errRefresh)
+}
+
+// mockSecurityContext answers the tokens of the server as given by a map.
+type mockSecurityContext struct {
+	host  string
+	steps map[string]string
+}
+
+func (m *mockSecurityContext) Step(token []byte) ([]byte, error) {
+	out, ok := m.steps[string(token)]
+	if !ok {
+		return nil, fmt.Errorf("unexpected token %q", token)
+	}
+
+	return []byte(out), nil
+}
+
+func negotiate(token string) string {
+	return "Negotiate " + base64.StdEncoding.EncodeToString([]byte(token))
+}
+
+func (s *ChallengeSuite) TestNegotiate(c *C) {
+	server := newChallengeServer(func(authz string) []string {
+		switch authz {
+		case negotiate("hello"):
+			return []string{negotiate("challenge")}
+		case negotiate("response"):
+			return nil
+		default:
+			return []string{"Negotiate", `Basic realm="git"`}
+		}
+	}, nil)
+	defer server.Close()
+
+	var ctx *mockSecurityContext
+	auth := NewChallengeAuth(&NegotiateChallengeHandler{
+		NewContext: func(host string) (SecurityContext, error) {
+			ctx = &mockSecurityContext{host: host, steps: map[string]string{
+				"":          "hello",
+				"challenge": "response",
+			}}
+
+			return ctx, nil
+		},
+	}, &BasicChallengeHandler{"foo", "bar"})
+
+	err := s.advertisedReferences(c, auth, server.URL+"/repo")
+	c.Assert(err, Equals, transport.ErrRepositoryNotFound)
+	c.Assert(ctx.host, Equals, "127.0.0.1")
+	c.Assert(server.authorizations, DeepEquals, []string{
+		"", negotiate("hello"), negotiate("response"),
+	})
+}
+
+func (s *ChallengeSuite) TestNegotiateFallback(c *C) {
+	server := newChallengeServer(func(authz string) []string {
+		if authz == basic("foo", "bar") {
+			return nil
+		}
+
+		return []string{"Negotiate", `Basic realm="git"`}
+	}, nil)
+	defer server.Close()
+
+	auth := NewChallengeAuth(&NegotiateChallengeHandler{
+		NewContext: func(host string) (SecurityContext, error) {
+			return &mockSecurityContext{steps: map[string]string{"": "hello"}}, nil
+		},
+	}, &BasicChallengeHandler{"foo", "bar"})
+
+	err := s.advertisedReferences(c, auth, server.URL+"/repo")
+	c.Assert(err, Equals, transport.ErrRepositoryNotFound)
+	c.Assert(server.authorizations, DeepEquals, []string{
+		"", negotiate("hello"), basic("foo", "bar"),
+	})
+}
+
+// customChallengeHandler answers the challenges of the Custom scheme with
+// their nonce, reversed.
+type customChallengeHandler struct{}
+
+func (customChallengeHandler) Scheme() string { return "Custom" }
+
+func (customChallengeHandler) Authorize(req *http.Request, c *Challenge, previous string) (string, error) {
+	nonce := []byte(c.Params["nonce"])
+	for i, j := 0, len(nonce)-1; i < j; i, j = i+1, j-1 {
+		nonce[i], nonce[j] = nonce[j], nonce[i]
+	}
+
+	return "Custom " + string(nonce), nil
+}
+
+func (s *ChallengeSuite) TestRegister(c *C) {
+	server := newChallengeServer(func(authz string) []string {
+		if authz == "Custom cba" {
+			return nil
+		}
+
+		return []string{`Custom nonce="abc"`, `Basic realm="git"`}
+	}, nil)
+	defer server.Close()
+
+	auth := NewChallengeAuth()
+	auth.Register(customChallengeHandler{})
+	auth.Register(&BasicChallengeHandler{"foo", "bar"})
+	auth.Register(&BasicChallengeHandler{"foo", "qux"})
+	c.Assert(auth.Handlers, HasLen, 2)
+	c.Assert(auth.String(), Equals, "http-challenge-auth - Custom, Basic")
+
+	err := s.advertisedReferences(c, auth, server.URL+"/repo")
+	c.Assert(err, Equals, transport.ErrRepositoryNotFound)
+	c.Assert(server.authorizations, DeepEquals, []string{"", "Custom cba"})
+}
+
+func (s *ChallengeSuite) TestMaxRounds(c *C) {
+	server := newChallengeServer(func(authz string) []string {
+		return []string{`Custom nonce="abc"`}
+	}, nil)
+	defer server.Close()
+
+	auth := NewChallengeAuth(customChallengeHandler{})
+	auth.MaxRounds = 3
+
+	err := s.advertisedReferences(c, auth, server.URL+"/repo")
+	c.Assert(err, Equals, transport.ErrAuthenticationRequired)
+	c.Assert(server.authorizations, HasLen, 4)
+}
+
+func (s *ChallengeSuite) TestUploadPack(c *C) {
+	out, err := exec.Command("git", "--exec-path").CombinedOutput()
+	c.Assert(err, IsNil)
+
+	fs := fixtures.Basic().One().DotGit()
+	c.Assert(fixtures.EnsureIsBare(fs), IsNil)
+
+	server := newChallengeServer(func(authz string) []string {
+		if authz == basic("foo", "bar") {
+			return nil
+		}
+
+		return []string{`Basic realm="git"`}
+	}, &cgi.Handler{
+		Path: filepath.Join(strings.TrimSpace(string(out)), "git-http-backend"),
+		Env: []string{
+			"GIT_HTTP_EXPORT_ALL=true",
+			fmt.Sprintf("GIT_PROJECT_ROOT=%s", filepath.Dir(fs.Root())),
+		},
+	})
+	defer server.Close()
+
+	ep, err := transport.NewEndpoint(fmt.Sprintf("%s/%s", server.URL, filepath.Base(fs.Root())))
+	c.Assert(err, IsNil)
+
+	r, err := DefaultClient.NewUploadPackSession(ep, NewChallengeAuth(&BasicChallengeHandler{"foo", "bar"}))
+	c.Assert(err, IsNil)
+	defer r.Close()
+
+	_, err = r.AdvertisedReferences()
+	c.Assert(err, IsNil)
+
+	req := packp.NewUploadPackRequest()
+	req.Wants = append(req.Wants, plumbing.NewHash("6ecf0ef2c2dffb796033e5a02219af86ec6584e5"))
+
+	res, err := r.UploadPack(context.Background(), req)
+	c.Assert(err, IsNil)
+	c.Assert(res.Close(), IsNil)
+	c.Assert(server.authorizations, DeepEquals, []string{
+		"", basic("foo", "bar"), basic("foo", "bar"),
+	})
+}
+
+func (s *ChallengeSuite) TestAcceptedUpFront(c *C) {
+	valid := "Bearer stale"
+	server := newChallengeServer(func(authz string) []string {
+		if authz == valid {
+			return nil
+		}
+
+		return []string{`Bearer realm="git"`}
+	}, nil)
+	defer server.Close()
+
+	token := "stale"
+	auth := NewChallengeAuth(&BearerChallengeHandler{TokenSourceFunc(func(refresh bool) (string, error) {
+		if refresh {
+			token = "fresh"
+		}
+
+		return token, nil
+	})})
+
+	ep, err := transport.NewEndpoint(server.URL + "/repo")
+	c.Assert(err, IsNil)
+
+	session, err := newSession(DefaultClient.(*client), ep, auth)
+	c.Assert(err, IsNil)
+
+	get := func() {
+		req, err := http.NewRequest(http.MethodGet, server.URL+"/repo", nil)
+		c.Assert(err, IsNil)
+
+		res, err := session.do(req, true)
+		c.Assert(err, IsNil)
+		c.Assert(res.StatusCode, Equals, http.StatusNotFound)
+		c.Assert(res.Body.Close(), IsNil)
+		c.Assert(req.Header.Get("Authorization"), Equals, "")
+	}
+
+	get()
+	get()
+	c.Assert(server.authorizations, DeepEquals, []string{"", "Bearer stale", "Bearer stale"})
+
+	valid = "Bearer fresh"
+	get()
+	c.Assert(server.authorizations[3:], DeepEquals, []string{"Bearer stale", "Bearer fresh"})
+}
@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
 	"gopkg.in/src-d/go-git.v4/plumbing"
 	"gopkg.in/src-d/go-git.v4/plumbing/protocol/packp"
@@ -18,6 +19,7 @@ import (
 func applyHeadersToRequest(req *http.Request, content *bytes.Buffer, host string, requestType string) {
 	req.Header.Add("User-Agent", "git/1.0")
 	req.Header.Add("Host", host) // host:port
+	req.Header.Add("Accept-Encoding", "deflate, gzip")
 
 	if content == nil {
 		req.Header.Add("Accept", "*/*")
@@ -44,7 +46,8 @@ func advertisedReferences(s *session, serviceName string) (ref *packp.AdvRefs, e
 
 	s.ApplyAuthToRequest(req)
 	applyHeadersToRequest(req, nil, s.endpoint.Host, serviceName)
-	res, err := s.client.Do(req)
+	s.applyExtraHeaders(req)
+	res, err := s.do(req, true)
 	if err != nil {
 		return nil, err
 	}
@@ -72,7 +75,14 @@ func advertisedReferences(s *session, serviceName string) (ref *packp.AdvRefs, e
 }
 
 type client struct {
-	c *http.Client
+	c       *http.Client
+	headers http.Header
+
+	maxRetries         int
+	retryBackoff       time.Duration
+	maxRetryBackoff    time.Duration
+	disableCompression bool
+	onResponse         func(*ResponseInfo)
 }
 
 // DefaultClient is the default HTTP client, which uses `http.DefaultClient`.
@@ -88,7 +98,7 @@ var DefaultClient = NewClient(nil)
 // for both.
 func NewClient(c *http.Client) transport.Transport {
 	if c == nil {
-		return &client{http.DefaultClient}
+		return &client{c: http.DefaultClient}
 	}
 
 	return &client{
@@ -99,23 +109,26 @@ func NewClient(c *http.Client) transport.Transport {
 func (c *client) NewUploadPackSession(ep *transport.Endpoint, auth transport.AuthMethod) (
 	transport.UploadPackSession, error) {
 
-	return newUploadPackSession(c.c, ep, auth)
+	return newUploadPackSession(c, ep, auth)
 }
 
 func (c *client) NewReceivePackSession(ep *transport.Endpoint, auth transport.AuthMethod) (
 	transport.ReceivePackSession, error) {
 
-	return newReceivePackSession(c.c, ep, auth)
+	return newReceivePackSession(c, ep, auth)
 }
 
 type session struct {
 	auth     AuthMethod
-	client   *http.Client
+	client   *client
 	endpoint *transport.Endpoint
 	advRefs  *packp.AdvRefs
+	// challenge is the answer to the challenges of the server accepted by
+	// the last request, sent up front with the next ones.
+	challenge acceptedChallenge
 }
 
-func newSession(c *http.Client, ep *transport.Endpoint, auth transport.AuthMethod) (*session, error) {
+func newSession(c *client, ep *transport.Endpoint, auth transport.AuthMethod) (*session, error) {
 	s := &session{
 		auth:     basicAuthFromEndpoint(ep),
 		client:   c,
@@ -141,6 +154,15 @@ func (s *session) ApplyAuthToRequest(req *http.Request) {
 	s.auth.setAuth(req)
 }
 
+// applyExtraHeaders adds the extra headers of the client to the request.
+func (s *session) applyExtraHeaders(req *http.Request) {
+	for k, v := range s.client.headers {
+		for _, h := range v {
+			req.Header.Add(k, h)
+		}
+	}
+}
+
 func (s *session) ModifyEndpointIfRedirect(res *http.Response) {
 	if res.Request == nil {
 		return
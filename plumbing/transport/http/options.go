@@ -0,0 +1,263 @@
+package http
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"gopkg.in/src-d/go-git.v4/plumbing/transport"
+)
+
+var (
+	// ErrInvalidExtraHeader is returned by NewClientWithOptions when an
+	// extra header isn't a "Name: value" header.
+	ErrInvalidExtraHeader = errors.New("invalid extra header")
+	// ErrInvalidCAInfo is returned by NewClientWithOptions when the CA file
+	// holds no PEM certificate.
+	ErrInvalidCAInfo = errors.New("no certificate found in CA file")
+)
+
+const (
+	noProxyEnv      = "NO_PROXY"
+	noProxyLowerEnv = "no_proxy"
+)
+
+// ClientOptions are the options of the clients returned by
+// NewClientWithOptions, the equivalents of the http.* options of git.
+type ClientOptions struct {
+	// Proxy is the URL of the proxy the requests are sent through, as
+	// http.proxy, with the user and password of the proxy if it requires
+	// authentication. http:// is used if it has no scheme. If empty, the
+	// proxy is the one of the HTTPS_PROXY, HTTP_PROXY and NO_PROXY
+	// environment variables.
+	Proxy string
+	// NoProxy is the comma-separated list of the hosts, domains and CIDR
+	// blocks reached without the proxy, "*" matching all of them, be it
+	// Proxy or the one of the environment. If empty, the NO_PROXY environment
+	// variable is used.
+	NoProxy string
+	// CAInfo is the file with the PEM certificates verifying the servers, as
+	// http.sslCAInfo. If empty, the certificates of the system are used.
+	CAInfo string
+	// SSLCert is the file with the PEM client certificate, as http.sslCert.
+	SSLCert string
+	// SSLKey is the file with the PEM key of the client certificate, as
+	// http.sslKey. If empty, the key must be in SSLCert.
+	SSLKey string
+	// InsecureSkipTLS if true the certificates of the servers aren't
+	// verified, as http.sslVerify set to false.
+	InsecureSkipTLS bool
+	// ExtraHeaders are the "Name: value" headers added to every request, as
+	// http.extraHeader.
+	ExtraHeaders []string
+	// MaxRetries is the number of times the idempotent requests, the ones of
+	// the reference discovery and of git-upload-pack, are retried when they
+	// fail without response or with a 408, 429, 500, 502, 503 or 504 status
+	// code. Only the requests are retried: resumable downloads are not
+	// supported, a response whose body fails while read aborts the
+	// operation.
+	MaxRetries int
+	// RetryBackoff is the time waited before the first retry, doubled for
+	// each next one, DefaultRetryBackoff if zero. The Retry-After header of
+	// the response, in seconds or as an HTTP date, overrides it, up to
+	// MaxRetryBackoff.
+	RetryBackoff time.Duration
+	// MaxRetryBackoff is the maximum time waited before a retry,
+	// DefaultMaxRetryBackoff if zero.
+	MaxRetryBackoff time.Duration
+	// DisableRequestCompression if true the git-upload-pack requests are
+	// never gzipped, as they are when larger than 1KB otherwise.
+	DisableRequestCompression bool
+	// OnResponse if not nil is called with the metadata of the response to
+	// each attempt of each request, e.g. to log them.
+	OnResponse func(*ResponseInfo)
+}
+
+// NewClientWithOptions creates a new client using a net/http client built
+// from the given options.
+func NewClientWithOptions(o *ClientOptions) (transport.Transport, error) {
+	headers, err := o.headers()
+	if err != nil {
+		return nil, err
+	}
+
+	tlsConfig, err := o.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	proxy, err := o.proxy()
+	if err != nil {
+		return nil, err
+	}
+
+	// the settings of http.DefaultTransport
+	tr := &http.Transport{
+		Proxy: proxy,
+		DialContext: (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		MaxIdleConns:          100,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+		TLSClientConfig:       tlsConfig,
+	}
+
+	return &client{
+		c:                  &http.Client{Transport: tr},
+		headers:            headers,
+		maxRetries:         o.MaxRetries,
+		retryBackoff:       o.RetryBackoff,
+		maxRetryBackoff:    o.MaxRetryBackoff,
+		disableCompression: o.DisableRequestCompression,
+		onResponse:         o.OnResponse,
+	}, nil
+}
+
+func (o *ClientOptions) headers() (http.Header, error) {
+	if len(o.ExtraHeaders) == 0 {
+		return nil, nil
+	}
+
+	h := make(http.Header)
+	for _, v := range o.ExtraHeaders {
+		i := strings.IndexByte(v, ':')
+		if i <= 0 {
+			return nil, ErrInvalidExtraHeader
+		}
+
+		name := strings.TrimSpace(v[:i])
+		if name == "" || strings.ContainsAny(name, " \t\r\n") {
+			return nil, ErrInvalidExtraHeader
+		}
+
+		h.Add(textproto.CanonicalMIMEHeaderKey(name), strings.TrimSpace(v[i+1:]))
+	}
+
+	return h, nil
+}
+
+func (o *ClientOptions) tlsConfig() (*tls.Config, error) {
+	c := &tls.Config{InsecureSkipVerify: o.InsecureSkipTLS}
+	if o.CAInfo != "" {
+		pem, err := ioutil.ReadFile(o.CAInfo)
+		if err != nil {
+			return nil, err
+		}
+
+		c.RootCAs = x509.NewCertPool()
+		if !c.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, ErrInvalidCAInfo
+		}
+	}
+
+	if o.SSLCert != "" {
+		key := o.SSLKey
+		if key == "" {
+			key = o.SSLCert
+		}
+
+		cert, err := tls.LoadX509KeyPair(o.SSLCert, key)
+		if err != nil {
+			return nil, err
+		}
+
+		c.Certificates = []tls.Certificate{cert}
+	}
+
+	return c, nil
+}
+
+func (o *ClientOptions) proxy() (func(*http.Request) (*url.URL, error), error) {
+	if o.Proxy == "" && o.NoProxy == "" {
+		return http.ProxyFromEnvironment, nil
+	}
+
+	if o.Proxy == "" {
+		return func(r *http.Request) (*url.URL, error) {
+			if !useProxy(r.URL, o.NoProxy) {
+				return nil, nil
+			}
+
+			return http.ProxyFromEnvironment(r)
+		}, nil
+	}
+
+	raw := o.Proxy
+	if !strings.Contains(raw, "://") {
+		raw = "http://" + raw
+	}
+
+	proxy, err := url.Parse(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	noProxy := o.NoProxy
+	if noProxy == "" {
+		noProxy = os.Getenv(noProxyEnv)
+	}
+
+	if noProxy == "" {
+		noProxy = os.Getenv(noProxyLowerEnv)
+	}
+
+	return func(r *http.Request) (*url.URL, error) {
+		if !useProxy(r.URL, noProxy) {
+			return nil, nil
+		}
+
+		return proxy, nil
+	}, nil
+}
+
+// useProxy reports whether the requests to the URL are sent through the
+// proxy, the URL not matching any of the entries of noProxy. As curl does,
+// the entries match the host and its subdomains, with or without leading dot,
+// and the port if they have one.
+func useProxy(u *url.URL, noProxy string) bool {
+	host, port := strings.ToLower(u.Hostname()), u.Port()
+	for _, p := range strings.Split(noProxy, ",") {
+		p = strings.ToLower(strings.TrimSpace(p))
+		if p == "" {
+			continue
+		}
+
+		if p == "*" {
+			return false
+		}
+
+		if _, block, err := net.ParseCIDR(p); err == nil {
+			if ip := net.ParseIP(host); ip != nil && block.Contains(ip) {
+				return false
+			}
+
+			continue
+		}
+
+		if h, pp, err := net.SplitHostPort(p); err == nil {
+			if pp != port {
+				continue
+			}
+
+			p = h
+		}
+
+		p = strings.TrimPrefix(p, ".")
+		if host == p || strings.HasSuffix(host, "."+p) {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,257 @@
+package http
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"log"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+
+	"gopkg.in/src-d/go-git.v4/plumbing/transport"
+
+	. "gopkg.in/check.v1"
+)
+
+type ClientOptionsSuite struct {
+	dir string
+}
+
+var _ = Suite(&ClientOptionsSuite{})
+
+func (s *ClientOptionsSuite) SetUpTest(c *C) {
+	var err error
+	s.dir, err = ioutil.TempDir("", "go-git-http-options")
+	c.Assert(err, IsNil)
+}
+
+func (s *ClientOptionsSuite) TearDownTest(c *C) {
+	c.Assert(os.RemoveAll(s.dir), IsNil)
+}
+
+func (s *ClientOptionsSuite) advertisedReferences(c *C, cl transport.Transport, rawURL string) error {
+	ep, err := transport.NewEndpoint(rawURL)
+	c.Assert(err, IsNil)
+
+	r, err := cl.NewUploadPackSession(ep, nil)
+	c.Assert(err, IsNil)
+	defer r.Close()
+
+	_, err = r.AdvertisedReferences()
+	return err
+}
+
+func (s *ClientOptionsSuite) TestExtraHeaders(c *C) {
+	var header http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	cl, err := NewClientWithOptions(&ClientOptions{
+		ExtraHeaders: []string{"X-Foo: bar", "x-foo: qux", "X-Empty:"},
+	})
+	c.Assert(err, IsNil)
+
+	err = s.advertisedReferences(c, cl, server.URL+"/repo")
+	c.Assert(err, Equals, transport.ErrRepositoryNotFound)
+	c.Assert(header["X-Foo"], DeepEquals, []string{"bar", "qux"})
+	c.Assert(header["X-Empty"], DeepEquals, []string{""})
+}
+
+func (s *ClientOptionsSuite) TestInvalidExtraHeader(c *C) {
+	for _, h := range []string{"foo", ": bar", "X Foo: bar"} {
+		_, err := NewClientWithOptions(&ClientOptions{ExtraHeaders: []string{h}})
+		c.Assert(err, Equals, ErrInvalidExtraHeader, Commentf("header %q", h))
+	}
+}
+
+func (s *ClientOptionsSuite) TestProxy(c *C) {
+	var requested, auth string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested = r.URL.String()
+		auth = r.Header.Get("Proxy-Authorization")
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer proxy.Close()
+
+	u, err := url.Parse(proxy.URL)
+	c.Assert(err, IsNil)
+
+	cl, err := NewClientWithOptions(&ClientOptions{
+		Proxy: "foo:bar@" + u.Host,
+	})
+	c.Assert(err, IsNil)
+
+	err = s.advertisedReferences(c, cl, "http://example.com/repo")
+	c.Assert(err, Equals, transport.ErrRepositoryNotFound)
+	c.Assert(requested, Equals, "http://example.com/repo/info/refs?service=git-upload-pack")
+	c.Assert(auth, Equals, "Basic Zm9vOmJhcg==")
+}
+
+func (s *ClientOptionsSuite) TestNoProxy(c *C) {
+	var proxied bool
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = true
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer proxy.Close()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	cl, err := NewClientWithOptions(&ClientOptions{
+		Proxy:   proxy.URL,
+		NoProxy: "example.com, 127.0.0.0/8",
+	})
+	c.Assert(err, IsNil)
+
+	err = s.advertisedReferences(c, cl, server.URL+"/repo")
+	c.Assert(err, Equals, transport.ErrRepositoryNotFound)
+	c.Assert(proxied, Equals, false)
+}
+
+func (s *ClientOptionsSuite) TestNoProxyWithoutProxy(c *C) {
+	o := &ClientOptions{NoProxy: "example.com"}
+	proxy, err := o.proxy()
+	c.Assert(err, IsNil)
+
+	req, err := http.NewRequest(http.MethodGet, "https://git.example.com/repo", nil)
+	c.Assert(err, IsNil)
+
+	u, err := proxy(req)
+	c.Assert(err, IsNil)
+	c.Assert(u, IsNil)
+}
+
+func (s *ClientOptionsSuite) TestUseProxy(c *C) {
+	for _, t := range []struct {
+		url, noProxy string
+		use          bool
+	}{
+		{"http://example.com", "", true},
+		{"http://example.com", "*", false},
+		{"http://example.com", "example.com", false},
+		{"http://git.example.com", "example.com", false},
+		{"http://git.example.com", ".example.com", false},
+		{"http://notexample.com", "example.com", true},
+		{"http://example.com:8080", "example.com:8080", false},
+		{"http://example.com:8081", "example.com:8080", true},
+		{"http://10.1.2.3", "foo, 10.0.0.0/8", false},
+		{"http://192.168.1.1", "10.0.0.0/8", true},
+	} {
+		u, err := url.Parse(t.url)
+		c.Assert(err, IsNil)
+		c.Assert(useProxy(u, t.noProxy), Equals, t.use,
+			Commentf("url %q, no proxy %q", t.url, t.noProxy))
+	}
+}
+
+func (s *ClientOptionsSuite) TestCAInfo(c *C) {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	server.Config.ErrorLog = log.New(ioutil.Discard, "", 0)
+	server.StartTLS()
+	defer server.Close()
+
+	cl, err := NewClientWithOptions(&ClientOptions{})
+	c.Assert(err, IsNil)
+
+	err = s.advertisedReferences(c, cl, server.URL+"/repo")
+	c.Assert(err, ErrorMatches, ".*certificate.*")
+
+	ca := filepath.Join(s.dir, "ca.pem")
+	err = ioutil.WriteFile(ca, pem.EncodeToMemory(&pem.Block{
+		Type:  "CERTIFICATE",
+		Bytes: server.Certificate().Raw,
+	}), 0644)
+	c.Assert(err, IsNil)
+
+	cl, err = NewClientWithOptions(&ClientOptions{CAInfo: ca})
+	c.Assert(err, IsNil)
+
+	err = s.advertisedReferences(c, cl, server.URL+"/repo")
+	c.Assert(err, Equals, transport.ErrRepositoryNotFound)
+}
+
+func (s *ClientOptionsSuite) TestInvalidCAInfo(c *C) {
+	ca := filepath.Join(s.dir, "ca.pem")
+	c.Assert(ioutil.WriteFile(ca, []byte("foo"), 0644), IsNil)
+
+	_, err := NewClientWithOptions(&ClientOptions{CAInfo: ca})
+	c.Assert(err, Equals, ErrInvalidCAInfo)
+}
+
+func (s *ClientOptionsSuite) TestInsecureSkipTLS(c *C) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	cl, err := NewClientWithOptions(&ClientOptions{InsecureSkipTLS: true})
+	c.Assert(err, IsNil)
+
+	err = s.advertisedReferences(c, cl, server.URL+"/repo")
+	c.Assert(err, Equals, transport.ErrRepositoryNotFound)
+}
+
+func (s *ClientOptionsSuite) TestSSLCert(c *C) {
+	var peers int
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		peers = len(r.TLS.PeerCertificates)
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	server.StartTLS()
+	defer server.Close()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	c.Assert(err, IsNil)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "foo"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	c.Assert(err, IsNil)
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	c.Assert(err, IsNil)
+
+	cert := filepath.Join(s.dir, "cert.pem")
+	err = ioutil.WriteFile(cert, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644)
+	c.Assert(err, IsNil)
+
+	keyFile := filepath.Join(s.dir, "key.pem")
+	err = ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600)
+	c.Assert(err, IsNil)
+
+	cl, err := NewClientWithOptions(&ClientOptions{
+		SSLCert:         cert,
+		SSLKey:          keyFile,
+		InsecureSkipTLS: true,
+	})
+	c.Assert(err, IsNil)
+
+	err = s.advertisedReferences(c, cl, server.URL+"/repo")
+	c.Assert(err, Equals, transport.ErrRepositoryNotFound)
+	c.Assert(peers, Equals, 1)
+}
@@ -19,7 +19,7 @@ type rpSession struct {
 	*session
 }
 
-func newReceivePackSession(c *http.Client, ep *transport.Endpoint, auth transport.AuthMethod) (transport.ReceivePackSession, error) {
+func newReceivePackSession(c *client, ep *transport.Endpoint, auth transport.AuthMethod) (transport.ReceivePackSession, error) {
 	s, err := newSession(c, ep, auth)
 	return &rpSession{s}, err
 }
@@ -90,9 +90,10 @@ func (s *rpSession) doRequest(
 	}
 
 	applyHeadersToRequest(req, content, s.endpoint.Host, transport.ReceivePackServiceName)
+	s.applyExtraHeaders(req)
 	s.ApplyAuthToRequest(req)
 
-	res, err := s.client.Do(req.WithContext(ctx))
+	res, err := s.do(req.WithContext(ctx), false)
 	if err != nil {
 		return nil, plumbing.NewUnexpectedError(err)
 	}
@@ -0,0 +1,296 @@
+package http
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrUnsupportedContentEncoding is returned when a response is encoded with
+// a Content-Encoding other than gzip and deflate.
+var ErrUnsupportedContentEncoding = errors.New("unsupported content encoding")
+
+const (
+	// DefaultRetryBackoff is the time waited before the first retry of a
+	// request, if ClientOptions.RetryBackoff is zero.
+	DefaultRetryBackoff = time.Second
+	// DefaultMaxRetryBackoff is the maximum time waited before a retry, if
+	// ClientOptions.MaxRetryBackoff is zero.
+	DefaultMaxRetryBackoff = 30 * time.Second
+
+	// requestCompressionThreshold is the size of the git-upload-pack
+	// requests from which they are gzipped, as git does.
+	requestCompressionThreshold = 1024
+)
+
+// ResponseInfo is the metadata of the response to a request, given to
+// ClientOptions.OnResponse.
+type ResponseInfo struct {
+	// Method is the method of the request.
+	Method string
+	// URL is the URL of the request.
+	URL *url.URL
+	// Attempt is the number of the attempt of the request, starting at 1.
+	Attempt int
+	// StatusCode is the status code of the response, 0 if it failed.
+	StatusCode int
+	// Header is the header of the response, nil if it failed.
+	Header http.Header
+	// Start is the time the request was sent.
+	Start time.Time
+	// Duration is the time until the header of the response was received,
+	// or the request failed.
+	Duration time.Duration
+	// Err is the error of the request, if it failed without response.
+	Err error
+}
+
+// do sends the request as doWithRetries does, answering the challenges of
+// the server if the auth method is a ChallengeAuth.
+func (s *session) do(req *http.Request, idempotent bool) (*http.Response, error) {
+	send := func(r *http.Request) (*http.Response, error) {
+		return s.doWithRetries(r, idempotent)
+	}
+
+	if a, ok := s.auth.(*ChallengeAuth); ok {
+		return a.do(req, send, &s.challenge)
+	}
+
+	return send(req)
+}
+
+// doWithRetries sends the request, retrying it as configured by the client if
+// it is idempotent, and decodes the body of the response.
+func (s *session) doWithRetries(req *http.Request, idempotent bool) (*http.Response, error) {
+	c := s.client
+	maxRetries := c.maxRetries
+	if !idempotent || (req.Body != nil && req.GetBody == nil) {
+		maxRetries = 0
+	}
+
+	for attempt := 1; ; attempt++ {
+		res, err := c.send(req, attempt)
+		if attempt > maxRetries || !isRetryable(res, err) {
+			if err != nil {
+				return nil, err
+			}
+
+			return decodeResponse(res)
+		}
+
+		wait := c.backoff(attempt, res)
+		if res != nil {
+			_ = res.Body.Close()
+		}
+
+		if req, err = rewindRequest(req); err != nil {
+			return nil, err
+		}
+
+		select {
+		case <-time.After(wait):
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+	}
+}
+
+// send sends the request, reporting the response to the hook of the client.
+func (c *client) send(req *http.Request, attempt int) (*http.Response, error) {
+	start := time.Now()
+	res, err := c.c.Do(req)
+	if c.onResponse == nil {
+		return res, err
+	}
+
+	info := &ResponseInfo{
+		Method:   req.Method,
+		URL:      req.URL,
+		Attempt:  attempt,
+		Start:    start,
+		Duration: time.Since(start),
+		Err:      err,
+	}
+
+	if res != nil {
+		info.StatusCode = res.StatusCode
+		info.Header = res.Header
+	}
+
+	c.onResponse(info)
+	return res, err
+}
+
+// isRetryable reports whether a request failing without response, unless
+// canceled, or with a 408, 429, 500, 502, 503 or 504 status code may be
+// retried.
+func isRetryable(res *http.Response, err error) bool {
+	if err != nil {
+		return !isCanceled(err)
+	}
+
+	switch res.StatusCode {
+	case http.StatusRequestTimeout,
+		http.StatusTooManyRequests,
+		http.StatusInternalServerError,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout:
+		return true
+	default:
+		return false
+	}
+}
+
+func isCanceled(err error) bool {
+	if uerr, ok := err.(*url.Error); ok {
+		err = uerr.Err
+	}
+
+	return err == context.Canceled || err == context.DeadlineExceeded
+}
+
+// backoff returns the time waited before the retry following the given
+// attempt: the Retry-After of the response, in seconds or as a date, if any,
+// or the backoff of the client doubled for each attempt, up to its maximum
+// backoff.
+func (c *client) backoff(attempt int, res *http.Response) time.Duration {
+	max := c.maxRetryBackoff
+	if max == 0 {
+		max = DefaultMaxRetryBackoff
+	}
+
+	if wait, ok := retryAfter(res); ok {
+		return minDuration(wait, max)
+	}
+
+	wait := c.retryBackoff
+	if wait == 0 {
+		wait = DefaultRetryBackoff
+	}
+
+	for i := 1; i < attempt && wait < max; i++ {
+		wait *= 2
+	}
+
+	return minDuration(wait, max)
+}
+
+// retryAfter returns the time to wait given by the Retry-After header of the
+// response, as a number of seconds or an HTTP date, zero if the date is past.
+func retryAfter(res *http.Response) (time.Duration, bool) {
+	if res == nil {
+		return 0, false
+	}
+
+	v := res.Header.Get("Retry-After")
+	if secs, err := strconv.Atoi(v); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second, true
+	}
+
+	date, err := http.ParseTime(v)
+	if err != nil {
+		return 0, false
+	}
+
+	wait := date.Sub(time.Now())
+	if wait < 0 {
+		wait = 0
+	}
+
+	return wait, true
+}
+
+func minDuration(a, b time.Duration) time.Duration {
+	if a < b {
+		return a
+	}
+
+	return b
+}
+
+// rewindRequest returns a copy of the request with a new body, to be sent
+// again.
+func rewindRequest(req *http.Request) (*http.Request, error) {
+	if req.GetBody == nil {
+		return req, nil
+	}
+
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, err
+	}
+
+	r := req.WithContext(req.Context())
+	r.Body = body
+	return r, nil
+}
+
+// decodeResponse replaces the body of a successful response by its decoded
+// content, according to its Content-Encoding.
+func decodeResponse(res *http.Response) (*http.Response, error) {
+	if res.StatusCode < http.StatusOK || res.StatusCode >= http.StatusMultipleChoices {
+		return res, nil
+	}
+
+	var r io.ReadCloser
+	var err error
+	switch strings.ToLower(strings.TrimSpace(res.Header.Get("Content-Encoding"))) {
+	case "", "identity":
+		return res, nil
+	case "gzip", "x-gzip":
+		r, err = gzip.NewReader(res.Body)
+	case "deflate":
+		r, err = zlib.NewReader(res.Body)
+	default:
+		err = ErrUnsupportedContentEncoding
+	}
+
+	if err != nil {
+		_ = res.Body.Close()
+		return nil, err
+	}
+
+	res.Header.Del("Content-Encoding")
+	res.Header.Del("Content-Length")
+	res.ContentLength = -1
+	res.Body = &decodedBody{r, res.Body}
+	return res, nil
+}
+
+type decodedBody struct {
+	io.ReadCloser
+	body io.Closer
+}
+
+func (b *decodedBody) Close() error {
+	err := b.ReadCloser.Close()
+	if cerr := b.body.Close(); err == nil {
+		err = cerr
+	}
+
+	return err
+}
+
+// gzipContent returns the content gzipped.
+func gzipContent(content *bytes.Buffer) (*bytes.Buffer, error) {
+	buf := bytes.NewBuffer(nil)
+	w := gzip.NewWriter(buf)
+	if _, err := content.WriteTo(w); err != nil {
+		return nil, err
+	}
+
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf, nil
+}
@@ -0,0 +1,303 @@
+package http
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/cgi"
+	"net/http/httptest"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/protocol/packp"
+	"gopkg.in/src-d/go-git.v4/plumbing/transport"
+
+	. "gopkg.in/check.v1"
+	"gopkg.in/src-d/go-git-fixtures.v3"
+)
+
+type RequestSuite struct {
+	fixtures.Suite
+}
+
+var _ = Suite(&RequestSuite{})
+
+// responses records the responses given to the OnResponse hook.
+type responses struct {
+	sync.Mutex
+	infos []*ResponseInfo
+}
+
+func (r *responses) add(info *ResponseInfo) {
+	r.Lock()
+	defer r.Unlock()
+	r.infos = append(r.infos, info)
+}
+
+func (r *responses) statusCodes() []int {
+	r.Lock()
+	defer r.Unlock()
+
+	var codes []int
+	for _, info := range r.infos {
+		codes = append(codes, info.StatusCode)
+	}
+
+	return codes
+}
+
+func (s *RequestSuite) newSession(c *C, o *ClientOptions, url string) *session {
+	cl, err := NewClientWithOptions(o)
+	c.Assert(err, IsNil)
+
+	ep, err := transport.NewEndpoint(url)
+	c.Assert(err, IsNil)
+
+	r, err := newSession(cl.(*client), ep, nil)
+	c.Assert(err, IsNil)
+	return r
+}
+
+func (s *RequestSuite) TestRetry(c *C) {
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	res := &responses{}
+	sess := s.newSession(c, &ClientOptions{
+		MaxRetries:   3,
+		RetryBackoff: time.Millisecond,
+		OnResponse:   res.add,
+	}, server.URL+"/repo")
+
+	_, err := advertisedReferences(sess, transport.UploadPackServiceName)
+	c.Assert(err, Equals, transport.ErrRepositoryNotFound)
+	c.Assert(requests, Equals, 3)
+	c.Assert(res.statusCodes(), DeepEquals, []int{503, 503, 404})
+
+	for i, info := range res.infos {
+		c.Assert(info.Attempt, Equals, i+1)
+		c.Assert(info.Method, Equals, http.MethodGet)
+		c.Assert(info.URL.Path, Equals, "/repo/info/refs")
+		c.Assert(info.Header, NotNil)
+		c.Assert(info.Start.IsZero(), Equals, false)
+		c.Assert(info.Err, IsNil)
+	}
+}
+
+func (s *RequestSuite) TestRetryExhausted(c *C) {
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	sess := s.newSession(c, &ClientOptions{
+		MaxRetries:   2,
+		RetryBackoff: time.Millisecond,
+	}, server.URL+"/repo")
+
+	_, err := advertisedReferences(sess, transport.UploadPackServiceName)
+	c.Assert(err, ErrorMatches, ".*status code: 502")
+	c.Assert(requests, Equals, 3)
+}
+
+func (s *RequestSuite) TestRetryConnectionError(c *C) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	url := server.URL + "/repo"
+	server.Close()
+
+	res := &responses{}
+	sess := s.newSession(c, &ClientOptions{
+		MaxRetries:   1,
+		RetryBackoff: time.Millisecond,
+		OnResponse:   res.add,
+	}, url)
+
+	_, err := advertisedReferences(sess, transport.UploadPackServiceName)
+	c.Assert(err, NotNil)
+	c.Assert(res.statusCodes(), DeepEquals, []int{0, 0})
+	c.Assert(res.infos[1].Err, NotNil)
+}
+
+func (s *RequestSuite) TestNoRetryReceivePack(c *C) {
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	sess := s.newSession(c, &ClientOptions{
+		MaxRetries:   3,
+		RetryBackoff: time.Millisecond,
+	}, server.URL+"/repo")
+
+	rp := &rpSession{sess}
+	_, err := rp.doRequest(context.Background(), http.MethodPost,
+		server.URL+"/repo/git-receive-pack", bytes.NewBufferString("0000"))
+	c.Assert(err, ErrorMatches, ".*status code: 503")
+	c.Assert(requests, Equals, 1)
+}
+
+func (s *RequestSuite) TestRetryCanceled(c *C) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	sess := s.newSession(c, &ClientOptions{
+		MaxRetries:   3,
+		RetryBackoff: time.Hour,
+	}, server.URL+"/repo")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	req, err := http.NewRequest(http.MethodGet, server.URL, nil)
+	c.Assert(err, IsNil)
+
+	_, err = sess.do(req.WithContext(ctx), true)
+	c.Assert(err, Equals, context.DeadlineExceeded)
+}
+
+func (s *RequestSuite) TestBackoff(c *C) {
+	cl := &client{
+		retryBackoff:    time.Second,
+		maxRetryBackoff: 5 * time.Second,
+	}
+
+	c.Assert(cl.backoff(1, nil), Equals, time.Second)
+	c.Assert(cl.backoff(2, nil), Equals, 2*time.Second)
+	c.Assert(cl.backoff(3, nil), Equals, 4*time.Second)
+	c.Assert(cl.backoff(4, nil), Equals, 5*time.Second)
+	c.Assert(cl.backoff(100, nil), Equals, 5*time.Second)
+
+	res := &http.Response{Header: http.Header{"Retry-After": []string{"3"}}}
+	c.Assert(cl.backoff(1, res), Equals, 3*time.Second)
+
+	res.Header.Set("Retry-After", "60")
+	c.Assert(cl.backoff(1, res), Equals, 5*time.Second)
+
+	res.Header.Set("Retry-After", time.Now().Add(time.Hour).UTC().Format(http.TimeFormat))
+	c.Assert(cl.backoff(1, res), Equals, 5*time.Second)
+
+	res.Header.Set("Retry-After", time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat))
+	c.Assert(cl.backoff(1, res), Equals, time.Duration(0))
+
+	res.Header.Set("Retry-After", time.Now().Add(3*time.Second).UTC().Format(http.TimeFormat))
+	wait := cl.backoff(1, res)
+	c.Assert(wait > time.Second && wait <= 3*time.Second, Equals, true, Commentf("wait: %s", wait))
+
+	res.Header.Set("Retry-After", "foo")
+	c.Assert(cl.backoff(1, res), Equals, time.Second)
+
+	c.Assert((&client{}).backoff(1, nil), Equals, DefaultRetryBackoff)
+}
+
+func (s *RequestSuite) TestDecodeResponse(c *C) {
+	var gz bytes.Buffer
+	w := gzip.NewWriter(&gz)
+	_, _ = w.Write([]byte("foo"))
+	c.Assert(w.Close(), IsNil)
+
+	var deflate bytes.Buffer
+	zw := zlib.NewWriter(&deflate)
+	_, _ = zw.Write([]byte("foo"))
+	c.Assert(zw.Close(), IsNil)
+
+	for encoding, body := range map[string][]byte{
+		"":         []byte("foo"),
+		"identity": []byte("foo"),
+		"gzip":     gz.Bytes(),
+		"deflate":  deflate.Bytes(),
+	} {
+		res, err := decodeResponse(&http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Encoding": []string{encoding}},
+			Body:       ioutil.NopCloser(bytes.NewReader(body)),
+		})
+		c.Assert(err, IsNil)
+
+		content, err := ioutil.ReadAll(res.Body)
+		c.Assert(err, IsNil)
+		c.Assert(string(content), Equals, "foo", Commentf("encoding %q", encoding))
+		c.Assert(res.Body.Close(), IsNil)
+	}
+
+	_, err := decodeResponse(&http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Encoding": []string{"br"}},
+		Body:       ioutil.NopCloser(bytes.NewReader(nil)),
+	})
+	c.Assert(err, Equals, ErrUnsupportedContentEncoding)
+}
+
+func (s *RequestSuite) TestUploadPackCompression(c *C) {
+	out, err := exec.Command("git", "--exec-path").CombinedOutput()
+	c.Assert(err, IsNil)
+
+	fs := fixtures.Basic().One().DotGit()
+	c.Assert(fixtures.EnsureIsBare(fs), IsNil)
+
+	backend := &cgi.Handler{
+		Path: filepath.Join(strings.TrimSpace(string(out)), "git-http-backend"),
+		Env: []string{
+			"GIT_HTTP_EXPORT_ALL=true",
+			fmt.Sprintf("GIT_PROJECT_ROOT=%s", filepath.Dir(fs.Root())),
+		},
+	}
+
+	var encodings []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			encodings = append(encodings, r.Header.Get("Content-Encoding"))
+		}
+
+		backend.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	req := packp.NewUploadPackRequest()
+	req.Wants = append(req.Wants, plumbing.NewHash("6ecf0ef2c2dffb796033e5a02219af86ec6584e5"))
+	for i := 0; i < 100; i++ {
+		req.Haves = append(req.Haves, plumbing.NewHash(fmt.Sprintf("%040x", i+1)))
+	}
+
+	for _, disable := range []bool{false, true} {
+		cl, err := NewClientWithOptions(&ClientOptions{DisableRequestCompression: disable})
+		c.Assert(err, IsNil)
+
+		ep, err := transport.NewEndpoint(fmt.Sprintf("%s/%s", server.URL, filepath.Base(fs.Root())))
+		c.Assert(err, IsNil)
+
+		r, err := cl.NewUploadPackSession(ep, nil)
+		c.Assert(err, IsNil)
+
+		_, err = r.AdvertisedReferences()
+		c.Assert(err, IsNil)
+
+		res, err := r.UploadPack(context.Background(), req)
+		c.Assert(err, IsNil)
+		c.Assert(res.Close(), IsNil)
+	}
+
+	c.Assert(encodings, DeepEquals, []string{"gzip", ""})
+}
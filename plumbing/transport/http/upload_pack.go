@@ -19,7 +19,7 @@ type upSession struct {
 	*session
 }
 
-func newUploadPackSession(c *http.Client, ep *transport.Endpoint, auth transport.AuthMethod) (transport.UploadPackSession, error) {
+func newUploadPackSession(c *client, ep *transport.Endpoint, auth transport.AuthMethod) (transport.UploadPackSession, error) {
 	s, err := newSession(c, ep, auth)
 	return &upSession{s}, err
 }
@@ -77,6 +77,16 @@ func (s *upSession) doRequest(
 	ctx context.Context, method, url string, content *bytes.Buffer,
 ) (*http.Response, error) {
 
+	var encoding string
+	if content != nil && content.Len() > requestCompressionThreshold && !s.client.disableCompression {
+		var err error
+		if content, err = gzipContent(content); err != nil {
+			return nil, err
+		}
+
+		encoding = "gzip"
+	}
+
 	var body io.Reader
 	if content != nil {
 		body = content
@@ -88,9 +98,14 @@ func (s *upSession) doRequest(
 	}
 
 	applyHeadersToRequest(req, content, s.endpoint.Host, transport.UploadPackServiceName)
+	if encoding != "" {
+		req.Header.Set("Content-Encoding", encoding)
+	}
+
+	s.applyExtraHeaders(req)
 	s.ApplyAuthToRequest(req)
 
-	res, err := s.client.Do(req.WithContext(ctx))
+	res, err := s.do(req.WithContext(ctx), true)
 	if err != nil {
 		return nil, plumbing.NewUnexpectedError(err)
 	}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"reflect"
 	"strconv"
+	"strings"
 
 	"gopkg.in/src-d/go-git.v4/plumbing/transport"
 	"gopkg.in/src-d/go-git.v4/plumbing/transport/internal/common"
@@ -117,6 +118,10 @@ func (c *command) connect() error {
 
 	c.client, err = ssh.Dial("tcp", c.getHostWithPort(), config)
 	if err != nil {
+		if isAuthenticationError(err) {
+			return transport.ErrAuthenticationRequired
+		}
+
 		return err
 	}
 
@@ -130,6 +135,13 @@ func (c *command) connect() error {
 	return nil
 }
 
+// isAuthenticationError reports whether the error of ssh.Dial is due to the
+// server rejecting every auth method, the ssh package not having a dedicated
+// error for it.
+func isAuthenticationError(err error) bool {
+	return strings.Contains(err.Error(), "ssh: unable to authenticate")
+}
+
 func (c *command) getHostWithPort() string {
 	if addr, found := c.doGetHostWithPortFromSSHConfig(); found {
 		return addr
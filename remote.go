@@ -5,6 +5,8 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"reflect"
+	"sync"
 
 	"gopkg.in/src-d/go-git.v4/config"
 	"gopkg.in/src-d/go-git.v4/plumbing"
@@ -17,6 +19,8 @@ import (
 	"gopkg.in/src-d/go-git.v4/plumbing/storer"
 	"gopkg.in/src-d/go-git.v4/plumbing/transport"
 	"gopkg.in/src-d/go-git.v4/plumbing/transport/client"
+	"gopkg.in/src-d/go-git.v4/plumbing/transport/credential"
+	githttp "gopkg.in/src-d/go-git.v4/plumbing/transport/http"
 	"gopkg.in/src-d/go-git.v4/storage"
 	"gopkg.in/src-d/go-git.v4/storage/memory"
 	"gopkg.in/src-d/go-git.v4/utils/ioutil"
@@ -40,6 +44,17 @@ const (
 type Remote struct {
 	c *config.RemoteConfig
 	s storage.Storer
+	// loadConfig loads the merged config of the repository, holding the
+	// url.<base> rules rewriting the URLs of the remote and the credential
+	// helpers, the first time they are needed. If nil, the URLs are used as
+	// they are and no helper is used.
+	loadConfig func() (*config.Config, error)
+
+	m      sync.Mutex
+	merged *config.Config
+	// clients are the clients of the URLs of the remote, reused by every
+	// session.
+	clients map[string]transport.Transport
 }
 
 func newRemote(s storage.Storer, c *config.RemoteConfig) *Remote {
@@ -54,13 +69,55 @@ func (r *Remote) Config() *config.RemoteConfig {
 func (r *Remote) String() string {
 	var fetch, push string
 	if len(r.c.URLs) > 0 {
-		fetch = r.c.URLs[0]
-		push = r.c.URLs[0]
+		// the URLs are shown as they are if the config can't be loaded
+		fetch, _ = r.fetchURL()
+		push, _ = r.pushURL()
 	}
 
 	return fmt.Sprintf("%s\t%s (fetch)\n%[1]s\t%[3]s (push)", r.c.Name, fetch, push)
 }
 
+// fetchURL returns the URL used to fetch from the remote, its first URL
+// rewritten by the url.<base>.insteadOf rules.
+func (r *Remote) fetchURL() (string, error) {
+	cfg, err := r.mergedConfig()
+	if err != nil || cfg == nil {
+		return r.c.URLs[0], err
+	}
+
+	return cfg.RewriteURL(r.c.URLs[0]), nil
+}
+
+// pushURL returns the URL used to push to the remote, its first URL
+// rewritten by the url.<base>.pushInsteadOf and url.<base>.insteadOf rules.
+func (r *Remote) pushURL() (string, error) {
+	cfg, err := r.mergedConfig()
+	if err != nil || cfg == nil {
+		return r.c.URLs[0], err
+	}
+
+	return cfg.RewritePushURL(r.c.URLs[0]), nil
+}
+
+// mergedConfig returns the merged config of the repository, loading it on
+// first use. nil is returned if the remote has none.
+func (r *Remote) mergedConfig() (*config.Config, error) {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	if r.merged != nil || r.loadConfig == nil {
+		return r.merged, nil
+	}
+
+	cfg, err := r.loadConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	r.merged = cfg
+	return cfg, nil
+}
+
 // Push performs a push to the remote. Returns NoErrAlreadyUpToDate if the
 // remote was already up-to-date.
 func (r *Remote) Push(o *PushOptions) error {
@@ -82,18 +139,13 @@ func (r *Remote) PushContext(ctx context.Context, o *PushOptions) (err error) {
 		return fmt.Errorf("remote names don't match: %s != %s", o.RemoteName, r.c.Name)
 	}
 
-	s, err := newSendPackSession(r.c.URLs[0], o.Auth)
+	s, ar, err := r.newSendPackSession(o.Auth)
 	if err != nil {
 		return err
 	}
 
 	defer ioutil.CheckClose(s, &err)
 
-	ar, err := s.AdvertisedReferences()
-	if err != nil {
-		return err
-	}
-
 	remoteRefs, err := ar.AllReferences()
 	if err != nil {
 		return err
@@ -260,18 +312,13 @@ func (r *Remote) fetch(ctx context.Context, o *FetchOptions) (sto storer.Referen
 		o.RefSpecs = r.c.Fetch
 	}
 
-	s, err := newUploadPackSession(r.c.URLs[0], o.Auth)
+	s, ar, err := r.newUploadPackSession(o.Auth)
 	if err != nil {
 		return nil, err
 	}
 
 	defer ioutil.CheckClose(s, &err)
 
-	ar, err := s.AdvertisedReferences()
-	if err != nil {
-		return nil, err
-	}
-
 	req, err := r.newUploadPackRequest(o, ar)
 	if err != nil {
 		return nil, err
@@ -316,22 +363,215 @@ func (r *Remote) fetch(ctx context.Context, o *FetchOptions) (sto storer.Referen
 	return remoteRefs, nil
 }
 
-func newUploadPackSession(url string, auth transport.AuthMethod) (transport.UploadPackSession, error) {
-	c, ep, err := newClient(url)
+// newUploadPackSession starts a git-upload-pack session with the remote and
+// retrieves its advertised references, see openSession.
+func (r *Remote) newUploadPackSession(auth transport.AuthMethod) (
+	transport.UploadPackSession, *packp.AdvRefs, error) {
+
+	url, err := r.fetchURL()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	s, ar, err := r.openSession(url, auth, func(a transport.AuthMethod) (transport.Session, error) {
+		c, ep, err := r.newClient(url)
+		if err != nil {
+			return nil, err
+		}
+
+		return c.NewUploadPackSession(ep, a)
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return s.(transport.UploadPackSession), ar, nil
+}
+
+// newSendPackSession starts a git-receive-pack session with the remote and
+// retrieves its advertised references, see openSession.
+func (r *Remote) newSendPackSession(auth transport.AuthMethod) (
+	transport.ReceivePackSession, *packp.AdvRefs, error) {
+
+	url, err := r.pushURL()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	s, ar, err := r.openSession(url, auth, func(a transport.AuthMethod) (transport.Session, error) {
+		c, ep, err := r.newClient(url)
+		if err != nil {
+			return nil, err
+		}
+
+		return c.NewReceivePackSession(ep, a)
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return s.(transport.ReceivePackSession), ar, nil
+}
+
+// openSession opens a session with the given auth method and retrieves the
+// advertised references. If the auth method is a transport.AuthProvider, or
+// is nil and credential helpers are configured for the URL, the session is
+// opened without auth method first and, if the endpoint requires
+// authentication, again with the one provided, the provider being then told
+// whether it was accepted.
+func (r *Remote) openSession(
+	url string, auth transport.AuthMethod,
+	open func(transport.AuthMethod) (transport.Session, error),
+) (transport.Session, *packp.AdvRefs, error) {
+
+	provider, err := r.authProvider(url, auth)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if provider != nil {
+		auth = nil
+	}
+
+	s, ar, err := advertisedReferences(open, auth)
+	if err != transport.ErrAuthenticationRequired || provider == nil {
+		return s, ar, err
+	}
+
+	ep, err := transport.NewEndpoint(url)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	auth, err = provider.Fill(ep)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if auth == nil {
+		return nil, nil, transport.ErrAuthenticationRequired
+	}
+
+	// as git does, the failures of the helpers storing or erasing the
+	// credentials don't fail the operation.
+	s, ar, err = advertisedReferences(open, auth)
+	switch err {
+	case nil:
+		_ = provider.Approve(ep, auth)
+	case transport.ErrAuthenticationRequired:
+		_ = provider.Reject(ep, auth)
+	}
+
+	return s, ar, err
+}
+
+func advertisedReferences(
+	open func(transport.AuthMethod) (transport.Session, error), auth transport.AuthMethod,
+) (transport.Session, *packp.AdvRefs, error) {
+
+	s, err := open(auth)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ar, err := s.AdvertisedReferences()
 	if err != nil {
+		_ = s.Close()
+		return nil, nil, err
+	}
+
+	return s, ar, nil
+}
+
+// authProvider returns the provider of the auth method of the URL: the given
+// auth method if it is a transport.AuthProvider or, if no auth method is
+// given, the credential helpers configured for the URL. nil is returned if
+// there is none.
+func (r *Remote) authProvider(url string, auth transport.AuthMethod) (transport.AuthProvider, error) {
+	if p, ok := auth.(transport.AuthProvider); ok {
+		return p, nil
+	}
+
+	if auth != nil {
+		return nil, nil
+	}
+
+	cfg, err := r.mergedConfig()
+	if err != nil || cfg == nil {
 		return nil, err
 	}
 
-	return c.NewUploadPackSession(ep, auth)
+	p, err := newCredentialProvider(cfg, url)
+	if err != nil || p == nil {
+		return nil, err
+	}
+
+	return p, nil
 }
 
-func newSendPackSession(url string, auth transport.AuthMethod) (transport.ReceivePackSession, error) {
+// newCredentialProvider returns the provider of the credential helpers
+// configured for the URL. nil is returned if there are none.
+func newCredentialProvider(cfg *config.Config, url string) (*credential.Provider, error) {
+	cred := cfg.CredentialOptions(url)
+	if len(cred.Helpers) == 0 {
+		return nil, nil
+	}
+
+	p := &credential.Provider{
+		Username:    cred.Username,
+		UseHTTPPath: cred.UseHTTPPath,
+	}
+
+	for _, cmd := range cred.Helpers {
+		h, err := credential.NewHelper(cmd)
+		if err != nil {
+			return nil, err
+		}
+
+		p.Helpers = append(p.Helpers, h)
+	}
+
+	return p, nil
+}
+
+// newClient returns the client of the URL. If it is the default HTTP client
+// and the URL has http.* options or GIT_SSL_* environment variables, a client
+// with these options is returned instead, built once for the remote.
+func (r *Remote) newClient(url string) (transport.Transport, *transport.Endpoint, error) {
 	c, ep, err := newClient(url)
+	if err != nil || c != githttp.DefaultClient {
+		return c, ep, err
+	}
+
+	cfg, err := r.mergedConfig()
+	if err != nil || cfg == nil {
+		return c, ep, err
+	}
+
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	if hc, ok := r.clients[url]; ok {
+		return hc, ep, nil
+	}
+
+	o, err := newHTTPClientOptions(cfg, url)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+
+	if o != nil {
+		if c, err = githttp.NewClientWithOptions(o); err != nil {
+			return nil, nil, err
+		}
 	}
 
-	return c.NewReceivePackSession(ep, auth)
+	if r.clients == nil {
+		r.clients = make(map[string]transport.Transport)
+	}
+
+	r.clients[url] = c
+	return c, ep, nil
 }
 
 func newClient(url string) (transport.Transport, *transport.Endpoint, error) {
@@ -348,6 +588,31 @@ func newClient(url string) (transport.Transport, *transport.Endpoint, error) {
 	return c, ep, err
 }
 
+// newHTTPClientOptions returns the options of the HTTP client of the URL, as
+// configured by http.* and the GIT_SSL_* environment variables. nil is
+// returned if there are none.
+func newHTTPClientOptions(cfg *config.Config, url string) (*githttp.ClientOptions, error) {
+	h, err := cfg.HTTPOptions(url)
+	if err != nil {
+		return nil, err
+	}
+
+	o := &githttp.ClientOptions{
+		Proxy:           h.Proxy,
+		CAInfo:          h.SSLCAInfo,
+		SSLCert:         h.SSLCert,
+		SSLKey:          h.SSLKey,
+		InsecureSkipTLS: !h.SSLVerify,
+		ExtraHeaders:    h.ExtraHeaders,
+	}
+
+	if reflect.DeepEqual(*o, githttp.ClientOptions{}) {
+		return nil, nil
+	}
+
+	return o, nil
+}
+
 func (r *Remote) fetchPack(ctx context.Context, o *FetchOptions, s transport.UploadPackSession,
 	req *packp.UploadPackRequest) (err error) {
 
@@ -927,18 +1192,13 @@ func (r *Remote) buildFetchedTags(refs memory.ReferenceStorage) (updated bool, e
 
 // List the references on the remote repository.
 func (r *Remote) List(o *ListOptions) (rfs []*plumbing.Reference, err error) {
-	s, err := newUploadPackSession(r.c.URLs[0], o.Auth)
+	s, ar, err := r.newUploadPackSession(o.Auth)
 	if err != nil {
 		return nil, err
 	}
 
 	defer ioutil.CheckClose(s, &err)
 
-	ar, err := s.AdvertisedReferences()
-	if err != nil {
-		return nil, err
-	}
-
 	allRefs, err := ar.AllReferences()
 	if err != nil {
 		return nil, err
@@ -0,0 +1,163 @@
+package git
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/cgi"
+	"net/http/httptest"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/src-d/go-git.v4/config"
+	"gopkg.in/src-d/go-git.v4/plumbing/transport"
+	"gopkg.in/src-d/go-git.v4/plumbing/transport/credential"
+	"gopkg.in/src-d/go-git.v4/storage/memory"
+
+	. "gopkg.in/check.v1"
+	"gopkg.in/src-d/go-git-fixtures.v3"
+)
+
+type RemoteCredentialSuite struct {
+	BaseSuite
+	server *httptest.Server
+	url    string
+	store  string
+}
+
+var _ = Suite(&RemoteCredentialSuite{})
+
+func (s *RemoteCredentialSuite) SetUpTest(c *C) {
+	out, err := exec.Command("git", "--exec-path").CombinedOutput()
+	c.Assert(err, IsNil)
+
+	fs := fixtures.Basic().One().DotGit()
+	c.Assert(fixtures.EnsureIsBare(fs), IsNil)
+
+	backend := &cgi.Handler{
+		Path: filepath.Join(strings.TrimSpace(string(out)), "git-http-backend"),
+		Env: []string{
+			"GIT_HTTP_EXPORT_ALL=true",
+			fmt.Sprintf("GIT_PROJECT_ROOT=%s", filepath.Dir(fs.Root())),
+		},
+	}
+
+	s.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, ok := r.BasicAuth(); !ok || user != "foo" || pass != "bar" {
+			w.Header().Set("WWW-Authenticate", `Basic realm="git"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		backend.ServeHTTP(w, r)
+	}))
+
+	s.url = fmt.Sprintf("%s/%s", s.server.URL, filepath.Base(fs.Root()))
+	s.store = filepath.Join(c.MkDir(), "credentials")
+}
+
+func (s *RemoteCredentialSuite) TearDownTest(c *C) {
+	s.server.Close()
+}
+
+func (s *RemoteCredentialSuite) newRepository(c *C, helpers ...string) *Repository {
+	r, err := Init(memory.NewStorage(), nil)
+	c.Assert(err, IsNil)
+
+	cfg, err := r.Config()
+	c.Assert(err, IsNil)
+	for _, h := range helpers {
+		cfg.Raw.Section("credential").AddOption("helper", h)
+	}
+
+	c.Assert(r.Storer.SetConfig(cfg), IsNil)
+
+	_, err = r.CreateRemote(&config.RemoteConfig{
+		Name: DefaultRemoteName,
+		URLs: []string{s.url},
+	})
+	c.Assert(err, IsNil)
+	return r
+}
+
+func (s *RemoteCredentialSuite) writeStore(c *C, user, pass string) {
+	url := strings.Replace(s.server.URL, "://", fmt.Sprintf("://%s:%s@", user, pass), 1)
+	c.Assert(ioutil.WriteFile(s.store, []byte(url+"\n"), 0600), IsNil)
+}
+
+func (s *RemoteCredentialSuite) readStore(c *C) string {
+	content, err := ioutil.ReadFile(s.store)
+	c.Assert(err, IsNil)
+	return string(content)
+}
+
+func (s *RemoteCredentialSuite) TestFetchWithoutHelper(c *C) {
+	r := s.newRepository(c)
+	err := r.Fetch(&FetchOptions{})
+	c.Assert(err, Equals, transport.ErrAuthenticationRequired)
+}
+
+func (s *RemoteCredentialSuite) TestFetchStore(c *C) {
+	s.writeStore(c, "foo", "bar")
+
+	r := s.newRepository(c, "store --file="+s.store)
+	c.Assert(r.Fetch(&FetchOptions{}), IsNil)
+
+	ref, err := r.Reference("refs/remotes/origin/master", false)
+	c.Assert(err, IsNil)
+	c.Assert(ref.Hash().String(), Equals, "6ecf0ef2c2dffb796033e5a02219af86ec6584e5")
+}
+
+func (s *RemoteCredentialSuite) TestFetchReject(c *C) {
+	s.writeStore(c, "foo", "wrong")
+
+	r := s.newRepository(c, "store --file="+s.store)
+	err := r.Fetch(&FetchOptions{})
+	c.Assert(err, Equals, transport.ErrAuthenticationRequired)
+	c.Assert(s.readStore(c), Equals, "")
+}
+
+func (s *RemoteCredentialSuite) TestFetchApprove(c *C) {
+	r := s.newRepository(c,
+		"store --file="+s.store,
+		"!f() { test $1 = get && echo username=foo && echo password=bar; }; f",
+	)
+
+	c.Assert(r.Fetch(&FetchOptions{}), IsNil)
+
+	url := strings.Replace(s.server.URL, "://", "://foo:bar@", 1)
+	c.Assert(s.readStore(c), Equals, url+"\n")
+}
+
+func (s *RemoteCredentialSuite) TestFetchHelperReset(c *C) {
+	s.writeStore(c, "foo", "bar")
+
+	r := s.newRepository(c, "store --file="+s.store, "")
+	err := r.Fetch(&FetchOptions{})
+	c.Assert(err, Equals, transport.ErrAuthenticationRequired)
+}
+
+func (s *RemoteCredentialSuite) TestFetchAuthProvider(c *C) {
+	cache := credential.NewCache(0)
+	c.Assert(cache.Store(&credential.Credential{
+		Protocol: "http",
+		Host:     strings.TrimPrefix(s.server.URL, "http://"),
+		Username: "foo",
+		Password: "bar",
+	}), IsNil)
+
+	r := s.newRepository(c)
+	err := r.Fetch(&FetchOptions{
+		Auth: &credential.Provider{Helpers: []credential.Helper{cache}},
+	})
+	c.Assert(err, IsNil)
+}
+
+func (s *RemoteCredentialSuite) TestPushStore(c *C) {
+	s.writeStore(c, "foo", "bar")
+
+	r := s.newRepository(c, "store --file="+s.store)
+	err := r.Push(&PushOptions{})
+	c.Assert(err, Equals, NoErrAlreadyUpToDate)
+}
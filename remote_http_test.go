@@ -0,0 +1,241 @@
+package git
+
+import (
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/cgi"
+	"net/http/httptest"
+	"net/url"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/src-d/go-git.v4/config"
+	"gopkg.in/src-d/go-git.v4/plumbing/transport"
+	githttp "gopkg.in/src-d/go-git.v4/plumbing/transport/http"
+	"gopkg.in/src-d/go-git.v4/storage/memory"
+
+	. "gopkg.in/check.v1"
+	"gopkg.in/src-d/go-git-fixtures.v3"
+)
+
+type RemoteHTTPSuite struct {
+	BaseSuite
+	backend    http.Handler
+	repo       string
+	restoreEnv func()
+}
+
+var _ = Suite(&RemoteHTTPSuite{})
+
+func (s *RemoteHTTPSuite) SetUpTest(c *C) {
+	out, err := exec.Command("git", "--exec-path").CombinedOutput()
+	c.Assert(err, IsNil)
+
+	fs := fixtures.Basic().One().DotGit()
+	c.Assert(fixtures.EnsureIsBare(fs), IsNil)
+
+	s.backend = &cgi.Handler{
+		Path: filepath.Join(strings.TrimSpace(string(out)), "git-http-backend"),
+		Env: []string{
+			"GIT_HTTP_EXPORT_ALL=true",
+			fmt.Sprintf("GIT_PROJECT_ROOT=%s", filepath.Dir(fs.Root())),
+		},
+	}
+
+	s.repo = filepath.Base(fs.Root())
+	s.restoreEnv = setEnv(map[string]string{
+		"GIT_SSL_NO_VERIFY": "",
+		"GIT_SSL_CAINFO":    "",
+		"GIT_SSL_CERT":      "",
+		"GIT_SSL_KEY":       "",
+	})
+}
+
+func (s *RemoteHTTPSuite) TearDownTest(c *C) {
+	s.restoreEnv()
+}
+
+// newServer returns a server serving the repository to the requests
+// accepted by the given function, if any.
+func (s *RemoteHTTPSuite) newServer(tls bool, accept func(*http.Request) bool) *httptest.Server {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if accept != nil && !accept(r) {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		s.backend.ServeHTTP(w, r)
+	}))
+
+	server.Config.ErrorLog = log.New(ioutil.Discard, "", 0)
+	if tls {
+		server.StartTLS()
+	} else {
+		server.Start()
+	}
+
+	return server
+}
+
+func (s *RemoteHTTPSuite) fetch(c *C, url string, options map[string]string) error {
+	r, err := Init(memory.NewStorage(), nil)
+	c.Assert(err, IsNil)
+
+	cfg, err := r.Config()
+	c.Assert(err, IsNil)
+	for k, v := range options {
+		parts := strings.SplitN(k, " ", 2)
+		if len(parts) == 1 {
+			cfg.Raw.Section("http").AddOption(k, v)
+			continue
+		}
+
+		cfg.Raw.Section("http").Subsection(parts[0]).AddOption(parts[1], v)
+	}
+
+	c.Assert(r.Storer.SetConfig(cfg), IsNil)
+
+	_, err = r.CreateRemote(&config.RemoteConfig{
+		Name: DefaultRemoteName,
+		URLs: []string{url},
+	})
+	c.Assert(err, IsNil)
+
+	return r.Fetch(&FetchOptions{})
+}
+
+func (s *RemoteHTTPSuite) TestFetchExtraHeader(c *C) {
+	server := s.newServer(false, func(r *http.Request) bool {
+		return r.Header.Get("X-Token") == "secret"
+	})
+	defer server.Close()
+
+	url := fmt.Sprintf("%s/%s", server.URL, s.repo)
+	err := s.fetch(c, url, nil)
+	c.Assert(err, Equals, transport.ErrAuthorizationFailed)
+
+	err = s.fetch(c, url, map[string]string{
+		server.URL + " extraHeader": "X-Token: secret",
+	})
+	c.Assert(err, IsNil)
+}
+
+func (s *RemoteHTTPSuite) TestFetchExtraHeaderReset(c *C) {
+	server := s.newServer(false, func(r *http.Request) bool {
+		return r.Header.Get("X-Token") == ""
+	})
+	defer server.Close()
+
+	url := fmt.Sprintf("%s/%s", server.URL, s.repo)
+	err := s.fetch(c, url, map[string]string{
+		"extraHeader": "X-Token: secret",
+	})
+	c.Assert(err, Equals, transport.ErrAuthorizationFailed)
+
+	err = s.fetch(c, url, map[string]string{
+		"extraHeader":               "X-Token: secret",
+		server.URL + " extraHeader": "",
+	})
+	c.Assert(err, IsNil)
+}
+
+func (s *RemoteHTTPSuite) TestFetchProxy(c *C) {
+	server := s.newServer(false, func(r *http.Request) bool {
+		user, pass, ok := parseProxyAuthorization(r)
+		return r.Host == "git.example.invalid" && ok && user == "foo" && pass == "bar"
+	})
+	defer server.Close()
+
+	u, err := url.Parse(server.URL)
+	c.Assert(err, IsNil)
+
+	err = s.fetch(c, "http://git.example.invalid/"+s.repo, map[string]string{
+		"http://git.example.invalid proxy": "http://foo:bar@" + u.Host,
+	})
+	c.Assert(err, IsNil)
+}
+
+func parseProxyAuthorization(r *http.Request) (user, pass string, ok bool) {
+	req := &http.Request{Header: http.Header{
+		"Authorization": r.Header["Proxy-Authorization"],
+	}}
+
+	return req.BasicAuth()
+}
+
+func (s *RemoteHTTPSuite) TestFetchSSLVerify(c *C) {
+	server := s.newServer(true, nil)
+	defer server.Close()
+
+	url := fmt.Sprintf("%s/%s", server.URL, s.repo)
+	err := s.fetch(c, url, nil)
+	c.Assert(err, ErrorMatches, ".*certificate.*")
+
+	err = s.fetch(c, url, map[string]string{
+		server.URL + " sslVerify": "false",
+	})
+	c.Assert(err, IsNil)
+}
+
+func (s *RemoteHTTPSuite) TestFetchSSLCAInfo(c *C) {
+	server := s.newServer(true, nil)
+	defer server.Close()
+
+	ca := filepath.Join(c.MkDir(), "ca.pem")
+	err := ioutil.WriteFile(ca, pem.EncodeToMemory(&pem.Block{
+		Type:  "CERTIFICATE",
+		Bytes: server.Certificate().Raw,
+	}), 0644)
+	c.Assert(err, IsNil)
+
+	err = s.fetch(c, fmt.Sprintf("%s/%s", server.URL, s.repo), map[string]string{
+		"sslCAInfo": ca,
+	})
+	c.Assert(err, IsNil)
+}
+
+func (s *RemoteHTTPSuite) TestNewClientReused(c *C) {
+	cfg := config.NewConfig()
+	cfg.Raw.Section("http").AddOption("extraHeader", "X-Foo: bar")
+
+	var loaded int
+	r := newRemote(nil, &config.RemoteConfig{Name: "foo", URLs: []string{"https://example.com/foo"}})
+	r.loadConfig = func() (*config.Config, error) {
+		loaded++
+		return cfg, nil
+	}
+
+	first, _, err := r.newClient("https://example.com/foo")
+	c.Assert(err, IsNil)
+	c.Assert(first, Not(Equals), githttp.DefaultClient)
+
+	second, _, err := r.newClient("https://example.com/foo")
+	c.Assert(err, IsNil)
+	c.Assert(second, Equals, first)
+	c.Assert(loaded, Equals, 1)
+}
+
+func (s *RemoteHTTPSuite) TestNewHTTPClientOptions(c *C) {
+	o, err := newHTTPClientOptions(config.NewConfig(), "https://example.com/foo")
+	c.Assert(err, IsNil)
+	c.Assert(o, IsNil)
+
+	cfg := config.NewConfig()
+	cfg.Raw.Section("http").AddOption("sslCAInfo", "/etc/ca.pem")
+	cfg.Raw.Section("http").AddOption("extraHeader", "X-Foo: bar")
+	cfg.Raw.Section("http").Subsection("https://example.com").AddOption("proxy", "proxy:3128")
+	cfg.Raw.Section("http").Subsection("https://example.com/foo").AddOption("sslVerify", "no")
+
+	o, err = newHTTPClientOptions(cfg, "https://example.com/foo")
+	c.Assert(err, IsNil)
+	c.Assert(o, DeepEquals, &githttp.ClientOptions{
+		Proxy:           "proxy:3128",
+		CAInfo:          "/etc/ca.pem",
+		InsecureSkipTLS: true,
+		ExtraHeaders:    []string{"X-Foo: bar"},
+	})
+}
@@ -395,6 +395,86 @@ func (s *RemoteSuite) TestString(c *C) {
 	)
 }
 
+func (s *RemoteSuite) TestStringWithURLRewriting(c *C) {
+	urls := config.NewConfig()
+	urls.URLs["https://mirror.example.com/"] = &config.URL{
+		Name:       "https://mirror.example.com/",
+		InsteadOfs: []string{"https://github.com/"},
+	}
+	urls.URLs["ssh://git@github.com/"] = &config.URL{
+		Name:           "ssh://git@github.com/",
+		PushInsteadOfs: []string{"https://github.com/"},
+	}
+
+	r := newRemote(nil, &config.RemoteConfig{
+		Name: "foo",
+		URLs: []string{"https://github.com/git-fixtures/basic.git"},
+	})
+	r.merged = urls
+
+	c.Assert(r.String(), Equals, ""+
+		"foo\thttps://mirror.example.com/git-fixtures/basic.git (fetch)\n"+
+		"foo\tssh://git@github.com/git-fixtures/basic.git (push)",
+	)
+}
+
+func (s *RemoteSuite) TestFetchInsteadOf(c *C) {
+	r, err := Init(memory.NewStorage(), nil)
+	c.Assert(err, IsNil)
+
+	url := s.GetBasicLocalRepositoryURL()
+	cfg, err := r.Config()
+	c.Assert(err, IsNil)
+	cfg.URLs[url] = &config.URL{
+		Name:       url,
+		InsteadOfs: []string{"https://github.com/git-fixtures/basic.git"},
+	}
+	c.Assert(r.Storer.SetConfig(cfg), IsNil)
+
+	_, err = r.CreateRemote(&config.RemoteConfig{
+		Name: DefaultRemoteName,
+		URLs: []string{"https://github.com/git-fixtures/basic.git"},
+	})
+	c.Assert(err, IsNil)
+
+	c.Assert(r.Fetch(&FetchOptions{}), IsNil)
+
+	ref, err := r.Reference("refs/remotes/origin/master", false)
+	c.Assert(err, IsNil)
+	c.Assert(ref.Hash().String(), Equals, "6ecf0ef2c2dffb796033e5a02219af86ec6584e5")
+
+	remote, err := r.Remote(DefaultRemoteName)
+	c.Assert(err, IsNil)
+	c.Assert(remote.Config().URLs, DeepEquals, []string{"https://github.com/git-fixtures/basic.git"})
+}
+
+func (s *RemoteSuite) TestPushInsteadOf(c *C) {
+	url := c.MkDir()
+	server, err := PlainInit(url, true)
+	c.Assert(err, IsNil)
+
+	origin := s.GetBasicLocalRepositoryURL()
+	r, err := PlainClone(c.MkDir(), false, &CloneOptions{URL: origin})
+	c.Assert(err, IsNil)
+
+	cfg, err := r.Config()
+	c.Assert(err, IsNil)
+	cfg.URLs[url] = &config.URL{
+		Name:           url,
+		PushInsteadOfs: []string{origin},
+	}
+	c.Assert(r.Storer.SetConfig(cfg), IsNil)
+
+	err = r.Push(&PushOptions{
+		RefSpecs: []config.RefSpec{"refs/heads/master:refs/heads/master"},
+	})
+	c.Assert(err, IsNil)
+
+	AssertReferences(c, server, map[string]string{
+		"refs/heads/master": "6ecf0ef2c2dffb796033e5a02219af86ec6584e5",
+	})
+}
+
 func (s *RemoteSuite) TestPushToEmptyRepository(c *C) {
 	url := c.MkDir()
 	server, err := PlainInit(url, true)
@@ -1,7 +1,6 @@
 package git
 
 import (
-	"bytes"
 	"context"
 	"errors"
 	"fmt"
@@ -12,7 +11,6 @@ import (
 	"strings"
 	"time"
 
-	"golang.org/x/crypto/openpgp"
 	"gopkg.in/src-d/go-git.v4/config"
 	"gopkg.in/src-d/go-git.v4/internal/revision"
 	"gopkg.in/src-d/go-git.v4/plumbing"
@@ -248,6 +246,10 @@ func PlainOpenWithOptions(path string, o *PlainOpenOptions) (*Repository, error)
 		return nil, err
 	}
 
+	if dot, err = dotGitCommonDirectory(dot); err != nil {
+		return nil, err
+	}
+
 	s := filesystem.NewStorage(dot, cache.NewObjectLRUDefault())
 
 	return Open(s, wt)
@@ -358,6 +360,21 @@ func newRepository(s storage.Storer, worktree billy.Filesystem) *Repository {
 	}
 }
 
+// dotGitFilesystem returns the filesystem of the $GIT_DIR, false if the
+// storage is not file based.
+func (r *Repository) dotGitFilesystem() (billy.Filesystem, bool) {
+	type fsBased interface {
+		Filesystem() billy.Filesystem
+	}
+
+	fs, ok := r.Storer.(fsBased)
+	if !ok {
+		return nil, false
+	}
+
+	return fs.Filesystem(), true
+}
+
 // Config return the repository config
 func (r *Repository) Config() (*config.Config, error) {
 	return r.Storer.Config()
@@ -375,7 +392,9 @@ func (r *Repository) Remote(name string) (*Remote, error) {
 		return nil, ErrRemoteNotFound
 	}
 
-	return newRemote(r.Storer, c), nil
+	remote := newRemote(r.Storer, c)
+	remote.loadConfig = r.mergedConfig
+	return remote, nil
 }
 
 // Remotes returns a list with all the remotes
@@ -390,6 +409,7 @@ func (r *Repository) Remotes() ([]*Remote, error) {
 	var i int
 	for _, c := range cfg.Remotes {
 		remotes[i] = newRemote(r.Storer, c)
+		remotes[i].loadConfig = r.mergedConfig
 		i++
 	}
 
@@ -402,8 +422,6 @@ func (r *Repository) CreateRemote(c *config.RemoteConfig) (*Remote, error) {
 		return nil, err
 	}
 
-	remote := newRemote(r.Storer, c)
-
 	cfg, err := r.Storer.Config()
 	if err != nil {
 		return nil, err
@@ -413,10 +431,24 @@ func (r *Repository) CreateRemote(c *config.RemoteConfig) (*Remote, error) {
 		return nil, ErrRemoteExists
 	}
 
+	remote := newRemote(r.Storer, c)
+	remote.loadConfig = r.mergedConfig
+
 	cfg.Remotes[c.Name] = c
 	return remote, r.Storer.SetConfig(cfg)
 }
 
+// mergedConfig returns the merged config of the repository, loaded by the
+// remotes for the url.<base> rules and the credential helpers.
+func (r *Repository) mergedConfig() (*config.Config, error) {
+	cfg, err := r.MergedConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	return cfg.Config, nil
+}
+
 // DeleteRemote delete a remote from the repository and delete the config
 func (r *Repository) DeleteRemote(name string) error {
 	cfg, err := r.Storer.Config()
@@ -534,8 +566,8 @@ func (r *Repository) createTagObject(name string, hash plumbing.Hash, opts *Crea
 		Target:     hash,
 	}
 
-	if opts.SignKey != nil {
-		sig, err := r.buildTagSignature(tag, opts.SignKey)
+	if opts.Signer != nil {
+		sig, err := r.buildTagSignature(tag, opts.Signer)
 		if err != nil {
 			return plumbing.ZeroHash, err
 		}
@@ -551,7 +583,7 @@ func (r *Repository) createTagObject(name string, hash plumbing.Hash, opts *Crea
 	return r.Storer.SetEncodedObject(obj)
 }
 
-func (r *Repository) buildTagSignature(tag *object.Tag, signKey *openpgp.Entity) (string, error) {
+func (r *Repository) buildTagSignature(tag *object.Tag, signer object.Signer) (string, error) {
 	encoded := &plumbing.MemoryObject{}
 	if err := tag.Encode(encoded); err != nil {
 		return "", err
@@ -562,12 +594,12 @@ func (r *Repository) buildTagSignature(tag *object.Tag, signKey *openpgp.Entity)
 		return "", err
 	}
 
-	var b bytes.Buffer
-	if err := openpgp.ArmoredDetachSign(&b, signKey, rdr, nil); err != nil {
+	sig, err := signer.Sign(rdr)
+	if err != nil {
 		return "", err
 	}
 
-	return b.String(), nil
+	return string(sig), nil
 }
 
 // Tag returns a tag from the repository.
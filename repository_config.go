@@ -0,0 +1,166 @@
+package git
+
+import (
+	"errors"
+	"os"
+	"strings"
+
+	"gopkg.in/src-d/go-git.v4/config"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	format "gopkg.in/src-d/go-git.v4/plumbing/format/config"
+	"gopkg.in/src-d/go-git.v4/utils/ioutil"
+)
+
+// ErrWorktreeConfigNotEnabled is returned by SetConfigScoped when the config
+// of the worktree is written but extensions.worktreeConfig is not enabled.
+var ErrWorktreeConfigNotEnabled = errors.New("extensions.worktreeConfig is not enabled")
+
+const (
+	configFile         = "config"
+	worktreeConfigFile = "config.worktree"
+	extensionsSection  = "extensions"
+	worktreeConfigKey  = "worktreeConfig"
+)
+
+// ConfigScoped returns the config of the given scope, with its include
+// directives expanded. The configs of the local and worktree scopes are the
+// ones of the repository, the others the ones of the user and of the system.
+func (r *Repository) ConfigScoped(scope config.Scope) (*config.Config, error) {
+	o, err := r.includeOptions()
+	if err != nil {
+		return nil, err
+	}
+
+	switch scope {
+	case config.LocalScope:
+		if _, ok := r.dotGitFilesystem(); !ok {
+			return r.Storer.Config()
+		}
+
+		return r.readScopedConfig(configFile, o)
+	case config.WorktreeScope:
+		if _, ok := r.dotGitFilesystem(); !ok {
+			return config.NewConfig(), nil
+		}
+
+		return r.readScopedConfig(worktreeConfigFile, o)
+	default:
+		return config.LoadConfig(scope, o)
+	}
+}
+
+func (r *Repository) readScopedConfig(path string, o *format.IncludeOptions) (c *config.Config, err error) {
+	fs, _ := r.dotGitFilesystem()
+	f, err := fs.Open(path)
+	if os.IsNotExist(err) {
+		return config.NewConfig(), nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer ioutil.CheckClose(f, &err)
+
+	o.Filesystem, o.Path = fs, path
+	return config.ReadConfig(f, o)
+}
+
+// includeOptions returns the options to expand the include directives of the
+// configs read for the repository.
+func (r *Repository) includeOptions() (*format.IncludeOptions, error) {
+	home, err := config.Home()
+	if err != nil {
+		return nil, err
+	}
+
+	o := &format.IncludeOptions{Home: home}
+	if fs, ok := r.dotGitFilesystem(); ok {
+		o.GitDir = fs.Root()
+	}
+
+	head, err := r.Storer.Reference(plumbing.HEAD)
+	if err == nil && head.Type() == plumbing.SymbolicReference && head.Target().IsBranch() {
+		o.Branch = head.Target().Short()
+	}
+
+	return o, nil
+}
+
+// SetConfigScoped writes the config of the given scope. Only the options of
+// the config file itself are written, not the ones of the files it includes.
+// The config of the worktree can only be written if extensions.worktreeConfig
+// is enabled.
+func (r *Repository) SetConfigScoped(cfg *config.Config, scope config.Scope) error {
+	switch scope {
+	case config.LocalScope:
+		return r.Storer.SetConfig(cfg)
+	case config.WorktreeScope:
+		return r.setWorktreeConfig(cfg)
+	default:
+		return config.SaveConfig(cfg, scope)
+	}
+}
+
+func (r *Repository) setWorktreeConfig(cfg *config.Config) (err error) {
+	fs, ok := r.dotGitFilesystem()
+	if !ok {
+		return config.ErrUnsupportedScope
+	}
+
+	local, err := r.Storer.Config()
+	if err != nil {
+		return err
+	}
+
+	if !isWorktreeConfigEnabled(local) {
+		return ErrWorktreeConfigNotEnabled
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+
+	b, err := cfg.MarshalScope(config.WorktreeScope)
+	if err != nil {
+		return err
+	}
+
+	f, err := fs.Create(worktreeConfigFile)
+	if err != nil {
+		return err
+	}
+
+	defer ioutil.CheckClose(f, &err)
+
+	_, err = f.Write(b)
+	return err
+}
+
+func isWorktreeConfigEnabled(cfg *config.Config) bool {
+	v := cfg.Raw.Section(extensionsSection).Options.Get(worktreeConfigKey)
+	return strings.ToLower(v) == "true"
+}
+
+// MergedConfig returns the merged view of the configs of every scope, as git
+// reads them: the config of the system, the ones of the user, the one of the
+// repository and the one of the worktree, the last ones taking precedence.
+// The config of the worktree is only read if extensions.worktreeConfig is
+// enabled.
+func (r *Repository) MergedConfig() (*config.ScopedConfig, error) {
+	configs := make(map[config.Scope]*config.Config, len(config.Scopes))
+	for _, s := range config.Scopes {
+		if s == config.WorktreeScope && !isWorktreeConfigEnabled(configs[config.LocalScope]) {
+			continue
+		}
+
+		cfg, err := r.ConfigScoped(s)
+		if err != nil {
+			return nil, err
+		}
+
+		configs[s] = cfg
+	}
+
+	return config.NewScopedConfig(configs)
+}
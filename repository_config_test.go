@@ -0,0 +1,167 @@
+package git
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/src-d/go-git.v4/config"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/storage/memory"
+
+	. "gopkg.in/check.v1"
+)
+
+type RepositoryConfigSuite struct {
+	BaseSuite
+	dir     string
+	restore func()
+}
+
+var _ = Suite(&RepositoryConfigSuite{})
+
+func (s *RepositoryConfigSuite) SetUpTest(c *C) {
+	var err error
+	s.dir, err = ioutil.TempDir("", "repository-config")
+	c.Assert(err, IsNil)
+
+	s.restore = setEnv(map[string]string{
+		"HOME":                filepath.Join(s.dir, "home"),
+		"GIT_CONFIG_GLOBAL":   filepath.Join(s.dir, "home", ".gitconfig"),
+		"GIT_CONFIG_SYSTEM":   filepath.Join(s.dir, "gitconfig"),
+		"GIT_CONFIG_NOSYSTEM": "",
+	})
+}
+
+func (s *RepositoryConfigSuite) TearDownTest(c *C) {
+	s.restore()
+	c.Assert(os.RemoveAll(s.dir), IsNil)
+}
+
+func (s *RepositoryConfigSuite) writeFile(c *C, path, content string) {
+	path = filepath.Join(s.dir, path)
+	c.Assert(os.MkdirAll(filepath.Dir(path), 0755), IsNil)
+	c.Assert(ioutil.WriteFile(path, []byte(content), 0644), IsNil)
+}
+
+func (s *RepositoryConfigSuite) readFile(c *C, path string) string {
+	b, err := ioutil.ReadFile(filepath.Join(s.dir, path))
+	c.Assert(err, IsNil)
+	return string(b)
+}
+
+func (s *RepositoryConfigSuite) TestMergedConfig(c *C) {
+	s.writeFile(c, "gitconfig", "[core]\n\tautocrlf = input\n[user]\n\tname = system\n")
+	s.writeFile(c, "home/.gitconfig", "[user]\n\tname = John Doe\n\temail = john@example.com\n"+
+		"[includeIf \"gitdir:~/work/\"]\n\tpath = ~/work.inc\n")
+	s.writeFile(c, "home/work.inc", "[user]\n\temail = john@work.example.com\n")
+
+	r, err := PlainInit(filepath.Join(s.dir, "home", "work", "repository"), false)
+	c.Assert(err, IsNil)
+
+	cfg, err := r.MergedConfig()
+	c.Assert(err, IsNil)
+	c.Assert(cfg.Core.AutoCRLF, Equals, "input")
+	c.Assert(cfg.User.Name, Equals, "John Doe")
+	c.Assert(cfg.User.Email, Equals, "john@work.example.com")
+
+	value, origin, ok := cfg.Value("user", "", "email")
+	c.Assert(ok, Equals, true)
+	c.Assert(value, Equals, "john@work.example.com")
+	c.Assert(origin.Scope, Equals, config.GlobalScope)
+	c.Assert(origin.Include, Equals, filepath.Join(s.dir, "home", "work.inc"))
+
+	local, err := r.ConfigScoped(config.LocalScope)
+	c.Assert(err, IsNil)
+	local.User.Name = "Jane Doe"
+	c.Assert(r.SetConfigScoped(local, config.LocalScope), IsNil)
+
+	cfg, err = r.MergedConfig()
+	c.Assert(err, IsNil)
+	c.Assert(cfg.User.Name, Equals, "Jane Doe")
+
+	_, origin, ok = cfg.Value("user", "", "name")
+	c.Assert(ok, Equals, true)
+	c.Assert(origin, Equals, config.Origin{Scope: config.LocalScope})
+}
+
+func (s *RepositoryConfigSuite) TestConfigScopedLocalInclude(c *C) {
+	r, err := PlainInit(filepath.Join(s.dir, "repository"), false)
+	c.Assert(err, IsNil)
+
+	s.writeFile(c, "repository/.git/master.inc", "[user]\n\tname = master\n")
+	s.writeFile(c, "repository/.git/config", "[includeIf \"onbranch:master\"]\n\tpath = master.inc\n")
+
+	local, err := r.ConfigScoped(config.LocalScope)
+	c.Assert(err, IsNil)
+	c.Assert(local.User.Name, Equals, "master")
+
+	local.User.Email = "master@example.com"
+	c.Assert(r.SetConfigScoped(local, config.LocalScope), IsNil)
+
+	content := s.readFile(c, "repository/.git/config")
+	c.Assert(content, Matches, "(?s).*email = master@example.com.*")
+	c.Assert(content, Not(Matches), "(?s).*name = master.*")
+
+	head := plumbing.NewSymbolicReference(plumbing.HEAD, plumbing.ReferenceName("refs/heads/topic"))
+	c.Assert(r.Storer.SetReference(head), IsNil)
+
+	local, err = r.ConfigScoped(config.LocalScope)
+	c.Assert(err, IsNil)
+	c.Assert(local.User.Name, Equals, "")
+}
+
+func (s *RepositoryConfigSuite) TestConfigScopedGlobal(c *C) {
+	r, err := Init(memory.NewStorage(), nil)
+	c.Assert(err, IsNil)
+
+	global, err := r.ConfigScoped(config.GlobalScope)
+	c.Assert(err, IsNil)
+	c.Assert(global.User.Name, Equals, "")
+
+	global.User.Name = "John Doe"
+	c.Assert(r.SetConfigScoped(global, config.GlobalScope), IsNil)
+	c.Assert(s.readFile(c, "home/.gitconfig"), Equals, "[user]\n\tname = John Doe\n")
+
+	global, err = r.ConfigScoped(config.GlobalScope)
+	c.Assert(err, IsNil)
+	c.Assert(global.User.Name, Equals, "John Doe")
+
+	s.writeFile(c, "home/.gitconfig", "[user]\n\tname = John Doe\n\temail = john@example.com\n")
+
+	o := &CommitOptions{}
+	c.Assert(o.Validate(r), IsNil)
+	c.Assert(o.Author.Name, Equals, "John Doe")
+	c.Assert(o.Author.Email, Equals, "john@example.com")
+}
+
+func (s *RepositoryConfigSuite) TestSetConfigScopedWorktree(c *C) {
+	r, err := PlainInit(filepath.Join(s.dir, "repository"), false)
+	c.Assert(err, IsNil)
+
+	worktree := config.NewConfig()
+	worktree.Core.SparseCheckout = true
+	err = r.SetConfigScoped(worktree, config.WorktreeScope)
+	c.Assert(err, Equals, ErrWorktreeConfigNotEnabled)
+
+	local, err := r.ConfigScoped(config.LocalScope)
+	c.Assert(err, IsNil)
+	local.Raw.Section("extensions").SetOption("worktreeConfig", "true")
+	c.Assert(r.SetConfigScoped(local, config.LocalScope), IsNil)
+
+	c.Assert(r.SetConfigScoped(worktree, config.WorktreeScope), IsNil)
+	c.Assert(s.readFile(c, "repository/.git/config.worktree"), Equals, "[core]\n\tsparseCheckout = true\n")
+
+	cfg, err := r.MergedConfig()
+	c.Assert(err, IsNil)
+	c.Assert(cfg.Core.SparseCheckout, Equals, true)
+
+	_, origin, ok := cfg.Value("core", "", "sparsecheckout")
+	c.Assert(ok, Equals, true)
+	c.Assert(origin.Scope, Equals, config.WorktreeScope)
+
+	r, err = Init(memory.NewStorage(), nil)
+	c.Assert(err, IsNil)
+	err = r.SetConfigScoped(worktree, config.WorktreeScope)
+	c.Assert(err, Equals, config.ErrUnsupportedScope)
+}
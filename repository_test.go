@@ -221,6 +221,34 @@ func (s *RepositorySuite) TestCreateRemoteAndRemote(c *C) {
 	c.Assert(alt.Config().Name, Equals, "foo")
 }
 
+func (s *RepositorySuite) TestRemotesWithInvalidGlobalConfig(c *C) {
+	dir, err := ioutil.TempDir("", "remotes-global-config")
+	c.Assert(err, IsNil)
+	defer os.RemoveAll(dir)
+
+	global := filepath.Join(dir, "gitconfig")
+	c.Assert(ioutil.WriteFile(global, []byte("[url \"foo"), 0644), IsNil)
+	defer setEnv(map[string]string{"GIT_CONFIG_GLOBAL": global})()
+
+	r, _ := Init(memory.NewStorage(), nil)
+	_, err = r.CreateRemote(&config.RemoteConfig{
+		Name: "foo",
+		URLs: []string{"http://foo/foo.git"},
+	})
+	c.Assert(err, IsNil)
+
+	remotes, err := r.Remotes()
+	c.Assert(err, IsNil)
+	c.Assert(remotes, HasLen, 1)
+	c.Assert(remotes[0].String(), Equals, ""+
+		"foo\thttp://foo/foo.git (fetch)\n"+
+		"foo\thttp://foo/foo.git (push)",
+	)
+
+	err = remotes[0].Fetch(&FetchOptions{})
+	c.Assert(err, ErrorMatches, ".*string not terminated")
+}
+
 func (s *RepositorySuite) TestCreateRemoteInvalid(c *C) {
 	r, _ := Init(memory.NewStorage(), nil)
 	remote, err := r.CreateRemote(&config.RemoteConfig{})
@@ -1584,6 +1612,44 @@ func (s *RepositorySuite) TestCreateTagSigned(c *C) {
 	c.Assert(actual.PrimaryKey, DeepEquals, key.PrimaryKey)
 }
 
+func (s *RepositorySuite) TestCreateTagSignedSigner(c *C) {
+	url := s.GetLocalRepositoryURL(
+		fixtures.ByURL("https://github.com/git-fixtures/tags.git").One(),
+	)
+
+	r, _ := Init(memory.NewStorage(), nil)
+	err := r.clone(context.Background(), &CloneOptions{URL: url})
+	c.Assert(err, IsNil)
+
+	h, err := r.Head()
+	c.Assert(err, IsNil)
+
+	key := commitSignKey(c, true)
+	tag, err := r.CreateTag("foobar", h.Hash(), &CreateTagOptions{
+		Tagger:  defaultSignature(),
+		Message: "foo bar baz qux",
+		Signer:  object.NewOpenPGPSigner(key),
+	})
+	c.Assert(err, IsNil)
+
+	obj, err := r.TagObject(tag.Hash())
+	c.Assert(err, IsNil)
+	c.Assert(obj.Message, Equals, "foo bar baz qux\n")
+
+	pks := new(bytes.Buffer)
+	pkw, err := armor.Encode(pks, openpgp.PublicKeyType, nil)
+	c.Assert(err, IsNil)
+	c.Assert(key.Serialize(pkw), IsNil)
+	c.Assert(pkw.Close(), IsNil)
+
+	verifier, err := object.NewOpenPGPVerifier(pks.String())
+	c.Assert(err, IsNil)
+
+	id, err := obj.VerifySignature(verifier)
+	c.Assert(err, IsNil)
+	c.Assert(id, Equals, "foo bar <foo@foo.foo>")
+}
+
 func (s *RepositorySuite) TestCreateTagSignedBadKey(c *C) {
 	url := s.GetLocalRepositoryURL(
 		fixtures.ByURL("https://github.com/git-fixtures/tags.git").One(),
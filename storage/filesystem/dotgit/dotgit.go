@@ -170,6 +170,11 @@ func (d *DotGit) Index() (billy.File, error) {
 	return d.fs.Open(indexPath)
 }
 
+// IndexStat returns the os.FileInfo of the index file
+func (d *DotGit) IndexStat() (os.FileInfo, error) {
+	return d.fs.Stat(indexPath)
+}
+
 // ShallowWriter returns a file pointer for write to the shallow file
 func (d *DotGit) ShallowWriter() (billy.File, error) {
 	return d.fs.Create(shallowPath)
@@ -0,0 +1,190 @@
+package dotgit
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/src-d/go-billy.v4"
+)
+
+const (
+	// CommonDirFile is the file of the $GIT_DIR of a linked worktree
+	// containing the path of the $GIT_COMMON_DIR, the $GIT_DIR of the main
+	// worktree.
+	CommonDirFile = "commondir"
+
+	branchesPath  = "branches"
+	hooksPath     = "hooks"
+	infoPath      = "info"
+	logsPath      = "logs"
+	remotesPath   = "remotes"
+	worktreesPath = "worktrees"
+)
+
+// RepositoryFilesystem is the billy.Filesystem of the $GIT_DIR of a linked
+// worktree. The paths shared by all the worktrees, like the objects, the
+// config or the branches, are resolved in the $GIT_COMMON_DIR while the rest,
+// like HEAD, the index or the refs in refs/worktree, are resolved in the
+// $GIT_DIR of the worktree.
+//
+// More info: https://git-scm.com/docs/gitrepository-layout
+type RepositoryFilesystem struct {
+	dotGitFs       billy.Filesystem
+	commonDotGitFs billy.Filesystem
+}
+
+// NewRepositoryFilesystem returns the filesystem of a linked worktree, given
+// the filesystems of its $GIT_DIR and of the $GIT_COMMON_DIR.
+func NewRepositoryFilesystem(dotGitFs, commonDotGitFs billy.Filesystem) *RepositoryFilesystem {
+	return &RepositoryFilesystem{
+		dotGitFs:       dotGitFs,
+		commonDotGitFs: commonDotGitFs,
+	}
+}
+
+// Common returns the filesystem of the $GIT_COMMON_DIR.
+func (fs *RepositoryFilesystem) Common() billy.Filesystem {
+	return fs.commonDotGitFs
+}
+
+// IsCommonPath returns true if the path of a $GIT_DIR is shared by all the
+// worktrees, being resolved in the $GIT_COMMON_DIR.
+func IsCommonPath(path string) bool {
+	path = filepath.ToSlash(filepath.Clean(path))
+	switch {
+	case path == logsPath+"/HEAD",
+		path == infoPath+"/sparse-checkout",
+		isPathOrChild(path, refsPath+"/bisect"),
+		isPathOrChild(path, refsPath+"/rewritten"),
+		isPathOrChild(path, refsPath+"/worktree"):
+		return false
+	}
+
+	switch strings.SplitN(path, "/", 2)[0] {
+	case objectsPath, refsPath, packedRefsPath, configPath, branchesPath,
+		hooksPath, infoPath, remotesPath, logsPath, shallowPath, worktreesPath,
+		modulePath:
+		return true
+	}
+
+	return false
+}
+
+func isPathOrChild(path, parent string) bool {
+	return path == parent || strings.HasPrefix(path, parent+"/")
+}
+
+func (fs *RepositoryFilesystem) mapToRepositoryFs(path string) billy.Filesystem {
+	if IsCommonPath(path) {
+		return fs.commonDotGitFs
+	}
+
+	return fs.dotGitFs
+}
+
+func (fs *RepositoryFilesystem) Create(filename string) (billy.File, error) {
+	return fs.mapToRepositoryFs(filename).Create(filename)
+}
+
+func (fs *RepositoryFilesystem) Open(filename string) (billy.File, error) {
+	return fs.mapToRepositoryFs(filename).Open(filename)
+}
+
+func (fs *RepositoryFilesystem) OpenFile(filename string, flag int, perm os.FileMode) (billy.File, error) {
+	return fs.mapToRepositoryFs(filename).OpenFile(filename, flag, perm)
+}
+
+func (fs *RepositoryFilesystem) Stat(filename string) (os.FileInfo, error) {
+	return fs.mapToRepositoryFs(filename).Stat(filename)
+}
+
+// Rename renames a file, both paths must be resolved in the same $GIT_DIR.
+func (fs *RepositoryFilesystem) Rename(oldpath, newpath string) error {
+	return fs.mapToRepositoryFs(oldpath).Rename(oldpath, newpath)
+}
+
+func (fs *RepositoryFilesystem) Remove(filename string) error {
+	return fs.mapToRepositoryFs(filename).Remove(filename)
+}
+
+func (fs *RepositoryFilesystem) Join(elem ...string) string {
+	return fs.dotGitFs.Join(elem...)
+}
+
+func (fs *RepositoryFilesystem) TempFile(dir, prefix string) (billy.File, error) {
+	return fs.mapToRepositoryFs(dir).TempFile(dir, prefix)
+}
+
+// ReadDir reads a directory. The directories of the $GIT_COMMON_DIR holding
+// paths of the worktree, like refs with refs/worktree, list the entries of
+// both $GIT_DIR.
+func (fs *RepositoryFilesystem) ReadDir(path string) ([]os.FileInfo, error) {
+	if !IsCommonPath(path) {
+		return fs.dotGitFs.ReadDir(path)
+	}
+
+	common, err := fs.commonDotGitFs.ReadDir(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	own, ownErr := fs.dotGitFs.ReadDir(path)
+	if ownErr != nil && !os.IsNotExist(ownErr) {
+		return nil, ownErr
+	}
+
+	if err != nil && ownErr != nil {
+		return nil, err
+	}
+
+	var result []os.FileInfo
+	for _, fi := range common {
+		if IsCommonPath(fs.Join(path, fi.Name())) {
+			result = append(result, fi)
+		}
+	}
+
+	for _, fi := range own {
+		if !IsCommonPath(fs.Join(path, fi.Name())) {
+			result = append(result, fi)
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name() < result[j].Name()
+	})
+
+	return result, nil
+}
+
+func (fs *RepositoryFilesystem) MkdirAll(filename string, perm os.FileMode) error {
+	return fs.mapToRepositoryFs(filename).MkdirAll(filename, perm)
+}
+
+func (fs *RepositoryFilesystem) Lstat(filename string) (os.FileInfo, error) {
+	return fs.mapToRepositoryFs(filename).Lstat(filename)
+}
+
+func (fs *RepositoryFilesystem) Symlink(target, link string) error {
+	return fs.mapToRepositoryFs(link).Symlink(target, link)
+}
+
+func (fs *RepositoryFilesystem) Readlink(link string) (string, error) {
+	return fs.mapToRepositoryFs(link).Readlink(link)
+}
+
+func (fs *RepositoryFilesystem) Chroot(path string) (billy.Filesystem, error) {
+	return fs.mapToRepositoryFs(path).Chroot(path)
+}
+
+// Root returns the root of the $GIT_DIR of the worktree.
+func (fs *RepositoryFilesystem) Root() string {
+	return fs.dotGitFs.Root()
+}
+
+// Capabilities returns the capabilities of the $GIT_COMMON_DIR filesystem.
+func (fs *RepositoryFilesystem) Capabilities() billy.Capability {
+	return billy.Capabilities(fs.commonDotGitFs)
+}
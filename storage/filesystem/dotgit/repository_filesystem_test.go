@@ -0,0 +1,72 @@
+package dotgit
+
+import (
+	. "gopkg.in/check.v1"
+	"gopkg.in/src-d/go-billy.v4/memfs"
+	"gopkg.in/src-d/go-billy.v4/util"
+)
+
+type RepositoryFilesystemSuite struct{}
+
+var _ = Suite(&RepositoryFilesystemSuite{})
+
+func (s *RepositoryFilesystemSuite) TestIsCommonPath(c *C) {
+	for _, path := range []string{
+		"objects/pack", "refs/heads/master", "refs/tags/v1.0.0", "packed-refs",
+		"config", "hooks/pre-commit", "info/exclude", "logs/refs/heads/master",
+		"shallow", "worktrees/foo", "modules/bar",
+	} {
+		c.Assert(IsCommonPath(path), Equals, true, Commentf("path: %s", path))
+	}
+
+	for _, path := range []string{
+		"HEAD", "index", "ORIG_HEAD", "logs/HEAD", "info/sparse-checkout",
+		"refs/bisect/bad", "refs/worktree/foo", "refs/rewritten",
+	} {
+		c.Assert(IsCommonPath(path), Equals, false, Commentf("path: %s", path))
+	}
+}
+
+func (s *RepositoryFilesystemSuite) TestRouting(c *C) {
+	dot, common := memfs.New(), memfs.New()
+	fs := NewRepositoryFilesystem(dot, common)
+
+	c.Assert(util.WriteFile(fs, "HEAD", []byte("ref: refs/heads/foo\n"), 0644), IsNil)
+	c.Assert(util.WriteFile(fs, "refs/heads/foo", []byte("foo\n"), 0644), IsNil)
+	c.Assert(util.WriteFile(fs, "refs/worktree/bar", []byte("bar\n"), 0644), IsNil)
+
+	_, err := dot.Stat("HEAD")
+	c.Assert(err, IsNil)
+	_, err = common.Stat("HEAD")
+	c.Assert(err, NotNil)
+
+	_, err = common.Stat("refs/heads/foo")
+	c.Assert(err, IsNil)
+	_, err = dot.Stat("refs/heads/foo")
+	c.Assert(err, NotNil)
+
+	_, err = dot.Stat("refs/worktree/bar")
+	c.Assert(err, IsNil)
+
+	c.Assert(fs.Common(), Equals, common)
+}
+
+func (s *RepositoryFilesystemSuite) TestReadDirMerged(c *C) {
+	dot, common := memfs.New(), memfs.New()
+	fs := NewRepositoryFilesystem(dot, common)
+
+	c.Assert(util.WriteFile(fs, "refs/heads/foo", []byte("foo\n"), 0644), IsNil)
+	c.Assert(util.WriteFile(fs, "refs/worktree/bar", []byte("bar\n"), 0644), IsNil)
+	c.Assert(util.WriteFile(common, "refs/worktree/main", []byte("main\n"), 0644), IsNil)
+
+	infos, err := fs.ReadDir("refs")
+	c.Assert(err, IsNil)
+	c.Assert(infos, HasLen, 2)
+	c.Assert(infos[0].Name(), Equals, "heads")
+	c.Assert(infos[1].Name(), Equals, "worktree")
+
+	infos, err = fs.ReadDir("refs/worktree")
+	c.Assert(err, IsNil)
+	c.Assert(infos, HasLen, 1)
+	c.Assert(infos[0].Name(), Equals, "bar")
+}
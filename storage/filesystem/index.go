@@ -12,7 +12,27 @@ type IndexStorage struct {
 	dir *dotgit.DotGit
 }
 
-func (s *IndexStorage) SetIndex(idx *index.Index) (err error) {
+// SetIndex writes the index, smudging the racily clean entries. The ModTime
+// of idx is set to the modification time of the written file.
+func (s *IndexStorage) SetIndex(idx *index.Index) error {
+	if err := idx.SmudgeRacilyClean(s.Index); err != nil {
+		return err
+	}
+
+	if err := s.writeIndex(idx); err != nil {
+		return err
+	}
+
+	fi, err := s.dir.IndexStat()
+	if err != nil {
+		return err
+	}
+
+	idx.ModTime = fi.ModTime()
+	return nil
+}
+
+func (s *IndexStorage) writeIndex(idx *index.Index) (err error) {
 	f, err := s.dir.IndexWriter()
 	if err != nil {
 		return err
@@ -41,6 +61,13 @@ func (s *IndexStorage) Index() (i *index.Index, err error) {
 
 	defer ioutil.CheckClose(f, &err)
 
+	fi, err := s.dir.IndexStat()
+	if err != nil {
+		return nil, err
+	}
+
+	idx.ModTime = fi.ModTime()
+
 	d := index.NewDecoder(f)
 	err = d.Decode(idx)
 	return idx, err
@@ -126,6 +126,13 @@ func (s *Submodule) Repository() (*Repository, error) {
 		return Open(storer, worktree)
 	}
 
+	// the URL is rewritten by the url.<base> rules of the superproject, the
+	// ones of the submodule only being read once it exists.
+	merged, err := s.w.r.mergedConfig()
+	if err != nil {
+		return nil, err
+	}
+
 	r, err := Init(storer, worktree)
 	if err != nil {
 		return nil, err
@@ -133,7 +140,7 @@ func (s *Submodule) Repository() (*Repository, error) {
 
 	_, err = r.CreateRemote(&config.RemoteConfig{
 		Name: DefaultRemoteName,
-		URLs: []string{s.c.URL},
+		URLs: []string{merged.RewriteURL(s.c.URL)},
 	})
 
 	return r, err
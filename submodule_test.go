@@ -7,6 +7,7 @@ import (
 	"path/filepath"
 	"testing"
 
+	"gopkg.in/src-d/go-git.v4/config"
 	"gopkg.in/src-d/go-git.v4/plumbing"
 
 	. "gopkg.in/check.v1"
@@ -101,6 +102,28 @@ func (s *SubmoduleSuite) TestRepositoryWithoutInit(c *C) {
 	c.Assert(r, IsNil)
 }
 
+func (s *SubmoduleSuite) TestRepositoryInsteadOf(c *C) {
+	sm, err := s.Worktree.Submodule("basic")
+	c.Assert(err, IsNil)
+
+	cfg, err := s.Repository.Config()
+	c.Assert(err, IsNil)
+	cfg.URLs["https://mirror.example.com/"] = &config.URL{
+		Name:       "https://mirror.example.com/",
+		InsteadOfs: []string{"https://github.com/"},
+	}
+	c.Assert(s.Repository.Storer.SetConfig(cfg), IsNil)
+
+	c.Assert(sm.Init(), IsNil)
+
+	r, err := sm.Repository()
+	c.Assert(err, IsNil)
+
+	remote, err := r.Remote(DefaultRemoteName)
+	c.Assert(err, IsNil)
+	c.Assert(remote.Config().URLs, DeepEquals, []string{"https://mirror.example.com/git-fixtures/basic.git"})
+}
+
 func (s *SubmoduleSuite) TestUpdateWithoutInit(c *C) {
 	sm, err := s.Worktree.Submodule("basic")
 	c.Assert(err, IsNil)
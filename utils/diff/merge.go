@@ -0,0 +1,132 @@
+package diff
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/sergi/go-diff/diffmatchpatch"
+)
+
+const (
+	conflictStart     = "<<<<<<<"
+	conflictSeparator = "======="
+	conflictEnd       = ">>>>>>>"
+)
+
+// Merge computes the (line oriented) three-way merge of the changes made to
+// base in ours and in theirs. The changes to different lines of base are
+// merged, the ones overlapping are written between conflict markers, as
+// git does, labeled with oursLabel and theirsLabel:
+//
+//   <<<<<<< oursLabel
+//   lines of ours
+//   =======
+//   lines of theirs
+//   >>>>>>> theirsLabel
+//
+// The returned conflict is true if any conflict markers were written.
+func Merge(base, ours, theirs, oursLabel, theirsLabel string) (merged string, conflict bool) {
+	baseLines := splitLines(base)
+	oursLines, theirsLines := splitLines(ours), splitLines(theirs)
+	oursMatch := matchLines(base, ours, len(baseLines))
+	theirsMatch := matchLines(base, theirs, len(baseLines))
+
+	var buf bytes.Buffer
+	var i, o, t int
+	for {
+		// the next line of base kept by both sides
+		next := i
+		for next < len(baseLines) && (oursMatch[next] < 0 || theirsMatch[next] < 0) {
+			next++
+		}
+
+		if next == i && next < len(baseLines) && oursMatch[i] == o && theirsMatch[i] == t {
+			buf.WriteString(baseLines[i])
+			i, o, t = i+1, o+1, t+1
+			continue
+		}
+
+		oEnd, tEnd := len(oursLines), len(theirsLines)
+		if next < len(baseLines) {
+			oEnd, tEnd = oursMatch[next], theirsMatch[next]
+		}
+
+		b := strings.Join(baseLines[i:next], "")
+		oc := strings.Join(oursLines[o:oEnd], "")
+		tc := strings.Join(theirsLines[t:tEnd], "")
+		switch {
+		case oc == b:
+			buf.WriteString(tc)
+		case tc == b || oc == tc:
+			buf.WriteString(oc)
+		default:
+			conflict = true
+			writeConflict(&buf, oc, tc, oursLabel, theirsLabel)
+		}
+
+		if next == len(baseLines) {
+			break
+		}
+
+		i, o, t = next, oEnd, tEnd
+	}
+
+	return buf.String(), conflict
+}
+
+func writeConflict(buf *bytes.Buffer, ours, theirs, oursLabel, theirsLabel string) {
+	buf.WriteString(conflictStart + " " + oursLabel + "\n")
+	writeWithNewline(buf, ours)
+	buf.WriteString(conflictSeparator + "\n")
+	writeWithNewline(buf, theirs)
+	buf.WriteString(conflictEnd + " " + theirsLabel + "\n")
+}
+
+func writeWithNewline(buf *bytes.Buffer, s string) {
+	buf.WriteString(s)
+	if s != "" && !strings.HasSuffix(s, "\n") {
+		buf.WriteByte('\n')
+	}
+}
+
+// matchLines returns, for each of the n lines of src, the index of the line
+// of dst matching it in the longest common subsequence, -1 if none.
+func matchLines(src, dst string, n int) []int {
+	match := make([]int, n)
+	var i, j int
+	for _, d := range Do(src, dst) {
+		lines := len(splitLines(d.Text))
+		switch d.Type {
+		case diffmatchpatch.DiffEqual:
+			for k := 0; k < lines; k++ {
+				match[i+k] = j + k
+			}
+
+			i, j = i+lines, j+lines
+		case diffmatchpatch.DiffDelete:
+			for k := 0; k < lines; k++ {
+				match[i+k] = -1
+			}
+
+			i += lines
+		case diffmatchpatch.DiffInsert:
+			j += lines
+		}
+	}
+
+	return match
+}
+
+// splitLines splits s in lines, keeping the line endings.
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	return lines
+}
@@ -0,0 +1,50 @@
+package diff_test
+
+import (
+	"gopkg.in/src-d/go-git.v4/utils/diff"
+
+	. "gopkg.in/check.v1"
+)
+
+type suiteMerge struct{}
+
+var _ = Suite(&suiteMerge{})
+
+var mergeTests = [...]struct {
+	base, ours, theirs string
+	merged             string
+	conflict           bool
+}{
+	// no changes
+	{"a\nb\n", "a\nb\n", "a\nb\n", "a\nb\n", false},
+	// changes in one side
+	{"a\nb\n", "a\nB\n", "a\nb\n", "a\nB\n", false},
+	{"a\nb\n", "a\nb\n", "A\nb\n", "A\nb\n", false},
+	// changes in different lines
+	{"a\nb\nc\nd\n", "A\nb\nc\nd\n", "a\nb\nc\nD\n", "A\nb\nc\nD\n", false},
+	{"a\nb\nc\n", "x\na\nb\nc\n", "a\nb\nc\ny\n", "x\na\nb\nc\ny\n", false},
+	{"a\nb\nc\n", "a\nc\n", "a\nb\nc\nd\n", "a\nc\nd\n", false},
+	// same changes in both sides
+	{"a\nb\nc\n", "a\nB\nc\n", "a\nB\nc\n", "a\nB\nc\n", false},
+	// overlapping changes
+	{
+		"a\nb\nc\n", "a\nB\nc\n", "a\nb2\nc\n",
+		"a\n<<<<<<< ours\nB\n=======\nb2\n>>>>>>> theirs\nc\n", true,
+	},
+	{
+		"a", "b", "c",
+		"<<<<<<< ours\nb\n=======\nc\n>>>>>>> theirs\n", true,
+	},
+	{
+		"", "a\n", "b\n",
+		"<<<<<<< ours\na\n=======\nb\n>>>>>>> theirs\n", true,
+	},
+}
+
+func (s *suiteMerge) TestMerge(c *C) {
+	for i, t := range mergeTests {
+		merged, conflict := diff.Merge(t.base, t.ours, t.theirs, "ours", "theirs")
+		c.Assert(merged, Equals, t.merged, Commentf("subtest %d", i))
+		c.Assert(conflict, Equals, t.conflict, Commentf("subtest %d", i))
+	}
+}
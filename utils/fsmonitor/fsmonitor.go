@@ -0,0 +1,24 @@
+// Package fsmonitor implements file system monitors, reporting the paths of a
+// worktree changed since a given point, so the status of the worktree only
+// visits the reported paths, like the core.fsmonitor hook of git.
+package fsmonitor
+
+// Monitor reports the paths of a worktree changed since a token.
+type Monitor interface {
+	// Changes returns the paths changed since token, and the token to request
+	// the next changes. The token must be requested before checking the
+	// paths, so no change is lost. If the changes since token are not known,
+	// e.g. token is empty or from another monitor, Changes.All is set.
+	Changes(token string) (*Changes, error)
+}
+
+// Changes are the changes reported by a Monitor.
+type Changes struct {
+	// Token is the token to request the changes after these ones.
+	Token string
+	// Paths are the slash-separated paths changed, relative to the root of
+	// the worktree. A path may be a file or a directory.
+	Paths []string
+	// All is set if any path may have changed, Paths is then empty.
+	All bool
+}
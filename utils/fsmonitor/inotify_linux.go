@@ -0,0 +1,302 @@
+// +build linux
+
+package fsmonitor
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+const (
+	gitDir = ".git"
+
+	inotifyMask = syscall.IN_CREATE | syscall.IN_DELETE | syscall.IN_MODIFY |
+		syscall.IN_ATTRIB | syscall.IN_MOVED_FROM | syscall.IN_MOVED_TO |
+		syscall.IN_DELETE_SELF | syscall.IN_MOVE_SELF | syscall.IN_ONLYDIR
+
+	// maxQueried is the number of the last tokens queried whose changes are
+	// kept, the changes before the oldest of them are dropped.
+	maxQueried = 8
+)
+
+// Inotify is a Monitor of a worktree using the inotify API of Linux. Every
+// directory of the worktree is watched, except the .git directory. The events
+// are read when the changes are requested, so no goroutine is used, but the
+// changes are lost if the inotify queue overflows; all the paths are then
+// reported as changed, as they are when a directory is moved. Only the changes
+// since the last tokens queried are kept, the older tokens report all the
+// paths as changed.
+type Inotify struct {
+	root string
+	id   string
+
+	m        sync.Mutex
+	fd       int
+	watches  map[int32]string
+	changed  map[string]uint64
+	queried  []uint64
+	seq      uint64
+	overflow uint64
+}
+
+// NewInotify returns an Inotify monitor of the worktree at root.
+func NewInotify(root string) (*Inotify, error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_NONBLOCK | syscall.IN_CLOEXEC)
+	if err != nil {
+		return nil, os.NewSyscallError("inotify_init1", err)
+	}
+
+	m := &Inotify{
+		root:    root,
+		id:      fmt.Sprintf("%d.%d", os.Getpid(), time.Now().UnixNano()),
+		fd:      fd,
+		watches: make(map[int32]string),
+		changed: make(map[string]uint64),
+	}
+
+	if err := m.watch(""); err != nil {
+		m.Close()
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// Changes returns the paths changed since token, it is an implementation of
+// Monitor.Changes. The tokens of other Inotify monitors are not known.
+func (m *Inotify) Changes(token string) (*Changes, error) {
+	m.m.Lock()
+	defer m.m.Unlock()
+
+	m.seq++
+	if err := m.read(); err != nil {
+		return nil, err
+	}
+
+	c := &Changes{Token: fmt.Sprintf("inotify:%s:%d", m.id, m.seq)}
+
+	since, ok := m.parseToken(token)
+	if !ok || since < m.overflow {
+		c.All = true
+		return c, nil
+	}
+
+	for p, seq := range m.changed {
+		if seq > since {
+			c.Paths = append(c.Paths, p)
+		}
+	}
+
+	sort.Strings(c.Paths)
+	m.prune(since)
+	return c, nil
+}
+
+// prune drops the changes not reported to any of the last maxQueried tokens
+// queried, since is the one just queried. The older tokens are then handled
+// as after an overflow.
+func (m *Inotify) prune(since uint64) {
+	m.queried = append(m.queried, since)
+	if len(m.queried) > maxQueried {
+		m.queried = m.queried[1:]
+	}
+
+	oldest := since
+	for _, seq := range m.queried {
+		if seq < oldest {
+			oldest = seq
+		}
+	}
+
+	if oldest <= m.overflow {
+		return
+	}
+
+	for p, seq := range m.changed {
+		if seq <= oldest {
+			delete(m.changed, p)
+		}
+	}
+
+	m.overflow = oldest
+}
+
+func (m *Inotify) parseToken(token string) (uint64, bool) {
+	prefix := fmt.Sprintf("inotify:%s:", m.id)
+	if !strings.HasPrefix(token, prefix) {
+		return 0, false
+	}
+
+	seq, err := strconv.ParseUint(token[len(prefix):], 10, 64)
+	if err != nil || seq > m.seq {
+		return 0, false
+	}
+
+	return seq, true
+}
+
+// Close stops watching the worktree.
+func (m *Inotify) Close() error {
+	m.m.Lock()
+	defer m.m.Unlock()
+
+	return os.NewSyscallError("close", syscall.Close(m.fd))
+}
+
+// watch adds a watch to the directory at the given path and its
+// subdirectories, the paths found are reported as changed, since they may
+// have changed before being watched.
+func (m *Inotify) watch(dir string) error {
+	return filepath.Walk(filepath.Join(m.root, dir), func(name string, fi os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+
+			return err
+		}
+
+		rel, err := filepath.Rel(m.root, name)
+		if err != nil {
+			return err
+		}
+
+		rel = filepath.ToSlash(rel)
+		if rel == "." {
+			rel = ""
+		}
+
+		if fi.IsDir() && fi.Name() == gitDir {
+			return filepath.SkipDir
+		}
+
+		if rel != dir {
+			m.changed[rel] = m.seq
+		}
+
+		if !fi.IsDir() {
+			return nil
+		}
+
+		wd, err := syscall.InotifyAddWatch(m.fd, name, inotifyMask)
+		if err != nil {
+			// the directory was removed while walking
+			if err == syscall.ENOENT || err == syscall.ENOTDIR {
+				return filepath.SkipDir
+			}
+
+			return os.NewSyscallError("inotify_add_watch", err)
+		}
+
+		m.watches[int32(wd)] = rel
+		return nil
+	})
+}
+
+// read reads the pending events, without blocking.
+func (m *Inotify) read() error {
+	var buf [syscall.SizeofInotifyEvent * 4096]byte
+	for {
+		n, err := syscall.Read(m.fd, buf[:])
+		if err == syscall.EAGAIN || (err == nil && n == 0) {
+			return nil
+		}
+
+		if err == syscall.EINTR {
+			continue
+		}
+
+		if err != nil {
+			return os.NewSyscallError("read", err)
+		}
+
+		if err := m.handleEvents(buf[:n]); err != nil {
+			return err
+		}
+	}
+}
+
+func (m *Inotify) handleEvents(buf []byte) error {
+	for offset := 0; offset+syscall.SizeofInotifyEvent <= len(buf); {
+		e := (*syscall.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+		offset += syscall.SizeofInotifyEvent
+
+		end := offset + int(e.Len)
+		if end > len(buf) {
+			end = len(buf)
+		}
+
+		name := string(bytes.TrimRight(buf[offset:end], "\x00"))
+		offset = end
+
+		if e.Mask&syscall.IN_Q_OVERFLOW != 0 {
+			m.overflow = m.seq
+			continue
+		}
+
+		dir, ok := m.watches[e.Wd]
+		if !ok {
+			continue
+		}
+
+		if e.Mask&syscall.IN_IGNORED != 0 {
+			delete(m.watches, e.Wd)
+			continue
+		}
+
+		// the moves of the subdirectories are handled with the IN_MOVED_FROM
+		// event of their parent, the worktree itself has none
+		if e.Mask&syscall.IN_MOVE_SELF != 0 {
+			if dir == "" {
+				m.overflow = m.seq
+			}
+
+			continue
+		}
+
+		if name == gitDir {
+			continue
+		}
+
+		p := path.Join(dir, name)
+		m.changed[p] = m.seq
+
+		isDir := e.Mask&syscall.IN_ISDIR != 0
+		if isDir && e.Mask&syscall.IN_MOVED_FROM != 0 {
+			m.unwatch(p)
+		}
+
+		if isDir && e.Mask&(syscall.IN_CREATE|syscall.IN_MOVED_TO) != 0 {
+			if err := m.watch(p); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// unwatch removes the watches of the directory moved from the given path and
+// of its subdirectories, which would report their changes with the old paths,
+// and makes the next changes report all the paths, the moved ones being
+// unknown. The moved directory is watched again if moved into the worktree.
+func (m *Inotify) unwatch(dir string) {
+	for wd, p := range m.watches {
+		if p == dir || strings.HasPrefix(p, dir+"/") {
+			syscall.InotifyRmWatch(m.fd, uint32(wd))
+			delete(m.watches, wd)
+		}
+	}
+
+	m.overflow = m.seq
+}
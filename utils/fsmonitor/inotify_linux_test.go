@@ -0,0 +1,161 @@
+// +build linux
+
+package fsmonitor
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "gopkg.in/check.v1"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type InotifySuite struct {
+	dir string
+	m   *Inotify
+}
+
+var _ = Suite(&InotifySuite{})
+
+func (s *InotifySuite) SetUpTest(c *C) {
+	var err error
+	s.dir, err = ioutil.TempDir("", "inotify")
+	c.Assert(err, IsNil)
+
+	c.Assert(os.MkdirAll(filepath.Join(s.dir, ".git"), 0755), IsNil)
+	c.Assert(os.MkdirAll(filepath.Join(s.dir, "qux"), 0755), IsNil)
+	s.writeFile(c, "foo")
+
+	s.m, err = NewInotify(s.dir)
+	c.Assert(err, IsNil)
+}
+
+func (s *InotifySuite) TearDownTest(c *C) {
+	c.Assert(s.m.Close(), IsNil)
+	c.Assert(os.RemoveAll(s.dir), IsNil)
+}
+
+func (s *InotifySuite) writeFile(c *C, name string) {
+	err := ioutil.WriteFile(filepath.Join(s.dir, name), []byte(name), 0644)
+	c.Assert(err, IsNil)
+}
+
+func (s *InotifySuite) TestChanges(c *C) {
+	ch, err := s.m.Changes("")
+	c.Assert(err, IsNil)
+	c.Assert(ch.All, Equals, true)
+	c.Assert(ch.Token, Not(Equals), "")
+
+	ch, err = s.m.Changes(ch.Token)
+	c.Assert(err, IsNil)
+	c.Assert(ch.All, Equals, false)
+	c.Assert(ch.Paths, HasLen, 0)
+
+	token := ch.Token
+	s.writeFile(c, "foo")
+	s.writeFile(c, "qux/bar")
+	s.writeFile(c, ".git/index")
+
+	ch, err = s.m.Changes(token)
+	c.Assert(err, IsNil)
+	c.Assert(ch.All, Equals, false)
+	c.Assert(ch.Paths, DeepEquals, []string{"foo", "qux/bar"})
+
+	// the changes since the older token are still reported
+	s.writeFile(c, "bar")
+	ch, err = s.m.Changes(token)
+	c.Assert(err, IsNil)
+	c.Assert(ch.Paths, DeepEquals, []string{"bar", "foo", "qux/bar"})
+}
+
+func (s *InotifySuite) TestChangesNewDirectory(c *C) {
+	ch, err := s.m.Changes("")
+	c.Assert(err, IsNil)
+
+	c.Assert(os.MkdirAll(filepath.Join(s.dir, "bar", "baz"), 0755), IsNil)
+	s.writeFile(c, "bar/baz/qux")
+
+	ch, err = s.m.Changes(ch.Token)
+	c.Assert(err, IsNil)
+	c.Assert(ch.Paths, DeepEquals, []string{"bar", "bar/baz", "bar/baz/qux"})
+
+	s.writeFile(c, "bar/baz/qux")
+	ch, err = s.m.Changes(ch.Token)
+	c.Assert(err, IsNil)
+	c.Assert(ch.Paths, DeepEquals, []string{"bar/baz/qux"})
+}
+
+func (s *InotifySuite) TestChangesMovedDirectory(c *C) {
+	out, err := ioutil.TempDir("", "inotify-out")
+	c.Assert(err, IsNil)
+	defer os.RemoveAll(out)
+
+	ch, err := s.m.Changes("")
+	c.Assert(err, IsNil)
+
+	c.Assert(os.Rename(filepath.Join(s.dir, "qux"), filepath.Join(out, "qux")), IsNil)
+	ch, err = s.m.Changes(ch.Token)
+	c.Assert(err, IsNil)
+	c.Assert(ch.All, Equals, true)
+
+	// the directory moved out is not watched anymore
+	c.Assert(ioutil.WriteFile(filepath.Join(out, "qux", "bar"), nil, 0644), IsNil)
+	ch, err = s.m.Changes(ch.Token)
+	c.Assert(err, IsNil)
+	c.Assert(ch.All, Equals, false)
+	c.Assert(ch.Paths, HasLen, 0)
+
+	c.Assert(os.Rename(filepath.Join(out, "qux"), filepath.Join(s.dir, "quux")), IsNil)
+	ch, err = s.m.Changes(ch.Token)
+	c.Assert(err, IsNil)
+	c.Assert(ch.Paths, DeepEquals, []string{"quux", "quux/bar"})
+
+	c.Assert(os.Rename(filepath.Join(s.dir, "quux"), filepath.Join(s.dir, "qux")), IsNil)
+	ch, err = s.m.Changes(ch.Token)
+	c.Assert(err, IsNil)
+	c.Assert(ch.All, Equals, true)
+
+	s.writeFile(c, "qux/bar")
+	ch, err = s.m.Changes(ch.Token)
+	c.Assert(err, IsNil)
+	c.Assert(ch.Paths, DeepEquals, []string{"qux/bar"})
+}
+
+func (s *InotifySuite) TestChangesPruned(c *C) {
+	ch, err := s.m.Changes("")
+	c.Assert(err, IsNil)
+
+	first := ch.Token
+	for i := 0; i < maxQueried+1; i++ {
+		s.writeFile(c, "foo")
+		ch, err = s.m.Changes(ch.Token)
+		c.Assert(err, IsNil)
+		c.Assert(ch.Paths, DeepEquals, []string{"foo"})
+	}
+
+	c.Assert(s.m.changed, HasLen, 1)
+
+	ch, err = s.m.Changes(first)
+	c.Assert(err, IsNil)
+	c.Assert(ch.All, Equals, true)
+}
+
+func (s *InotifySuite) TestChangesUnknownToken(c *C) {
+	other, err := NewInotify(s.dir)
+	c.Assert(err, IsNil)
+	defer other.Close()
+
+	ch, err := other.Changes("")
+	c.Assert(err, IsNil)
+
+	ch, err = s.m.Changes(ch.Token)
+	c.Assert(err, IsNil)
+	c.Assert(ch.All, Equals, true)
+
+	ch, err = s.m.Changes("foo")
+	c.Assert(err, IsNil)
+	c.Assert(ch.All, Equals, true)
+}
@@ -2,8 +2,10 @@ package filesystem
 
 import (
 	"io"
+	"io/ioutil"
 	"os"
 	"path"
+	"sync"
 
 	"gopkg.in/src-d/go-git.v4/plumbing"
 	"gopkg.in/src-d/go-git.v4/plumbing/filemode"
@@ -16,6 +18,32 @@ var ignore = map[string]bool{
 	".git": true,
 }
 
+// CleanFunc converts the content of a file into the content that would be
+// stored in the blob when the file is added to the index.
+type CleanFunc func(content []byte) ([]byte, error)
+
+// Options defines how the nodes of a filesystem are created.
+type Options struct {
+	// Clean, if not nil, returns the CleanFunc applied to the content of the
+	// regular file at the given path before calculating its hash, or nil if
+	// the hash is calculated from the content as is.
+	Clean func(path string) (CleanFunc, error)
+	// CachedHash, if not nil, returns the hash of the blob of the file at the
+	// given path, with the given info, when it is already known, e.g. because
+	// its stat information matches the one cached in the index. The content
+	// of the file is then not read.
+	CachedHash func(path string, fi os.FileInfo) (plumbing.Hash, bool)
+	// Workers is the number of files of a directory hashed concurrently, the
+	// files are hashed sequentially if lower than 2. The files with a
+	// CleanFunc are always hashed sequentially, the CleanFunc and CachedHash
+	// functions are not required to be safe for concurrent use.
+	Workers int
+	// ReadDir, if not nil, returns the files of the directory at the given
+	// path instead of reading it from the filesystem, e.g. to list them from
+	// a cache. The directories are listed before their subdirectories.
+	ReadDir func(path string) ([]os.FileInfo, error)
+}
+
 // The node represents a file or a directory in a billy.Filesystem. It
 // implements the interface noder.Noder of merkletrie package.
 //
@@ -24,6 +52,7 @@ var ignore = map[string]bool{
 type node struct {
 	fs         billy.Filesystem
 	submodules map[string]plumbing.Hash
+	options    *Options
 
 	path     string
 	hash     []byte
@@ -40,7 +69,17 @@ func NewRootNode(
 	fs billy.Filesystem,
 	submodules map[string]plumbing.Hash,
 ) noder.Noder {
-	return &node{fs: fs, submodules: submodules, isDir: true}
+	return NewRootNodeWithOptions(fs, submodules, Options{})
+}
+
+// NewRootNodeWithOptions returns the root node based on a given
+// billy.Filesystem, using the given options to create the nodes.
+func NewRootNodeWithOptions(
+	fs billy.Filesystem,
+	submodules map[string]plumbing.Hash,
+	options Options,
+) noder.Noder {
+	return &node{fs: fs, submodules: submodules, options: &options, isDir: true}
 }
 
 // Hash the hash of a filesystem is the result of concatenating the computed
@@ -86,7 +125,7 @@ func (n *node) calculateChildren() error {
 		return nil
 	}
 
-	files, err := n.fs.ReadDir(n.path)
+	files, err := n.readDir()
 	if err != nil {
 		if os.IsNotExist(err) {
 			return nil
@@ -95,64 +134,154 @@ func (n *node) calculateChildren() error {
 		return nil
 	}
 
+	var pending []*pendingHash
 	for _, file := range files {
 		if _, ok := ignore[file.Name()]; ok {
 			continue
 		}
 
-		c, err := n.newChildNode(file)
+		c, p, err := n.newChildNode(file)
 		if err != nil {
 			return err
 		}
 
+		if p != nil {
+			pending = append(pending, p)
+		}
+
 		n.children = append(n.children, c)
 	}
 
-	return nil
+	return n.calculatePendingHashes(pending)
 }
 
-func (n *node) newChildNode(file os.FileInfo) (*node, error) {
-	path := path.Join(n.path, file.Name())
-
-	hash, err := n.calculateHash(path, file)
-	if err != nil {
-		return nil, err
+func (n *node) readDir() ([]os.FileInfo, error) {
+	if n.options.ReadDir != nil {
+		return n.options.ReadDir(n.path)
 	}
 
+	return n.fs.ReadDir(n.path)
+}
+
+// pendingHash is a file of a directory to be hashed concurrently.
+type pendingHash struct {
+	node *node
+	file os.FileInfo
+	err  error
+}
+
+func (n *node) newChildNode(file os.FileInfo) (*node, *pendingHash, error) {
+	path := path.Join(n.path, file.Name())
+
 	node := &node{
 		fs:         n.fs,
 		submodules: n.submodules,
+		options:    n.options,
 
 		path:  path,
-		hash:  hash,
 		isDir: file.IsDir(),
 	}
 
 	if hash, isSubmodule := n.submodules[path]; isSubmodule {
 		node.hash = append(hash[:], filemode.Submodule.Bytes()...)
 		node.isDir = false
+		return node, nil, nil
+	}
+
+	if file.IsDir() {
+		node.hash = make([]byte, 24)
+		return node, nil, nil
+	}
+
+	if n.options.CachedHash != nil {
+		if hash, ok := n.options.CachedHash(path, file); ok {
+			var err error
+			node.hash, err = hashWithMode(hash, file)
+			return node, nil, err
+		}
 	}
 
-	return node, nil
+	clean, err := n.cleanFunc(path, file)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if clean == nil && n.options.Workers > 1 {
+		return node, &pendingHash{node: node, file: file}, nil
+	}
+
+	node.hash, err = n.calculateHash(path, file, clean)
+	return node, nil, err
 }
 
-func (n *node) calculateHash(path string, file os.FileInfo) ([]byte, error) {
-	if file.IsDir() {
-		return make([]byte, 24), nil
+// cleanFunc returns the CleanFunc of the regular file at path, nil if none.
+func (n *node) cleanFunc(path string, file os.FileInfo) (CleanFunc, error) {
+	if n.options.Clean == nil || file.Mode()&os.ModeSymlink != 0 {
+		return nil, nil
 	}
 
+	return n.options.Clean(path)
+}
+
+// calculatePendingHashes calculates the hashes of the pending files with up
+// to Workers goroutines.
+func (n *node) calculatePendingHashes(pending []*pendingHash) error {
+	if len(pending) == 0 {
+		return nil
+	}
+
+	workers := n.options.Workers
+	if workers > len(pending) {
+		workers = len(pending)
+	}
+
+	ch := make(chan *pendingHash)
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer wg.Done()
+			for p := range ch {
+				p.node.hash, p.err = n.calculateHash(p.node.path, p.file, nil)
+			}
+		}()
+	}
+
+	for _, p := range pending {
+		ch <- p
+	}
+
+	close(ch)
+	wg.Wait()
+
+	for _, p := range pending {
+		if p.err != nil {
+			return p.err
+		}
+	}
+
+	return nil
+}
+
+// calculateHash calculates the hash of the file at path, applying clean to
+// its content if not nil.
+func (n *node) calculateHash(path string, file os.FileInfo, clean CleanFunc) ([]byte, error) {
 	var hash plumbing.Hash
 	var err error
 	if file.Mode()&os.ModeSymlink != 0 {
 		hash, err = n.doCalculateHashForSymlink(path, file)
 	} else {
-		hash, err = n.doCalculateHashForRegular(path, file)
+		hash, err = n.doCalculateHashForRegular(path, file, clean)
 	}
 
 	if err != nil {
 		return nil, err
 	}
 
+	return hashWithMode(hash, file)
+}
+
+func hashWithMode(hash plumbing.Hash, file os.FileInfo) ([]byte, error) {
 	mode, err := filemode.NewFromOSFileMode(file.Mode())
 	if err != nil {
 		return nil, err
@@ -161,7 +290,7 @@ func (n *node) calculateHash(path string, file os.FileInfo) ([]byte, error) {
 	return append(hash[:], mode.Bytes()...), nil
 }
 
-func (n *node) doCalculateHashForRegular(path string, file os.FileInfo) (plumbing.Hash, error) {
+func (n *node) doCalculateHashForRegular(path string, file os.FileInfo, clean CleanFunc) (plumbing.Hash, error) {
 	f, err := n.fs.Open(path)
 	if err != nil {
 		return plumbing.ZeroHash, err
@@ -169,6 +298,10 @@ func (n *node) doCalculateHashForRegular(path string, file os.FileInfo) (plumbin
 
 	defer f.Close()
 
+	if clean != nil {
+		return calculateCleanHash(f, clean)
+	}
+
 	h := plumbing.NewHasher(plumbing.BlobObject, file.Size())
 	if _, err := io.Copy(h, f); err != nil {
 		return plumbing.ZeroHash, err
@@ -177,6 +310,24 @@ func (n *node) doCalculateHashForRegular(path string, file os.FileInfo) (plumbin
 	return h.Sum(), nil
 }
 
+func calculateCleanHash(r io.Reader, clean CleanFunc) (plumbing.Hash, error) {
+	content, err := ioutil.ReadAll(r)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	if content, err = clean(content); err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	h := plumbing.NewHasher(plumbing.BlobObject, int64(len(content)))
+	if _, err := h.Write(content); err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	return h.Sum(), nil
+}
+
 func (n *node) doCalculateHashForSymlink(path string, file os.FileInfo) (plumbing.Hash, error) {
 	target, err := n.fs.Readlink(path)
 	if err != nil {
@@ -2,6 +2,7 @@ package filesystem
 
 import (
 	"bytes"
+	"fmt"
 	"io"
 	"os"
 	"path"
@@ -220,3 +221,112 @@ func IsEquals(a, b noder.Hasher) bool {
 
 	return bytes.Equal(a.Hash(), b.Hash())
 }
+
+func (s *NoderSuite) TestDiffCleanContent(c *C) {
+	fsA := memfs.New()
+	WriteFile(fsA, "foo", []byte("foo\r\nbar\r\n"), 0644)
+	WriteFile(fsA, "bar", []byte("foo\r\n"), 0644)
+
+	fsB := memfs.New()
+	WriteFile(fsB, "foo", []byte("foo\nbar\n"), 0644)
+	WriteFile(fsB, "bar", []byte("foo\n"), 0644)
+
+	clean := func(path string) (CleanFunc, error) {
+		if path != "foo" {
+			return nil, nil
+		}
+
+		return func(content []byte) ([]byte, error) {
+			return bytes.Replace(content, []byte("\r\n"), []byte("\n"), -1), nil
+		}, nil
+	}
+
+	ch, err := merkletrie.DiffTree(
+		NewRootNodeWithOptions(fsA, nil, Options{Clean: clean}),
+		NewRootNode(fsB, nil),
+		IsEquals,
+	)
+
+	c.Assert(err, IsNil)
+	c.Assert(ch, HasLen, 1)
+	c.Assert(ch[0].From.String(), Equals, "bar")
+}
+
+func (s *NoderSuite) TestDiffCachedHash(c *C) {
+	fsA := memfs.New()
+	WriteFile(fsA, "foo", []byte("foo"), 0644)
+	WriteFile(fsA, "qux/bar", []byte("bar"), 0644)
+
+	fsB := memfs.New()
+	WriteFile(fsB, "foo", []byte("foo"), 0644)
+	WriteFile(fsB, "qux/bar", []byte("foo"), 0644)
+
+	// the cached hash is trusted, the content of the file is not read
+	cached := func(path string, fi os.FileInfo) (plumbing.Hash, bool) {
+		if path != "qux/bar" {
+			return plumbing.ZeroHash, false
+		}
+
+		return plumbing.ComputeHash(plumbing.BlobObject, []byte("foo")), true
+	}
+
+	ch, err := merkletrie.DiffTree(
+		NewRootNodeWithOptions(fsA, nil, Options{CachedHash: cached}),
+		NewRootNode(fsB, nil),
+		IsEquals,
+	)
+
+	c.Assert(err, IsNil)
+	c.Assert(ch, HasLen, 0)
+}
+
+func (s *NoderSuite) TestDiffWorkers(c *C) {
+	fsA := memfs.New()
+	fsB := memfs.New()
+	for i := 0; i < 100; i++ {
+		name := path.Join(fmt.Sprintf("dir%d", i%3), fmt.Sprintf("file%d", i))
+		content := []byte(name)
+		WriteFile(fsA, name, content, 0644)
+		if i%10 == 0 {
+			content = append(content, '\n')
+		}
+
+		WriteFile(fsB, name, content, 0644)
+	}
+
+	ch, err := merkletrie.DiffTree(
+		NewRootNodeWithOptions(fsA, nil, Options{Workers: 4}),
+		NewRootNodeWithOptions(fsB, nil, Options{Workers: 4}),
+		IsEquals,
+	)
+
+	c.Assert(err, IsNil)
+	c.Assert(ch, HasLen, 10)
+}
+
+func (s *NoderSuite) TestDiffReadDir(c *C) {
+	fsA := memfs.New()
+	WriteFile(fsA, "foo", []byte("foo"), 0644)
+	WriteFile(fsA, "qux/bar", []byte("bar"), 0644)
+
+	fsB := memfs.New()
+	WriteFile(fsB, "foo", []byte("foo"), 0644)
+
+	// qux is not listed, so it is not visited
+	var visited []string
+	readDir := func(path string) ([]os.FileInfo, error) {
+		visited = append(visited, path)
+		fi, err := fsA.Lstat("foo")
+		return []os.FileInfo{fi}, err
+	}
+
+	ch, err := merkletrie.DiffTree(
+		NewRootNodeWithOptions(fsA, nil, Options{ReadDir: readDir}),
+		NewRootNode(fsB, nil),
+		IsEquals,
+	)
+
+	c.Assert(err, IsNil)
+	c.Assert(ch, HasLen, 0)
+	c.Assert(visited, DeepEquals, []string{""})
+}
@@ -7,16 +7,20 @@ import (
 	"io"
 	stdioutil "io/ioutil"
 	"os"
+	"path"
 	"path/filepath"
+	"sort"
 	"strings"
 
 	"gopkg.in/src-d/go-git.v4/config"
 	"gopkg.in/src-d/go-git.v4/plumbing"
 	"gopkg.in/src-d/go-git.v4/plumbing/filemode"
+	"gopkg.in/src-d/go-git.v4/plumbing/format/gitattributes"
 	"gopkg.in/src-d/go-git.v4/plumbing/format/gitignore"
 	"gopkg.in/src-d/go-git.v4/plumbing/format/index"
 	"gopkg.in/src-d/go-git.v4/plumbing/object"
 	"gopkg.in/src-d/go-git.v4/plumbing/storer"
+	"gopkg.in/src-d/go-git.v4/utils/fsmonitor"
 	"gopkg.in/src-d/go-git.v4/utils/ioutil"
 	"gopkg.in/src-d/go-git.v4/utils/merkletrie"
 
@@ -37,6 +41,18 @@ type Worktree struct {
 	Filesystem billy.Filesystem
 	// External excludes not found in the repository .gitignore
 	Excludes []gitignore.Pattern
+	// External attributes not found in the repository .gitattributes files,
+	// e.g. the ones loaded with gitattributes.LoadGlobalPatterns
+	ExternalAttributes []gitattributes.MatchAttribute
+	// Filters are the filter drivers applied to the files with the
+	// filter=<name> attribute, by name. The files with a filter not
+	// found here are not filtered.
+	Filters map[string]Filter
+	// FSMonitor, if not nil, reports the paths changed in the worktree, so
+	// Status only checks them, e.g. fsmonitor.NewInotify. It is used along
+	// with the untracked cache of the index, added if needed unless the
+	// core.untrackedCache config is false.
+	FSMonitor fsmonitor.Monitor
 
 	r *Repository
 }
@@ -140,6 +156,13 @@ func (w *Worktree) updateSubmodules(o *SubmoduleUpdateOptions) error {
 }
 
 // Checkout switch branches or restore working tree files.
+//
+// Unless Force is used, the local changes are kept if the files don't differ
+// between HEAD and the commit being checked out, otherwise a
+// *CheckoutConflictError is returned listing the conflicting files. With the
+// Merge option the local changes to those files are merged instead, if any
+// merge conflicts the checkout is done and a *CheckoutConflictError is
+// returned with Merged set.
 func (w *Worktree) Checkout(opts *CheckoutOptions) error {
 	if err := opts.Validate(); err != nil {
 		return err
@@ -151,25 +174,33 @@ func (w *Worktree) Checkout(opts *CheckoutOptions) error {
 		}
 	}
 
-	if !opts.Force {
-		unstaged, err := w.containsUnstagedChanges()
-		if err != nil {
-			return err
-		}
-
-		if unstaged {
-			return ErrUnstagedChanges
-		}
+	c, err := w.getCommitFromCheckoutOptions(opts)
+	if err != nil {
+		return err
 	}
 
-	c, err := w.getCommitFromCheckoutOptions(opts)
+	idx, err := w.r.Storer.Index()
 	if err != nil {
 		return err
 	}
 
-	ro := &ResetOptions{Commit: c, Mode: MergeReset}
-	if opts.Force {
-		ro.Mode = HardReset
+	// the initial checkout, with an empty index, is done as a reset
+	var merged []string
+	reset := opts.Force || len(idx.Entries) == 0
+	if !reset {
+		t, err := w.getTreeFromCommitHash(c)
+		if err != nil {
+			return err
+		}
+
+		label := opts.Branch.Short()
+		if !opts.Hash.IsZero() && !opts.Create {
+			label = opts.Hash.String()
+		}
+
+		if merged, err = w.checkoutTree(t, label, opts.Merge); err != nil {
+			return err
+		}
 	}
 
 	if !opts.Hash.IsZero() && !opts.Create {
@@ -182,8 +213,22 @@ func (w *Worktree) Checkout(opts *CheckoutOptions) error {
 		return err
 	}
 
-	return w.Reset(ro)
+	if reset {
+		ro := &ResetOptions{Commit: c, Mode: MergeReset}
+		if opts.Force {
+			ro.Mode = HardReset
+		}
+
+		return w.Reset(ro)
+	}
+
+	if len(merged) != 0 {
+		return &CheckoutConflictError{Paths: merged, Merged: true}
+	}
+
+	return nil
 }
+
 func (w *Worktree) createBranch(opts *CheckoutOptions) error {
 	_, err := w.r.Storer.Reference(opts.Branch)
 	if err == nil {
@@ -293,7 +338,7 @@ func (w *Worktree) Reset(opts *ResetOptions) error {
 	}
 
 	if opts.Mode == MixedReset || opts.Mode == MergeReset || opts.Mode == HardReset {
-		if err := w.resetIndex(t); err != nil {
+		if err := w.resetIndex(t, nil); err != nil {
 			return err
 		}
 	}
@@ -307,7 +352,9 @@ func (w *Worktree) Reset(opts *ResetOptions) error {
 	return nil
 }
 
-func (w *Worktree) resetIndex(t *object.Tree) error {
+// resetIndex resets the entries of the index to the tree t, if paths is not
+// empty only the entries matching them are reset.
+func (w *Worktree) resetIndex(t *object.Tree, paths []string) error {
 	idx, err := w.r.Storer.Index()
 	if err != nil {
 		return err
@@ -318,6 +365,11 @@ func (w *Worktree) resetIndex(t *object.Tree) error {
 		return err
 	}
 
+	sparse, err := w.sparseCheckout()
+	if err != nil {
+		return err
+	}
+
 	for _, ch := range changes {
 		a, err := ch.Action()
 		if err != nil {
@@ -338,19 +390,25 @@ func (w *Worktree) resetIndex(t *object.Tree) error {
 			name = ch.From.String()
 		}
 
+		if len(paths) != 0 && !matchPaths(paths, name) {
+			continue
+		}
+
 		_, _ = idx.Remove(name)
 		if e == nil {
 			continue
 		}
 
 		idx.Entries = append(idx.Entries, &index.Entry{
-			Name: name,
-			Hash: e.Hash,
-			Mode: e.Mode,
+			Name:         name,
+			Hash:         e.Hash,
+			Mode:         e.Mode,
+			SkipWorktree: !sparse.Match(name),
 		})
 
 	}
 
+	setIndexVersion(idx)
 	return w.r.Storer.SetIndex(idx)
 }
 
@@ -365,8 +423,13 @@ func (w *Worktree) resetWorktree(t *object.Tree) error {
 		return err
 	}
 
+	conv, err := w.newContentConverter()
+	if err != nil {
+		return err
+	}
+
 	for _, ch := range changes {
-		if err := w.checkoutChange(ch, t, idx); err != nil {
+		if err := w.checkoutChange(ch, t, idx, conv); err != nil {
 			return err
 		}
 	}
@@ -374,7 +437,7 @@ func (w *Worktree) resetWorktree(t *object.Tree) error {
 	return w.r.Storer.SetIndex(idx)
 }
 
-func (w *Worktree) checkoutChange(ch merkletrie.Change, t *object.Tree, idx *index.Index) error {
+func (w *Worktree) checkoutChange(ch merkletrie.Change, t *object.Tree, idx *index.Index, conv *contentConverter) error {
 	a, err := ch.Action()
 	if err != nil {
 		return err
@@ -401,7 +464,7 @@ func (w *Worktree) checkoutChange(ch merkletrie.Change, t *object.Tree, idx *ind
 		return w.checkoutChangeSubmodule(name, a, e, idx)
 	}
 
-	return w.checkoutChangeRegularFile(name, a, t, e, idx)
+	return w.checkoutChangeRegularFile(name, a, t, e, idx, conv)
 }
 
 func (w *Worktree) containsUnstagedChanges() (bool, error) {
@@ -488,6 +551,7 @@ func (w *Worktree) checkoutChangeRegularFile(name string,
 	t *object.Tree,
 	e *object.TreeEntry,
 	idx *index.Index,
+	conv *contentConverter,
 ) error {
 	switch a {
 	case merkletrie.Modify:
@@ -506,7 +570,7 @@ func (w *Worktree) checkoutChangeRegularFile(name string,
 			return err
 		}
 
-		if err := w.checkoutFile(f); err != nil {
+		if err := w.checkoutFile(f, conv); err != nil {
 			return err
 		}
 
@@ -516,7 +580,7 @@ func (w *Worktree) checkoutChangeRegularFile(name string,
 	return nil
 }
 
-func (w *Worktree) checkoutFile(f *object.File) (err error) {
+func (w *Worktree) checkoutFile(f *object.File, conv *contentConverter) (err error) {
 	mode, err := f.Mode.ToOSFileMode()
 	if err != nil {
 		return
@@ -540,7 +604,26 @@ func (w *Worktree) checkoutFile(f *object.File) (err error) {
 
 	defer ioutil.CheckClose(to, &err)
 
-	_, err = io.Copy(to, from)
+	smudge, err := conv.needsSmudge(f.Name)
+	if err != nil {
+		return
+	}
+
+	if !smudge {
+		_, err = io.Copy(to, from)
+		return
+	}
+
+	content, err := stdioutil.ReadAll(from)
+	if err != nil {
+		return
+	}
+
+	if content, err = conv.smudge(f.Name, content); err != nil {
+		return
+	}
+
+	_, err = to.Write(content)
 	return
 }
 
@@ -715,53 +798,166 @@ func (w *Worktree) readGitmodulesFile() (*config.Modules, error) {
 // Clean the worktree by removing untracked files.
 // An empty dir could be removed - this is what  `git clean -f -d .` does.
 func (w *Worktree) Clean(opts *CleanOptions) error {
-	s, err := w.Status()
-	if err != nil {
-		return err
+	_, err := w.CleanPaths(opts)
+	return err
+}
+
+// CleanPaths cleans the worktree as Clean does, returning the removed paths,
+// or the ones that would be removed if DryRun is used. The paths of the
+// directories removed with all their content end with a slash.
+func (w *Worktree) CleanPaths(opts *CleanOptions) ([]string, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
 	}
 
-	root := ""
-	files, err := w.Filesystem.ReadDir(root)
+	idx, err := w.r.Storer.Index()
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	c := &cleaner{
+		w:       w,
+		opts:    opts,
+		tracked: make(map[string]bool),
+		dirs:    make(map[string]bool),
+	}
+
+	for _, e := range idx.Entries {
+		c.tracked[e.Name] = true
+		for dir := path.Dir(e.Name); dir != "."; dir = path.Dir(dir) {
+			c.dirs[dir] = true
+		}
+	}
+
+	if !opts.Ignored {
+		patterns, err := gitignore.ReadPatterns(w.Filesystem, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		c.ignore = gitignore.NewMatcher(append(patterns, w.Excludes...))
 	}
-	return w.doClean(s, opts, root, files)
+
+	c.excludes = gitignore.NewMatcher(opts.Excludes)
+
+	removed, _, err := c.clean("", false)
+	sort.Strings(removed)
+	return removed, err
 }
 
-func (w *Worktree) doClean(status Status, opts *CleanOptions, dir string, files []os.FileInfo) error {
+// cleaner removes the untracked files of a worktree.
+type cleaner struct {
+	w    *Worktree
+	opts *CleanOptions
+	// tracked are the files in the index, dirs the directories containing
+	// them.
+	tracked, dirs map[string]bool
+	// ignore are the standard ignore rules, nil if not used, excludes the
+	// additional ones.
+	ignore, excludes gitignore.Matcher
+}
+
+// clean cleans the directory dir, ignored is true if dir is ignored. Returns
+// the removed paths and true if all the content of the directory was removed.
+func (c *cleaner) clean(dir string, ignored bool) (removed []string, all bool, err error) {
+	files, err := c.w.Filesystem.ReadDir(dir)
+	if err != nil {
+		return nil, false, err
+	}
+
+	all = true
 	for _, fi := range files {
-		if fi.Name() == ".git" {
+		name := path.Join(dir, fi.Name())
+		if (dir == "" && fi.Name() == GitDirName) || c.tracked[name] {
+			all = false
 			continue
 		}
 
-		// relative path under the root
-		path := filepath.Join(dir, fi.Name())
-		if fi.IsDir() {
-			if !opts.Dir {
+		isIgnored := ignored || c.isIgnored(name, fi.IsDir())
+		if !fi.IsDir() {
+			if !c.shouldRemove(name, isIgnored) {
+				all = false
 				continue
 			}
 
-			subfiles, err := w.Filesystem.ReadDir(path)
-			if err != nil {
-				return err
-			}
-			err = w.doClean(status, opts, path, subfiles)
-			if err != nil {
-				return err
-			}
-		} else {
-			if status.IsUntracked(path) {
-				if err := w.Filesystem.Remove(path); err != nil {
-					return err
-				}
+			if err := c.remove(name); err != nil {
+				return nil, false, err
 			}
+
+			removed = append(removed, name)
+			continue
+		}
+
+		if !c.dirs[name] && !c.shouldCleanDir(name) {
+			all = false
+			continue
+		}
+
+		r, dirAll, err := c.clean(name, isIgnored)
+		if err != nil {
+			return nil, false, err
+		}
+
+		if !dirAll || c.dirs[name] || !c.shouldRemove(name, isIgnored) {
+			all = false
+			removed = append(removed, r...)
+			continue
+		}
+
+		// the directory is removed with all its content
+		if err := c.remove(name); err != nil {
+			return nil, false, err
 		}
+
+		removed = append(removed, name+"/")
 	}
 
-	if opts.Dir {
-		return doCleanDirectories(w.Filesystem, dir)
+	return removed, all, nil
+}
+
+func (c *cleaner) isIgnored(name string, isDir bool) bool {
+	parts := strings.Split(name, "/")
+	if c.excludes.Match(parts, isDir) {
+		return true
 	}
-	return nil
+
+	return c.ignore != nil && c.ignore.Match(parts, isDir)
+}
+
+// shouldRemove returns true if the untracked file or directory name is
+// removed.
+func (c *cleaner) shouldRemove(name string, ignored bool) bool {
+	if len(c.opts.Paths) != 0 && !matchPaths(c.opts.Paths, name) {
+		return false
+	}
+
+	if c.opts.OnlyIgnored {
+		return ignored
+	}
+
+	return !ignored
+}
+
+// shouldCleanDir returns true if the untracked directory name is cleaned, the
+// nested repositories are only cleaned with Force.
+func (c *cleaner) shouldCleanDir(name string) bool {
+	if !c.opts.Dir || c.excludes.Match(strings.Split(name, "/"), true) {
+		return false
+	}
+
+	if _, err := c.w.Filesystem.Lstat(path.Join(name, GitDirName)); err == nil {
+		return c.opts.Force
+	}
+
+	return true
+}
+
+func (c *cleaner) remove(name string) error {
+	if c.opts.DryRun {
+		return nil
+	}
+
+	return c.w.Filesystem.Remove(name)
 }
 
 // GrepResult is structure of a grep result.
@@ -0,0 +1,218 @@
+package git
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/filemode"
+	"gopkg.in/src-d/go-git.v4/plumbing/format/diff"
+	"gopkg.in/src-d/go-git.v4/plumbing/format/index"
+)
+
+var (
+	// ErrPatchNotApplicable is the error of a PatchError returned by
+	// ApplyToIndex when the content a patch applies to doesn't match the
+	// content of the index.
+	ErrPatchNotApplicable = errors.New("patch does not apply to the index")
+	// ErrBinaryPatch is the error of a PatchError returned by ApplyToIndex
+	// when a patch of a binary file can't be applied, since the blob of the
+	// resulting file is not in the repository.
+	ErrBinaryPatch = errors.New("binary patch can't be applied")
+)
+
+// PatchError is returned by ApplyToIndex when the patch of a file can't be
+// applied.
+type PatchError struct {
+	// Err is the reason, ErrPatchNotApplicable or ErrBinaryPatch.
+	Err error
+	// Path is the path of the file.
+	Path string
+}
+
+func (e *PatchError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Err, e.Path)
+}
+
+// ApplyToIndex applies the patch to the blobs of the index, without touching
+// the worktree. Applying a patch from the index to the worktree, or some of
+// its chunks using ApplyOptions.Select, stages those changes, like
+// git add -p. Applying in reverse a patch from HEAD to the index unstages
+// them, like git reset -p. The index is only written if the patches of all
+// the files apply.
+func (w *Worktree) ApplyToIndex(p diff.Patch, o *ApplyOptions) error {
+	if o == nil {
+		o = &ApplyOptions{}
+	}
+
+	idx, err := w.r.Storer.Index()
+	if err != nil {
+		return err
+	}
+
+	for _, fp := range p.FilePatches() {
+		if err := w.applyFilePatch(idx, fp, o); err != nil {
+			return err
+		}
+	}
+
+	setIndexVersion(idx)
+	return w.r.Storer.SetIndex(idx)
+}
+
+func (w *Worktree) applyFilePatch(idx *index.Index, fp diff.FilePatch, o *ApplyOptions) error {
+	from, to := fp.Files()
+	if o.Reverse {
+		from, to = to, from
+	}
+
+	name := patchFilePath(from, to)
+	e, err := idx.Entry(name)
+	if err != nil && err != index.ErrEntryNotFound {
+		return err
+	}
+
+	if (e == nil) != (from == nil) {
+		return &PatchError{ErrPatchNotApplicable, name}
+	}
+
+	if fp.IsBinary() {
+		return w.applyBinaryFilePatch(idx, e, name, to)
+	}
+
+	src, dst, all := applyChunks(fp, o)
+	if e != nil {
+		content, err := w.readBlob(e.Hash)
+		if err != nil {
+			return err
+		}
+
+		if !bytes.Equal(content, src) {
+			return &PatchError{ErrPatchNotApplicable, name}
+		}
+	}
+
+	// the file is deleted only if all the chunks are applied
+	if to == nil && all {
+		_, err := idx.Remove(name)
+		return err
+	}
+
+	h, err := w.storeBlob(dst)
+	if err != nil {
+		return err
+	}
+
+	mode := filemode.Regular
+	if to != nil {
+		mode = to.Mode()
+	} else if e != nil {
+		mode = e.Mode
+	}
+
+	// renames are applied if all the chunks are applied
+	if to != nil && to.Path() != name && all {
+		_, _ = idx.Remove(name)
+		name, e = to.Path(), nil
+		if te, err := idx.Entry(name); err == nil {
+			e = te
+		}
+	}
+
+	setIndexEntry(idx, e, name, h, mode)
+	return nil
+}
+
+func (w *Worktree) applyBinaryFilePatch(idx *index.Index, e *index.Entry, name string, to diff.File) error {
+	if to == nil {
+		_, err := idx.Remove(name)
+		return err
+	}
+
+	if _, err := w.r.BlobObject(to.Hash()); err != nil {
+		return &PatchError{ErrBinaryPatch, name}
+	}
+
+	setIndexEntry(idx, e, name, to.Hash(), to.Mode())
+	return nil
+}
+
+// applyChunks returns the content the file patch fp applies to, and the
+// content resulting of applying the chunks selected by o. all is true if all
+// the chunks were selected.
+func applyChunks(fp diff.FilePatch, o *ApplyOptions) (src, dst []byte, all bool) {
+	var srcBuf, dstBuf bytes.Buffer
+	all = true
+	for i, c := range fp.Chunks() {
+		selected := o.Select == nil || o.Select(fp, i)
+		all = all && selected
+
+		t := c.Type()
+		if o.Reverse && t != diff.Equal {
+			t = diff.Add + diff.Delete - t
+		}
+
+		switch t {
+		case diff.Equal:
+			srcBuf.WriteString(c.Content())
+			dstBuf.WriteString(c.Content())
+		case diff.Delete:
+			srcBuf.WriteString(c.Content())
+			if !selected {
+				dstBuf.WriteString(c.Content())
+			}
+		case diff.Add:
+			if selected {
+				dstBuf.WriteString(c.Content())
+			}
+		}
+	}
+
+	return srcBuf.Bytes(), dstBuf.Bytes(), all
+}
+
+// setIndexEntry sets the hash and mode of the entry name of the index, adding
+// it if e is nil. The stat information is reset, since the entry may not
+// match the worktree.
+func setIndexEntry(idx *index.Index, e *index.Entry, name string, h plumbing.Hash, mode filemode.FileMode) {
+	if e == nil {
+		e = idx.Add(name)
+	}
+
+	*e = index.Entry{
+		Name:         name,
+		Hash:         h,
+		Mode:         mode,
+		SkipWorktree: e.SkipWorktree,
+	}
+}
+
+func (w *Worktree) storeBlob(content []byte) (h plumbing.Hash, err error) {
+	obj := w.r.Storer.NewEncodedObject()
+	obj.SetType(plumbing.BlobObject)
+	obj.SetSize(int64(len(content)))
+
+	writer, err := obj.Writer()
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	if _, err := writer.Write(content); err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	if err := writer.Close(); err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	return w.r.Storer.SetEncodedObject(obj)
+}
+
+func patchFilePath(from, to diff.File) string {
+	if from != nil {
+		return from.Path()
+	}
+
+	return to.Path()
+}
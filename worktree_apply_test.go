@@ -0,0 +1,156 @@
+package git
+
+import (
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/filemode"
+	"gopkg.in/src-d/go-git.v4/plumbing/format/diff"
+	"gopkg.in/src-d/go-git.v4/plumbing/format/index"
+
+	. "gopkg.in/check.v1"
+	"gopkg.in/src-d/go-billy.v4/util"
+)
+
+type testPatch []diff.FilePatch
+
+func (p testPatch) FilePatches() []diff.FilePatch { return p }
+func (p testPatch) Message() string               { return "" }
+
+type testFilePatch struct {
+	from, to diff.File
+	chunks   []diff.Chunk
+}
+
+func (fp *testFilePatch) IsBinary() bool              { return false }
+func (fp *testFilePatch) Files() (from, to diff.File) { return fp.from, fp.to }
+func (fp *testFilePatch) Chunks() []diff.Chunk        { return fp.chunks }
+
+type testFile string
+
+func (f testFile) Hash() plumbing.Hash     { return plumbing.ZeroHash }
+func (f testFile) Mode() filemode.FileMode { return filemode.Regular }
+func (f testFile) Path() string            { return string(f) }
+
+type testChunk struct {
+	content string
+	op      diff.Operation
+}
+
+func (c testChunk) Content() string      { return c.content }
+func (c testChunk) Type() diff.Operation { return c.op }
+
+// worktreePatch is the patch from the index to the worktree.
+var worktreePatch = testPatch{&testFilePatch{
+	from: testFile("foo"),
+	to:   testFile("foo"),
+	chunks: []diff.Chunk{
+		testChunk{"a\n", diff.Delete},
+		testChunk{"A\n", diff.Add},
+		testChunk{"b\nc\n", diff.Equal},
+		testChunk{"d\n", diff.Delete},
+		testChunk{"D\n", diff.Add},
+	},
+}}
+
+func (s *WorktreeSuite) TestApplyToIndex(c *C) {
+	w, fs := s.newInitWorktree(c, map[string]string{"foo": "a\nb\nc\nd\n"})
+	c.Assert(util.WriteFile(fs, "foo", []byte("A\nb\nc\nD\n"), 0644), IsNil)
+
+	err := w.ApplyToIndex(worktreePatch, nil)
+	c.Assert(err, IsNil)
+
+	status, err := w.Status()
+	c.Assert(err, IsNil)
+	c.Assert(status.File("foo").Staging, Equals, Modified)
+	c.Assert(status.File("foo").Worktree, Equals, Unmodified)
+
+	c.Assert(fileContent(c, fs, "foo"), Equals, "A\nb\nc\nD\n")
+}
+
+func (s *WorktreeSuite) TestApplyToIndexSelect(c *C) {
+	w, fs := s.newInitWorktree(c, map[string]string{"foo": "a\nb\nc\nd\n"})
+	c.Assert(util.WriteFile(fs, "foo", []byte("A\nb\nc\nD\n"), 0644), IsNil)
+
+	err := w.ApplyToIndex(worktreePatch, &ApplyOptions{
+		Select: func(fp diff.FilePatch, i int) bool { return i < 2 },
+	})
+	c.Assert(err, IsNil)
+
+	idx, err := w.r.Storer.Index()
+	c.Assert(err, IsNil)
+	e, err := idx.Entry("foo")
+	c.Assert(err, IsNil)
+	c.Assert(blobContent(c, w, e.Hash), Equals, "A\nb\nc\nd\n")
+
+	status, err := w.Status()
+	c.Assert(err, IsNil)
+	c.Assert(status.File("foo").Staging, Equals, Modified)
+	c.Assert(status.File("foo").Worktree, Equals, Modified)
+
+	// the first hunk is unstaged applying in reverse the patch from HEAD to
+	// the index
+	err = w.ApplyToIndex(testPatch{&testFilePatch{
+		from: testFile("foo"),
+		to:   testFile("foo"),
+		chunks: []diff.Chunk{
+			testChunk{"a\n", diff.Delete},
+			testChunk{"A\n", diff.Add},
+			testChunk{"b\nc\nd\n", diff.Equal},
+		},
+	}}, &ApplyOptions{Reverse: true})
+	c.Assert(err, IsNil)
+
+	status, err = w.Status()
+	c.Assert(err, IsNil)
+	c.Assert(status.File("foo").Staging, Equals, Unmodified)
+	c.Assert(status.File("foo").Worktree, Equals, Modified)
+}
+
+func (s *WorktreeSuite) TestApplyToIndexNewAndDeletedFiles(c *C) {
+	w, fs := s.newInitWorktree(c, map[string]string{"foo": "a\nb\nc\nd\n"})
+	c.Assert(util.WriteFile(fs, "foo", []byte("A\nb\nc\nD\n"), 0644), IsNil)
+
+	err := w.ApplyToIndex(testPatch{
+		&testFilePatch{
+			to:     testFile("bar"),
+			chunks: []diff.Chunk{testChunk{"bar\n", diff.Add}},
+		},
+		&testFilePatch{
+			from:   testFile("foo"),
+			chunks: []diff.Chunk{testChunk{"a\nb\nc\nd\n", diff.Delete}},
+		},
+	}, nil)
+	c.Assert(err, IsNil)
+
+	status, err := w.Status()
+	c.Assert(err, IsNil)
+	c.Assert(status.File("bar").Staging, Equals, Added)
+	c.Assert(status.File("bar").Worktree, Equals, Deleted)
+
+	idx, err := w.r.Storer.Index()
+	c.Assert(err, IsNil)
+	_, err = idx.Entry("foo")
+	c.Assert(err, Equals, index.ErrEntryNotFound)
+
+	_, err = fs.Stat("foo")
+	c.Assert(err, IsNil)
+	_, err = fs.Stat("bar")
+	c.Assert(err, NotNil)
+}
+
+func (s *WorktreeSuite) TestApplyToIndexNotApplicable(c *C) {
+	w, fs := s.newInitWorktree(c, map[string]string{"foo": "a\nb\nc\nd\n"})
+	c.Assert(util.WriteFile(fs, "foo", []byte("A\nb\nc\nD\n"), 0644), IsNil)
+
+	err := w.ApplyToIndex(testPatch{&testFilePatch{
+		from:   testFile("foo"),
+		to:     testFile("foo"),
+		chunks: []diff.Chunk{testChunk{"qux\n", diff.Delete}},
+	}}, nil)
+	c.Assert(err, DeepEquals, &PatchError{ErrPatchNotApplicable, "foo"})
+
+	err = w.ApplyToIndex(testPatch{&testFilePatch{
+		to:     testFile("foo"),
+		chunks: []diff.Chunk{testChunk{"qux\n", diff.Add}},
+	}}, nil)
+	c.Assert(err, DeepEquals, &PatchError{ErrPatchNotApplicable, "foo"})
+}
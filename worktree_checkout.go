@@ -0,0 +1,376 @@
+package git
+
+import (
+	"fmt"
+	stdioutil "io/ioutil"
+	"os"
+	"sort"
+	"strings"
+
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/filemode"
+	"gopkg.in/src-d/go-git.v4/plumbing/format/index"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+	"gopkg.in/src-d/go-git.v4/utils/diff"
+	"gopkg.in/src-d/go-git.v4/utils/ioutil"
+	"gopkg.in/src-d/go-git.v4/utils/merkletrie"
+	"gopkg.in/src-d/go-git.v4/utils/merkletrie/noder"
+)
+
+// localLabel is the label of the local changes in the conflict markers.
+const localLabel = "local"
+
+// CheckoutConflictError is returned by Checkout when the local changes to
+// some files conflict with the commit being checked out.
+type CheckoutConflictError struct {
+	// Paths are the conflicting files.
+	Paths []string
+	// Merged is true if the checkout was done with the Merge option, the
+	// conflicting files contain conflict markers. Otherwise nothing was
+	// checked out.
+	Merged bool
+}
+
+func (e *CheckoutConflictError) Error() string {
+	if e.Merged {
+		return fmt.Sprintf("merge conflicts in: %s", strings.Join(e.Paths, ", "))
+	}
+
+	return fmt.Sprintf(
+		"local changes would be overwritten by checkout: %s",
+		strings.Join(e.Paths, ", "),
+	)
+}
+
+// mergeResult is the content of a file three-way merged on checkout.
+type mergeResult struct {
+	entry    *object.TreeEntry
+	content  []byte
+	conflict bool
+}
+
+// checkoutTree switches the index and the worktree from the tree of HEAD to
+// the tree t. Only the files differing between both trees are updated, the
+// local changes to the rest are kept. If the files to update contain local
+// changes, a *CheckoutConflictError is returned and nothing is changed,
+// unless merge is true: the local changes are merged into the files of t,
+// returning the files left with conflict markers. The label names t in the
+// conflict markers.
+func (w *Worktree) checkoutTree(t *object.Tree, label string, merge bool) ([]string, error) {
+	head, err := w.headTree()
+	if err != nil {
+		return nil, err
+	}
+
+	var from noder.Noder
+	if head != nil {
+		from = object.NewTreeRootNode(head)
+	}
+
+	changes, err := merkletrie.DiffTree(from, object.NewTreeRootNode(t), diffTreeIsEquals)
+	if err != nil {
+		return nil, err
+	}
+
+	staged, err := w.diffTreeWithStaging(head, false)
+	if err != nil {
+		return nil, err
+	}
+
+	unstaged, err := w.diffStagingWithWorktree(false)
+	if err != nil {
+		return nil, err
+	}
+
+	local := make(map[string]bool)
+	for _, ch := range append(staged, unstaged...) {
+		local[nameFromAction(&ch)] = true
+	}
+
+	modified := make(map[string]merkletrie.Action)
+	for _, ch := range unstaged {
+		if modified[nameFromAction(&ch)], err = ch.Action(); err != nil {
+			return nil, err
+		}
+	}
+
+	idx, err := w.r.Storer.Index()
+	if err != nil {
+		return nil, err
+	}
+
+	conv, err := w.newContentConverter()
+	if err != nil {
+		return nil, err
+	}
+
+	var clean merkletrie.Changes
+	var conflicts []string
+	merges := make(map[string]*mergeResult)
+	for _, ch := range changes {
+		name := nameFromAction(&ch)
+		if !local[name] {
+			clean = append(clean, ch)
+			continue
+		}
+
+		e, err := findTreeEntry(t, name)
+		if err != nil {
+			return nil, err
+		}
+
+		_, isModified := modified[name]
+		if !isModified && indexEntryMatches(idx, name, e) {
+			continue
+		}
+
+		if merge && modified[name] != merkletrie.Insert {
+			m, err := w.mergeLocalChanges(head, e, idx, conv, modified[name], name, label)
+			if err != nil {
+				return nil, err
+			}
+
+			if m != nil {
+				merges[name] = m
+				continue
+			}
+		}
+
+		conflicts = append(conflicts, name)
+	}
+
+	if len(conflicts) != 0 {
+		sort.Strings(conflicts)
+		return nil, &CheckoutConflictError{Paths: conflicts}
+	}
+
+	if err := w.checkoutChanges(clean, t, idx, conv); err != nil {
+		return nil, err
+	}
+
+	var merged []string
+	for name, m := range merges {
+		if err := w.writeMergeResult(name, m, idx, conv); err != nil {
+			return nil, err
+		}
+
+		if m.conflict {
+			merged = append(merged, name)
+		}
+	}
+
+	sort.Strings(merged)
+	setIndexVersion(idx)
+	return merged, w.r.Storer.SetIndex(idx)
+}
+
+// checkoutChanges applies the changes between HEAD and the tree t to the
+// index and the worktree, the files excluded by the sparse checkout are only
+// updated in the index.
+func (w *Worktree) checkoutChanges(changes merkletrie.Changes, t *object.Tree, idx *index.Index, conv *contentConverter) error {
+	sparse, err := w.sparseCheckout()
+	if err != nil {
+		return err
+	}
+
+	for _, ch := range changes {
+		name := nameFromAction(&ch)
+		a, err := ch.Action()
+		if err != nil {
+			return err
+		}
+
+		if a == merkletrie.Delete {
+			_, _ = idx.Remove(name)
+		}
+
+		if sparse.Match(name) {
+			if err := w.checkoutChange(ch, t, idx, conv); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		if a == merkletrie.Delete {
+			continue
+		}
+
+		e, err := t.FindEntry(name)
+		if err != nil {
+			return err
+		}
+
+		_, _ = idx.Remove(name)
+		idx.Entries = append(idx.Entries, &index.Entry{
+			Name:         name,
+			Hash:         e.Hash,
+			Mode:         e.Mode,
+			SkipWorktree: true,
+		})
+	}
+
+	return nil
+}
+
+// mergeLocalChanges three-way merges the local changes to the file name, from
+// the tree of HEAD, into the entry e. Returns nil if the changes can't be
+// merged: the file is missing in any of the trees or in the worktree, is not
+// a regular file, or is binary.
+func (w *Worktree) mergeLocalChanges(
+	head *object.Tree,
+	e *object.TreeEntry,
+	idx *index.Index,
+	conv *contentConverter,
+	a merkletrie.Action,
+	name, label string,
+) (*mergeResult, error) {
+	if head == nil || e == nil || a == merkletrie.Delete || !isRegularMode(e.Mode) {
+		return nil, nil
+	}
+
+	if _, err := idx.Entry(name); err != nil {
+		return nil, nil
+	}
+
+	base, err := findTreeEntry(head, name)
+	if err != nil || base == nil || !isRegularMode(base.Mode) {
+		return nil, err
+	}
+
+	baseContent, err := w.readBlob(base.Hash)
+	if err != nil {
+		return nil, err
+	}
+
+	theirs, err := w.readBlob(e.Hash)
+	if err != nil {
+		return nil, err
+	}
+
+	ours, err := w.cleanFileContent(name, conv)
+	if err != nil {
+		return nil, err
+	}
+
+	if isBinary(baseContent) || isBinary(theirs) || isBinary(ours) {
+		return nil, nil
+	}
+
+	merged, conflict := diff.Merge(string(baseContent), string(theirs), string(ours), label, localLabel)
+	return &mergeResult{entry: e, content: []byte(merged), conflict: conflict}, nil
+}
+
+// writeMergeResult writes the merged content to the worktree, the index entry
+// is set to the file being checked out.
+func (w *Worktree) writeMergeResult(name string, m *mergeResult, idx *index.Index, conv *contentConverter) (err error) {
+	content, err := conv.smudge(name, m.content)
+	if err != nil {
+		return err
+	}
+
+	mode, err := m.entry.Mode.ToOSFileMode()
+	if err != nil {
+		return err
+	}
+
+	if err := w.Filesystem.Remove(name); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	f, err := w.Filesystem.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode.Perm())
+	if err != nil {
+		return err
+	}
+
+	defer ioutil.CheckClose(f, &err)
+
+	if _, err = f.Write(content); err != nil {
+		return err
+	}
+
+	_, _ = idx.Remove(name)
+	idx.Entries = append(idx.Entries, &index.Entry{
+		Name: name,
+		Hash: m.entry.Hash,
+		Mode: m.entry.Mode,
+	})
+
+	return nil
+}
+
+// cleanFileContent returns the content of the file name of the worktree, as
+// it would be added to the index.
+func (w *Worktree) cleanFileContent(name string, conv *contentConverter) (content []byte, err error) {
+	f, err := w.Filesystem.Open(name)
+	if err != nil {
+		return nil, err
+	}
+
+	defer ioutil.CheckClose(f, &err)
+
+	if content, err = stdioutil.ReadAll(f); err != nil {
+		return nil, err
+	}
+
+	clean, err := conv.cleanFunc(name)
+	if err != nil || clean == nil {
+		return content, err
+	}
+
+	return clean(content)
+}
+
+func (w *Worktree) readBlob(h plumbing.Hash) (content []byte, err error) {
+	blob, err := w.r.BlobObject(h)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := blob.Reader()
+	if err != nil {
+		return nil, err
+	}
+
+	defer ioutil.CheckClose(r, &err)
+	return stdioutil.ReadAll(r)
+}
+
+// headTree returns the tree of HEAD, nil if HEAD doesn't point to a commit.
+func (w *Worktree) headTree() (*object.Tree, error) {
+	ref, err := w.r.Head()
+	if err == plumbing.ErrReferenceNotFound {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return w.getTreeFromCommitHash(ref.Hash())
+}
+
+// findTreeEntry returns the entry name of the tree t, nil if not found.
+func findTreeEntry(t *object.Tree, name string) (*object.TreeEntry, error) {
+	e, err := t.FindEntry(name)
+	if err == object.ErrEntryNotFound || err == object.ErrDirectoryNotFound {
+		return nil, nil
+	}
+
+	return e, err
+}
+
+// indexEntryMatches returns true if the index entry name matches the tree
+// entry e, or both are missing.
+func indexEntryMatches(idx *index.Index, name string, e *object.TreeEntry) bool {
+	ie, err := idx.Entry(name)
+	if err != nil {
+		return e == nil
+	}
+
+	return e != nil && ie.Hash == e.Hash && ie.Mode == e.Mode
+}
+
+func isRegularMode(m filemode.FileMode) bool {
+	return m == filemode.Regular || m == filemode.Executable || m == filemode.Deprecated
+}
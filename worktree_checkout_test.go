@@ -0,0 +1,138 @@
+package git
+
+import (
+	"gopkg.in/src-d/go-git.v4/plumbing"
+
+	. "gopkg.in/check.v1"
+	"gopkg.in/src-d/go-billy.v4/util"
+)
+
+// e8d3ffa adds README and deletes vendor/foo.go from master
+var branchCommit = plumbing.NewHash("e8d3ffab552895c19b9fcf7aa264d277cde33881")
+
+func (s *WorktreeSuite) TestCheckoutKeepLocalChanges(c *C) {
+	w, fs := s.newWorktree(c)
+
+	c.Assert(util.WriteFile(fs, "LICENSE", []byte("foo"), 0644), IsNil)
+	c.Assert(util.WriteFile(fs, "CHANGELOG", []byte("bar"), 0644), IsNil)
+	_, err := w.Add("CHANGELOG")
+	c.Assert(err, IsNil)
+
+	err = w.Checkout(&CheckoutOptions{Hash: branchCommit})
+	c.Assert(err, IsNil)
+
+	head, err := w.r.Head()
+	c.Assert(err, IsNil)
+	c.Assert(head.Hash(), Equals, branchCommit)
+
+	_, err = fs.Stat("README")
+	c.Assert(err, IsNil)
+	_, err = fs.Stat("vendor/foo.go")
+	c.Assert(err, NotNil)
+
+	c.Assert(fileContent(c, fs, "LICENSE"), Equals, "foo")
+
+	status, err := w.Status()
+	c.Assert(err, IsNil)
+	c.Assert(status, HasLen, 2)
+	c.Assert(status.File("LICENSE").Worktree, Equals, Modified)
+	c.Assert(status.File("CHANGELOG").Staging, Equals, Modified)
+}
+
+func (s *WorktreeSuite) TestCheckoutConflict(c *C) {
+	w, fs := s.newWorktree(c)
+
+	c.Assert(util.WriteFile(fs, "vendor/foo.go", []byte("foo"), 0644), IsNil)
+	c.Assert(util.WriteFile(fs, "README", []byte("bar"), 0644), IsNil)
+
+	err := w.Checkout(&CheckoutOptions{Hash: branchCommit})
+	c.Assert(err, DeepEquals, &CheckoutConflictError{Paths: []string{"README", "vendor/foo.go"}})
+	c.Assert(err, ErrorMatches, "local changes would be overwritten by checkout: README, vendor/foo.go")
+
+	head, err := w.r.Head()
+	c.Assert(err, IsNil)
+	c.Assert(head.Name(), Equals, plumbing.Master)
+
+	c.Assert(fileContent(c, fs, "vendor/foo.go"), Equals, "foo")
+	c.Assert(fileContent(c, fs, "README"), Equals, "bar")
+}
+
+func (s *WorktreeSuite) TestCheckoutIndexMatchesTarget(c *C) {
+	w, fs := s.newWorktree(c)
+
+	_, err := w.Remove("vendor/foo.go")
+	c.Assert(err, IsNil)
+
+	err = w.Checkout(&CheckoutOptions{Hash: branchCommit})
+	c.Assert(err, IsNil)
+
+	_, err = fs.Stat("vendor/foo.go")
+	c.Assert(err, NotNil)
+
+	status, err := w.Status()
+	c.Assert(err, IsNil)
+	c.Assert(status.IsClean(), Equals, true)
+}
+
+func (s *WorktreeSuite) TestCheckoutMerge(c *C) {
+	w, fs := s.newInitWorktree(c, map[string]string{"foo": "a\nb\nc\n", "bar": "a\nb\nc\n"})
+
+	err := w.Checkout(&CheckoutOptions{Branch: "refs/heads/other", Create: true})
+	c.Assert(err, IsNil)
+	c.Assert(util.WriteFile(fs, "foo", []byte("a\nb\nC\n"), 0644), IsNil)
+	c.Assert(util.WriteFile(fs, "bar", []byte("a\nb\nC\n"), 0644), IsNil)
+	_, err = w.Commit("foo\n", &CommitOptions{All: true, Author: defaultSignature()})
+	c.Assert(err, IsNil)
+	err = w.Checkout(&CheckoutOptions{Branch: plumbing.Master})
+	c.Assert(err, IsNil)
+
+	c.Assert(util.WriteFile(fs, "foo", []byte("A\nb\nc\n"), 0644), IsNil)
+
+	err = w.Checkout(&CheckoutOptions{Branch: "refs/heads/other"})
+	c.Assert(err, FitsTypeOf, &CheckoutConflictError{})
+
+	err = w.Checkout(&CheckoutOptions{Branch: "refs/heads/other", Merge: true})
+	c.Assert(err, IsNil)
+	c.Assert(fileContent(c, fs, "foo"), Equals, "A\nb\nC\n")
+	c.Assert(fileContent(c, fs, "bar"), Equals, "a\nb\nC\n")
+
+	head, err := w.r.Head()
+	c.Assert(err, IsNil)
+	c.Assert(head.Name(), Equals, plumbing.ReferenceName("refs/heads/other"))
+
+	status, err := w.Status()
+	c.Assert(err, IsNil)
+	c.Assert(status, HasLen, 1)
+	c.Assert(status.File("foo").Staging, Equals, Unmodified)
+	c.Assert(status.File("foo").Worktree, Equals, Modified)
+}
+
+func (s *WorktreeSuite) TestCheckoutMergeConflict(c *C) {
+	w, fs := s.newInitWorktree(c, map[string]string{"foo": "a\nb\nc\n", "bar": "a\nb\nc\n"})
+
+	err := w.Checkout(&CheckoutOptions{Branch: "refs/heads/other", Create: true})
+	c.Assert(err, IsNil)
+	c.Assert(util.WriteFile(fs, "foo", []byte("a\nb\nC\n"), 0644), IsNil)
+	c.Assert(util.WriteFile(fs, "bar", []byte("a\nb\nC\n"), 0644), IsNil)
+	_, err = w.Commit("foo\n", &CommitOptions{All: true, Author: defaultSignature()})
+	c.Assert(err, IsNil)
+	err = w.Checkout(&CheckoutOptions{Branch: plumbing.Master})
+	c.Assert(err, IsNil)
+
+	c.Assert(util.WriteFile(fs, "foo", []byte("a\nb\nX\n"), 0644), IsNil)
+
+	err = w.Checkout(&CheckoutOptions{Branch: "refs/heads/other", Merge: true})
+	c.Assert(err, DeepEquals, &CheckoutConflictError{Paths: []string{"foo"}, Merged: true})
+	c.Assert(fileContent(c, fs, "foo"), Equals, "a\nb\n<<<<<<< other\nC\n=======\nX\n>>>>>>> local\n")
+
+	head, err := w.r.Head()
+	c.Assert(err, IsNil)
+	c.Assert(head.Name(), Equals, plumbing.ReferenceName("refs/heads/other"))
+}
+
+func (s *WorktreeSuite) TestCheckoutForceMergeExclusive(c *C) {
+	w, _ := s.newWorktree(c)
+
+	err := w.Checkout(&CheckoutOptions{Hash: branchCommit, Force: true, Merge: true})
+	c.Assert(err, Equals, ErrForceMergeExclusive)
+}
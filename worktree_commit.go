@@ -6,7 +6,6 @@ import (
 	"sort"
 	"strings"
 
-	"golang.org/x/crypto/openpgp"
 	"gopkg.in/src-d/go-git.v4/plumbing"
 	"gopkg.in/src-d/go-git.v4/plumbing/filemode"
 	"gopkg.in/src-d/go-git.v4/plumbing/format/index"
@@ -44,7 +43,29 @@ func (w *Worktree) Commit(msg string, opts *CommitOptions) (plumbing.Hash, error
 		return plumbing.ZeroHash, err
 	}
 
-	commit, err := w.buildCommitObject(msg, opts, tree)
+	if opts.DisallowEmpty {
+		if err := w.checkEmptyCommit(tree, opts.parents()); err != nil {
+			return plumbing.ZeroHash, err
+		}
+	}
+
+	var amended *object.Commit
+	var encoding string
+	if opts.Amend {
+		if amended, err = w.headCommit(); err != nil {
+			return plumbing.ZeroHash, err
+		}
+
+		if msg == "" {
+			msg, encoding = amended.Message, amended.Encoding
+		}
+	}
+
+	if msg, err = w.cleanupCommitMessage(msg, opts.Cleanup); err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	commit, err := w.buildCommitObject(msg, encoding, opts, tree, amended)
 	if err != nil {
 		return plumbing.ZeroHash, err
 	}
@@ -52,6 +73,148 @@ func (w *Worktree) Commit(msg string, opts *CommitOptions) (plumbing.Hash, error
 	return commit, w.updateHEAD(commit)
 }
 
+// checkEmptyCommit returns ErrEmptyCommit if the tree is the tree of the only
+// parent, or the empty tree for a commit without parents.
+func (w *Worktree) checkEmptyCommit(tree plumbing.Hash, parents []plumbing.Hash) error {
+	if len(parents) > 1 {
+		return nil
+	}
+
+	var parentTree plumbing.Hash
+	if len(parents) == 0 {
+		obj := &plumbing.MemoryObject{}
+		if err := (&object.Tree{}).Encode(obj); err != nil {
+			return err
+		}
+
+		parentTree = obj.Hash()
+	} else {
+		parent, err := w.r.CommitObject(parents[0])
+		if err != nil {
+			return err
+		}
+
+		parentTree = parent.TreeHash
+	}
+
+	if tree == parentTree {
+		return ErrEmptyCommit
+	}
+
+	return nil
+}
+
+func (w *Worktree) headCommit() (*object.Commit, error) {
+	head, err := w.r.Head()
+	if err != nil {
+		return nil, err
+	}
+
+	return w.r.CommitObject(head.Hash())
+}
+
+const (
+	defaultCommentChar = "#"
+	autoCommentChar    = "auto"
+	autoCommentChars   = "#;@!$%^&|:"
+	scissorsLine       = " ------------------------ >8 ------------------------"
+)
+
+// cleanupCommitMessage cleans up the message of a commit, as git commit
+// --cleanup does. ErrEmptyCommitMessage is returned if the message cleaned up
+// is empty.
+func (w *Worktree) cleanupCommitMessage(msg string, mode CleanupMode) (string, error) {
+	if mode == VerbatimCleanup {
+		return msg, nil
+	}
+
+	var comment string
+	if mode == StripCleanup || mode == ScissorsCleanup {
+		cfg, err := w.r.Config()
+		if err != nil {
+			return "", err
+		}
+
+		comment = commentChar(cfg.Core.CommentChar, msg)
+	}
+
+	switch mode {
+	case ScissorsCleanup:
+		msg = stripSpace(cutScissors(msg, comment), "")
+	case StripCleanup:
+		msg = stripSpace(msg, comment)
+	default:
+		msg = stripSpace(msg, "")
+	}
+
+	if msg == "" {
+		return "", ErrEmptyCommitMessage
+	}
+
+	return msg, nil
+}
+
+// commentChar returns the comment character configured, if auto the first
+// candidate not starting any line of the message is chosen.
+func commentChar(configured, msg string) string {
+	switch configured {
+	case "":
+		return defaultCommentChar
+	case autoCommentChar:
+		for _, c := range autoCommentChars {
+			if !strings.HasPrefix(msg, string(c)) && !strings.Contains(msg, "\n"+string(c)) {
+				return string(c)
+			}
+		}
+
+		return defaultCommentChar
+	default:
+		return configured
+	}
+}
+
+// cutScissors truncates the message from the scissors line.
+func cutScissors(msg, comment string) string {
+	scissors := comment + scissorsLine + "\n"
+	if strings.HasPrefix(msg, scissors) {
+		return ""
+	}
+
+	if i := strings.Index(msg, "\n"+scissors); i != -1 {
+		return msg[:i+1]
+	}
+
+	return msg
+}
+
+// stripSpace strips the trailing whitespace of the lines, the leading and
+// trailing empty lines, and collapses the consecutive empty lines. The lines
+// starting with comment are removed, if not empty.
+func stripSpace(msg, comment string) string {
+	var b bytes.Buffer
+	var empty bool
+	for _, line := range strings.Split(msg, "\n") {
+		if comment != "" && strings.HasPrefix(line, comment) {
+			continue
+		}
+
+		line = strings.TrimRight(line, " \t\r\v\f")
+		if line == "" {
+			empty = true
+			continue
+		}
+
+		if empty && b.Len() != 0 {
+			b.WriteString("\n")
+		}
+
+		empty = false
+		b.WriteString(line + "\n")
+	}
+
+	return b.String()
+}
+
 func (w *Worktree) autoAddModifiedAndDeleted() error {
 	s, err := w.Status()
 	if err != nil {
@@ -86,17 +249,28 @@ func (w *Worktree) updateHEAD(commit plumbing.Hash) error {
 	return w.r.Storer.SetReference(ref)
 }
 
-func (w *Worktree) buildCommitObject(msg string, opts *CommitOptions, tree plumbing.Hash) (plumbing.Hash, error) {
+func (w *Worktree) buildCommitObject(msg, encoding string, opts *CommitOptions, tree plumbing.Hash, amended *object.Commit) (plumbing.Hash, error) {
 	commit := &object.Commit{
 		Author:       *opts.Author,
 		Committer:    *opts.Committer,
 		Message:      msg,
+		Encoding:     encoding,
 		TreeHash:     tree,
-		ParentHashes: opts.Parents,
+		ParentHashes: opts.parents(),
+	}
+
+	// the extra headers of the amended commit are kept, but the signatures
+	if amended != nil {
+		commit.MergeTag = amended.MergeTag
+		for _, h := range amended.ExtraHeaders {
+			if !strings.HasPrefix(h.Key, "gpgsig") {
+				commit.ExtraHeaders = append(commit.ExtraHeaders, h)
+			}
+		}
 	}
 
-	if opts.SignKey != nil {
-		sig, err := w.buildCommitSignature(commit, opts.SignKey)
+	if opts.Signer != nil {
+		sig, err := w.buildCommitSignature(commit, opts.Signer)
 		if err != nil {
 			return plumbing.ZeroHash, err
 		}
@@ -110,7 +284,7 @@ func (w *Worktree) buildCommitObject(msg string, opts *CommitOptions, tree plumb
 	return w.r.Storer.SetEncodedObject(obj)
 }
 
-func (w *Worktree) buildCommitSignature(commit *object.Commit, signKey *openpgp.Entity) (string, error) {
+func (w *Worktree) buildCommitSignature(commit *object.Commit, signer object.Signer) (string, error) {
 	encoded := &plumbing.MemoryObject{}
 	if err := commit.Encode(encoded); err != nil {
 		return "", err
@@ -119,11 +293,11 @@ func (w *Worktree) buildCommitSignature(commit *object.Commit, signKey *openpgp.
 	if err != nil {
 		return "", err
 	}
-	var b bytes.Buffer
-	if err := openpgp.ArmoredDetachSign(&b, signKey, r, nil); err != nil {
+	sig, err := signer.Sign(r)
+	if err != nil {
 		return "", err
 	}
-	return b.String(), nil
+	return string(sig), nil
 }
 
 // buildTreeHelper converts a given index.Index file into multiple git objects
@@ -145,6 +319,11 @@ func (h *buildTreeHelper) BuildTree(idx *index.Index) (plumbing.Hash, error) {
 	h.entries = map[string]*object.TreeEntry{}
 
 	for _, e := range idx.Entries {
+		// the files added with intent to add are not committed
+		if e.IntentToAdd {
+			continue
+		}
+
 		if err := h.commitIndexEntry(e); err != nil {
 			return plumbing.ZeroHash, err
 		}
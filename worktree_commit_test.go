@@ -2,6 +2,7 @@ package git
 
 import (
 	"bytes"
+	"crypto/rand"
 	"io/ioutil"
 	"os"
 	"os/exec"
@@ -10,14 +11,17 @@ import (
 
 	"gopkg.in/src-d/go-git.v4/plumbing"
 	"gopkg.in/src-d/go-git.v4/plumbing/cache"
+	"gopkg.in/src-d/go-git.v4/plumbing/format/sshsig"
 	"gopkg.in/src-d/go-git.v4/plumbing/object"
 	"gopkg.in/src-d/go-git.v4/plumbing/storer"
 	"gopkg.in/src-d/go-git.v4/storage/filesystem"
 	"gopkg.in/src-d/go-git.v4/storage/memory"
 
+	"golang.org/x/crypto/ed25519"
 	"golang.org/x/crypto/openpgp"
 	"golang.org/x/crypto/openpgp/armor"
 	"golang.org/x/crypto/openpgp/errors"
+	"golang.org/x/crypto/ssh"
 	. "gopkg.in/check.v1"
 	"gopkg.in/src-d/go-billy.v4/memfs"
 	"gopkg.in/src-d/go-billy.v4/osfs"
@@ -182,6 +186,41 @@ func (s *WorktreeSuite) TestCommitSign(c *C) {
 	c.Assert(actual.PrimaryKey, DeepEquals, key.PrimaryKey)
 }
 
+func (s *WorktreeSuite) TestCommitSignSSH(c *C) {
+	r, err := Init(memory.NewStorage(), memfs.New())
+	c.Assert(err, IsNil)
+
+	w, err := r.Worktree()
+	c.Assert(err, IsNil)
+
+	util.WriteFile(w.Filesystem, "foo", []byte("foo"), 0644)
+	_, err = w.Add("foo")
+	c.Assert(err, IsNil)
+
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	c.Assert(err, IsNil)
+	signer, err := sshsig.NewSignerFromKey(key)
+	c.Assert(err, IsNil)
+
+	hash, err := w.Commit("foo\n", &CommitOptions{
+		Author: defaultSignature(),
+		Signer: sshsig.NewSigner(signer),
+	})
+	c.Assert(err, IsNil)
+
+	commit, err := r.CommitObject(hash)
+	c.Assert(err, IsNil)
+	c.Assert(strings.HasPrefix(commit.PGPSignature, "-----BEGIN SSH SIGNATURE-----"), Equals, true)
+
+	allowed := "foo@foo.foo " + string(ssh.MarshalAuthorizedKey(signer.PublicKey()))
+	verifier, err := sshsig.NewVerifier(strings.NewReader(allowed))
+	c.Assert(err, IsNil)
+
+	principal, err := commit.VerifySignature(verifier)
+	c.Assert(err, IsNil)
+	c.Assert(principal, Equals, "foo@foo.foo")
+}
+
 func (s *WorktreeSuite) TestCommitSignBadKey(c *C) {
 	fs := memfs.New()
 	storage := memory.NewStorage()
@@ -202,6 +241,209 @@ func (s *WorktreeSuite) TestCommitSignBadKey(c *C) {
 	c.Assert(err, Equals, errors.InvalidArgumentError("signing key is encrypted"))
 }
 
+func (s *WorktreeSuite) TestCommitAmend(c *C) {
+	fs := memfs.New()
+	r, err := Init(memory.NewStorage(), fs)
+	c.Assert(err, IsNil)
+
+	w, err := r.Worktree()
+	c.Assert(err, IsNil)
+
+	util.WriteFile(fs, "foo", []byte("foo"), 0644)
+	_, err = w.Add("foo")
+	c.Assert(err, IsNil)
+	first, err := w.Commit("foo\n", &CommitOptions{Author: defaultSignature()})
+	c.Assert(err, IsNil)
+
+	util.WriteFile(fs, "bar", []byte("bar"), 0644)
+	_, err = w.Add("bar")
+	c.Assert(err, IsNil)
+	second, err := w.Commit("bar\n", &CommitOptions{Author: defaultSignature()})
+	c.Assert(err, IsNil)
+
+	obj, err := r.CommitObject(second)
+	c.Assert(err, IsNil)
+	obj.Encoding = "ISO-8859-1"
+	obj.ExtraHeaders = []object.ExtraHeader{
+		{Key: "foo", Value: "bar"},
+		{Key: "gpgsig-sha256", Value: "qux"},
+	}
+
+	encoded := r.Storer.NewEncodedObject()
+	c.Assert(obj.Encode(encoded), IsNil)
+	second, err = r.Storer.SetEncodedObject(encoded)
+	c.Assert(err, IsNil)
+	c.Assert(w.updateHEAD(second), IsNil)
+
+	util.WriteFile(fs, "bar", []byte("qux"), 0644)
+	committer := &object.Signature{Name: "qux", Email: "qux@qux.qux", When: time.Now()}
+	amended, err := w.Commit("", &CommitOptions{
+		All:       true,
+		Amend:     true,
+		Committer: committer,
+	})
+	c.Assert(err, IsNil)
+
+	commit, err := r.CommitObject(amended)
+	c.Assert(err, IsNil)
+	c.Assert(commit.ParentHashes, DeepEquals, []plumbing.Hash{first})
+	c.Assert(commit.Message, Equals, "bar\n")
+	c.Assert(commit.Encoding, Equals, "ISO-8859-1")
+	c.Assert(commit.Author.Name, Equals, defaultSignature().Name)
+	c.Assert(commit.Committer.Name, Equals, "qux")
+	c.Assert(commit.ExtraHeaders, DeepEquals, []object.ExtraHeader{{Key: "foo", Value: "bar"}})
+
+	head, err := r.Head()
+	c.Assert(err, IsNil)
+	c.Assert(head.Hash(), Equals, amended)
+
+	opts := &CommitOptions{Amend: true, Author: committer}
+	for _, msg := range []string{"qux\n", "baz\n"} {
+		amended, err = w.Commit(msg, opts)
+		c.Assert(err, IsNil)
+		c.Assert(opts.Parents, HasLen, 0)
+
+		commit, err = r.CommitObject(amended)
+		c.Assert(err, IsNil)
+		c.Assert(commit.ParentHashes, DeepEquals, []plumbing.Hash{first})
+		c.Assert(commit.Message, Equals, msg)
+		c.Assert(commit.Encoding, Equals, "")
+		c.Assert(commit.Author.Name, Equals, "qux")
+	}
+}
+
+func (s *WorktreeSuite) TestCommitAmendInvalidOptions(c *C) {
+	fs := memfs.New()
+	r, err := Init(memory.NewStorage(), fs)
+	c.Assert(err, IsNil)
+
+	w, err := r.Worktree()
+	c.Assert(err, IsNil)
+
+	_, err = w.Commit("foo\n", &CommitOptions{Amend: true, Author: defaultSignature()})
+	c.Assert(err, Equals, ErrNothingToAmend)
+
+	util.WriteFile(fs, "foo", []byte("foo"), 0644)
+	_, err = w.Add("foo")
+	c.Assert(err, IsNil)
+	hash, err := w.Commit("foo\n", &CommitOptions{Author: defaultSignature()})
+	c.Assert(err, IsNil)
+
+	_, err = w.Commit("foo\n", &CommitOptions{
+		Amend:   true,
+		Author:  defaultSignature(),
+		Parents: []plumbing.Hash{hash},
+	})
+	c.Assert(err, Equals, ErrAmendParentsExclusive)
+
+	_, err = w.Commit("foo\n", &CommitOptions{Amend: true})
+	c.Assert(err, Equals, ErrMissingCommitter)
+}
+
+func (s *WorktreeSuite) TestCommitEmpty(c *C) {
+	fs := memfs.New()
+	r, err := Init(memory.NewStorage(), fs)
+	c.Assert(err, IsNil)
+
+	w, err := r.Worktree()
+	c.Assert(err, IsNil)
+
+	_, err = w.Commit("foo\n", &CommitOptions{Author: defaultSignature(), DisallowEmpty: true})
+	c.Assert(err, Equals, ErrEmptyCommit)
+
+	util.WriteFile(fs, "foo", []byte("foo"), 0644)
+	_, err = w.Add("foo")
+	c.Assert(err, IsNil)
+	first, err := w.Commit("foo\n", &CommitOptions{Author: defaultSignature(), DisallowEmpty: true})
+	c.Assert(err, IsNil)
+
+	_, err = w.Commit("bar\n", &CommitOptions{Author: defaultSignature(), DisallowEmpty: true})
+	c.Assert(err, Equals, ErrEmptyCommit)
+
+	head, err := r.Head()
+	c.Assert(err, IsNil)
+	c.Assert(head.Hash(), Equals, first)
+
+	second, err := w.Commit("bar\n", &CommitOptions{Author: defaultSignature()})
+	c.Assert(err, IsNil)
+
+	commit, err := r.CommitObject(second)
+	c.Assert(err, IsNil)
+	c.Assert(commit.ParentHashes, DeepEquals, []plumbing.Hash{first})
+
+	merge, err := w.Commit("merge\n", &CommitOptions{
+		Author:        defaultSignature(),
+		Parents:       []plumbing.Hash{first, second},
+		DisallowEmpty: true,
+	})
+	c.Assert(err, IsNil)
+	c.Assert(merge.IsZero(), Equals, false)
+}
+
+func (s *WorktreeSuite) TestCommitCleanup(c *C) {
+	msg := "\n\nfoo  \n\n\n# comment\nbar\t\n; other\n\n" +
+		"#" + scissorsLine + "\n" +
+		"# everything below is removed\nqux\n\n"
+
+	for _, t := range []struct {
+		mode        CleanupMode
+		commentChar string
+		expected    string
+	}{
+		{VerbatimCleanup, "", msg},
+		{WhitespaceCleanup, "", "foo\n\n# comment\nbar\n; other\n\n#" + scissorsLine + "\n# everything below is removed\nqux\n"},
+		{StripCleanup, "", "foo\n\nbar\n; other\n\nqux\n"},
+		{StripCleanup, ";", "foo\n\n# comment\nbar\n\n#" + scissorsLine + "\n# everything below is removed\nqux\n"},
+		{StripCleanup, "auto", "foo\n\n# comment\nbar\n; other\n\n#" + scissorsLine + "\n# everything below is removed\nqux\n"},
+		{ScissorsCleanup, "", "foo\n\n# comment\nbar\n; other\n"},
+		{ScissorsCleanup, ";", "foo\n\n# comment\nbar\n; other\n\n#" + scissorsLine + "\n# everything below is removed\nqux\n"},
+	} {
+		fs := memfs.New()
+		r, err := Init(memory.NewStorage(), fs)
+		c.Assert(err, IsNil)
+
+		if t.commentChar != "" {
+			cfg, err := r.Config()
+			c.Assert(err, IsNil)
+			cfg.Core.CommentChar = t.commentChar
+			c.Assert(r.Storer.SetConfig(cfg), IsNil)
+		}
+
+		w, err := r.Worktree()
+		c.Assert(err, IsNil)
+
+		hash, err := w.Commit(msg, &CommitOptions{
+			Author:  defaultSignature(),
+			Cleanup: t.mode,
+		})
+		c.Assert(err, IsNil)
+
+		commit, err := r.CommitObject(hash)
+		c.Assert(err, IsNil)
+		c.Assert(commit.Message, Equals, t.expected, Commentf("mode %d, commentChar %q", t.mode, t.commentChar))
+	}
+}
+
+func (s *WorktreeSuite) TestCommitCleanupEmptyMessage(c *C) {
+	r, err := Init(memory.NewStorage(), memfs.New())
+	c.Assert(err, IsNil)
+
+	w, err := r.Worktree()
+	c.Assert(err, IsNil)
+
+	opts := &CommitOptions{Author: defaultSignature()}
+	_, err = w.Commit(" \n\n", opts)
+	c.Assert(err, IsNil)
+
+	opts.Cleanup = StripCleanup
+	_, err = w.Commit(" \n# foo\n\n", opts)
+	c.Assert(err, Equals, ErrEmptyCommitMessage)
+
+	opts.Cleanup = CleanupMode(42)
+	_, err = w.Commit("foo\n", opts)
+	c.Assert(err, Equals, ErrInvalidCommitCleanup)
+}
+
 func (s *WorktreeSuite) TestCommitTreeSort(c *C) {
 	path, err := ioutil.TempDir(os.TempDir(), "test-commit-tree-sort")
 	c.Assert(err, IsNil)
@@ -0,0 +1,190 @@
+package git
+
+import (
+	"bytes"
+	stdioutil "io/ioutil"
+
+	"gopkg.in/src-d/go-git.v4/plumbing/format/gitattributes"
+	"gopkg.in/src-d/go-git.v4/plumbing/format/index"
+	"gopkg.in/src-d/go-git.v4/utils/ioutil"
+	"gopkg.in/src-d/go-git.v4/utils/merkletrie/filesystem"
+)
+
+const filterAttr = "filter"
+
+// Filter is a filter driver, converting the content of the files with the
+// filter=<name> attribute, being name the key of the filter in
+// Worktree.Filters. lfs.Filter is the filter driver of Git LFS.
+type Filter interface {
+	// Clean converts the content of the file at path in the worktree, to
+	// the content stored in the blob when the file is added to the index.
+	Clean(path string, content []byte) ([]byte, error)
+	// Smudge converts the content of the blob, to the content of the file
+	// at path written to the worktree on checkout.
+	Smudge(path string, content []byte) ([]byte, error)
+}
+
+// DryRunFilter is a Filter whose Clean has side effects, e.g. storing the
+// content elsewhere, as lfs.Filter does. DryClean is used instead of Clean
+// when the files are only compared with the index, e.g. by Status.
+type DryRunFilter interface {
+	Filter
+	// DryClean returns the content Clean would return, without its side
+	// effects.
+	DryClean(path string, content []byte) ([]byte, error)
+}
+
+// contentConverter applies the conversions defined by the attributes and the
+// config to the content of the files, when they are added to the index (clean)
+// or checked out to the worktree (smudge).
+type contentConverter struct {
+	r        *Repository
+	idx      *index.Index
+	attrs    *worktreeAttributes
+	filters  map[string]Filter
+	autocrlf string
+	eol      string
+}
+
+func (w *Worktree) newContentConverter() (*contentConverter, error) {
+	cfg, err := w.r.Config()
+	if err != nil {
+		return nil, err
+	}
+
+	attrs, err := w.attributesMatcher()
+	if err != nil {
+		return nil, err
+	}
+
+	idx, err := w.r.Storer.Index()
+	if err != nil {
+		return nil, err
+	}
+
+	return &contentConverter{
+		r:        w.r,
+		idx:      idx,
+		attrs:    attrs,
+		filters:  w.Filters,
+		autocrlf: cfg.Core.AutoCRLF,
+		eol:      cfg.Core.EOL,
+	}, nil
+}
+
+func (c *contentConverter) attributes(path string) (map[string]gitattributes.Attribute, error) {
+	return c.attrs.Match(splitPath(path), []string{textAttr, eolAttr, filterAttr})
+}
+
+// filter returns the filter driver assigned to a file, given its attributes,
+// nil if none.
+func (c *contentConverter) filter(attrs map[string]gitattributes.Attribute) Filter {
+	a, ok := attrs[filterAttr]
+	if !ok || !a.IsValueSet() {
+		return nil
+	}
+
+	return c.filters[a.Value()]
+}
+
+// cleanFunc returns the conversion of the file at path compared with the
+// index, as addFunc does but with the DryClean of the filter driver, if it
+// is a DryRunFilter.
+func (c *contentConverter) cleanFunc(path string) (filesystem.CleanFunc, error) {
+	return c.clean(path, true)
+}
+
+// addFunc returns the conversion applied to the file at path when it is
+// added to the index, nil if no conversion is required. The filter driver is
+// applied before the line ending conversion.
+func (c *contentConverter) addFunc(path string) (filesystem.CleanFunc, error) {
+	return c.clean(path, false)
+}
+
+func (c *contentConverter) clean(path string, dryRun bool) (filesystem.CleanFunc, error) {
+	attrs, err := c.attributes(path)
+	if err != nil {
+		return nil, err
+	}
+
+	a, f := c.eolAction(attrs), c.filter(attrs)
+	if a == crlfBinary && f == nil {
+		return nil, nil
+	}
+
+	var clean func(string, []byte) ([]byte, error)
+	if df, ok := f.(DryRunFilter); ok && dryRun {
+		clean = df.DryClean
+	} else if f != nil {
+		clean = f.Clean
+	}
+
+	return func(content []byte) (_ []byte, err error) {
+		if clean != nil {
+			if content, err = clean(path, content); err != nil {
+				return nil, err
+			}
+		}
+
+		return c.cleanEOL(path, a, content)
+	}, nil
+}
+
+// smudge converts the content of the blob of the file at path, to the content
+// written to the worktree. The filter driver is applied after the line ending
+// conversion.
+func (c *contentConverter) smudge(path string, content []byte) ([]byte, error) {
+	attrs, err := c.attributes(path)
+	if err != nil {
+		return nil, err
+	}
+
+	content = c.smudgeEOL(c.eolAction(attrs), content)
+	if f := c.filter(attrs); f != nil {
+		return f.Smudge(path, content)
+	}
+
+	return content, nil
+}
+
+// needsSmudge returns true if the content of the file at path may be
+// converted on checkout.
+func (c *contentConverter) needsSmudge(path string) (bool, error) {
+	attrs, err := c.attributes(path)
+	if err != nil {
+		return false, err
+	}
+
+	return c.outputCRLF(c.eolAction(attrs)) || c.filter(attrs) != nil, nil
+}
+
+// hasCRInIndex returns true if the blob staged for path contains CR.
+func (c *contentConverter) hasCRInIndex(path string) (has bool, err error) {
+	e, err := c.idx.Entry(path)
+	if err == index.ErrEntryNotFound {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, err
+	}
+
+	blob, err := c.r.BlobObject(e.Hash)
+	if err != nil {
+		return false, err
+	}
+
+	r, err := blob.Reader()
+	if err != nil {
+		return false, err
+	}
+
+	defer ioutil.CheckClose(r, &err)
+
+	content, err := stdioutil.ReadAll(r)
+	if err != nil {
+		return false, err
+	}
+
+	return bytes.Contains(content, cr), nil
+}
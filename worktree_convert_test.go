@@ -0,0 +1,126 @@
+package git
+
+import (
+	"bytes"
+
+	"gopkg.in/src-d/go-git.v4/plumbing/lfs"
+
+	. "gopkg.in/check.v1"
+	"gopkg.in/src-d/go-billy.v4/memfs"
+	"gopkg.in/src-d/go-billy.v4/util"
+)
+
+type FilterSuite struct {
+	BaseSuite
+}
+
+var _ = Suite(&FilterSuite{})
+
+// upperFilter stores the content of the files in uppercase and checks them
+// out in lowercase.
+type upperFilter struct{}
+
+func (upperFilter) Clean(path string, content []byte) ([]byte, error) {
+	return bytes.ToUpper(content), nil
+}
+
+func (upperFilter) Smudge(path string, content []byte) ([]byte, error) {
+	return bytes.ToLower(content), nil
+}
+
+func (s *FilterSuite) TestFilter(c *C) {
+	w, fs := s.newInitWorktree(c, nil)
+	setAutoCRLF(c, w.r, "true")
+	w.Filters = map[string]Filter{"upper": upperFilter{}}
+
+	c.Assert(util.WriteFile(fs, ".gitattributes", []byte("*.up filter=upper\n*.none filter=none\n"), 0644), IsNil)
+	c.Assert(util.WriteFile(fs, "foo.up", []byte("foo\r\nbar\r\n"), 0644), IsNil)
+	c.Assert(util.WriteFile(fs, "foo.none", []byte("foo"), 0644), IsNil)
+
+	h, err := w.Add("foo.up")
+	c.Assert(err, IsNil)
+	c.Assert(blobContent(c, w, h), Equals, "FOO\nBAR\n")
+
+	h, err = w.Add("foo.none")
+	c.Assert(err, IsNil)
+	c.Assert(blobContent(c, w, h), Equals, "foo")
+
+	_, err = w.Add(".gitattributes")
+	c.Assert(err, IsNil)
+
+	commit, err := w.Commit("foo\n", &CommitOptions{Author: defaultSignature()})
+	c.Assert(err, IsNil)
+
+	status, err := w.Status()
+	c.Assert(err, IsNil)
+	c.Assert(status.IsClean(), Equals, true)
+
+	c.Assert(fs.Remove("foo.up"), IsNil)
+	err = w.Reset(&ResetOptions{Commit: commit, Mode: HardReset})
+	c.Assert(err, IsNil)
+	c.Assert(fileContent(c, fs, "foo.up"), Equals, "foo\r\nbar\r\n")
+}
+
+func (s *FilterSuite) TestLFS(c *C) {
+	w, fs := s.newInitWorktree(c, nil)
+	local := lfs.NewFilesystemStorage(memfs.New())
+	w.Filters = map[string]Filter{"lfs": lfs.NewFilter(local, nil)}
+
+	c.Assert(util.WriteFile(fs, ".gitattributes", []byte("*.bin filter=lfs diff=lfs merge=lfs -text\n"), 0644), IsNil)
+	c.Assert(util.WriteFile(fs, "foo.bin", []byte("foo\x00"), 0644), IsNil)
+
+	p := lfs.NewPointer([]byte("foo\x00"))
+	h, err := w.Add("foo.bin")
+	c.Assert(err, IsNil)
+	c.Assert(blobContent(c, w, h), Equals, p.String())
+	c.Assert(local.HasObject(p), IsNil)
+
+	_, err = w.Add(".gitattributes")
+	c.Assert(err, IsNil)
+
+	commit, err := w.Commit("foo\n", &CommitOptions{Author: defaultSignature()})
+	c.Assert(err, IsNil)
+
+	status, err := w.Status()
+	c.Assert(err, IsNil)
+	c.Assert(status.IsClean(), Equals, true)
+
+	c.Assert(fs.Remove("foo.bin"), IsNil)
+	err = w.Reset(&ResetOptions{Commit: commit, Mode: HardReset})
+	c.Assert(err, IsNil)
+	c.Assert(fileContent(c, fs, "foo.bin"), Equals, "foo\x00")
+
+	// without the filter the pointer is checked out
+	w.Filters = nil
+	c.Assert(fs.Remove("foo.bin"), IsNil)
+	err = w.Reset(&ResetOptions{Commit: commit, Mode: HardReset})
+	c.Assert(err, IsNil)
+	c.Assert(fileContent(c, fs, "foo.bin"), Equals, p.String())
+}
+
+func (s *FilterSuite) TestLFSStatus(c *C) {
+	w, fs := s.newInitWorktree(c, nil)
+	local := lfs.NewFilesystemStorage(memfs.New())
+	w.Filters = map[string]Filter{"lfs": lfs.NewFilter(local, nil)}
+
+	c.Assert(util.WriteFile(fs, ".gitattributes", []byte("*.bin filter=lfs\n"), 0644), IsNil)
+	c.Assert(util.WriteFile(fs, "foo.bin", []byte("foo"), 0644), IsNil)
+
+	p := lfs.NewPointer([]byte("foo"))
+	_, err := w.Add("foo.bin")
+	c.Assert(err, IsNil)
+	c.Assert(local.HasObject(p), IsNil)
+
+	// the content is only stored when added, not when compared
+	c.Assert(util.WriteFile(fs, "foo.bin", []byte("bar"), 0644), IsNil)
+	status, err := w.Status()
+	c.Assert(err, IsNil)
+	c.Assert(status.File("foo.bin").Worktree, Equals, Modified)
+
+	p = lfs.NewPointer([]byte("bar"))
+	c.Assert(local.HasObject(p), Equals, lfs.ErrObjectNotFound)
+
+	_, err = w.Add("foo.bin")
+	c.Assert(err, IsNil)
+	c.Assert(local.HasObject(p), IsNil)
+}
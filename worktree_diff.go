@@ -0,0 +1,315 @@
+package git
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/filemode"
+	fdiff "gopkg.in/src-d/go-git.v4/plumbing/format/diff"
+	"gopkg.in/src-d/go-git.v4/plumbing/format/index"
+	"gopkg.in/src-d/go-git.v4/utils/diff"
+	"gopkg.in/src-d/go-git.v4/utils/merkletrie"
+
+	dmp "github.com/sergi/go-diff/diffmatchpatch"
+)
+
+// Diff returns the patch of the changes between the index and the worktree,
+// like git diff, or between HEAD and the index if DiffOptions.Staged is set,
+// like git diff --cached. The untracked files are not included. The patch can
+// be encoded with diff.UnifiedEncoder, or applied to the index with
+// ApplyToIndex.
+func (w *Worktree) Diff(o *DiffOptions) (*WorktreePatch, error) {
+	if o == nil {
+		o = &DiffOptions{}
+	}
+
+	if err := o.Validate(); err != nil {
+		return nil, err
+	}
+
+	idx, err := w.r.Storer.Index()
+	if err != nil {
+		return nil, err
+	}
+
+	var files []*diffFilePair
+	if o.Staged {
+		files, err = w.diffStagedFiles(idx, o.Paths)
+	} else {
+		files, err = w.diffUnstagedFiles(idx, o.Paths)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].name < files[j].name
+	})
+
+	p := &WorktreePatch{contextLines: o.ContextLines}
+	for _, f := range files {
+		p.filePatches = append(p.filePatches, newDiffFilePatch(f.from, f.to))
+	}
+
+	return p, nil
+}
+
+// diffFilePair are the sides of a file changed.
+type diffFilePair struct {
+	name     string
+	from, to *diffFile
+}
+
+// diffStagedFiles returns the files changed between HEAD and the index idx,
+// the entries intended to be added are skipped.
+func (w *Worktree) diffStagedFiles(idx *index.Index, paths []string) ([]*diffFilePair, error) {
+	head, err := w.headTree()
+	if err != nil {
+		return nil, err
+	}
+
+	changes, err := w.diffTreeWithStaging(head, false)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []*diffFilePair
+	for _, ch := range changes {
+		name := nameFromAction(&ch)
+		if len(paths) != 0 && !matchPaths(paths, name) {
+			continue
+		}
+
+		pair := &diffFilePair{name: name}
+		if head != nil {
+			e, err := findTreeEntry(head, name)
+			if err != nil {
+				return nil, err
+			}
+
+			if e != nil && e.Mode != filemode.Submodule {
+				if pair.from, err = w.diffBlobFile(name, e.Hash, e.Mode); err != nil {
+					return nil, err
+				}
+			}
+		}
+
+		if e, err := idx.Entry(name); err == nil && !e.IntentToAdd && e.Mode != filemode.Submodule {
+			if pair.to, err = w.diffBlobFile(name, e.Hash, e.Mode); err != nil {
+				return nil, err
+			}
+		}
+
+		if pair.from != nil || pair.to != nil {
+			files = append(files, pair)
+		}
+	}
+
+	return files, nil
+}
+
+// diffUnstagedFiles returns the files changed between the index idx and the
+// worktree, the entries intended to be added are diffed as new files.
+func (w *Worktree) diffUnstagedFiles(idx *index.Index, paths []string) ([]*diffFilePair, error) {
+	changes, err := w.diffStagingWithWorktree(false)
+	if err != nil {
+		return nil, err
+	}
+
+	conv, err := w.newContentConverter()
+	if err != nil {
+		return nil, err
+	}
+
+	var files []*diffFilePair
+	for _, ch := range changes {
+		name := nameFromAction(&ch)
+		if len(paths) != 0 && !matchPaths(paths, name) {
+			continue
+		}
+
+		e, err := idx.Entry(name)
+		if err == index.ErrEntryNotFound {
+			// untracked file
+			continue
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		if e.Mode == filemode.Submodule {
+			continue
+		}
+
+		a, err := ch.Action()
+		if err != nil {
+			return nil, err
+		}
+
+		pair := &diffFilePair{name: name}
+		if !e.IntentToAdd {
+			if pair.from, err = w.diffBlobFile(name, e.Hash, e.Mode); err != nil {
+				return nil, err
+			}
+		}
+
+		if a != merkletrie.Delete {
+			if pair.to, err = w.diffWorktreeFile(name, conv); err != nil {
+				return nil, err
+			}
+		}
+
+		if pair.from != nil || pair.to != nil {
+			files = append(files, pair)
+		}
+	}
+
+	return files, nil
+}
+
+func (w *Worktree) diffBlobFile(name string, h plumbing.Hash, mode filemode.FileMode) (*diffFile, error) {
+	content, err := w.readBlob(h)
+	if err != nil {
+		return nil, err
+	}
+
+	return &diffFile{path: name, hash: h, mode: mode, content: content}, nil
+}
+
+// diffWorktreeFile returns the file name of the worktree, with its content as
+// it would be added to the index.
+func (w *Worktree) diffWorktreeFile(name string, conv *contentConverter) (*diffFile, error) {
+	fi, err := w.Filesystem.Lstat(name)
+	if err != nil {
+		return nil, err
+	}
+
+	mode, err := filemode.NewFromOSFileMode(fi.Mode())
+	if err != nil {
+		return nil, err
+	}
+
+	var content []byte
+	if fi.Mode()&os.ModeSymlink != 0 {
+		var target string
+		target, err = w.Filesystem.Readlink(name)
+		content = []byte(target)
+	} else {
+		content, err = w.cleanFileContent(name, conv)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &diffFile{
+		path:    name,
+		hash:    plumbing.ComputeHash(plumbing.BlobObject, content),
+		mode:    mode,
+		content: content,
+	}, nil
+}
+
+// WorktreePatch is the patch of the changes of the worktree or the index,
+// returned by Worktree.Diff. It is an implementation of the diff.Patch
+// interface.
+type WorktreePatch struct {
+	filePatches  []fdiff.FilePatch
+	contextLines int
+}
+
+// FilePatches returns the patches of the changed files, sorted by path.
+func (p *WorktreePatch) FilePatches() []fdiff.FilePatch {
+	return p.filePatches
+}
+
+// Message returns an empty message, a diff of the worktree has no message.
+func (p *WorktreePatch) Message() string {
+	return ""
+}
+
+// Encode encodes the patch in the unified format, with the number of context
+// lines of the DiffOptions.
+func (p *WorktreePatch) Encode(w io.Writer) error {
+	return fdiff.NewUnifiedEncoder(w, p.contextLines).Encode(p)
+}
+
+func (p *WorktreePatch) String() string {
+	buf := bytes.NewBuffer(nil)
+	if err := p.Encode(buf); err != nil {
+		return fmt.Sprintf("malformed patch: %s", err.Error())
+	}
+
+	return buf.String()
+}
+
+// diffFile is an implementation of the diff.File interface.
+type diffFile struct {
+	path    string
+	hash    plumbing.Hash
+	mode    filemode.FileMode
+	content []byte
+}
+
+func (f *diffFile) Hash() plumbing.Hash     { return f.hash }
+func (f *diffFile) Mode() filemode.FileMode { return f.mode }
+func (f *diffFile) Path() string            { return f.path }
+
+// diffFilePatch is an implementation of the diff.FilePatch interface.
+type diffFilePatch struct {
+	from, to fdiff.File
+	binary   bool
+	chunks   []fdiff.Chunk
+}
+
+func newDiffFilePatch(from, to *diffFile) *diffFilePatch {
+	fp := &diffFilePatch{}
+	var fromContent, toContent []byte
+	if from != nil {
+		fp.from, fromContent = from, from.content
+	}
+
+	if to != nil {
+		fp.to, toContent = to, to.content
+	}
+
+	if isBinary(fromContent) || isBinary(toContent) {
+		fp.binary = true
+		return fp
+	}
+
+	for _, d := range diff.Do(string(fromContent), string(toContent)) {
+		var op fdiff.Operation
+		switch d.Type {
+		case dmp.DiffEqual:
+			op = fdiff.Equal
+		case dmp.DiffDelete:
+			op = fdiff.Delete
+		case dmp.DiffInsert:
+			op = fdiff.Add
+		}
+
+		fp.chunks = append(fp.chunks, &diffChunk{content: d.Text, op: op})
+	}
+
+	return fp
+}
+
+func (fp *diffFilePatch) IsBinary() bool               { return fp.binary }
+func (fp *diffFilePatch) Files() (from, to fdiff.File) { return fp.from, fp.to }
+func (fp *diffFilePatch) Chunks() []fdiff.Chunk        { return fp.chunks }
+
+// diffChunk is an implementation of the diff.Chunk interface.
+type diffChunk struct {
+	content string
+	op      fdiff.Operation
+}
+
+func (c *diffChunk) Content() string       { return c.content }
+func (c *diffChunk) Type() fdiff.Operation { return c.op }
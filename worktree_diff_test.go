@@ -0,0 +1,158 @@
+package git
+
+import (
+	"gopkg.in/src-d/go-git.v4/plumbing/format/diff"
+
+	. "gopkg.in/check.v1"
+	"gopkg.in/src-d/go-billy.v4/util"
+)
+
+func (s *WorktreeSuite) TestDiffWorktree(c *C) {
+	w, fs := s.newInitWorktree(c, map[string]string{"foo": "a\nb\nc\nd\ne\nf\ng\nh\n", "bar/baz": "baz\n"})
+	c.Assert(util.WriteFile(fs, "foo", []byte("a\nb\nc\nd\ne\nf\ng\nH\n"), 0644), IsNil)
+	c.Assert(util.WriteFile(fs, "qux", []byte("qux\n"), 0644), IsNil)
+	c.Assert(fs.Remove("bar/baz"), IsNil)
+
+	p, err := w.Diff(nil)
+	c.Assert(err, IsNil)
+	c.Assert(p.String(), Equals, ""+
+		"diff --git a/bar/baz b/bar/baz\n"+
+		"deleted file mode 100644\n"+
+		"index 76018072e09c5d31c8c6e3113b8aa0fe625195ca..0000000000000000000000000000000000000000\n"+
+		"--- a/bar/baz\n"+
+		"+++ /dev/null\n"+
+		"@@ -1 +0,0 @@\n"+
+		"-baz\n"+
+		"diff --git a/foo b/foo\n"+
+		"index 71ac1b5791204c80666ab1a4f9886b79e982739c..b9a82af054004ae75bdcf37f94b0215ab84fae9f 100644\n"+
+		"--- a/foo\n"+
+		"+++ b/foo\n"+
+		"@@ -5,4 +5,4 @@ d\n"+
+		" e\n"+
+		" f\n"+
+		" g\n"+
+		"-h\n"+
+		"+H\n",
+	)
+}
+
+func (s *WorktreeSuite) TestDiffStaged(c *C) {
+	w, fs := s.newInitWorktree(c, map[string]string{"foo": "a\nb\nc\nd\ne\nf\ng\nh\n", "bar/baz": "baz\n"})
+	c.Assert(util.WriteFile(fs, "foo", []byte("A\nb\nc\nd\ne\nf\ng\nh\n"), 0644), IsNil)
+	c.Assert(util.WriteFile(fs, "qux", []byte("qux\n"), 0644), IsNil)
+	c.Assert(w.AddWithOptions(&AddOptions{Paths: []string{"qux"}}), IsNil)
+
+	p, err := w.Diff(&DiffOptions{Staged: true})
+	c.Assert(err, IsNil)
+
+	fps := p.FilePatches()
+	c.Assert(fps, HasLen, 1)
+	from, to := fps[0].Files()
+	c.Assert(from, IsNil)
+	c.Assert(to.Path(), Equals, "qux")
+	c.Assert(fps[0].Chunks(), HasLen, 1)
+	c.Assert(fps[0].Chunks()[0].Type(), Equals, diff.Add)
+	c.Assert(fps[0].Chunks()[0].Content(), Equals, "qux\n")
+
+	p, err = w.Diff(nil)
+	c.Assert(err, IsNil)
+	fps = p.FilePatches()
+	c.Assert(fps, HasLen, 1)
+	from, to = fps[0].Files()
+	c.Assert(from.Path(), Equals, "foo")
+	c.Assert(to.Path(), Equals, "foo")
+}
+
+func (s *WorktreeSuite) TestDiffPaths(c *C) {
+	w, fs := s.newInitWorktree(c, map[string]string{"foo": "a\nb\nc\nd\ne\nf\ng\nh\n", "bar/baz": "baz\n"})
+	c.Assert(util.WriteFile(fs, "foo", []byte("A\n"), 0644), IsNil)
+	c.Assert(util.WriteFile(fs, "bar/baz", []byte("BAZ\n"), 0644), IsNil)
+
+	p, err := w.Diff(&DiffOptions{Paths: []string{"bar"}})
+	c.Assert(err, IsNil)
+	c.Assert(p.FilePatches(), HasLen, 1)
+	_, to := p.FilePatches()[0].Files()
+	c.Assert(to.Path(), Equals, "bar/baz")
+
+	p, err = w.Diff(&DiffOptions{Paths: []string{"f*"}})
+	c.Assert(err, IsNil)
+	c.Assert(p.FilePatches(), HasLen, 1)
+	_, to = p.FilePatches()[0].Files()
+	c.Assert(to.Path(), Equals, "foo")
+}
+
+func (s *WorktreeSuite) TestDiffContextLines(c *C) {
+	w, fs := s.newInitWorktree(c, map[string]string{"foo": "a\nb\nc\nd\ne\nf\ng\nh\n", "bar/baz": "baz\n"})
+	c.Assert(util.WriteFile(fs, "foo", []byte("a\nb\nc\nD\ne\nf\ng\nh\n"), 0644), IsNil)
+
+	p, err := w.Diff(&DiffOptions{ContextLines: -1})
+	c.Assert(err, IsNil)
+	c.Assert(p.String(), Equals, ""+
+		"diff --git a/foo b/foo\n"+
+		"index 71ac1b5791204c80666ab1a4f9886b79e982739c..797b965a5983e76165670f7a1850ce9e2b979806 100644\n"+
+		"--- a/foo\n"+
+		"+++ b/foo\n"+
+		"@@ -4 +4 @@ c\n"+
+		"-d\n"+
+		"+D\n",
+	)
+
+	p, err = w.Diff(&DiffOptions{ContextLines: 1})
+	c.Assert(err, IsNil)
+	c.Assert(p.String(), Equals, ""+
+		"diff --git a/foo b/foo\n"+
+		"index 71ac1b5791204c80666ab1a4f9886b79e982739c..797b965a5983e76165670f7a1850ce9e2b979806 100644\n"+
+		"--- a/foo\n"+
+		"+++ b/foo\n"+
+		"@@ -3,3 +3,3 @@ b\n"+
+		" c\n"+
+		"-d\n"+
+		"+D\n"+
+		" e\n",
+	)
+}
+
+func (s *WorktreeSuite) TestDiffIntentToAdd(c *C) {
+	w, fs := s.newInitWorktree(c, map[string]string{"foo": "a\nb\nc\nd\ne\nf\ng\nh\n", "bar/baz": "baz\n"})
+	c.Assert(util.WriteFile(fs, "qux", []byte("qux\n"), 0644), IsNil)
+	c.Assert(w.AddWithOptions(&AddOptions{IntentToAdd: true, Paths: []string{"qux"}}), IsNil)
+
+	p, err := w.Diff(&DiffOptions{Staged: true})
+	c.Assert(err, IsNil)
+	c.Assert(p.FilePatches(), HasLen, 0)
+
+	p, err = w.Diff(nil)
+	c.Assert(err, IsNil)
+	c.Assert(p.FilePatches(), HasLen, 1)
+	from, to := p.FilePatches()[0].Files()
+	c.Assert(from, IsNil)
+	c.Assert(to.Path(), Equals, "qux")
+}
+
+func (s *WorktreeSuite) TestDiffBinary(c *C) {
+	w, fs := s.newInitWorktree(c, map[string]string{"foo": "a\nb\nc\nd\ne\nf\ng\nh\n", "bar/baz": "baz\n"})
+	c.Assert(util.WriteFile(fs, "foo", []byte("a\x00b\n"), 0644), IsNil)
+
+	p, err := w.Diff(nil)
+	c.Assert(err, IsNil)
+	c.Assert(p.FilePatches(), HasLen, 1)
+	c.Assert(p.FilePatches()[0].IsBinary(), Equals, true)
+	c.Assert(p.FilePatches()[0].Chunks(), HasLen, 0)
+}
+
+func (s *WorktreeSuite) TestDiffApplyToIndex(c *C) {
+	w, fs := s.newInitWorktree(c, map[string]string{"foo": "a\nb\nc\nd\ne\nf\ng\nh\n", "bar/baz": "baz\n"})
+	c.Assert(util.WriteFile(fs, "foo", []byte("A\nb\nc\nd\ne\nf\ng\nh\n"), 0644), IsNil)
+
+	p, err := w.Diff(nil)
+	c.Assert(err, IsNil)
+	c.Assert(w.ApplyToIndex(p, nil), IsNil)
+
+	p, err = w.Diff(nil)
+	c.Assert(err, IsNil)
+	c.Assert(p.FilePatches(), HasLen, 0)
+
+	p, err = w.Diff(&DiffOptions{Staged: true})
+	c.Assert(err, IsNil)
+	c.Assert(p.FilePatches(), HasLen, 1)
+}
@@ -0,0 +1,203 @@
+package git
+
+import (
+	"bytes"
+	"runtime"
+
+	"gopkg.in/src-d/go-git.v4/plumbing/format/gitattributes"
+)
+
+const (
+	textAttr = "text"
+	eolAttr  = "eol"
+
+	autoValue  = "auto"
+	inputValue = "input"
+	trueValue  = "true"
+	lfValue    = "lf"
+	crlfValue  = "crlf"
+)
+
+// crlfAction is the line ending conversion applied to a file, resulting of
+// the text and eol attributes and the core.autocrlf and core.eol options.
+type crlfAction int
+
+const (
+	// crlfBinary means no conversion.
+	crlfBinary crlfAction = iota
+	// crlfText converts to LF on add and to core.eol on checkout.
+	crlfText
+	// crlfTextInput converts to LF on add.
+	crlfTextInput
+	// crlfTextCRLF converts to LF on add and to CRLF on checkout.
+	crlfTextCRLF
+	// crlfAuto, crlfAutoInput and crlfAutoCRLF are the same as the text
+	// actions but only applied to the files detected as text.
+	crlfAuto
+	crlfAutoInput
+	crlfAutoCRLF
+)
+
+func (a crlfAction) isAuto() bool {
+	return a == crlfAuto || a == crlfAutoInput || a == crlfAutoCRLF
+}
+
+// eolAction returns the line ending conversion of a file, given its attributes.
+func (c *contentConverter) eolAction(attrs map[string]gitattributes.Attribute) crlfAction {
+	eol := ""
+	if a, ok := attrs[eolAttr]; ok && a.IsValueSet() {
+		eol = a.Value()
+	}
+
+	text, ok := attrs[textAttr]
+	switch {
+	case ok && text.IsUnset():
+		return crlfBinary
+	case ok && text.IsSet():
+		return withEOL(crlfText, crlfTextInput, crlfTextCRLF, eol)
+	case ok && text.Value() == autoValue:
+		return withEOL(crlfAuto, crlfAutoInput, crlfAutoCRLF, eol)
+	case eol != "":
+		return withEOL(crlfText, crlfTextInput, crlfTextCRLF, eol)
+	}
+
+	switch c.autocrlf {
+	case trueValue:
+		return crlfAutoCRLF
+	case inputValue:
+		return crlfAutoInput
+	default:
+		return crlfBinary
+	}
+}
+
+func withEOL(text, input, crlf crlfAction, eol string) crlfAction {
+	switch eol {
+	case lfValue:
+		return input
+	case crlfValue:
+		return crlf
+	default:
+		return text
+	}
+}
+
+// outputCRLF returns true if the files are checked out with CRLF line endings
+// for the given action.
+func (c *contentConverter) outputCRLF(a crlfAction) bool {
+	switch a {
+	case crlfTextCRLF, crlfAutoCRLF:
+		return true
+	case crlfText, crlfAuto:
+		switch c.autocrlf {
+		case trueValue:
+			return true
+		case inputValue:
+			return false
+		}
+
+		switch c.eol {
+		case crlfValue:
+			return true
+		case lfValue:
+			return false
+		default:
+			return runtime.GOOS == "windows"
+		}
+	default:
+		return false
+	}
+}
+
+// cleanEOL converts the line endings of the content of the file at path to LF,
+// if required by the action.
+func (c *contentConverter) cleanEOL(path string, a crlfAction, content []byte) ([]byte, error) {
+	if a == crlfBinary || !bytes.Contains(content, crlf) {
+		return content, nil
+	}
+
+	if a.isAuto() {
+		if isBinary(content) {
+			return content, nil
+		}
+
+		// as git does, the files committed with CRLF are not normalized in
+		// auto mode, otherwise they would be always reported as modified
+		hasCR, err := c.hasCRInIndex(path)
+		if err != nil || hasCR {
+			return content, err
+		}
+	}
+
+	return bytes.Replace(content, crlf, lf, -1), nil
+}
+
+// smudgeEOL converts the line endings of content to CRLF, if required by the
+// action.
+func (c *contentConverter) smudgeEOL(a crlfAction, content []byte) []byte {
+	if !c.outputCRLF(a) || !bytes.Contains(content, lf) {
+		return content
+	}
+
+	// as git does, the files with CR are never converted in auto mode, they
+	// were committed with the line endings converted
+	if a.isAuto() && (bytes.Contains(content, cr) || isBinary(content)) {
+		return content
+	}
+
+	return toCRLF(content)
+}
+
+var (
+	cr   = []byte("\r")
+	lf   = []byte("\n")
+	crlf = []byte("\r\n")
+)
+
+// toCRLF converts the LF line endings to CRLF, keeping the existing CRLF.
+func toCRLF(content []byte) []byte {
+	buf := bytes.NewBuffer(make([]byte, 0, len(content)+len(content)/16))
+	for i, b := range content {
+		if b == '\n' && (i == 0 || content[i-1] != '\r') {
+			buf.WriteByte('\r')
+		}
+
+		buf.WriteByte(b)
+	}
+
+	return buf.Bytes()
+}
+
+// isBinary returns true if the content looks like binary data, using the same
+// heuristic as git: containing a NUL, a lone CR or too many non printable
+// characters.
+func isBinary(content []byte) bool {
+	var printable, nonPrintable int
+	for i, b := range content {
+		switch {
+		case b == 0:
+			return true
+		case b == '\r':
+			if i+1 >= len(content) || content[i+1] != '\n' {
+				return true
+			}
+		case b == '\n':
+		case b == 127:
+			nonPrintable++
+		case b < 32:
+			switch b {
+			case '\b', '\t', '\033', '\014':
+				printable++
+			default:
+				// the EOF character is allowed at the end of the file
+				if b != '\032' || i != len(content)-1 {
+					nonPrintable++
+				}
+			}
+		default:
+			printable++
+		}
+	}
+
+	return printable>>7 < nonPrintable
+}
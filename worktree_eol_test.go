@@ -0,0 +1,149 @@
+package git
+
+import (
+	"io/ioutil"
+
+	"gopkg.in/src-d/go-git.v4/plumbing"
+
+	. "gopkg.in/check.v1"
+	"gopkg.in/src-d/go-billy.v4"
+	"gopkg.in/src-d/go-billy.v4/util"
+)
+
+type EOLSuite struct {
+	BaseSuite
+}
+
+var _ = Suite(&EOLSuite{})
+
+func setAutoCRLF(c *C, r *Repository, autocrlf string) {
+	cfg, err := r.Config()
+	c.Assert(err, IsNil)
+
+	cfg.Core.AutoCRLF = autocrlf
+	c.Assert(r.Storer.SetConfig(cfg), IsNil)
+}
+
+func blobContent(c *C, w *Worktree, h plumbing.Hash) string {
+	blob, err := w.r.BlobObject(h)
+	c.Assert(err, IsNil)
+
+	r, err := blob.Reader()
+	c.Assert(err, IsNil)
+	defer r.Close()
+
+	content, err := ioutil.ReadAll(r)
+	c.Assert(err, IsNil)
+	return string(content)
+}
+
+func fileContent(c *C, fs billy.Filesystem, name string) string {
+	f, err := fs.Open(name)
+	c.Assert(err, IsNil)
+	defer f.Close()
+
+	content, err := ioutil.ReadAll(f)
+	c.Assert(err, IsNil)
+	return string(content)
+}
+
+func (s *EOLSuite) TestAddAutoCRLF(c *C) {
+	w, fs := s.newInitWorktree(c, nil)
+	setAutoCRLF(c, w.r, "true")
+	c.Assert(util.WriteFile(fs, "foo.txt", []byte("foo\r\nbar\r\n"), 0644), IsNil)
+	c.Assert(util.WriteFile(fs, "foo.bin", []byte("foo\r\n\x00"), 0644), IsNil)
+
+	h, err := w.Add("foo.txt")
+	c.Assert(err, IsNil)
+	c.Assert(blobContent(c, w, h), Equals, "foo\nbar\n")
+
+	h, err = w.Add("foo.bin")
+	c.Assert(err, IsNil)
+	c.Assert(blobContent(c, w, h), Equals, "foo\r\n\x00")
+
+	status, err := w.Status()
+	c.Assert(err, IsNil)
+	c.Assert(status.File("foo.txt").Worktree, Equals, Unmodified)
+	c.Assert(status.File("foo.bin").Worktree, Equals, Unmodified)
+}
+
+func (s *EOLSuite) TestAddWithoutAutoCRLF(c *C) {
+	w, fs := s.newInitWorktree(c, nil)
+	c.Assert(util.WriteFile(fs, "foo.txt", []byte("foo\r\nbar\r\n"), 0644), IsNil)
+
+	h, err := w.Add("foo.txt")
+	c.Assert(err, IsNil)
+	c.Assert(blobContent(c, w, h), Equals, "foo\r\nbar\r\n")
+}
+
+func (s *EOLSuite) TestCheckoutAutoCRLF(c *C) {
+	w, fs := s.newInitWorktree(c, nil)
+	c.Assert(util.WriteFile(fs, "foo.txt", []byte("foo\nbar\n"), 0644), IsNil)
+	c.Assert(util.WriteFile(fs, "mixed.txt", []byte("foo\r\nbar\n"), 0644), IsNil)
+
+	c.Assert(w.AddGlob("*.txt"), IsNil)
+	commit, err := w.Commit("foo\n", &CommitOptions{Author: defaultSignature()})
+	c.Assert(err, IsNil)
+
+	setAutoCRLF(c, w.r, "true")
+	c.Assert(fs.Remove("foo.txt"), IsNil)
+	c.Assert(fs.Remove("mixed.txt"), IsNil)
+
+	err = w.Reset(&ResetOptions{Commit: commit, Mode: HardReset})
+	c.Assert(err, IsNil)
+
+	c.Assert(fileContent(c, fs, "foo.txt"), Equals, "foo\r\nbar\r\n")
+	c.Assert(fileContent(c, fs, "mixed.txt"), Equals, "foo\r\nbar\n")
+
+	status, err := w.Status()
+	c.Assert(err, IsNil)
+	c.Assert(status.IsClean(), Equals, true)
+}
+
+func (s *EOLSuite) TestAttributes(c *C) {
+	w, fs := s.newInitWorktree(c, nil)
+	c.Assert(util.WriteFile(fs, ".gitattributes", []byte("*.bat eol=crlf\n*.sh text eol=lf\n*.txt text\n"), 0644), IsNil)
+	c.Assert(util.WriteFile(fs, "foo.bat", []byte("foo\r\nbar\n"), 0644), IsNil)
+	c.Assert(util.WriteFile(fs, "foo.sh", []byte("foo\r\nbar\n"), 0644), IsNil)
+
+	for _, name := range []string{".gitattributes", "foo.bat", "foo.sh"} {
+		_, err := w.Add(name)
+		c.Assert(err, IsNil)
+	}
+
+	commit, err := w.Commit("foo\n", &CommitOptions{Author: defaultSignature()})
+	c.Assert(err, IsNil)
+
+	for _, name := range []string{"foo.bat", "foo.sh"} {
+		idx, err := w.r.Storer.Index()
+		c.Assert(err, IsNil)
+
+		e, err := idx.Entry(name)
+		c.Assert(err, IsNil)
+		c.Assert(blobContent(c, w, e.Hash), Equals, "foo\nbar\n")
+
+		c.Assert(fs.Remove(name), IsNil)
+	}
+
+	err = w.Reset(&ResetOptions{Commit: commit, Mode: HardReset})
+	c.Assert(err, IsNil)
+
+	c.Assert(fileContent(c, fs, "foo.bat"), Equals, "foo\r\nbar\r\n")
+	c.Assert(fileContent(c, fs, "foo.sh"), Equals, "foo\nbar\n")
+
+	status, err := w.Status()
+	c.Assert(err, IsNil)
+	c.Assert(status.IsClean(), Equals, true)
+}
+
+func (s *EOLSuite) TestToCRLF(c *C) {
+	c.Assert(string(toCRLF([]byte("foo\nbar\r\n\n"))), Equals, "foo\r\nbar\r\n\r\n")
+	c.Assert(string(toCRLF([]byte("\nfoo"))), Equals, "\r\nfoo")
+}
+
+func (s *EOLSuite) TestIsBinary(c *C) {
+	c.Assert(isBinary([]byte("foo\r\nbar\n\tqux\x1a")), Equals, false)
+	c.Assert(isBinary([]byte("foo\x00bar")), Equals, true)
+	c.Assert(isBinary([]byte("foo\rbar")), Equals, true)
+	c.Assert(isBinary([]byte("\x01\x02\x03")), Equals, true)
+}
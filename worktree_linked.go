@@ -0,0 +1,477 @@
+package git
+
+import (
+	"errors"
+	"fmt"
+	stdioutil "io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/src-d/go-billy.v4"
+	"gopkg.in/src-d/go-billy.v4/osfs"
+	"gopkg.in/src-d/go-billy.v4/util"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/cache"
+	"gopkg.in/src-d/go-git.v4/storage/filesystem"
+	"gopkg.in/src-d/go-git.v4/storage/filesystem/dotgit"
+	"gopkg.in/src-d/go-git.v4/utils/ioutil"
+)
+
+const (
+	worktreesPath = "worktrees"
+	gitdirFile    = "gitdir"
+	lockedFile    = "locked"
+	headFile      = "HEAD"
+)
+
+var (
+	// ErrWorktreeExists is returned by AddWorktree when a worktree with the
+	// same name already exists.
+	ErrWorktreeExists = errors.New("worktree already exists")
+	// ErrWorktreeNotFound is returned when the given linked worktree
+	// doesn't exist.
+	ErrWorktreeNotFound = errors.New("worktree not found")
+	// ErrWorktreeLocked is returned by RemoveWorktree when the worktree is
+	// locked.
+	ErrWorktreeLocked = errors.New("worktree is locked")
+	// ErrBranchCheckedOut is returned by AddWorktree when the branch is
+	// already checked out by other worktree.
+	ErrBranchCheckedOut = errors.New("branch is already checked out by other worktree")
+	// ErrWorktreeUnsupportedStorage is returned when the linked worktrees
+	// are used in a repository not stored in a filesystem.
+	ErrWorktreeUnsupportedStorage = errors.New("linked worktrees require a filesystem based storage")
+)
+
+// LinkedWorktree is a worktree linked to a repository, sharing with it all
+// but the HEAD, the index and the per worktree refs.
+type LinkedWorktree struct {
+	// Name of the worktree, its administrative files are kept at
+	// $GIT_DIR/worktrees/<name>.
+	Name string
+	// Path is the root of the worktree.
+	Path string
+	// Head is the HEAD of the worktree.
+	Head *plumbing.Reference
+	// Locked is true if the worktree is locked, it can't be pruned.
+	Locked bool
+	// Prunable is true if the worktree doesn't exist anymore.
+	Prunable bool
+}
+
+// AddWorktree adds a linked worktree to the repository, rooted at the given
+// filesystem, and returns the repository opened from it. The linked worktree
+// shares the objects, the config and the refs of the repository, but has its
+// own HEAD and index.
+func (r *Repository) AddWorktree(worktree billy.Filesystem, o *AddWorktreeOptions) (*Repository, error) {
+	if err := o.Validate(worktree); err != nil {
+		return nil, err
+	}
+
+	common, err := r.commonDotGitFilesystem()
+	if err != nil {
+		return nil, err
+	}
+
+	admin := common.Join(worktreesPath, o.Name)
+	if _, err := common.Stat(admin); err == nil {
+		return nil, ErrWorktreeExists
+	}
+
+	co, err := r.worktreeCheckoutOptions(o)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := common.MkdirAll(admin, os.ModeDir|os.ModePerm); err != nil {
+		return nil, err
+	}
+
+	// as git does, nothing is left of the worktree in $GIT_DIR if it can't
+	// be added
+	linked, err := r.addWorktree(worktree, common, admin, co)
+	if err != nil {
+		_ = util.RemoveAll(common, admin)
+		_ = worktree.Remove(GitDirName)
+		return nil, err
+	}
+
+	return linked, nil
+}
+
+// addWorktree writes the $GIT_DIR of a new linked worktree in admin and
+// checks it out.
+func (r *Repository) addWorktree(
+	worktree, common billy.Filesystem, admin string, co *CheckoutOptions,
+) (*Repository, error) {
+
+	if err := writeWorktreeFile(common, common.Join(admin, dotgit.CommonDirFile), "../.."); err != nil {
+		return nil, err
+	}
+
+	gitdir := filepath.Join(worktree.Root(), GitDirName)
+	if err := writeWorktreeFile(common, common.Join(admin, gitdirFile), gitdir); err != nil {
+		return nil, err
+	}
+
+	dot, err := common.Chroot(admin)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := createDotGitFile(worktree, dot); err != nil {
+		return nil, err
+	}
+
+	s := filesystem.NewStorage(
+		dotgit.NewRepositoryFilesystem(dot, common),
+		cache.NewObjectLRUDefault(),
+	)
+
+	head, err := r.Head()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.SetReference(plumbing.NewHashReference(plumbing.HEAD, head.Hash())); err != nil {
+		return nil, err
+	}
+
+	linked := newRepository(s, worktree)
+	w, err := linked.Worktree()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := w.Checkout(co); err != nil {
+		return nil, err
+	}
+
+	return linked, nil
+}
+
+// worktreeCheckoutOptions returns the options of the checkout of a new linked
+// worktree, verifying that the branch is not checked out by other worktree.
+func (r *Repository) worktreeCheckoutOptions(o *AddWorktreeOptions) (*CheckoutOptions, error) {
+	co := &CheckoutOptions{
+		Branch: o.Branch,
+		Hash:   o.Hash,
+		Create: o.Create,
+		Force:  true,
+	}
+
+	if co.Branch == "" && co.Hash.IsZero() {
+		co.Branch = plumbing.ReferenceName("refs/heads/" + o.Name)
+		_, err := r.Storer.Reference(co.Branch)
+		if err == plumbing.ErrReferenceNotFound {
+			co.Create = true
+		} else if err != nil {
+			return nil, err
+		}
+	}
+
+	if co.Create {
+		if _, err := r.Storer.Reference(co.Branch); err == nil {
+			return nil, ErrBranchExists
+		}
+
+		if co.Hash.IsZero() {
+			head, err := r.Head()
+			if err != nil {
+				return nil, err
+			}
+
+			co.Hash = head.Hash()
+		}
+
+		return co, nil
+	}
+
+	if co.Branch == "" || o.Force {
+		return co, nil
+	}
+
+	checkedOut, err := r.isBranchCheckedOut(co.Branch)
+	if err != nil {
+		return nil, err
+	}
+
+	if checkedOut {
+		return nil, ErrBranchCheckedOut
+	}
+
+	return co, nil
+}
+
+// isBranchCheckedOut returns true if the branch is the HEAD of the main
+// worktree or of any of the linked worktrees.
+func (r *Repository) isBranchCheckedOut(branch plumbing.ReferenceName) (bool, error) {
+	common, err := r.commonDotGitFilesystem()
+	if err != nil {
+		return false, err
+	}
+
+	head, err := readWorktreeHead(common, headFile)
+	if err != nil {
+		return false, err
+	}
+
+	if head != nil && head.Target() == branch {
+		return true, nil
+	}
+
+	worktrees, err := r.Worktrees()
+	if err != nil {
+		return false, err
+	}
+
+	for _, w := range worktrees {
+		if w.Head != nil && w.Head.Target() == branch {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// Worktrees returns the linked worktrees of the repository, the main
+// worktree is not included.
+func (r *Repository) Worktrees() ([]*LinkedWorktree, error) {
+	common, err := r.commonDotGitFilesystem()
+	if err != nil {
+		return nil, err
+	}
+
+	fis, err := common.ReadDir(worktreesPath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	var worktrees []*LinkedWorktree
+	for _, fi := range fis {
+		if !fi.IsDir() {
+			continue
+		}
+
+		w, err := readLinkedWorktree(common, fi.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		worktrees = append(worktrees, w)
+	}
+
+	return worktrees, nil
+}
+
+func readLinkedWorktree(common billy.Filesystem, name string) (*LinkedWorktree, error) {
+	admin := common.Join(worktreesPath, name)
+	w := &LinkedWorktree{Name: name}
+
+	gitdir, err := readWorktreeFile(common, common.Join(admin, gitdirFile))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	if gitdir != "" {
+		w.Path = filepath.Dir(gitdir)
+		_, err := os.Stat(gitdir)
+		w.Prunable = os.IsNotExist(err)
+	} else {
+		w.Prunable = true
+	}
+
+	if w.Head, err = readWorktreeHead(common, common.Join(admin, headFile)); err != nil {
+		return nil, err
+	}
+
+	if _, err := common.Stat(common.Join(admin, lockedFile)); err == nil {
+		w.Locked = true
+	}
+
+	return w, nil
+}
+
+// RemoveWorktree removes the linked worktree with the given name, its files
+// and its administrative files. The worktrees locked or not clean are not
+// removed, returning ErrWorktreeLocked or ErrWorktreeNotClean, unless the
+// Force option is used.
+func (r *Repository) RemoveWorktree(name string, o *RemoveWorktreeOptions) error {
+	if o == nil {
+		o = &RemoveWorktreeOptions{}
+	}
+
+	common, err := r.commonDotGitFilesystem()
+	if err != nil {
+		return err
+	}
+
+	admin := common.Join(worktreesPath, name)
+	if _, err := common.Stat(admin); os.IsNotExist(err) {
+		return ErrWorktreeNotFound
+	}
+
+	w, err := readLinkedWorktree(common, name)
+	if err != nil {
+		return err
+	}
+
+	if w.Locked && !o.Force {
+		return ErrWorktreeLocked
+	}
+
+	if !w.Prunable {
+		if err := r.removeLinkedWorktreeFiles(common, w, o.Force); err != nil {
+			return err
+		}
+	}
+
+	return util.RemoveAll(common, admin)
+}
+
+// removeLinkedWorktreeFiles removes the files of the worktree w, only if it
+// is linked to this repository.
+func (r *Repository) removeLinkedWorktreeFiles(common billy.Filesystem, w *LinkedWorktree, force bool) error {
+	fs := osfs.New(w.Path)
+	dot, err := dotGitFileToOSFilesystem(w.Path, fs)
+	if err != nil {
+		return err
+	}
+
+	admin := filepath.Join(common.Root(), worktreesPath, w.Name)
+	if filepath.Clean(dot.Root()) != filepath.Clean(admin) {
+		return fmt.Errorf("worktree %q at %s is not linked to this repository", w.Name, w.Path)
+	}
+
+	if !force {
+		linked, err := Open(filesystem.NewStorage(
+			dotgit.NewRepositoryFilesystem(dot, common),
+			cache.NewObjectLRUDefault(),
+		), fs)
+		if err != nil {
+			return err
+		}
+
+		wt, err := linked.Worktree()
+		if err != nil {
+			return err
+		}
+
+		status, err := wt.Status()
+		if err != nil {
+			return err
+		}
+
+		if !status.IsClean() {
+			return ErrWorktreeNotClean
+		}
+	}
+
+	return os.RemoveAll(w.Path)
+}
+
+// PruneWorktrees removes the administrative files of the linked worktrees
+// not locked whose files don't exist anymore.
+func (r *Repository) PruneWorktrees() error {
+	common, err := r.commonDotGitFilesystem()
+	if err != nil {
+		return err
+	}
+
+	worktrees, err := r.Worktrees()
+	if err != nil {
+		return err
+	}
+
+	for _, w := range worktrees {
+		if !w.Prunable || w.Locked {
+			continue
+		}
+
+		if err := util.RemoveAll(common, common.Join(worktreesPath, w.Name)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// commonDotGitFilesystem returns the filesystem of the $GIT_COMMON_DIR, the
+// $GIT_DIR of the main worktree.
+func (r *Repository) commonDotGitFilesystem() (billy.Filesystem, error) {
+	fs, ok := r.dotGitFilesystem()
+	if !ok {
+		return nil, ErrWorktreeUnsupportedStorage
+	}
+
+	if rfs, ok := fs.(*dotgit.RepositoryFilesystem); ok {
+		return rfs.Common(), nil
+	}
+
+	return fs, nil
+}
+
+// dotGitCommonDirectory returns the filesystem of the $GIT_DIR of a linked
+// worktree, resolving the $GIT_COMMON_DIR from its commondir file. If the file
+// doesn't exist, dot is returned as is.
+func dotGitCommonDirectory(dot billy.Filesystem) (billy.Filesystem, error) {
+	path, err := readWorktreeFile(dot, dotgit.CommonDirFile)
+	if os.IsNotExist(err) {
+		return dot, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(dot.Root(), path)
+	}
+
+	return dotgit.NewRepositoryFilesystem(dot, osfs.New(path)), nil
+}
+
+func readWorktreeHead(fs billy.Filesystem, path string) (*plumbing.Reference, error) {
+	content, err := readWorktreeFile(fs, path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return plumbing.NewReferenceFromStrings(plumbing.HEAD.String(), content), nil
+}
+
+func readWorktreeFile(fs billy.Filesystem, path string) (content string, err error) {
+	f, err := fs.Open(path)
+	if err != nil {
+		return "", err
+	}
+
+	defer ioutil.CheckClose(f, &err)
+
+	b, err := stdioutil.ReadAll(f)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(string(b)), nil
+}
+
+func writeWorktreeFile(fs billy.Filesystem, path, content string) (err error) {
+	f, err := fs.Create(path)
+	if err != nil {
+		return err
+	}
+
+	defer ioutil.CheckClose(f, &err)
+
+	_, err = fmt.Fprintln(f, content)
+	return err
+}
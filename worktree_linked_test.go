@@ -0,0 +1,338 @@
+package git
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/src-d/go-git.v4/plumbing"
+
+	. "gopkg.in/check.v1"
+	"gopkg.in/src-d/go-billy.v4/memfs"
+	"gopkg.in/src-d/go-billy.v4/osfs"
+	"gopkg.in/src-d/go-billy.v4/util"
+	"gopkg.in/src-d/go-git.v4/storage/memory"
+)
+
+type LinkedWorktreeSuite struct {
+	BaseSuite
+	dir string
+}
+
+var _ = Suite(&LinkedWorktreeSuite{})
+
+func (s *LinkedWorktreeSuite) SetUpTest(c *C) {
+	dir, err := ioutil.TempDir("", "linked-worktree")
+	c.Assert(err, IsNil)
+	s.dir = dir
+}
+
+func (s *LinkedWorktreeSuite) TearDownTest(c *C) {
+	c.Assert(os.RemoveAll(s.dir), IsNil)
+}
+
+func (s *LinkedWorktreeSuite) newRepository(c *C) *Repository {
+	r, err := PlainClone(filepath.Join(s.dir, "main"), false, &CloneOptions{
+		URL: s.GetBasicLocalRepositoryURL(),
+	})
+	c.Assert(err, IsNil)
+
+	return r
+}
+
+func (s *LinkedWorktreeSuite) addWorktree(c *C, r *Repository, name string, o *AddWorktreeOptions) *Repository {
+	linked, err := r.AddWorktree(osfs.New(filepath.Join(s.dir, name)), o)
+	c.Assert(err, IsNil)
+
+	return linked
+}
+
+func (s *LinkedWorktreeSuite) TestAddWorktree(c *C) {
+	r := s.newRepository(c)
+	linked := s.addWorktree(c, r, "foo", &AddWorktreeOptions{})
+
+	head, err := linked.Head()
+	c.Assert(err, IsNil)
+	c.Assert(head.Name(), Equals, plumbing.ReferenceName("refs/heads/foo"))
+	c.Assert(head.Hash(), Equals, plumbing.NewHash("6ecf0ef2c2dffb796033e5a02219af86ec6584e5"))
+
+	// the branch is shared with the main worktree, but not the HEAD
+	ref, err := r.Reference("refs/heads/foo", false)
+	c.Assert(err, IsNil)
+	c.Assert(ref.Hash(), Equals, head.Hash())
+
+	head, err = r.Head()
+	c.Assert(err, IsNil)
+	c.Assert(head.Name(), Equals, plumbing.Master)
+
+	w, err := linked.Worktree()
+	c.Assert(err, IsNil)
+
+	status, err := w.Status()
+	c.Assert(err, IsNil)
+	c.Assert(status.IsClean(), Equals, true)
+
+	_, err = os.Stat(filepath.Join(s.dir, "foo", "CHANGELOG"))
+	c.Assert(err, IsNil)
+
+	gitdir := filepath.Join(s.dir, "main", ".git", "worktrees", "foo")
+	content, err := ioutil.ReadFile(filepath.Join(gitdir, "commondir"))
+	c.Assert(err, IsNil)
+	c.Assert(string(content), Equals, "../..\n")
+
+	content, err = ioutil.ReadFile(filepath.Join(gitdir, "gitdir"))
+	c.Assert(err, IsNil)
+	c.Assert(string(content), Equals, filepath.Join(s.dir, "foo", ".git")+"\n")
+}
+
+func (s *LinkedWorktreeSuite) TestAddWorktreeHash(c *C) {
+	r := s.newRepository(c)
+	hash := plumbing.NewHash("b029517f6300c2da0f4b651b8642506cd6aaf45d")
+	linked := s.addWorktree(c, r, "foo", &AddWorktreeOptions{Hash: hash})
+
+	head, err := linked.Head()
+	c.Assert(err, IsNil)
+	c.Assert(head.Name(), Equals, plumbing.HEAD)
+	c.Assert(head.Hash(), Equals, hash)
+
+	_, err = r.Reference("refs/heads/foo", false)
+	c.Assert(err, Equals, plumbing.ErrReferenceNotFound)
+}
+
+func (s *LinkedWorktreeSuite) TestAddWorktreeCommit(c *C) {
+	r := s.newRepository(c)
+	linked := s.addWorktree(c, r, "foo", &AddWorktreeOptions{})
+
+	w, err := linked.Worktree()
+	c.Assert(err, IsNil)
+
+	err = util.WriteFile(w.Filesystem, "bar", []byte("bar"), 0644)
+	c.Assert(err, IsNil)
+
+	_, err = w.Add("bar")
+	c.Assert(err, IsNil)
+
+	hash, err := w.Commit("bar\n", &CommitOptions{Author: defaultSignature()})
+	c.Assert(err, IsNil)
+
+	// the commit is visible from the main worktree, whose index is untouched
+	ref, err := r.Reference("refs/heads/foo", false)
+	c.Assert(err, IsNil)
+	c.Assert(ref.Hash(), Equals, hash)
+
+	_, err = r.CommitObject(hash)
+	c.Assert(err, IsNil)
+
+	mw, err := r.Worktree()
+	c.Assert(err, IsNil)
+
+	status, err := mw.Status()
+	c.Assert(err, IsNil)
+	c.Assert(status.IsClean(), Equals, true)
+}
+
+func (s *LinkedWorktreeSuite) TestWorktreeReferences(c *C) {
+	r := s.newRepository(c)
+	linked := s.addWorktree(c, r, "foo", &AddWorktreeOptions{})
+
+	hash := plumbing.NewHash("6ecf0ef2c2dffb796033e5a02219af86ec6584e5")
+	ref := plumbing.NewHashReference("refs/worktree/bar", hash)
+	c.Assert(linked.Storer.SetReference(ref), IsNil)
+
+	iter, err := linked.References()
+	c.Assert(err, IsNil)
+
+	var found bool
+	c.Assert(iter.ForEach(func(r *plumbing.Reference) error {
+		found = found || r.Name() == ref.Name()
+		return nil
+	}), IsNil)
+
+	c.Assert(found, Equals, true)
+}
+
+func (s *LinkedWorktreeSuite) TestAddWorktreeCheckoutError(c *C) {
+	r := s.newRepository(c)
+	hash := plumbing.NewHash("0000000000000000000000000000000000000001")
+	_, err := r.AddWorktree(osfs.New(filepath.Join(s.dir, "foo")), &AddWorktreeOptions{Hash: hash})
+	c.Assert(err, NotNil)
+
+	_, err = os.Stat(filepath.Join(s.dir, "main", ".git", "worktrees", "foo"))
+	c.Assert(os.IsNotExist(err), Equals, true)
+
+	_, err = os.Stat(filepath.Join(s.dir, "foo", ".git"))
+	c.Assert(os.IsNotExist(err), Equals, true)
+
+	linked := s.addWorktree(c, r, "foo", &AddWorktreeOptions{})
+	c.Assert(linked, NotNil)
+}
+
+func (s *LinkedWorktreeSuite) TestAddWorktreeExists(c *C) {
+	r := s.newRepository(c)
+	s.addWorktree(c, r, "foo", &AddWorktreeOptions{})
+
+	_, err := r.AddWorktree(osfs.New(filepath.Join(s.dir, "bar")), &AddWorktreeOptions{Name: "foo"})
+	c.Assert(err, Equals, ErrWorktreeExists)
+}
+
+func (s *LinkedWorktreeSuite) TestAddWorktreeBranchCheckedOut(c *C) {
+	r := s.newRepository(c)
+
+	_, err := r.AddWorktree(osfs.New(filepath.Join(s.dir, "foo")), &AddWorktreeOptions{
+		Branch: plumbing.Master,
+	})
+	c.Assert(err, Equals, ErrBranchCheckedOut)
+
+	s.addWorktree(c, r, "bar", &AddWorktreeOptions{})
+	_, err = r.AddWorktree(osfs.New(filepath.Join(s.dir, "qux")), &AddWorktreeOptions{
+		Branch: "refs/heads/bar",
+	})
+	c.Assert(err, Equals, ErrBranchCheckedOut)
+
+	s.addWorktree(c, r, "qux", &AddWorktreeOptions{Branch: plumbing.Master, Force: true})
+}
+
+func (s *LinkedWorktreeSuite) TestAddWorktreeInvalidName(c *C) {
+	r := s.newRepository(c)
+
+	_, err := r.AddWorktree(osfs.New(filepath.Join(s.dir, "foo")), &AddWorktreeOptions{Name: "foo/bar"})
+	c.Assert(err, Equals, ErrInvalidWorktreeName)
+}
+
+func (s *LinkedWorktreeSuite) TestAddWorktreeUnsupportedStorage(c *C) {
+	r, err := Init(memory.NewStorage(), memfs.New())
+	c.Assert(err, IsNil)
+
+	_, err = r.AddWorktree(memfs.New(), &AddWorktreeOptions{Name: "foo"})
+	c.Assert(err, Equals, ErrWorktreeUnsupportedStorage)
+}
+
+func (s *LinkedWorktreeSuite) TestPlainOpen(c *C) {
+	r := s.newRepository(c)
+	s.addWorktree(c, r, "foo", &AddWorktreeOptions{})
+
+	linked, err := PlainOpen(filepath.Join(s.dir, "foo"))
+	c.Assert(err, IsNil)
+
+	head, err := linked.Head()
+	c.Assert(err, IsNil)
+	c.Assert(head.Name(), Equals, plumbing.ReferenceName("refs/heads/foo"))
+
+	cfg, err := linked.Config()
+	c.Assert(err, IsNil)
+	c.Assert(cfg.Remotes, HasLen, 1)
+
+	worktrees, err := linked.Worktrees()
+	c.Assert(err, IsNil)
+	c.Assert(worktrees, HasLen, 1)
+}
+
+func (s *LinkedWorktreeSuite) TestWorktrees(c *C) {
+	r := s.newRepository(c)
+
+	worktrees, err := r.Worktrees()
+	c.Assert(err, IsNil)
+	c.Assert(worktrees, HasLen, 0)
+
+	s.addWorktree(c, r, "foo", &AddWorktreeOptions{})
+	s.addWorktree(c, r, "bar", &AddWorktreeOptions{Name: "qux"})
+
+	worktrees, err = r.Worktrees()
+	c.Assert(err, IsNil)
+	c.Assert(worktrees, HasLen, 2)
+
+	c.Assert(worktrees[0].Name, Equals, "foo")
+	c.Assert(worktrees[0].Path, Equals, filepath.Join(s.dir, "foo"))
+	c.Assert(worktrees[0].Head.Target(), Equals, plumbing.ReferenceName("refs/heads/foo"))
+	c.Assert(worktrees[0].Locked, Equals, false)
+	c.Assert(worktrees[0].Prunable, Equals, false)
+
+	c.Assert(worktrees[1].Name, Equals, "qux")
+	c.Assert(worktrees[1].Path, Equals, filepath.Join(s.dir, "bar"))
+}
+
+func (s *LinkedWorktreeSuite) TestRemoveWorktree(c *C) {
+	r := s.newRepository(c)
+	s.addWorktree(c, r, "foo", &AddWorktreeOptions{})
+
+	err := r.RemoveWorktree("foo", nil)
+	c.Assert(err, IsNil)
+
+	_, err = os.Stat(filepath.Join(s.dir, "foo"))
+	c.Assert(os.IsNotExist(err), Equals, true)
+
+	worktrees, err := r.Worktrees()
+	c.Assert(err, IsNil)
+	c.Assert(worktrees, HasLen, 0)
+
+	// the branch is kept
+	_, err = r.Reference("refs/heads/foo", false)
+	c.Assert(err, IsNil)
+
+	err = r.RemoveWorktree("foo", nil)
+	c.Assert(err, Equals, ErrWorktreeNotFound)
+}
+
+func (s *LinkedWorktreeSuite) TestRemoveWorktreeNotClean(c *C) {
+	r := s.newRepository(c)
+	linked := s.addWorktree(c, r, "foo", &AddWorktreeOptions{})
+
+	w, err := linked.Worktree()
+	c.Assert(err, IsNil)
+
+	err = util.WriteFile(w.Filesystem, "LICENSE", []byte("foo"), 0644)
+	c.Assert(err, IsNil)
+
+	err = r.RemoveWorktree("foo", nil)
+	c.Assert(err, Equals, ErrWorktreeNotClean)
+
+	err = r.RemoveWorktree("foo", &RemoveWorktreeOptions{Force: true})
+	c.Assert(err, IsNil)
+}
+
+func (s *LinkedWorktreeSuite) TestRemoveWorktreeLocked(c *C) {
+	r := s.newRepository(c)
+	s.addWorktree(c, r, "foo", &AddWorktreeOptions{})
+
+	locked := filepath.Join(s.dir, "main", ".git", "worktrees", "foo", "locked")
+	c.Assert(ioutil.WriteFile(locked, nil, 0644), IsNil)
+
+	worktrees, err := r.Worktrees()
+	c.Assert(err, IsNil)
+	c.Assert(worktrees[0].Locked, Equals, true)
+
+	err = r.RemoveWorktree("foo", nil)
+	c.Assert(err, Equals, ErrWorktreeLocked)
+
+	err = r.RemoveWorktree("foo", &RemoveWorktreeOptions{Force: true})
+	c.Assert(err, IsNil)
+}
+
+func (s *LinkedWorktreeSuite) TestPruneWorktrees(c *C) {
+	r := s.newRepository(c)
+	s.addWorktree(c, r, "foo", &AddWorktreeOptions{})
+	s.addWorktree(c, r, "bar", &AddWorktreeOptions{})
+	s.addWorktree(c, r, "qux", &AddWorktreeOptions{})
+
+	c.Assert(os.RemoveAll(filepath.Join(s.dir, "foo")), IsNil)
+	c.Assert(os.RemoveAll(filepath.Join(s.dir, "qux")), IsNil)
+
+	locked := filepath.Join(s.dir, "main", ".git", "worktrees", "qux", "locked")
+	c.Assert(ioutil.WriteFile(locked, nil, 0644), IsNil)
+
+	worktrees, err := r.Worktrees()
+	c.Assert(err, IsNil)
+	c.Assert(worktrees[0].Name, Equals, "bar")
+	c.Assert(worktrees[0].Prunable, Equals, false)
+	c.Assert(worktrees[1].Name, Equals, "foo")
+	c.Assert(worktrees[1].Prunable, Equals, true)
+
+	err = r.PruneWorktrees()
+	c.Assert(err, IsNil)
+
+	worktrees, err = r.Worktrees()
+	c.Assert(err, IsNil)
+	c.Assert(worktrees, HasLen, 2)
+	c.Assert(worktrees[0].Name, Equals, "bar")
+	c.Assert(worktrees[1].Name, Equals, "qux")
+}
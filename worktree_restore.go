@@ -0,0 +1,209 @@
+package git
+
+import (
+	"errors"
+	"os"
+	"path"
+	"strings"
+
+	"gopkg.in/src-d/go-git.v4/plumbing/format/index"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+	"gopkg.in/src-d/go-git.v4/utils/merkletrie"
+)
+
+// ErrPathspecNotMatched is returned by Restore and AddWithOptions when one of
+// the paths matches no file.
+var ErrPathspecNotMatched = errors.New("pathspec did not match any file(s) known to git")
+
+// Restore restores the given paths of the worktree and/or the index from the
+// Source commit, or the worktree from the index, without moving HEAD. The
+// tracked files matching the paths but missing in the source are removed,
+// the untracked files are kept. Nothing is restored if one of the paths
+// matches no file of the index or of the source.
+func (w *Worktree) Restore(o *RestoreOptions) error {
+	if err := o.Validate(w.r); err != nil {
+		return err
+	}
+
+	var t *object.Tree
+	if !o.Source.IsZero() {
+		var err error
+		t, err = w.getTreeFromCommitHash(o.Source)
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := w.checkPathspecs(t, o.Paths); err != nil {
+		return err
+	}
+
+	if o.Worktree {
+		if err := w.restoreWorktree(t, o.Staged, o.Paths); err != nil {
+			return err
+		}
+	}
+
+	if o.Staged {
+		return w.resetIndex(t, o.Paths)
+	}
+
+	return nil
+}
+
+// restoreWorktree restores the files matching paths from the tree t, or from
+// the index if t is nil. If staged, the index entries of the files restored
+// from t are updated.
+func (w *Worktree) restoreWorktree(t *object.Tree, staged bool, paths []string) error {
+	var changes merkletrie.Changes
+	var err error
+	if t == nil {
+		changes, err = w.diffStagingWithWorktree(true)
+	} else {
+		changes, err = w.diffTreeWithWorktree(t, true)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	idx, err := w.r.Storer.Index()
+	if err != nil {
+		return err
+	}
+
+	conv, err := w.newContentConverter()
+	if err != nil {
+		return err
+	}
+
+	for _, ch := range changes {
+		name := nameFromAction(&ch)
+		if !matchPaths(paths, name) {
+			continue
+		}
+
+		a, err := ch.Action()
+		if err != nil {
+			return err
+		}
+
+		// files missing in the source are only removed if tracked
+		if a == merkletrie.Delete {
+			if _, err := idx.Entry(name); err != nil {
+				continue
+			}
+		}
+
+		switch {
+		case t == nil:
+			err = w.restoreIndexEntry(name, a, idx, conv)
+		case staged:
+			err = w.checkoutChange(ch, t, idx, conv)
+		default:
+			err = w.restoreTreeEntry(name, a, t, conv)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return w.r.Storer.SetIndex(idx)
+}
+
+func (w *Worktree) restoreIndexEntry(name string, a merkletrie.Action, idx *index.Index, conv *contentConverter) error {
+	e, err := idx.Entry(name)
+	if err != nil {
+		return err
+	}
+
+	if a == merkletrie.Modify {
+		if err := w.Filesystem.Remove(name); err != nil {
+			return err
+		}
+	}
+
+	return w.checkoutIndexEntry(e, conv)
+}
+
+func (w *Worktree) restoreTreeEntry(name string, a merkletrie.Action, t *object.Tree, conv *contentConverter) error {
+	switch a {
+	case merkletrie.Delete:
+		return rmFileAndDirIfEmpty(w.Filesystem, name)
+	case merkletrie.Modify:
+		if err := w.Filesystem.Remove(name); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	f, err := t.File(name)
+	if err != nil {
+		return err
+	}
+
+	return w.checkoutFile(f, conv)
+}
+
+// checkPathspecs returns ErrPathspecNotMatched if one of the paths matches no
+// entry of the index nor, if not nil, file of the tree t.
+func (w *Worktree) checkPathspecs(t *object.Tree, paths []string) error {
+	matched := make([]bool, len(paths))
+	match := func(name string) {
+		for i, p := range paths {
+			matched[i] = matched[i] || matchPath(p, name)
+		}
+	}
+
+	idx, err := w.r.Storer.Index()
+	if err != nil {
+		return err
+	}
+
+	for _, e := range idx.Entries {
+		match(e.Name)
+	}
+
+	if t != nil {
+		err := t.Files().ForEach(func(f *object.File) error {
+			match(f.Name)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, ok := range matched {
+		if !ok {
+			return ErrPathspecNotMatched
+		}
+	}
+
+	return nil
+}
+
+// matchPaths returns true if name matches one of paths, as matchPath does.
+func matchPaths(paths []string, name string) bool {
+	for _, p := range paths {
+		if matchPath(p, name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// matchPath returns true if name is p, is contained in p or matches p as a
+// glob pattern. As in git pathspecs, the wildcards match the slashes, e.g.
+// "*.go" matches "foo/bar.go".
+func matchPath(p, name string) bool {
+	if p == "." || p == name || strings.HasPrefix(name, p+"/") {
+		return true
+	}
+
+	// path.Match wildcards don't match the separator, replaced by a byte not
+	// allowed in a path
+	ok, _ := path.Match(strings.Replace(p, "/", "\x00", -1), strings.Replace(name, "/", "\x00", -1))
+	return ok
+}
@@ -0,0 +1,147 @@
+package git
+
+import (
+	"gopkg.in/src-d/go-git.v4/plumbing"
+
+	. "gopkg.in/check.v1"
+	"gopkg.in/src-d/go-billy.v4/util"
+)
+
+func (s *WorktreeSuite) TestRestoreWorktree(c *C) {
+	w, fs := s.newWorktree(c)
+
+	c.Assert(util.WriteFile(fs, "LICENSE", []byte("foo"), 0644), IsNil)
+	c.Assert(util.WriteFile(fs, "CHANGELOG", []byte("bar"), 0644), IsNil)
+	c.Assert(fs.Remove("json/short.json"), IsNil)
+	c.Assert(util.WriteFile(fs, "json/untracked.json", []byte("qux"), 0644), IsNil)
+
+	err := w.Restore(&RestoreOptions{Paths: []string{"LICENSE", "json"}})
+	c.Assert(err, IsNil)
+
+	status, err := w.Status()
+	c.Assert(err, IsNil)
+	c.Assert(status, HasLen, 2)
+	c.Assert(status.File("CHANGELOG").Worktree, Equals, Modified)
+	c.Assert(status.File("json/untracked.json").Worktree, Equals, Untracked)
+
+	c.Assert(fileContent(c, fs, "CHANGELOG"), Equals, "bar")
+}
+
+func (s *WorktreeSuite) TestRestoreWorktreeFromIndex(c *C) {
+	w, fs := s.newWorktree(c)
+
+	c.Assert(util.WriteFile(fs, "LICENSE", []byte("foo"), 0644), IsNil)
+	_, err := w.Add("LICENSE")
+	c.Assert(err, IsNil)
+
+	c.Assert(util.WriteFile(fs, "LICENSE", []byte("bar"), 0644), IsNil)
+
+	err = w.Restore(&RestoreOptions{Paths: []string{"LICENSE"}})
+	c.Assert(err, IsNil)
+	c.Assert(fileContent(c, fs, "LICENSE"), Equals, "foo")
+
+	status, err := w.Status()
+	c.Assert(err, IsNil)
+	c.Assert(status, HasLen, 1)
+	c.Assert(status.File("LICENSE").Staging, Equals, Modified)
+	c.Assert(status.File("LICENSE").Worktree, Equals, Unmodified)
+}
+
+func (s *WorktreeSuite) TestRestoreStaged(c *C) {
+	w, fs := s.newWorktree(c)
+
+	c.Assert(util.WriteFile(fs, "LICENSE", []byte("foo"), 0644), IsNil)
+	c.Assert(util.WriteFile(fs, "CHANGELOG", []byte("bar"), 0644), IsNil)
+	c.Assert(w.AddGlob("*"), IsNil)
+
+	err := w.Restore(&RestoreOptions{Staged: true, Paths: []string{"LICENSE"}})
+	c.Assert(err, IsNil)
+
+	status, err := w.Status()
+	c.Assert(err, IsNil)
+	c.Assert(status, HasLen, 2)
+	c.Assert(status.File("LICENSE").Staging, Equals, Unmodified)
+	c.Assert(status.File("LICENSE").Worktree, Equals, Modified)
+	c.Assert(status.File("CHANGELOG").Staging, Equals, Modified)
+
+	c.Assert(fileContent(c, fs, "LICENSE"), Equals, "foo")
+}
+
+func (s *WorktreeSuite) TestRestoreStagedAndWorktreeFromSource(c *C) {
+	w, fs := s.newWorktree(c)
+
+	// README is added and vendor/foo.go is deleted at the source
+	source := plumbing.NewHash("e8d3ffab552895c19b9fcf7aa264d277cde33881")
+	err := w.Restore(&RestoreOptions{
+		Source:   source,
+		Staged:   true,
+		Worktree: true,
+		Paths:    []string{"README", "vendor", "LICENSE"},
+	})
+	c.Assert(err, IsNil)
+
+	_, err = fs.Stat("vendor/foo.go")
+	c.Assert(err, NotNil)
+
+	head, err := w.r.Head()
+	c.Assert(err, IsNil)
+	c.Assert(head.Hash(), Equals, plumbing.NewHash("6ecf0ef2c2dffb796033e5a02219af86ec6584e5"))
+
+	status, err := w.Status()
+	c.Assert(err, IsNil)
+	c.Assert(status, HasLen, 2)
+	c.Assert(status.File("README").Staging, Equals, Added)
+	c.Assert(status.File("README").Worktree, Equals, Unmodified)
+	c.Assert(status.File("vendor/foo.go").Staging, Equals, Deleted)
+	c.Assert(status.File("vendor/foo.go").Worktree, Equals, Unmodified)
+}
+
+func (s *WorktreeSuite) TestRestoreWorktreeFromSource(c *C) {
+	w, _ := s.newWorktree(c)
+
+	source := plumbing.NewHash("b8e471f58bcbca63b07bda20e428190409c2db47")
+	err := w.Restore(&RestoreOptions{Source: source, Paths: []string{"*.go"}})
+	c.Assert(err, IsNil)
+
+	status, err := w.Status()
+	c.Assert(err, IsNil)
+	c.Assert(status, HasLen, 2)
+	c.Assert(status.File("go/example.go").Staging, Equals, Unmodified)
+	c.Assert(status.File("go/example.go").Worktree, Equals, Deleted)
+	c.Assert(status.File("vendor/foo.go").Worktree, Equals, Deleted)
+}
+
+func (s *WorktreeSuite) TestRestoreNoPaths(c *C) {
+	w, _ := s.newWorktree(c)
+
+	err := w.Restore(&RestoreOptions{})
+	c.Assert(err, Equals, ErrNoRestorePaths)
+}
+
+func (s *WorktreeSuite) TestRestorePathspecNotMatched(c *C) {
+	w, fs := s.newWorktree(c)
+
+	c.Assert(util.WriteFile(fs, "LICENSE", []byte("foo"), 0644), IsNil)
+
+	err := w.Restore(&RestoreOptions{Paths: []string{"LICENSE", "missing"}})
+	c.Assert(err, Equals, ErrPathspecNotMatched)
+	c.Assert(fileContent(c, fs, "LICENSE"), Equals, "foo")
+
+	err = w.Restore(&RestoreOptions{
+		Paths:  []string{"*.md"},
+		Source: plumbing.NewHash("b029517f6300c2da0f4b651b8642506cd6aaf45d"),
+	})
+	c.Assert(err, Equals, ErrPathspecNotMatched)
+}
+
+func (s *WorktreeSuite) TestMatchPaths(c *C) {
+	paths := []string{"foo", "*.go", "docs/*.md"}
+	c.Assert(matchPaths(paths, "foo"), Equals, true)
+	c.Assert(matchPaths(paths, "foo/bar"), Equals, true)
+	c.Assert(matchPaths(paths, "foobar"), Equals, false)
+	c.Assert(matchPaths(paths, "bar.go"), Equals, true)
+	c.Assert(matchPaths(paths, "bar/qux.go"), Equals, true)
+	c.Assert(matchPaths(paths, "docs/api/index.md"), Equals, true)
+	c.Assert(matchPaths(paths, "bar/index.md"), Equals, false)
+	c.Assert(matchPaths([]string{"."}, "bar/index.md"), Equals, true)
+}
@@ -0,0 +1,434 @@
+package git
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"gopkg.in/src-d/go-billy.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing/format/gitignore"
+	"gopkg.in/src-d/go-git.v4/plumbing/format/index"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+	"gopkg.in/src-d/go-git.v4/utils/ioutil"
+	"gopkg.in/src-d/go-git.v4/utils/merkletrie"
+)
+
+const sparseCheckoutFile = "info/sparse-checkout"
+
+var (
+	// ErrSparseCheckoutUnsupportedStorage is returned when the sparse
+	// checkout is configured in a repository not stored in a filesystem.
+	ErrSparseCheckoutUnsupportedStorage = errors.New("sparse checkout requires a filesystem based storage")
+)
+
+// SetSparseCheckout enables the sparse checkout, writing the patterns of the
+// given options to $GIT_DIR/info/sparse-checkout, and applies it to the
+// worktree: the paths not matching the patterns are removed from the worktree
+// and flagged as skip-worktree in the index, the matching paths missing in the
+// worktree are checked out.
+func (w *Worktree) SetSparseCheckout(opts *SparseCheckoutOptions) error {
+	if err := opts.Validate(); err != nil {
+		return err
+	}
+
+	fs, ok := w.r.dotGitFilesystem()
+	if !ok {
+		return ErrSparseCheckoutUnsupportedStorage
+	}
+
+	if err := writeSparseCheckoutFile(fs, opts); err != nil {
+		return err
+	}
+
+	cfg, err := w.r.Config()
+	if err != nil {
+		return err
+	}
+
+	cfg.Core.SparseCheckout = true
+	cfg.Core.SparseCheckoutCone = opts.Cone
+	if err := w.r.Storer.SetConfig(cfg); err != nil {
+		return err
+	}
+
+	return w.ApplySparseCheckout()
+}
+
+// DisableSparseCheckout disables the sparse checkout, checking out all the
+// paths flagged as skip-worktree in the index. The
+// $GIT_DIR/info/sparse-checkout file is kept.
+func (w *Worktree) DisableSparseCheckout() error {
+	cfg, err := w.r.Config()
+	if err != nil {
+		return err
+	}
+
+	cfg.Core.SparseCheckout = false
+	if err := w.r.Storer.SetConfig(cfg); err != nil {
+		return err
+	}
+
+	return w.ApplySparseCheckout()
+}
+
+// SparseCheckout returns the patterns of the sparse checkout, nil if the
+// sparse checkout is not enabled.
+func (w *Worktree) SparseCheckout() (*SparseCheckoutOptions, error) {
+	cfg, err := w.r.Config()
+	if err != nil {
+		return nil, err
+	}
+
+	if !cfg.Core.SparseCheckout {
+		return nil, nil
+	}
+
+	fs, ok := w.r.dotGitFilesystem()
+	if !ok {
+		return nil, ErrSparseCheckoutUnsupportedStorage
+	}
+
+	lines, err := readSparseCheckoutFile(fs)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.Core.SparseCheckoutCone {
+		if dirs, ok := parseConePatterns(lines); ok {
+			return &SparseCheckoutOptions{Cone: true, Patterns: dirs}, nil
+		}
+	}
+
+	return &SparseCheckoutOptions{Patterns: lines}, nil
+}
+
+// ApplySparseCheckout applies the current sparse checkout patterns to the
+// worktree, e.g. after changing the $GIT_DIR/info/sparse-checkout file. The
+// files excluded by the patterns are removed from the worktree unless they
+// contain changes, the files included and flagged as skip-worktree are
+// checked out.
+func (w *Worktree) ApplySparseCheckout() error {
+	sparse, err := w.sparseCheckout()
+	if err != nil {
+		return err
+	}
+
+	changes, err := w.diffStagingWithWorktree(false)
+	if err != nil {
+		return err
+	}
+
+	modified := make(map[string]bool)
+	for _, ch := range changes {
+		modified[nameFromAction(&ch)] = true
+	}
+
+	idx, err := w.r.Storer.Index()
+	if err != nil {
+		return err
+	}
+
+	conv, err := w.newContentConverter()
+	if err != nil {
+		return err
+	}
+
+	for _, e := range idx.Entries {
+		include := sparse.Match(e.Name)
+		switch {
+		case include && e.SkipWorktree:
+			if err := w.checkoutIndexEntry(e, conv); err != nil {
+				return err
+			}
+
+			e.SkipWorktree = false
+		case !include && !e.SkipWorktree && !modified[e.Name]:
+			err := rmFileAndDirIfEmpty(w.Filesystem, e.Name)
+			if err != nil && !os.IsNotExist(err) {
+				return err
+			}
+
+			e.SkipWorktree = true
+		}
+	}
+
+	setIndexVersion(idx)
+	return w.r.Storer.SetIndex(idx)
+}
+
+// checkoutIndexEntry writes the blob of the index entry e to the worktree,
+// updating the stat information of the entry.
+func (w *Worktree) checkoutIndexEntry(e *index.Entry, conv *contentConverter) error {
+	blob, err := w.r.BlobObject(e.Hash)
+	if err != nil {
+		return err
+	}
+
+	if err := w.checkoutFile(object.NewFile(e.Name, e.Mode, blob), conv); err != nil {
+		return err
+	}
+
+	fi, err := w.Filesystem.Lstat(e.Name)
+	if err != nil {
+		return err
+	}
+
+	e.ModifiedAt = fi.ModTime()
+	e.Size = uint32(fi.Size())
+	if fillSystemInfo != nil {
+		fillSystemInfo(e, fi.Sys())
+	}
+
+	return nil
+}
+
+// setIndexVersion sets the minimum index version able to encode the entries
+// of idx, the skip-worktree flag requires the version 3.
+func setIndexVersion(idx *index.Index) {
+	if idx.Version > 3 {
+		return
+	}
+
+	idx.Version = 2
+	for _, e := range idx.Entries {
+		if e.SkipWorktree || e.IntentToAdd {
+			idx.Version = 3
+			return
+		}
+	}
+}
+
+// excludeSkipWorktreeChanges removes from changes the paths flagged as
+// skip-worktree in the index, the ones excluded by the sparse checkout.
+func excludeSkipWorktreeChanges(changes merkletrie.Changes, idx *index.Index) merkletrie.Changes {
+	skip := make(map[string]bool)
+	for _, e := range idx.Entries {
+		if e.SkipWorktree {
+			skip[e.Name] = true
+		}
+	}
+
+	if len(skip) == 0 {
+		return changes
+	}
+
+	var res merkletrie.Changes
+	for _, ch := range changes {
+		if skip[nameFromAction(&ch)] {
+			continue
+		}
+
+		res = append(res, ch)
+	}
+
+	return res
+}
+
+// sparseCheckout returns the sparse checkout patterns, nil if the sparse
+// checkout is not enabled.
+func (w *Worktree) sparseCheckout() (*sparseCheckout, error) {
+	opts, err := w.SparseCheckout()
+	if err != nil || opts == nil {
+		return nil, err
+	}
+
+	if opts.Cone {
+		return newConeSparseCheckout(opts.Patterns), nil
+	}
+
+	var ps []gitignore.Pattern
+	for _, p := range opts.Patterns {
+		ps = append(ps, gitignore.ParsePattern(p, nil))
+	}
+
+	return &sparseCheckout{patterns: gitignore.NewMatcher(ps)}, nil
+}
+
+// sparseCheckout matches the paths included in a sparse checkout, using the
+// gitignore syntax, or the directories of the cone mode.
+type sparseCheckout struct {
+	patterns gitignore.Matcher
+
+	cone      bool
+	recursive map[string]bool
+	parents   map[string]bool
+}
+
+func newConeSparseCheckout(dirs []string) *sparseCheckout {
+	s := &sparseCheckout{
+		cone:      true,
+		recursive: make(map[string]bool),
+		parents:   make(map[string]bool),
+	}
+
+	for _, dir := range dirs {
+		s.recursive[dir] = true
+		for p := path.Dir(dir); p != "."; p = path.Dir(p) {
+			s.parents[p] = true
+		}
+	}
+
+	return s
+}
+
+// Match returns true if the file at path is included in the sparse checkout,
+// all the files are included if s is nil.
+func (s *sparseCheckout) Match(name string) bool {
+	if s == nil {
+		return true
+	}
+
+	if s.cone {
+		dir := path.Dir(name)
+		if dir == "." || s.parents[dir] {
+			return true
+		}
+
+		for ; dir != "."; dir = path.Dir(dir) {
+			if s.recursive[dir] {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	return s.patterns.Match(strings.Split(name, "/"), false)
+}
+
+func readSparseCheckoutFile(fs billy.Filesystem) (lines []string, err error) {
+	f, err := fs.Open(sparseCheckoutFile)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer ioutil.CheckClose(f, &err)
+
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		lines = append(lines, line)
+	}
+
+	return lines, s.Err()
+}
+
+func writeSparseCheckoutFile(fs billy.Filesystem, opts *SparseCheckoutOptions) (err error) {
+	lines := opts.Patterns
+	if opts.Cone {
+		lines = conePatterns(opts.Patterns)
+	}
+
+	buf := bytes.NewBuffer(nil)
+	for _, l := range lines {
+		buf.WriteString(l)
+		buf.WriteByte('\n')
+	}
+
+	if err := fs.MkdirAll(path.Dir(sparseCheckoutFile), os.ModeDir|os.ModePerm); err != nil {
+		return err
+	}
+
+	f, err := fs.Create(sparseCheckoutFile)
+	if err != nil {
+		return err
+	}
+
+	defer ioutil.CheckClose(f, &err)
+
+	_, err = f.Write(buf.Bytes())
+	return err
+}
+
+// conePatterns returns the patterns of the cone mode including the files of
+// the root, the given directories recursively and the files of their parent
+// directories, in the same way git does:
+//
+//   /*
+//   !/*/
+//   /foo/
+//   !/foo/*/
+//   /foo/bar/
+func conePatterns(dirs []string) []string {
+	recursive := make(map[string]bool)
+	parents := make(map[string]bool)
+	for _, dir := range dirs {
+		recursive[dir] = true
+	}
+
+	for _, dir := range dirs {
+		for p := path.Dir(dir); p != "."; p = path.Dir(p) {
+			if !recursive[p] {
+				parents[p] = true
+			}
+		}
+	}
+
+	var all []string
+	for dir := range recursive {
+		// the directories inside other recursive directories are redundant
+		redundant := false
+		for p := path.Dir(dir); p != "."; p = path.Dir(p) {
+			redundant = redundant || recursive[p]
+		}
+
+		if !redundant {
+			all = append(all, dir)
+		}
+	}
+
+	for dir := range parents {
+		all = append(all, dir)
+	}
+
+	sort.Strings(all)
+
+	lines := []string{"/*", "!/*/"}
+	for _, dir := range all {
+		lines = append(lines, "/"+dir+"/")
+		if parents[dir] {
+			lines = append(lines, "!/"+dir+"/*/")
+		}
+	}
+
+	return lines
+}
+
+// parseConePatterns returns the recursive directories of the given cone mode
+// patterns, false if the patterns are not valid cone mode patterns.
+func parseConePatterns(lines []string) ([]string, bool) {
+	var dirs []string
+	parents := make(map[string]bool)
+	for _, l := range lines {
+		switch {
+		case l == "/*" || l == "!/*/":
+		case strings.HasPrefix(l, "!/") && strings.HasSuffix(l, "/*/"):
+			parents[strings.TrimSuffix(l[2:], "/*/")] = true
+		case strings.HasPrefix(l, "/") && strings.HasSuffix(l, "/") && len(l) > 2:
+			dirs = append(dirs, strings.Trim(l, "/"))
+		default:
+			return nil, false
+		}
+	}
+
+	var recursive []string
+	for _, dir := range dirs {
+		if !parents[dir] {
+			recursive = append(recursive, dir)
+		}
+	}
+
+	return recursive, true
+}
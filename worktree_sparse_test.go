@@ -0,0 +1,185 @@
+package git
+
+import (
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/storage/filesystem"
+	"gopkg.in/src-d/go-git.v4/storage/memory"
+
+	. "gopkg.in/check.v1"
+	"gopkg.in/src-d/go-billy.v4/memfs"
+	"gopkg.in/src-d/go-billy.v4/util"
+)
+
+func (s *WorktreeSuite) assertSparseFiles(c *C, w *Worktree, files ...string) {
+	expected := make(map[string]bool)
+	for _, f := range files {
+		expected[f] = true
+	}
+
+	idx, err := w.r.Storer.Index()
+	c.Assert(err, IsNil)
+
+	for _, e := range idx.Entries {
+		_, err := w.Filesystem.Lstat(e.Name)
+		c.Assert(err == nil, Equals, expected[e.Name], Commentf("file: %s", e.Name))
+		c.Assert(e.SkipWorktree, Equals, !expected[e.Name], Commentf("file: %s", e.Name))
+	}
+
+	status, err := w.Status()
+	c.Assert(err, IsNil)
+	c.Assert(status.IsClean(), Equals, true)
+}
+
+var basicRootFiles = []string{".gitignore", "CHANGELOG", "LICENSE", "binary.jpg"}
+
+func (s *WorktreeSuite) TestSetSparseCheckoutCone(c *C) {
+	w, _ := s.newWorktree(c)
+	dotgit := s.Repository.Storer.(*filesystem.Storage).Filesystem()
+
+	err := w.SetSparseCheckout(&SparseCheckoutOptions{Cone: true, Patterns: []string{"/go/"}})
+	c.Assert(err, IsNil)
+	s.assertSparseFiles(c, w, append(basicRootFiles, "go/example.go")...)
+
+	c.Assert(fileContent(c, dotgit, "info/sparse-checkout"), Equals, "/*\n!/*/\n/go/\n")
+
+	idx, err := w.r.Storer.Index()
+	c.Assert(err, IsNil)
+	c.Assert(idx.Version, Equals, uint32(3))
+
+	cfg, err := w.r.Config()
+	c.Assert(err, IsNil)
+	c.Assert(cfg.Core.SparseCheckout, Equals, true)
+	c.Assert(cfg.Core.SparseCheckoutCone, Equals, true)
+
+	opts, err := w.SparseCheckout()
+	c.Assert(err, IsNil)
+	c.Assert(opts, DeepEquals, &SparseCheckoutOptions{Cone: true, Patterns: []string{"go"}})
+
+	err = w.SetSparseCheckout(&SparseCheckoutOptions{Cone: true, Patterns: []string{"json", "vendor"}})
+	c.Assert(err, IsNil)
+	s.assertSparseFiles(c, w, append(basicRootFiles, "json/long.json", "json/short.json", "vendor/foo.go")...)
+}
+
+func (s *WorktreeSuite) TestSetSparseCheckoutPatterns(c *C) {
+	w, _ := s.newWorktree(c)
+
+	err := w.SetSparseCheckout(&SparseCheckoutOptions{Patterns: []string{"/json/", "LICENSE", "*.go"}})
+	c.Assert(err, IsNil)
+	s.assertSparseFiles(c, w, "LICENSE", "json/long.json", "json/short.json", "go/example.go", "vendor/foo.go")
+
+	opts, err := w.SparseCheckout()
+	c.Assert(err, IsNil)
+	c.Assert(opts, DeepEquals, &SparseCheckoutOptions{Patterns: []string{"/json/", "LICENSE", "*.go"}})
+}
+
+func (s *WorktreeSuite) TestSetSparseCheckoutModified(c *C) {
+	w, fs := s.newWorktree(c)
+	c.Assert(util.WriteFile(fs, "php/crappy.php", []byte("foo"), 0644), IsNil)
+
+	err := w.SetSparseCheckout(&SparseCheckoutOptions{Cone: true})
+	c.Assert(err, IsNil)
+
+	// the modified files are kept
+	_, err = fs.Lstat("php/crappy.php")
+	c.Assert(err, IsNil)
+
+	status, err := w.Status()
+	c.Assert(err, IsNil)
+	c.Assert(status, HasLen, 1)
+	c.Assert(status.File("php/crappy.php").Worktree, Equals, Modified)
+}
+
+func (s *WorktreeSuite) TestDisableSparseCheckout(c *C) {
+	w, _ := s.newWorktree(c)
+
+	err := w.SetSparseCheckout(&SparseCheckoutOptions{Cone: true})
+	c.Assert(err, IsNil)
+	s.assertSparseFiles(c, w, basicRootFiles...)
+
+	err = w.DisableSparseCheckout()
+	c.Assert(err, IsNil)
+	s.assertSparseFiles(c, w,
+		".gitignore", "CHANGELOG", "LICENSE", "binary.jpg", "go/example.go",
+		"json/long.json", "json/short.json", "php/crappy.php", "vendor/foo.go",
+	)
+
+	idx, err := w.r.Storer.Index()
+	c.Assert(err, IsNil)
+	c.Assert(idx.Version, Equals, uint32(2))
+
+	opts, err := w.SparseCheckout()
+	c.Assert(err, IsNil)
+	c.Assert(opts, IsNil)
+}
+
+func (s *WorktreeSuite) TestCheckoutSparse(c *C) {
+	w, _ := s.newWorktree(c)
+
+	err := w.SetSparseCheckout(&SparseCheckoutOptions{Cone: true, Patterns: []string{"json"}})
+	c.Assert(err, IsNil)
+
+	err = w.Checkout(&CheckoutOptions{
+		Hash: plumbing.NewHash("b029517f6300c2da0f4b651b8642506cd6aaf45d"),
+	})
+	c.Assert(err, IsNil)
+	s.assertSparseFiles(c, w, ".gitignore", "CHANGELOG", "LICENSE")
+
+	err = w.Checkout(&CheckoutOptions{
+		Hash: plumbing.NewHash("6ecf0ef2c2dffb796033e5a02219af86ec6584e5"),
+	})
+	c.Assert(err, IsNil)
+	s.assertSparseFiles(c, w, append(basicRootFiles, "json/long.json", "json/short.json")...)
+}
+
+func (s *WorktreeSuite) TestSetSparseCheckoutUnsupportedStorage(c *C) {
+	r, err := Init(memory.NewStorage(), memfs.New())
+	c.Assert(err, IsNil)
+
+	w, err := r.Worktree()
+	c.Assert(err, IsNil)
+
+	err = w.SetSparseCheckout(&SparseCheckoutOptions{Cone: true})
+	c.Assert(err, Equals, ErrSparseCheckoutUnsupportedStorage)
+}
+
+func (s *WorktreeSuite) TestSparseCheckoutOptionsValidate(c *C) {
+	opts := &SparseCheckoutOptions{Cone: true, Patterns: []string{"/foo/", "foo/bar/../qux"}}
+	c.Assert(opts.Validate(), IsNil)
+	c.Assert(opts.Patterns, DeepEquals, []string{"foo", "foo/qux"})
+
+	for _, p := range []string{"*.go", "/", "!foo"} {
+		opts := &SparseCheckoutOptions{Cone: true, Patterns: []string{p}}
+		c.Assert(opts.Validate(), Equals, ErrInvalidConePattern)
+	}
+
+	opts = &SparseCheckoutOptions{Patterns: []string{"*.go"}}
+	c.Assert(opts.Validate(), IsNil)
+}
+
+func (s *WorktreeSuite) TestConePatterns(c *C) {
+	lines := conePatterns([]string{"d", "a/b/c", "a/b/c/e"})
+	c.Assert(lines, DeepEquals, []string{
+		"/*", "!/*/",
+		"/a/", "!/a/*/",
+		"/a/b/", "!/a/b/*/",
+		"/a/b/c/",
+		"/d/",
+	})
+
+	dirs, ok := parseConePatterns(lines)
+	c.Assert(ok, Equals, true)
+	c.Assert(dirs, DeepEquals, []string{"a/b/c", "d"})
+
+	_, ok = parseConePatterns([]string{"/*", "*.go"})
+	c.Assert(ok, Equals, false)
+}
+
+func (s *WorktreeSuite) TestConeMatch(c *C) {
+	m := newConeSparseCheckout([]string{"a/b"})
+	c.Assert(m.Match("foo"), Equals, true)
+	c.Assert(m.Match("a/foo"), Equals, true)
+	c.Assert(m.Match("a/b/foo"), Equals, true)
+	c.Assert(m.Match("a/b/c/foo"), Equals, true)
+	c.Assert(m.Match("a/c/foo"), Equals, false)
+	c.Assert(m.Match("c/foo"), Equals, false)
+}
@@ -0,0 +1,110 @@
+package git
+
+import (
+	"os"
+
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/filemode"
+	"gopkg.in/src-d/go-git.v4/plumbing/format/index"
+	"gopkg.in/src-d/go-git.v4/utils/merkletrie"
+)
+
+// emptyBlobHash is the hash of the blob with no content.
+var emptyBlobHash = plumbing.ComputeHash(plumbing.BlobObject, nil)
+
+// statCache provides the hashes of the files of the worktree whose stat
+// information matches their index entries, so their content is not read. The
+// racily clean entries are not trusted. The files hashed because of a stat
+// mismatch are recorded, so their stat information can be refreshed in the
+// index if they turn out to be unchanged.
+type statCache struct {
+	idx     *index.Index
+	entries map[string]*index.Entry
+	stale   map[string]os.FileInfo
+}
+
+func newStatCache(idx *index.Index) *statCache {
+	c := &statCache{
+		idx:     idx,
+		entries: make(map[string]*index.Entry, len(idx.Entries)),
+		stale:   make(map[string]os.FileInfo),
+	}
+
+	for _, e := range idx.Entries {
+		if e.Stage == 0 {
+			c.entries[e.Name] = e
+		}
+	}
+
+	return c
+}
+
+// Hash returns the hash of the index entry of the file name, if its stat
+// information matches fi. It is used as filesystem.Options.CachedHash.
+func (c *statCache) Hash(name string, fi os.FileInfo) (plumbing.Hash, bool) {
+	e, ok := c.entries[name]
+	if !ok || e.IntentToAdd || e.SkipWorktree {
+		return plumbing.ZeroHash, false
+	}
+
+	if !c.idx.IsRacilyClean(e) && statMatches(e, fi) {
+		return e.Hash, true
+	}
+
+	c.stale[name] = fi
+	return plumbing.ZeroHash, false
+}
+
+// Refresh updates the stat information of the index entries of the stale
+// files without changes. Returns true if any entry was updated.
+func (c *statCache) Refresh(changes merkletrie.Changes) bool {
+	for _, ch := range changes {
+		delete(c.stale, nameFromAction(&ch))
+	}
+
+	for name, fi := range c.stale {
+		e := c.entries[name]
+		e.ModifiedAt = fi.ModTime()
+		e.Size = uint32(fi.Size())
+		if fillSystemInfo != nil {
+			fillSystemInfo(e, fi.Sys())
+		}
+	}
+
+	return len(c.stale) != 0
+}
+
+// statMatches returns true if the stat information of the index entry e
+// matches fi, as git does comparing the mode, size, modification time and,
+// when available, the change time, device, inode and owner.
+func statMatches(e *index.Entry, fi os.FileInfo) bool {
+	mode, err := filemode.NewFromOSFileMode(fi.Mode())
+	if err != nil || mode != e.Mode {
+		return false
+	}
+
+	// a zero size of a non-empty blob is a smudged racily clean entry
+	if e.Size != uint32(fi.Size()) || (e.Size == 0 && e.Hash != emptyBlobHash) {
+		return false
+	}
+
+	if !e.ModifiedAt.Equal(fi.ModTime()) {
+		return false
+	}
+
+	if fillSystemInfo == nil {
+		return true
+	}
+
+	sys := &index.Entry{}
+	fillSystemInfo(sys, fi.Sys())
+	if sys.Inode == 0 && sys.CreatedAt.IsZero() {
+		return true
+	}
+
+	return e.CreatedAt.Equal(sys.CreatedAt) &&
+		e.Dev == sys.Dev &&
+		e.Inode == sys.Inode &&
+		e.UID == sys.UID &&
+		e.GID == sys.GID
+}
@@ -0,0 +1,116 @@
+package git
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"gopkg.in/src-d/go-git.v4/plumbing"
+
+	. "gopkg.in/check.v1"
+)
+
+// setIndexHash replaces the hash of the index entry foo, keeping its stat
+// information, so the status shows if the file was hashed.
+func (s *WorktreeSuite) setIndexHash(c *C, w *Worktree, h plumbing.Hash) {
+	idx, err := w.r.Storer.Index()
+	c.Assert(err, IsNil)
+
+	e, err := idx.Entry("foo")
+	c.Assert(err, IsNil)
+	e.Hash = h
+	c.Assert(w.r.Storer.SetIndex(idx), IsNil)
+}
+
+func (s *WorktreeSuite) TestStatusStatCache(c *C) {
+	w, dir := s.newPlainInitWorktree(c, map[string]string{"foo": "foo\n"}, time.Now().Add(-time.Hour))
+	defer os.RemoveAll(dir)
+
+	s.setIndexHash(c, w, plumbing.ComputeHash(plumbing.BlobObject, []byte("bar\n")))
+
+	status, err := w.Status()
+	c.Assert(err, IsNil)
+	c.Assert(status.File("foo").Worktree, Equals, Unmodified)
+}
+
+func (s *WorktreeSuite) TestStatusStatCacheRacilyClean(c *C) {
+	w, dir := s.newPlainInitWorktree(c, map[string]string{"foo": "foo\n"}, time.Now().Add(time.Hour))
+	defer os.RemoveAll(dir)
+
+	s.setIndexHash(c, w, plumbing.ComputeHash(plumbing.BlobObject, []byte("bar\n")))
+
+	status, err := w.Status()
+	c.Assert(err, IsNil)
+	c.Assert(status.File("foo").Worktree, Equals, Modified)
+}
+
+func (s *WorktreeSuite) TestStatusStatCacheStale(c *C) {
+	w, dir := s.newPlainInitWorktree(c, map[string]string{"foo": "foo\n"}, time.Now().Add(-time.Hour))
+	defer os.RemoveAll(dir)
+
+	mtime := time.Now().Add(-time.Minute).Truncate(time.Second)
+	c.Assert(os.Chtimes(filepath.Join(dir, "foo"), mtime, mtime), IsNil)
+
+	status, err := w.Status()
+	c.Assert(err, IsNil)
+	c.Assert(status.File("foo").Worktree, Equals, Unmodified)
+
+	idx, err := w.r.Storer.Index()
+	c.Assert(err, IsNil)
+	e, err := idx.Entry("foo")
+	c.Assert(err, IsNil)
+	c.Assert(e.ModifiedAt.Equal(mtime), Equals, false)
+
+	status, err = w.StatusWithOptions(&StatusOptions{RefreshIndex: true})
+	c.Assert(err, IsNil)
+	c.Assert(status.File("foo").Worktree, Equals, Unmodified)
+
+	idx, err = w.r.Storer.Index()
+	c.Assert(err, IsNil)
+	e, err = idx.Entry("foo")
+	c.Assert(err, IsNil)
+	c.Assert(e.ModifiedAt.Equal(mtime), Equals, true)
+	c.Assert(e.Size, Equals, uint32(4))
+
+	// the refreshed stat information is used by the next status
+	s.setIndexHash(c, w, plumbing.ComputeHash(plumbing.BlobObject, []byte("bar\n")))
+
+	status, err = w.Status()
+	c.Assert(err, IsNil)
+	c.Assert(status.File("foo").Worktree, Equals, Unmodified)
+}
+
+func (s *WorktreeSuite) TestStatusContentChanged(c *C) {
+	w, dir := s.newPlainInitWorktree(c, map[string]string{"foo": "foo\n"}, time.Now().Add(-time.Hour))
+	defer os.RemoveAll(dir)
+
+	// same size and modification time, the change time differs
+	name := filepath.Join(dir, "foo")
+	fi, err := os.Stat(name)
+	c.Assert(err, IsNil)
+	c.Assert(ioutil.WriteFile(name, []byte("bar\n"), 0644), IsNil)
+	c.Assert(os.Chtimes(name, fi.ModTime(), fi.ModTime()), IsNil)
+
+	status, err := w.Status()
+	c.Assert(err, IsNil)
+	c.Assert(status.File("foo").Worktree, Equals, Modified)
+}
+
+func (s *WorktreeSuite) TestStatusDeviceChanged(c *C) {
+	w, dir := s.newPlainInitWorktree(c, map[string]string{"foo": "foo\n"}, time.Now().Add(-time.Hour))
+	defer os.RemoveAll(dir)
+
+	idx, err := w.r.Storer.Index()
+	c.Assert(err, IsNil)
+
+	e, err := idx.Entry("foo")
+	c.Assert(err, IsNil)
+	e.Hash = plumbing.ComputeHash(plumbing.BlobObject, []byte("bar\n"))
+	e.Dev++
+	c.Assert(w.r.Storer.SetIndex(idx), IsNil)
+
+	status, err := w.Status()
+	c.Assert(err, IsNil)
+	c.Assert(status.File("foo").Worktree, Equals, Modified)
+}
@@ -4,9 +4,11 @@ import (
 	"bytes"
 	"errors"
 	"io"
+	stdioutil "io/ioutil"
 	"os"
 	"path"
 	"path/filepath"
+	"runtime"
 
 	"gopkg.in/src-d/go-billy.v4/util"
 	"gopkg.in/src-d/go-git.v4/plumbing"
@@ -32,6 +34,13 @@ var (
 
 // Status returns the working tree status.
 func (w *Worktree) Status() (Status, error) {
+	return w.StatusWithOptions(&StatusOptions{})
+}
+
+// StatusWithOptions returns the working tree status. As git does, the files
+// whose stat information matches their index entries are not read, the rest
+// are hashed concurrently.
+func (w *Worktree) StatusWithOptions(o *StatusOptions) (Status, error) {
 	var hash plumbing.Hash
 
 	ref, err := w.r.Head()
@@ -43,10 +52,10 @@ func (w *Worktree) Status() (Status, error) {
 		hash = ref.Hash()
 	}
 
-	return w.status(hash)
+	return w.status(hash, o)
 }
 
-func (w *Worktree) status(commit plumbing.Hash) (Status, error) {
+func (w *Worktree) status(commit plumbing.Hash, o *StatusOptions) (Status, error) {
 	s := make(Status)
 
 	left, err := w.diffCommitWithStaging(commit, false)
@@ -73,11 +82,33 @@ func (w *Worktree) status(commit plumbing.Hash) (Status, error) {
 		}
 	}
 
-	right, err := w.diffStagingWithWorktree(false)
+	idx, err := w.r.Storer.Index()
+	if err != nil {
+		return nil, err
+	}
+
+	cache := newStatCache(idx)
+	untracked, err := w.newUntrackedCache(idx, cache)
 	if err != nil {
 		return nil, err
 	}
 
+	right, err := w.diffNoderWithWorktree(mindex.NewRootNode(idx), cache, untracked, false)
+	if err != nil {
+		return nil, err
+	}
+
+	changed := o.RefreshIndex && cache.Refresh(right)
+	if untracked != nil && untracked.Update(right) {
+		changed = true
+	}
+
+	if changed {
+		if err := w.r.Storer.SetIndex(idx); err != nil {
+			return nil, err
+		}
+	}
+
 	for _, ch := range right {
 		a, err := ch.Action()
 		if err != nil {
@@ -100,7 +131,30 @@ func (w *Worktree) status(commit plumbing.Hash) (Status, error) {
 		}
 	}
 
-	return s, nil
+	return s, w.intentToAddStatus(s)
+}
+
+// intentToAddStatus sets the status of the files added with intent to add,
+// not staged but added in the worktree.
+func (w *Worktree) intentToAddStatus(s Status) error {
+	idx, err := w.r.Storer.Index()
+	if err != nil {
+		return err
+	}
+
+	for _, e := range idx.Entries {
+		if !e.IntentToAdd {
+			continue
+		}
+
+		fs := s.File(e.Name)
+		fs.Staging = Unmodified
+		if fs.Worktree != Deleted {
+			fs.Worktree = Added
+		}
+	}
+
+	return nil
 }
 
 func nameFromAction(ch *merkletrie.Change) string {
@@ -118,13 +172,45 @@ func (w *Worktree) diffStagingWithWorktree(reverse bool) (merkletrie.Changes, er
 		return nil, err
 	}
 
-	from := mindex.NewRootNode(idx)
+	return w.diffNoderWithWorktree(mindex.NewRootNode(idx), newStatCache(idx), nil, reverse)
+}
+
+// diffTreeWithWorktree returns the changes between the tree t and the
+// worktree, the paths flagged as skip-worktree in the index are excluded.
+func (w *Worktree) diffTreeWithWorktree(t *object.Tree, reverse bool) (merkletrie.Changes, error) {
+	idx, err := w.r.Storer.Index()
+	if err != nil {
+		return nil, err
+	}
+
+	return w.diffNoderWithWorktree(object.NewTreeRootNode(t), newStatCache(idx), nil, reverse)
+}
+
+// diffNoderWithWorktree returns the changes between from and the worktree,
+// the files whose stat information matches the cache are not read. If
+// untracked is not nil, the directories are listed with it.
+func (w *Worktree) diffNoderWithWorktree(from noder.Noder, cache *statCache, untracked *untrackedCache, reverse bool) (merkletrie.Changes, error) {
 	submodules, err := w.getSubmodulesStatus()
 	if err != nil {
 		return nil, err
 	}
 
-	to := filesystem.NewRootNode(w.Filesystem, submodules)
+	conv, err := w.newContentConverter()
+	if err != nil {
+		return nil, err
+	}
+
+	opts := filesystem.Options{
+		Clean:      conv.cleanFunc,
+		CachedHash: cache.Hash,
+		Workers:    runtime.NumCPU(),
+	}
+
+	if untracked != nil {
+		opts.ReadDir = untracked.ReadDir
+	}
+
+	to := filesystem.NewRootNodeWithOptions(w.Filesystem, submodules, opts)
 
 	var c merkletrie.Changes
 	if reverse {
@@ -137,6 +223,11 @@ func (w *Worktree) diffStagingWithWorktree(reverse bool) (merkletrie.Changes, er
 		return nil, err
 	}
 
+	c = excludeSkipWorktreeChanges(c, cache.idx)
+	if untracked != nil {
+		return excludeMatchedChanges(c, untracked), nil
+	}
+
 	return w.excludeIgnoredChanges(c), nil
 }
 
@@ -148,8 +239,11 @@ func (w *Worktree) excludeIgnoredChanges(changes merkletrie.Changes) merkletrie.
 
 	patterns = append(patterns, w.Excludes...)
 
-	m := gitignore.NewMatcher(patterns)
+	return excludeMatchedChanges(changes, gitignore.NewMatcher(patterns))
+}
 
+// excludeMatchedChanges returns the changes whose path is not matched by m.
+func excludeMatchedChanges(changes merkletrie.Changes, m gitignore.Matcher) merkletrie.Changes {
 	var res merkletrie.Changes
 	for _, ch := range changes {
 		var path []string
@@ -271,14 +365,19 @@ func (w *Worktree) Add(path string) (plumbing.Hash, error) {
 		return plumbing.ZeroHash, err
 	}
 
+	conv, err := w.newContentConverter()
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
 	var h plumbing.Hash
 	var added bool
 
 	fi, err := w.Filesystem.Lstat(path)
 	if err != nil || !fi.IsDir() {
-		added, h, err = w.doAddFile(idx, s, path)
+		added, h, err = w.doAddFile(idx, s, conv, path)
 	} else {
-		added, err = w.doAddDirectory(idx, s, path)
+		added, err = w.doAddDirectory(idx, s, conv, path)
 	}
 
 	if err != nil {
@@ -292,7 +391,7 @@ func (w *Worktree) Add(path string) (plumbing.Hash, error) {
 	return h, w.r.Storer.SetIndex(idx)
 }
 
-func (w *Worktree) doAddDirectory(idx *index.Index, s Status, directory string) (added bool, err error) {
+func (w *Worktree) doAddDirectory(idx *index.Index, s Status, conv *contentConverter, directory string) (added bool, err error) {
 	files, err := w.Filesystem.ReadDir(directory)
 	if err != nil {
 		return false, err
@@ -307,9 +406,9 @@ func (w *Worktree) doAddDirectory(idx *index.Index, s Status, directory string)
 				// ignore special git directory
 				continue
 			}
-			a, err = w.doAddDirectory(idx, s, name)
+			a, err = w.doAddDirectory(idx, s, conv, name)
 		} else {
-			a, _, err = w.doAddFile(idx, s, name)
+			a, _, err = w.doAddFile(idx, s, conv, name)
 		}
 
 		if err != nil {
@@ -347,6 +446,11 @@ func (w *Worktree) AddGlob(pattern string) error {
 		return err
 	}
 
+	conv, err := w.newContentConverter()
+	if err != nil {
+		return err
+	}
+
 	var saveIndex bool
 	for _, file := range files {
 		fi, err := w.Filesystem.Lstat(file)
@@ -356,9 +460,9 @@ func (w *Worktree) AddGlob(pattern string) error {
 
 		var added bool
 		if fi.IsDir() {
-			added, err = w.doAddDirectory(idx, s, file)
+			added, err = w.doAddDirectory(idx, s, conv, file)
 		} else {
-			added, _, err = w.doAddFile(idx, s, file)
+			added, _, err = w.doAddFile(idx, s, conv, file)
 		}
 
 		if err != nil {
@@ -377,14 +481,219 @@ func (w *Worktree) AddGlob(pattern string) error {
 	return nil
 }
 
+// AddWithOptions adds the files matching the given options to the index. The
+// index is written once, after adding all the files.
+func (w *Worktree) AddWithOptions(o *AddOptions) error {
+	if err := o.Validate(); err != nil {
+		return err
+	}
+
+	s, err := w.Status()
+	if err != nil {
+		return err
+	}
+
+	idx, err := w.r.Storer.Index()
+	if err != nil {
+		return err
+	}
+
+	conv, err := w.newContentConverter()
+	if err != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(s))
+	for name, fs := range s {
+		if fs.Worktree == Unmodified {
+			continue
+		}
+
+		if len(o.Paths) != 0 && !matchPaths(o.Paths, name) {
+			continue
+		}
+
+		if fs.Worktree == Untracked && o.Update {
+			continue
+		}
+
+		names = append(names, name)
+	}
+
+	if o.Force && len(o.Paths) != 0 {
+		ignored, err := w.ignoredFiles(idx, s, o.Paths)
+		if err != nil {
+			return err
+		}
+
+		names = append(names, ignored...)
+	}
+
+	if !o.IgnoreMissing {
+		if err := checkAddPaths(o.Paths, idx, s, names); err != nil {
+			return err
+		}
+	}
+
+	var saveIndex bool
+	for _, name := range names {
+		var added bool
+		if o.IntentToAdd && s.File(name).Worktree == Untracked {
+			added, err = w.doAddIntentToAdd(idx, name)
+		} else {
+			added, _, err = w.doAddFile(idx, s, conv, name)
+		}
+
+		if err != nil {
+			return err
+		}
+
+		saveIndex = saveIndex || added
+	}
+
+	if !saveIndex {
+		return nil
+	}
+
+	setIndexVersion(idx)
+	return w.r.Storer.SetIndex(idx)
+}
+
+// checkAddPaths returns ErrPathspecNotMatched if one of the paths matches no
+// entry of the index, no file of the status s and none of the names added.
+func checkAddPaths(paths []string, idx *index.Index, s Status, names []string) error {
+	for _, p := range paths {
+		if !matchAnyPath(p, idx, s, names) {
+			return ErrPathspecNotMatched
+		}
+	}
+
+	return nil
+}
+
+func matchAnyPath(p string, idx *index.Index, s Status, names []string) bool {
+	for _, e := range idx.Entries {
+		if matchPath(p, e.Name) {
+			return true
+		}
+	}
+
+	for name := range s {
+		if matchPath(p, name) {
+			return true
+		}
+	}
+
+	for _, name := range names {
+		if matchPath(p, name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ignoredFiles returns the files matching paths not in the index nor in the
+// status s, the ones ignored.
+func (w *Worktree) ignoredFiles(idx *index.Index, s Status, paths []string) ([]string, error) {
+	var files []string
+	for _, p := range paths {
+		matches, err := util.Glob(w.Filesystem, p)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, m := range matches {
+			if files, err = w.doIgnoredFiles(idx, s, filepath.ToSlash(m), files); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	seen := make(map[string]bool)
+	unique := files[:0]
+	for _, f := range files {
+		if !seen[f] {
+			seen[f] = true
+			unique = append(unique, f)
+		}
+	}
+
+	return unique, nil
+}
+
+func (w *Worktree) doIgnoredFiles(idx *index.Index, s Status, name string, files []string) ([]string, error) {
+	fi, err := w.Filesystem.Lstat(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if !fi.IsDir() {
+		if _, ok := s[name]; ok {
+			return files, nil
+		}
+
+		if _, err := idx.Entry(name); err == nil {
+			return files, nil
+		}
+
+		return append(files, name), nil
+	}
+
+	fis, err := w.Filesystem.ReadDir(name)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, fi := range fis {
+		if fi.Name() == GitDirName {
+			continue
+		}
+
+		if files, err = w.doIgnoredFiles(idx, s, path.Join(name, fi.Name()), files); err != nil {
+			return nil, err
+		}
+	}
+
+	return files, nil
+}
+
+// doAddIntentToAdd adds an entry for the file at path flagged as intent to add,
+// with the empty blob, added is false if the entry already exists.
+func (w *Worktree) doAddIntentToAdd(idx *index.Index, path string) (added bool, err error) {
+	if _, err := idx.Entry(path); err == nil {
+		return false, nil
+	}
+
+	obj := w.r.Storer.NewEncodedObject()
+	obj.SetType(plumbing.BlobObject)
+	h, err := w.r.Storer.SetEncodedObject(obj)
+	if err != nil {
+		return false, err
+	}
+
+	if err := w.doAddFileToIndex(idx, path, h); err != nil {
+		return false, err
+	}
+
+	e, err := idx.Entry(path)
+	if err != nil {
+		return false, err
+	}
+
+	e.Size = 0
+	e.IntentToAdd = true
+	return true, nil
+}
+
 // doAddFile create a new blob from path and update the index, added is true if
 // the file added is different from the index.
-func (w *Worktree) doAddFile(idx *index.Index, s Status, path string) (added bool, h plumbing.Hash, err error) {
+func (w *Worktree) doAddFile(idx *index.Index, s Status, conv *contentConverter, path string) (added bool, h plumbing.Hash, err error) {
 	if s.File(path).Worktree == Unmodified {
 		return false, h, nil
 	}
 
-	h, err = w.copyFileToStorage(path)
+	h, err = w.copyFileToStorage(conv, path)
 	if err != nil {
 		if os.IsNotExist(err) {
 			added = true
@@ -401,7 +710,7 @@ func (w *Worktree) doAddFile(idx *index.Index, s Status, path string) (added boo
 	return true, h, err
 }
 
-func (w *Worktree) copyFileToStorage(path string) (hash plumbing.Hash, err error) {
+func (w *Worktree) copyFileToStorage(conv *contentConverter, path string) (hash plumbing.Hash, err error) {
 	fi, err := w.Filesystem.Lstat(path)
 	if err != nil {
 		return plumbing.ZeroHash, err
@@ -421,7 +730,7 @@ func (w *Worktree) copyFileToStorage(path string) (hash plumbing.Hash, err error
 	if fi.Mode()&os.ModeSymlink != 0 {
 		err = w.fillEncodedObjectFromSymlink(writer, path, fi)
 	} else {
-		err = w.fillEncodedObjectFromFile(writer, path, fi)
+		err = w.fillEncodedObjectFromFile(writer, conv, path, fi)
 	}
 
 	if err != nil {
@@ -431,7 +740,7 @@ func (w *Worktree) copyFileToStorage(path string) (hash plumbing.Hash, err error
 	return w.r.Storer.SetEncodedObject(obj)
 }
 
-func (w *Worktree) fillEncodedObjectFromFile(dst io.Writer, path string, fi os.FileInfo) (err error) {
+func (w *Worktree) fillEncodedObjectFromFile(dst io.Writer, conv *contentConverter, path string, fi os.FileInfo) (err error) {
 	src, err := w.Filesystem.Open(path)
 	if err != nil {
 		return err
@@ -439,10 +748,26 @@ func (w *Worktree) fillEncodedObjectFromFile(dst io.Writer, path string, fi os.F
 
 	defer ioutil.CheckClose(src, &err)
 
-	if _, err := io.Copy(dst, src); err != nil {
+	clean, err := conv.addFunc(path)
+	if err != nil {
+		return err
+	}
+
+	if clean == nil {
+		_, err = io.Copy(dst, src)
+		return err
+	}
+
+	content, err := stdioutil.ReadAll(src)
+	if err != nil {
+		return err
+	}
+
+	if content, err = clean(content); err != nil {
 		return err
 	}
 
+	_, err = dst.Write(content)
 	return err
 }
 
@@ -480,6 +805,7 @@ func (w *Worktree) doUpdateFileToIndex(e *index.Entry, filename string, h plumbi
 	}
 
 	e.Hash = h
+	e.IntentToAdd = false
 	e.ModifiedAt = info.ModTime()
 	e.Mode, err = filemode.NewFromOSFileMode(info.Mode())
 	if err != nil {
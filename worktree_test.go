@@ -22,6 +22,7 @@ import (
 
 	"golang.org/x/text/unicode/norm"
 	. "gopkg.in/check.v1"
+	"gopkg.in/src-d/go-billy.v4"
 	"gopkg.in/src-d/go-billy.v4/memfs"
 	"gopkg.in/src-d/go-billy.v4/osfs"
 	"gopkg.in/src-d/go-billy.v4/util"
@@ -1889,11 +1890,13 @@ func (s *WorktreeSuite) TestAddAndCommit(c *C) {
 	_, err = w.Add(".")
 	c.Assert(err, IsNil)
 
-	w.Commit("Test Add And Commit", &CommitOptions{Author: &object.Signature{
-		Name:  "foo",
-		Email: "foo@foo.foo",
-		When:  time.Now(),
-	}})
+	w.Commit("Test Add And Commit", &CommitOptions{
+		Author: &object.Signature{
+			Name:  "foo",
+			Email: "foo@foo.foo",
+			When:  time.Now(),
+		},
+	})
 
 	iter, err := w.r.Log(&LogOptions{})
 	c.Assert(err, IsNil)
@@ -1910,3 +1913,281 @@ func (s *WorktreeSuite) TestAddAndCommit(c *C) {
 	})
 	c.Assert(err, IsNil)
 }
+
+// newWorktree returns a worktree in memory, with the HEAD of the basic
+// fixture checked out.
+func (s *WorktreeSuite) newWorktree(c *C) (*Worktree, billy.Filesystem) {
+	fs := memfs.New()
+	w := &Worktree{
+		r:          s.Repository,
+		Filesystem: fs,
+	}
+
+	err := w.Checkout(&CheckoutOptions{Force: true})
+	c.Assert(err, IsNil)
+
+	return w, fs
+}
+
+func (s *WorktreeSuite) newCleanWorktree(c *C) (*Worktree, billy.Filesystem) {
+	w, fs := s.newWorktree(c)
+	for name, content := range map[string]string{
+		".gitignore":       "*.o\nbuild/\n",
+		"foo":              "foo",
+		"foo.o":            "foo",
+		"go/bar.o":         "bar",
+		"go/bar":           "bar",
+		"build/qux":        "qux",
+		"untracked/qux":    "qux",
+		"untracked/qux.o":  "qux",
+		"nested/.git/HEAD": "ref: refs/heads/master\n",
+		"nested/foo":       "foo",
+	} {
+		c.Assert(util.WriteFile(fs, name, []byte(content), 0644), IsNil)
+	}
+
+	c.Assert(fs.MkdirAll("empty", 0755), IsNil)
+	return w, fs
+}
+
+func (s *WorktreeSuite) TestCleanPaths(c *C) {
+	w, _ := s.newCleanWorktree(c)
+
+	paths, err := w.CleanPaths(&CleanOptions{DryRun: true})
+	c.Assert(err, IsNil)
+	c.Assert(paths, DeepEquals, []string{"foo", "go/bar"})
+
+	paths, err = w.CleanPaths(&CleanOptions{Dir: true, DryRun: true})
+	c.Assert(err, IsNil)
+	c.Assert(paths, DeepEquals, []string{"empty/", "foo", "go/bar", "untracked/qux"})
+
+	paths, err = w.CleanPaths(&CleanOptions{Dir: true})
+	c.Assert(err, IsNil)
+	c.Assert(paths, DeepEquals, []string{"empty/", "foo", "go/bar", "untracked/qux"})
+
+	status, err := w.Status()
+	c.Assert(err, IsNil)
+	c.Assert(status, HasLen, 2)
+	c.Assert(status.File(".gitignore").Worktree, Equals, Modified)
+	c.Assert(status.IsUntracked("nested/foo"), Equals, true)
+}
+
+func (s *WorktreeSuite) TestCleanIgnored(c *C) {
+	w, fs := s.newCleanWorktree(c)
+
+	paths, err := w.CleanPaths(&CleanOptions{Dir: true, Ignored: true, Force: true})
+	c.Assert(err, IsNil)
+	c.Assert(paths, DeepEquals, []string{
+		"build/", "empty/", "foo", "foo.o", "go/bar", "go/bar.o", "nested/", "untracked/",
+	})
+
+	_, err = fs.Stat("nested")
+	c.Assert(os.IsNotExist(err), Equals, true)
+}
+
+func (s *WorktreeSuite) TestCleanOnlyIgnored(c *C) {
+	w, _ := s.newCleanWorktree(c)
+
+	paths, err := w.CleanPaths(&CleanOptions{OnlyIgnored: true})
+	c.Assert(err, IsNil)
+	c.Assert(paths, DeepEquals, []string{"foo.o", "go/bar.o"})
+
+	paths, err = w.CleanPaths(&CleanOptions{Dir: true, OnlyIgnored: true, DryRun: true})
+	c.Assert(err, IsNil)
+	c.Assert(paths, DeepEquals, []string{"build/", "untracked/qux.o"})
+}
+
+func (s *WorktreeSuite) TestCleanExcludesAndPaths(c *C) {
+	w, _ := s.newCleanWorktree(c)
+
+	paths, err := w.CleanPaths(&CleanOptions{
+		Dir:      true,
+		Ignored:  true,
+		Excludes: []gitignore.Pattern{gitignore.ParsePattern("foo*", nil)},
+		DryRun:   true,
+	})
+	c.Assert(err, IsNil)
+	c.Assert(paths, DeepEquals, []string{"build/", "empty/", "go/bar", "go/bar.o", "untracked/"})
+
+	paths, err = w.CleanPaths(&CleanOptions{
+		Dir:     true,
+		Ignored: true,
+		Paths:   []string{"*.o", "build"},
+		DryRun:  true,
+	})
+	c.Assert(err, IsNil)
+	c.Assert(paths, DeepEquals, []string{"build/", "foo.o", "go/bar.o", "untracked/qux.o"})
+}
+
+func (s *WorktreeSuite) TestCleanIgnoredOnlyIgnoredExclusive(c *C) {
+	w, _ := s.newCleanWorktree(c)
+
+	err := w.Clean(&CleanOptions{Ignored: true, OnlyIgnored: true})
+	c.Assert(err, Equals, ErrIgnoredOnlyIgnoredExclusive)
+}
+
+func (s *WorktreeSuite) newAddWorktree(c *C) (*Worktree, billy.Filesystem) {
+	w, fs := s.newWorktree(c)
+	for name, content := range map[string]string{
+		".gitignore":   "*.o\n",
+		"LICENSE":      "foo",
+		"foo":          "foo",
+		"foo.o":        "foo",
+		"go/bar":       "bar",
+		"go/bar.o":     "bar",
+		"json/qux.txt": "qux",
+	} {
+		c.Assert(util.WriteFile(fs, name, []byte(content), 0644), IsNil)
+	}
+
+	c.Assert(fs.Remove("CHANGELOG"), IsNil)
+	return w, fs
+}
+
+func (s *WorktreeSuite) TestAddWithOptionsAll(c *C) {
+	w, _ := s.newAddWorktree(c)
+
+	err := w.AddWithOptions(&AddOptions{All: true})
+	c.Assert(err, IsNil)
+
+	status, err := w.Status()
+	c.Assert(err, IsNil)
+	c.Assert(status, HasLen, 6)
+	c.Assert(status.File(".gitignore").Staging, Equals, Modified)
+	c.Assert(status.File("LICENSE").Staging, Equals, Modified)
+	c.Assert(status.File("CHANGELOG").Staging, Equals, Deleted)
+	c.Assert(status.File("foo").Staging, Equals, Added)
+	c.Assert(status.File("go/bar").Staging, Equals, Added)
+	c.Assert(status.File("json/qux.txt").Staging, Equals, Added)
+}
+
+func (s *WorktreeSuite) TestAddWithOptionsAllPaths(c *C) {
+	w, _ := s.newAddWorktree(c)
+
+	err := w.AddWithOptions(&AddOptions{All: true, Paths: []string{"go", "CHANGELOG"}})
+	c.Assert(err, IsNil)
+
+	status, err := w.Status()
+	c.Assert(err, IsNil)
+	c.Assert(status.File("CHANGELOG").Staging, Equals, Deleted)
+	c.Assert(status.File("go/bar").Staging, Equals, Added)
+	c.Assert(status.File("foo").Staging, Equals, Untracked)
+	c.Assert(status.File("LICENSE").Staging, Equals, Unmodified)
+}
+
+func (s *WorktreeSuite) TestAddWithOptionsUpdate(c *C) {
+	w, _ := s.newAddWorktree(c)
+
+	err := w.AddWithOptions(&AddOptions{Update: true})
+	c.Assert(err, IsNil)
+
+	status, err := w.Status()
+	c.Assert(err, IsNil)
+	c.Assert(status.File("LICENSE").Staging, Equals, Modified)
+	c.Assert(status.File("CHANGELOG").Staging, Equals, Deleted)
+	c.Assert(status.File("foo").Staging, Equals, Untracked)
+	c.Assert(status.File("go/bar").Staging, Equals, Untracked)
+}
+
+func (s *WorktreeSuite) TestAddWithOptionsPaths(c *C) {
+	w, _ := s.newAddWorktree(c)
+
+	err := w.AddWithOptions(&AddOptions{Paths: []string{"*.txt", "foo"}})
+	c.Assert(err, IsNil)
+
+	status, err := w.Status()
+	c.Assert(err, IsNil)
+	c.Assert(status.File("foo").Staging, Equals, Added)
+	c.Assert(status.File("json/qux.txt").Staging, Equals, Added)
+	c.Assert(status.File("go/bar").Staging, Equals, Untracked)
+
+	idx, err := w.r.Storer.Index()
+	c.Assert(err, IsNil)
+	_, err = idx.Entry("foo.o")
+	c.Assert(err, Equals, index.ErrEntryNotFound)
+}
+
+func (s *WorktreeSuite) TestAddWithOptionsPathsNotMatched(c *C) {
+	w, _ := s.newAddWorktree(c)
+
+	err := w.AddWithOptions(&AddOptions{Paths: []string{"foo", "missing"}})
+	c.Assert(err, Equals, ErrPathspecNotMatched)
+
+	// the unmodified and deleted files are matched
+	err = w.AddWithOptions(&AddOptions{Paths: []string{"binary.jpg", "CHANGELOG"}, All: true})
+	c.Assert(err, IsNil)
+
+	status, err := w.Status()
+	c.Assert(err, IsNil)
+	c.Assert(status.File("foo").Staging, Equals, Untracked)
+	c.Assert(status.File("CHANGELOG").Staging, Equals, Deleted)
+
+	err = w.AddWithOptions(&AddOptions{Paths: []string{"foo", "missing"}, IgnoreMissing: true})
+	c.Assert(err, IsNil)
+
+	status, err = w.Status()
+	c.Assert(err, IsNil)
+	c.Assert(status.File("foo").Staging, Equals, Added)
+}
+
+func (s *WorktreeSuite) TestAddWithOptionsForce(c *C) {
+	w, _ := s.newAddWorktree(c)
+
+	err := w.AddWithOptions(&AddOptions{Paths: []string{"*.o", "go"}, Force: true})
+	c.Assert(err, IsNil)
+
+	status, err := w.Status()
+	c.Assert(err, IsNil)
+	c.Assert(status.File("foo.o").Staging, Equals, Added)
+	c.Assert(status.File("go/bar.o").Staging, Equals, Added)
+	c.Assert(status.File("go/bar").Staging, Equals, Added)
+}
+
+func (s *WorktreeSuite) TestAddWithOptionsIntentToAdd(c *C) {
+	w, _ := s.newAddWorktree(c)
+
+	err := w.AddWithOptions(&AddOptions{Paths: []string{"foo", "LICENSE"}, IntentToAdd: true})
+	c.Assert(err, IsNil)
+
+	idx, err := w.r.Storer.Index()
+	c.Assert(err, IsNil)
+	c.Assert(idx.Version, Equals, uint32(3))
+
+	e, err := idx.Entry("foo")
+	c.Assert(err, IsNil)
+	c.Assert(e.IntentToAdd, Equals, true)
+	c.Assert(e.Hash, Equals, plumbing.NewHash("e69de29bb2d1d6434b8b29ae775ad8c2e48c5391"))
+
+	status, err := w.Status()
+	c.Assert(err, IsNil)
+	c.Assert(status.File("foo").Staging, Equals, Unmodified)
+	c.Assert(status.File("foo").Worktree, Equals, Added)
+	c.Assert(status.File("LICENSE").Staging, Equals, Modified)
+
+	// the files added with intent to add are not committed
+	hash, err := w.Commit("foo\n", &CommitOptions{Author: defaultSignature()})
+	c.Assert(err, IsNil)
+
+	commit, err := w.r.CommitObject(hash)
+	c.Assert(err, IsNil)
+	_, err = commit.File("foo")
+	c.Assert(err, Equals, object.ErrFileNotFound)
+
+	_, err = w.Add("foo")
+	c.Assert(err, IsNil)
+
+	status, err = w.Status()
+	c.Assert(err, IsNil)
+	c.Assert(status.File("foo").Staging, Equals, Added)
+	c.Assert(status.File("foo").Worktree, Equals, Unmodified)
+}
+
+func (s *WorktreeSuite) TestAddWithOptionsValidate(c *C) {
+	w, _ := s.newAddWorktree(c)
+
+	err := w.AddWithOptions(&AddOptions{})
+	c.Assert(err, Equals, ErrMissingAddPaths)
+
+	err = w.AddWithOptions(&AddOptions{All: true, Update: true})
+	c.Assert(err, Equals, ErrAllUpdateExclusive)
+}
@@ -0,0 +1,621 @@
+package git
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/format/gitignore"
+	"gopkg.in/src-d/go-git.v4/plumbing/format/index"
+	"gopkg.in/src-d/go-git.v4/utils/merkletrie"
+)
+
+const (
+	gitignoreFile = ".gitignore"
+
+	// untrackedCacheDirFlags are the flags used by git status to read the
+	// directories, DIR_SHOW_OTHER_DIRECTORIES and DIR_HIDE_EMPTY_DIRECTORIES.
+	untrackedCacheDirFlags = 1<<1 | 1<<2
+)
+
+// untrackedCache lists the directories of the worktree using the untracked
+// cache of the index, as git does: a directory whose stat information and
+// .gitignore files did not change is listed from the index entries and its
+// cached untracked files instead of being read, the rest of directories are
+// read and cached. If the worktree has a file system monitor, only the paths
+// it reports are checked, the rest are taken from the index and the cache.
+type untrackedCache struct {
+	w     *Worktree
+	idx   *index.Index
+	uc    *index.UntrackedCache
+	stat  *statCache
+	token string
+
+	// children are the names of the tracked files and directories of each
+	// directory with tracked files.
+	children map[string]map[string]bool
+	// monitored is set if the changes since the last status were reported
+	// by the file system monitor, reported are the paths reported and their
+	// parent directories.
+	monitored bool
+	reported  map[string]bool
+
+	excludes map[string]*dirExcludes
+	changed  bool
+}
+
+// dirExcludes is the per-directory exclude file of a directory.
+type dirExcludes struct {
+	hash    plumbing.Hash
+	content []byte
+	read    bool
+	// changed is set if the file, or the one of any parent, changed since
+	// the directory was cached.
+	changed  bool
+	patterns []gitignore.Pattern
+	matcher  gitignore.Matcher
+}
+
+// newUntrackedCache returns the untrackedCache of the index idx, or nil if it
+// is not used: core.untrackedCache is "false", or it is not "true" and the
+// index has no untracked cache and the worktree no file system monitor. It is
+// neither used if the worktree has external excludes, not recorded by the
+// cache, or if the storage does not provide the time the index was written,
+// required to trust the cache.
+func (w *Worktree) newUntrackedCache(idx *index.Index, stat *statCache) (*untrackedCache, error) {
+	if len(w.Excludes) != 0 || idx.ModTime.IsZero() {
+		return nil, nil
+	}
+
+	cfg, err := w.r.Config()
+	if err != nil {
+		return nil, err
+	}
+
+	mode := cfg.Core.UntrackedCache
+	if mode == "false" || (mode != "true" && idx.UntrackedCache == nil && w.FSMonitor == nil) {
+		return nil, nil
+	}
+
+	c := &untrackedCache{
+		w:        w,
+		idx:      idx,
+		uc:       idx.UntrackedCache,
+		stat:     stat,
+		children: make(map[string]map[string]bool),
+		excludes: make(map[string]*dirExcludes),
+	}
+
+	// the caches read with other excludes, e.g. the ones of git including
+	// $GIT_DIR/info/exclude, are replaced
+	ident := untrackedCacheIdent(w.Filesystem.Root())
+	if c.uc == nil || c.uc.Ident != ident ||
+		c.uc.DirFlags != untrackedCacheDirFlags ||
+		c.uc.ExcludePerDir != gitignoreFile ||
+		!c.uc.InfoExclude.Hash.IsZero() ||
+		!c.uc.ExcludesFile.Hash.IsZero() {
+		c.uc = &index.UntrackedCache{
+			Ident:         ident,
+			DirFlags:      untrackedCacheDirFlags,
+			ExcludePerDir: gitignoreFile,
+		}
+
+		c.changed = true
+	}
+
+	for _, e := range idx.Entries {
+		name := e.Name
+		for name != "" {
+			dir := parentDir(name)
+			if c.children[dir] == nil {
+				c.children[dir] = make(map[string]bool)
+			}
+
+			c.children[dir][path.Base(name)] = true
+			name = dir
+		}
+	}
+
+	return c, c.requestChanges()
+}
+
+// untrackedCacheIdent returns the environment where the cache can be used,
+// as described by git.
+func untrackedCacheIdent(root string) string {
+	system := map[string]string{
+		"darwin":  "Darwin",
+		"freebsd": "FreeBSD",
+		"linux":   "Linux",
+		"windows": "Windows",
+	}[runtime.GOOS]
+
+	if system == "" {
+		system = runtime.GOOS
+	}
+
+	return fmt.Sprintf("Location %s, system %s\x00", root, system)
+}
+
+// requestChanges requests to the file system monitor of the worktree, if
+// any, the changes since the last status. It is called before the worktree
+// is read, so the changes made while reading are reported the next time.
+func (c *untrackedCache) requestChanges() error {
+	if c.w.FSMonitor == nil {
+		return nil
+	}
+
+	var token string
+	if c.idx.FSMonitor != nil {
+		token = c.idx.FSMonitor.Token
+	}
+
+	changes, err := c.w.FSMonitor.Changes(token)
+	if err != nil {
+		return err
+	}
+
+	c.token = changes.Token
+	c.monitored = token != "" && !changes.All
+	c.reported = make(map[string]bool)
+	for _, p := range changes.Paths {
+		c.reported[p] = true
+		c.reported[parentDir(p)] = true
+	}
+
+	return nil
+}
+
+// ReadDir returns the files of the directory dir, without the ignored
+// untracked files. It is used as filesystem.Options.ReadDir.
+func (c *untrackedCache) ReadDir(dir string) ([]os.FileInfo, error) {
+	d := c.dir(dir)
+	ex, err := c.dirExcludes(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	if d.Valid && !ex.changed {
+		unchanged, err := c.isUnchanged(dir, d)
+		if err != nil {
+			return nil, err
+		}
+
+		if unchanged {
+			return c.listDir(dir, d)
+		}
+	}
+
+	return c.readDir(dir, d, ex)
+}
+
+// dir returns the cached directory dir, it is added if not found.
+func (c *untrackedCache) dir(dir string) *index.UntrackedCacheDir {
+	if c.uc.Root == nil {
+		c.uc.Root = &index.UntrackedCacheDir{}
+		c.changed = true
+	}
+
+	d := c.uc.Root
+	if dir == "" {
+		return d
+	}
+
+	for _, name := range strings.Split(dir, "/") {
+		sub := d.Dir(name)
+		if sub == nil {
+			sub = &index.UntrackedCacheDir{Name: name}
+			d.Dirs = append(d.Dirs, sub)
+			c.changed = true
+		}
+
+		d = sub
+	}
+
+	return d
+}
+
+// lookupDir returns the cached directory dir, nil if not found.
+func (c *untrackedCache) lookupDir(dir string) *index.UntrackedCacheDir {
+	d := c.uc.Root
+	if dir == "" || d == nil {
+		return d
+	}
+
+	for _, name := range strings.Split(dir, "/") {
+		if d = d.Dir(name); d == nil {
+			return nil
+		}
+	}
+
+	return d
+}
+
+// dirExcludes returns the exclude file of the directory dir. If the
+// directory is cached and the file system monitor did not report the file,
+// the cached hash is trusted and the file is not read until the patterns are
+// needed.
+func (c *untrackedCache) dirExcludes(dir string) (*dirExcludes, error) {
+	if ex, ok := c.excludes[dir]; ok {
+		return ex, nil
+	}
+
+	d := c.lookupDir(dir)
+	if d == nil {
+		d = &index.UntrackedCacheDir{}
+	}
+
+	name := path.Join(dir, gitignoreFile)
+
+	ex := &dirExcludes{hash: d.ExcludeHash}
+	if !d.Valid || !c.monitored || c.reported[name] {
+		if err := c.readExcludes(ex, name); err != nil {
+			return nil, err
+		}
+	}
+
+	ex.changed = ex.hash != d.ExcludeHash
+	if dir != "" {
+		parent, err := c.dirExcludes(parentDir(dir))
+		if err != nil {
+			return nil, err
+		}
+
+		ex.changed = ex.changed || parent.changed
+	}
+
+	c.excludes[dir] = ex
+	return ex, nil
+}
+
+func (c *untrackedCache) readExcludes(ex *dirExcludes, name string) error {
+	f, err := c.w.Filesystem.Open(name)
+	if os.IsNotExist(err) {
+		ex.hash, ex.content, ex.read = plumbing.ZeroHash, nil, true
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	defer f.Close()
+
+	content, err := ioutil.ReadAll(f)
+	if err != nil {
+		return err
+	}
+
+	ex.hash = plumbing.ComputeHash(plumbing.BlobObject, content)
+	ex.content, ex.read = content, true
+	return nil
+}
+
+// patterns returns the patterns applying to the directory dir, the ones of
+// its exclude file and the ones of its parents.
+func (c *untrackedCache) patterns(dir string) ([]gitignore.Pattern, error) {
+	ex, err := c.dirExcludes(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	if ex.patterns != nil {
+		return ex.patterns, nil
+	}
+
+	if !ex.read {
+		if err := c.readExcludes(ex, path.Join(dir, gitignoreFile)); err != nil {
+			return nil, err
+		}
+	}
+
+	var domain []string
+	if dir != "" {
+		domain = strings.Split(dir, "/")
+
+		parent, err := c.patterns(parentDir(dir))
+		if err != nil {
+			return nil, err
+		}
+
+		ex.patterns = append(ex.patterns, parent...)
+	}
+
+	for _, s := range strings.Split(string(ex.content), "\n") {
+		if !strings.HasPrefix(s, "#") && len(strings.TrimSpace(s)) > 0 {
+			ex.patterns = append(ex.patterns, gitignore.ParsePattern(s, domain))
+		}
+	}
+
+	if ex.patterns == nil {
+		ex.patterns = []gitignore.Pattern{}
+	}
+
+	return ex.patterns, nil
+}
+
+// Match returns true if the file at path is ignored, it is an implementation
+// of gitignore.Matcher reading only the exclude files of the directories of
+// the path. Errors reading them are ignored, as if they were missing.
+func (c *untrackedCache) Match(path []string, isDir bool) bool {
+	dir := strings.Join(path[:len(path)-1], "/")
+	m, err := c.matcher(dir)
+	if err != nil {
+		return false
+	}
+
+	return m.Match(path, isDir)
+}
+
+func (c *untrackedCache) matcher(dir string) (gitignore.Matcher, error) {
+	ps, err := c.patterns(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	ex := c.excludes[dir]
+	if ex.matcher == nil {
+		ex.matcher = gitignore.NewMatcher(ps)
+	}
+
+	return ex.matcher, nil
+}
+
+// isUnchanged returns true if the cached directory d was not modified, its
+// stat information matches the cached one and it is not racily clean, or
+// it was not reported by the file system monitor.
+func (c *untrackedCache) isUnchanged(dir string, d *index.UntrackedCacheDir) (bool, error) {
+	if c.monitored && !c.reported[dir] {
+		return true, nil
+	}
+
+	fi, err := c.w.Filesystem.Lstat(dir)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, err
+	}
+
+	if !d.Stat.ModifiedAt.Before(c.idx.ModTime.Truncate(time.Second)) {
+		return false, nil
+	}
+
+	return statDataEquals(d.Stat, newStatData(fi)), nil
+}
+
+// listDir lists the cached directory d, with the tracked files, the cached
+// untracked files and the subdirectories. The tracked files not reported by
+// the file system monitor are listed from their index entries.
+func (c *untrackedCache) listDir(dir string, d *index.UntrackedCacheDir) ([]os.FileInfo, error) {
+	names := make(map[string]bool)
+	for name := range c.children[dir] {
+		names[name] = true
+	}
+
+	for _, name := range d.Untracked {
+		names[strings.TrimSuffix(name, "/")] = true
+	}
+
+	for _, sub := range d.Dirs {
+		names[sub.Name] = true
+	}
+
+	var files []os.FileInfo
+	for name := range names {
+		p := path.Join(dir, name)
+		if e, ok := c.stat.entries[p]; ok && c.isMonitoredValid(e) {
+			files = append(files, &entryFileInfo{name: name, e: e})
+			continue
+		}
+
+		fi, err := c.w.Filesystem.Lstat(p)
+		if os.IsNotExist(err) {
+			continue
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		files = append(files, fi)
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Name() < files[j].Name()
+	})
+
+	return files, nil
+}
+
+// isMonitoredValid returns true if the file of the index entry e did not
+// change since the last status, according to the file system monitor.
+func (c *untrackedCache) isMonitoredValid(e *index.Entry) bool {
+	return c.monitored && e.FSMonitorValid && !c.reported[e.Name] &&
+		!e.IntentToAdd && !e.SkipWorktree && e.Mode.IsFile()
+}
+
+// readDir reads the directory dir and caches its untracked files.
+func (c *untrackedCache) readDir(dir string, d *index.UntrackedCacheDir, ex *dirExcludes) ([]os.FileInfo, error) {
+	fi, err := c.w.Filesystem.Lstat(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := c.w.Filesystem.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	if !ex.read {
+		if err := c.readExcludes(ex, path.Join(dir, gitignoreFile)); err != nil {
+			return nil, err
+		}
+	}
+
+	m, err := c.matcher(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var listed []os.FileInfo
+	var untracked []string
+	dirs := make(map[string]bool)
+	for _, f := range files {
+		name := f.Name()
+		if name == GitDirName {
+			continue
+		}
+
+		p := path.Join(dir, name)
+		if !c.children[dir][name] {
+			if m.Match(strings.Split(p, "/"), f.IsDir()) {
+				continue
+			}
+
+			if !f.IsDir() {
+				untracked = append(untracked, name)
+			}
+		}
+
+		if f.IsDir() {
+			dirs[name] = true
+		}
+
+		listed = append(listed, f)
+	}
+
+	var subs []*index.UntrackedCacheDir
+	for _, sub := range d.Dirs {
+		if dirs[sub.Name] {
+			subs = append(subs, sub)
+		}
+	}
+
+	d.Dirs = subs
+	d.Untracked = untracked
+	d.Valid = true
+	d.Stat = newStatData(fi)
+	d.ExcludeHash = ex.hash
+	c.changed = true
+
+	return listed, nil
+}
+
+// Update updates the untracked cache and the file system monitor extension
+// of the index after the status, with the changes found. Returns true if the
+// index changed.
+func (c *untrackedCache) Update(changes merkletrie.Changes) bool {
+	if c.changed {
+		c.updateUntrackedDirs(c.uc.Root, "")
+	}
+
+	c.idx.UntrackedCache = c.uc
+	if c.w.FSMonitor == nil {
+		return c.changed
+	}
+
+	changed := make(map[string]bool, len(changes))
+	for _, ch := range changes {
+		changed[nameFromAction(&ch)] = true
+	}
+
+	// the files hashed because of a stat mismatch are checked again, even if
+	// unchanged, e.g. because they are ignored
+	for _, e := range c.idx.Entries {
+		_, stale := c.stat.stale[e.Name]
+		e.FSMonitorValid = !changed[e.Name] && !stale
+	}
+
+	c.idx.FSMonitor = &index.FSMonitor{Token: c.token}
+	return true
+}
+
+// updateUntrackedDirs adds to the untracked files of the directory d, at
+// dir, the untracked subdirectories with untracked files, ending with a
+// slash, and flags them as check-only, as git does.
+func (c *untrackedCache) updateUntrackedDirs(d *index.UntrackedCacheDir, dir string) {
+	if d == nil || !d.Valid {
+		return
+	}
+
+	var untracked []string
+	for _, name := range d.Untracked {
+		if !strings.HasSuffix(name, "/") {
+			untracked = append(untracked, name)
+		}
+	}
+
+	for _, sub := range d.Dirs {
+		c.updateUntrackedDirs(sub, path.Join(dir, sub.Name))
+
+		sub.CheckOnly = !c.children[dir][sub.Name]
+		if sub.CheckOnly && sub.Valid && len(sub.Untracked) != 0 {
+			untracked = append(untracked, sub.Name+"/")
+		}
+	}
+
+	sort.Strings(untracked)
+	d.Untracked = untracked
+}
+
+// parentDir returns the parent directory of the path p, empty for the root.
+func parentDir(p string) string {
+	dir := path.Dir(p)
+	if dir == "." {
+		return ""
+	}
+
+	return dir
+}
+
+// newStatData returns the stat information of fi.
+func newStatData(fi os.FileInfo) index.StatData {
+	e := &index.Entry{}
+	if fillSystemInfo != nil {
+		fillSystemInfo(e, fi.Sys())
+	}
+
+	return index.StatData{
+		CreatedAt:  e.CreatedAt,
+		ModifiedAt: fi.ModTime(),
+		Dev:        e.Dev,
+		Inode:      e.Inode,
+		UID:        e.UID,
+		GID:        e.GID,
+		Size:       uint32(fi.Size()),
+	}
+}
+
+// statDataEquals compares the stat information as statMatches does.
+func statDataEquals(a, b index.StatData) bool {
+	return a.ModifiedAt.Equal(b.ModifiedAt) &&
+		a.CreatedAt.Equal(b.CreatedAt) &&
+		a.Inode == b.Inode &&
+		a.UID == b.UID &&
+		a.GID == b.GID &&
+		a.Size == b.Size
+}
+
+// entryFileInfo is the os.FileInfo of a file as recorded by its index entry.
+type entryFileInfo struct {
+	name string
+	e    *index.Entry
+}
+
+func (fi *entryFileInfo) Name() string       { return fi.name }
+func (fi *entryFileInfo) Size() int64        { return int64(fi.e.Size) }
+func (fi *entryFileInfo) ModTime() time.Time { return fi.e.ModifiedAt }
+func (fi *entryFileInfo) IsDir() bool        { return false }
+func (fi *entryFileInfo) Sys() interface{}   { return nil }
+
+func (fi *entryFileInfo) Mode() os.FileMode {
+	mode, _ := fi.e.Mode.ToOSFileMode()
+	return mode
+}
@@ -0,0 +1,213 @@
+package git
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"gopkg.in/src-d/go-git.v4/utils/fsmonitor"
+
+	. "gopkg.in/check.v1"
+)
+
+// untrackedMTime is the modification time of the files and directories
+// written by the untracked cache tests, in the past so they are not racily
+// clean.
+var untrackedMTime = time.Now().Add(-time.Hour).Truncate(time.Second)
+
+// addUntrackedFiles enables the untracked cache and writes the untracked
+// files bar, baz, ignored by the .gitignore of the tests, and qux/quux.
+func (s *WorktreeSuite) addUntrackedFiles(c *C, w *Worktree, dir string) {
+	cfg, err := w.r.Config()
+	c.Assert(err, IsNil)
+	cfg.Core.UntrackedCache = "true"
+	c.Assert(w.r.Storer.SetConfig(cfg), IsNil)
+
+	s.writeUntrackedFile(c, dir, "bar", "bar\n")
+	s.writeUntrackedFile(c, dir, "baz", "baz\n")
+	s.writeUntrackedFile(c, dir, "qux/quux", "quux\n")
+	s.touch(c, dir, "", "qux")
+}
+
+func (s *WorktreeSuite) writeUntrackedFile(c *C, dir, name, content string) {
+	name = filepath.Join(dir, name)
+	c.Assert(os.MkdirAll(filepath.Dir(name), 0755), IsNil)
+	c.Assert(ioutil.WriteFile(name, []byte(content), 0644), IsNil)
+	c.Assert(os.Chtimes(name, untrackedMTime, untrackedMTime), IsNil)
+}
+
+// touch sets the modification time of the given directories to the past,
+// so the files created in them are not detected by their stat information.
+func (s *WorktreeSuite) touch(c *C, dir string, names ...string) {
+	for _, name := range names {
+		name = filepath.Join(dir, name)
+		c.Assert(os.Chtimes(name, untrackedMTime, untrackedMTime), IsNil)
+	}
+}
+
+func (s *WorktreeSuite) untracked(c *C, w *Worktree) []string {
+	status, err := w.Status()
+	c.Assert(err, IsNil)
+
+	var untracked []string
+	for name, fs := range status {
+		if fs.Worktree == Untracked {
+			untracked = append(untracked, name)
+		}
+	}
+
+	sort.Strings(untracked)
+	return untracked
+}
+
+func (s *WorktreeSuite) TestStatusUntrackedCache(c *C) {
+	w, dir := s.newPlainInitWorktree(c, map[string]string{"foo": "foo\n", ".gitignore": "baz\n"}, untrackedMTime)
+	defer os.RemoveAll(dir)
+	s.addUntrackedFiles(c, w, dir)
+
+	c.Assert(s.untracked(c, w), DeepEquals, []string{"bar", "qux/quux"})
+
+	idx, err := w.r.Storer.Index()
+	c.Assert(err, IsNil)
+	c.Assert(idx.UntrackedCache, NotNil)
+
+	root := idx.UntrackedCache.Root
+	c.Assert(root.Valid, Equals, true)
+	c.Assert(root.Untracked, DeepEquals, []string{"bar", "qux/"})
+	c.Assert(root.ExcludeHash.IsZero(), Equals, false)
+	c.Assert(root.Dirs, HasLen, 1)
+	c.Assert(root.Dirs[0].Name, Equals, "qux")
+	c.Assert(root.Dirs[0].Untracked, DeepEquals, []string{"quux"})
+	c.Assert(root.Dirs[0].CheckOnly, Equals, true)
+
+	// the directories not modified are listed from the cache, so the
+	// untracked files removed from the cache are not found
+	root.Untracked = []string{"qux/"}
+	root.Dirs[0].Untracked = nil
+	c.Assert(w.r.Storer.SetIndex(idx), IsNil)
+	c.Assert(s.untracked(c, w), HasLen, 0)
+
+	// the directory modified is read again
+	s.writeUntrackedFile(c, dir, "qux/new", "new\n")
+	c.Assert(s.untracked(c, w), DeepEquals, []string{"qux/new", "qux/quux"})
+}
+
+func (s *WorktreeSuite) TestStatusUntrackedCacheModified(c *C) {
+	w, dir := s.newPlainInitWorktree(c, map[string]string{"foo": "foo\n", ".gitignore": "baz\n"}, untrackedMTime)
+	defer os.RemoveAll(dir)
+	s.addUntrackedFiles(c, w, dir)
+
+	c.Assert(s.untracked(c, w), DeepEquals, []string{"bar", "qux/quux"})
+
+	s.writeUntrackedFile(c, dir, "foo", "FOO\n")
+	status, err := w.Status()
+	c.Assert(err, IsNil)
+	c.Assert(status.File("foo").Worktree, Equals, Modified)
+}
+
+func (s *WorktreeSuite) TestStatusUntrackedCacheRemovedFromIndex(c *C) {
+	w, dir := s.newPlainInitWorktree(c, map[string]string{"foo": "foo\n", ".gitignore": "baz\n"}, untrackedMTime)
+	defer os.RemoveAll(dir)
+	s.addUntrackedFiles(c, w, dir)
+
+	c.Assert(s.untracked(c, w), DeepEquals, []string{"bar", "qux/quux"})
+
+	idx, err := w.r.Storer.Index()
+	c.Assert(err, IsNil)
+	_, err = idx.Remove("foo")
+	c.Assert(err, IsNil)
+	c.Assert(w.r.Storer.SetIndex(idx), IsNil)
+
+	c.Assert(s.untracked(c, w), DeepEquals, []string{"bar", "foo", "qux/quux"})
+}
+
+func (s *WorktreeSuite) TestStatusUntrackedCacheExcludesChanged(c *C) {
+	w, dir := s.newPlainInitWorktree(c, map[string]string{"foo": "foo\n", ".gitignore": "baz\n"}, untrackedMTime)
+	defer os.RemoveAll(dir)
+	s.addUntrackedFiles(c, w, dir)
+
+	c.Assert(s.untracked(c, w), DeepEquals, []string{"bar", "qux/quux"})
+
+	s.writeUntrackedFile(c, dir, ".gitignore", "baz\nquux\n")
+	s.touch(c, dir, "")
+	c.Assert(s.untracked(c, w), DeepEquals, []string{"bar"})
+}
+
+func (s *WorktreeSuite) TestStatusUntrackedCacheDisabled(c *C) {
+	w, dir := s.newPlainInitWorktree(c, map[string]string{"foo": "foo\n", ".gitignore": "baz\n"}, untrackedMTime)
+	defer os.RemoveAll(dir)
+	s.addUntrackedFiles(c, w, dir)
+
+	cfg, err := w.r.Config()
+	c.Assert(err, IsNil)
+	cfg.Core.UntrackedCache = "false"
+	c.Assert(w.r.Storer.SetConfig(cfg), IsNil)
+
+	c.Assert(s.untracked(c, w), DeepEquals, []string{"bar", "qux/quux"})
+
+	s.writeUntrackedFile(c, dir, "new", "new\n")
+	c.Assert(s.untracked(c, w), DeepEquals, []string{"bar", "new", "qux/quux"})
+}
+
+// fakeMonitor reports the paths set, since the last token.
+type fakeMonitor struct {
+	n     int
+	paths []string
+}
+
+func (m *fakeMonitor) Changes(token string) (*fsmonitor.Changes, error) {
+	m.n++
+	c := &fsmonitor.Changes{
+		Token: fmt.Sprintf("fake:%d", m.n),
+		All:   token == "",
+	}
+
+	if !c.All {
+		c.Paths, m.paths = m.paths, nil
+	}
+
+	return c, nil
+}
+
+func (s *WorktreeSuite) TestStatusFSMonitor(c *C) {
+	w, dir := s.newPlainInitWorktree(c, map[string]string{"foo": "foo\n", ".gitignore": "baz\n"}, untrackedMTime)
+	defer os.RemoveAll(dir)
+	s.addUntrackedFiles(c, w, dir)
+
+	m := &fakeMonitor{}
+	w.FSMonitor = m
+
+	c.Assert(s.untracked(c, w), DeepEquals, []string{"bar", "qux/quux"})
+
+	idx, err := w.r.Storer.Index()
+	c.Assert(err, IsNil)
+	c.Assert(idx.FSMonitor.Token, Equals, "fake:1")
+	for _, e := range idx.Entries {
+		c.Assert(e.FSMonitorValid, Equals, true)
+	}
+
+	// the changes not reported are not detected
+	s.writeUntrackedFile(c, dir, "foo", "FOO\n")
+	c.Assert(os.Chtimes(dir, time.Now(), time.Now()), IsNil)
+	s.writeUntrackedFile(c, dir, "new", "new\n")
+
+	status, err := w.Status()
+	c.Assert(err, IsNil)
+	c.Assert(status.File("foo").Worktree, Equals, Unmodified)
+	c.Assert(s.untracked(c, w), DeepEquals, []string{"bar", "qux/quux"})
+
+	m.paths = []string{"foo", "new"}
+	status, err = w.Status()
+	c.Assert(err, IsNil)
+	c.Assert(status.File("foo").Worktree, Equals, Modified)
+	c.Assert(status.File("new").Worktree, Equals, Untracked)
+
+	idx, err = w.r.Storer.Index()
+	c.Assert(err, IsNil)
+	e, err := idx.Entry("foo")
+	c.Assert(err, IsNil)
+	c.Assert(e.FSMonitorValid, Equals, false)
+}